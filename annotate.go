@@ -0,0 +1,203 @@
+package sift
+
+import (
+	"fmt"
+	"time"
+)
+
+// Annotations describe where a value came from. Decoders may attach them to
+// the values they produce with Annotate, so errors and lineage reports can
+// refer to the input precisely. Zero fields are unknown.
+type Annotations struct {
+	// Source names the input the value was read from, like a file name.
+	Source string
+
+	// Offset is the byte offset of the start of the value in the input.
+	Offset int64
+
+	// Line and Column are the 1-based line and column of the start of
+	// the value in the input.
+	Line, Column int
+
+	// Schema identifies a schema the value conforms to, like the URI of
+	// a JSON Schema or the name of a database table.
+	Schema string
+}
+
+// String formats the position described by a, like "file.json:3:7" or
+// "file.json@120" if only the byte offset is known.
+func (a Annotations) String() string {
+	s := a.Source
+	if s == "" {
+		s = "-"
+	}
+	if a.Line > 0 {
+		s = fmt.Sprintf("%s:%d", s, a.Line)
+		if a.Column > 0 {
+			s = fmt.Sprintf("%s:%d", s, a.Column)
+		}
+	} else if a.Offset > 0 {
+		s = fmt.Sprintf("%s@%d", s, a.Offset)
+	}
+	return s
+}
+
+// Annotated is implemented by values that carry Annotations. An annotated
+// value behaves like the value it wraps: it implements the same scalar
+// interfaces, and Attr or Index if the wrapped value does, so code that
+// isn't aware of annotations treats it the same way.
+type Annotated interface {
+	Value
+
+	// Annotations returns the value's annotations.
+	Annotations() Annotations
+
+	// Unannotated returns the wrapped value, without annotations.
+	Unannotated() Value
+}
+
+// Annotate returns v with annotations a. If v is already annotated, its
+// annotations are replaced.
+func Annotate(v Value, a Annotations) Value {
+	v = Strip(v)
+	base := annotated{v: v, a: a}
+	switch v.(type) {
+	case Attr:
+		return &annotatedAttr{base}
+	case Index:
+		return &annotatedIndex{base}
+	default:
+		return &base
+	}
+}
+
+// AnnotationsOf returns the annotations of v and true if v is annotated.
+func AnnotationsOf(v Value) (Annotations, bool) {
+	if a, ok := v.(Annotated); ok {
+		return a.Annotations(), true
+	}
+	return Annotations{}, false
+}
+
+// Strip returns v without annotations. Values nested in v are not
+// changed.
+func Strip(v Value) Value {
+	for {
+		a, ok := v.(Annotated)
+		if !ok {
+			return v
+		}
+		v = a.Unannotated()
+	}
+}
+
+// PropagateAnnotations returns a filter that applies f and annotates its
+// outputs with the annotations of its input, so values derived from an
+// annotated input may be traced back to it. Outputs that are already
+// annotated keep their own annotations.
+func PropagateAnnotations(f Filter) Filter {
+	return func(v Value) ([]Value, error) {
+		vs, err := f(v)
+		a, ok := AnnotationsOf(v)
+		if err != nil || !ok {
+			return vs, err
+		}
+		for i, out := range vs {
+			if _, ok := out.(Annotated); !ok {
+				vs[i] = Annotate(out, a)
+			}
+		}
+		return vs, nil
+	}
+}
+
+// annotated wraps a scalar value. It forwards each scalar interface to the
+// wrapped value; the Is methods report whether the wrapped value
+// implements the interface.
+type annotated struct {
+	v Value
+	a Annotations
+}
+
+var (
+	_ Null      = (*annotated)(nil)
+	_ Bool      = (*annotated)(nil)
+	_ Float64   = (*annotated)(nil)
+	_ String    = (*annotated)(nil)
+	_ BigNumber = (*annotated)(nil)
+	_ Bytes     = (*annotated)(nil)
+	_ Time      = (*annotated)(nil)
+	_ Annotated = (*annotated)(nil)
+)
+
+func (a *annotated) Annotations() Annotations { return a.a }
+func (a *annotated) Unannotated() Value       { return a.v }
+func (a *annotated) Truth() bool              { return a.v.Truth() }
+func (a *annotated) IsNull() bool             { return IsNull(a.v) }
+
+func (a *annotated) IsBool() bool {
+	_, ok := AsBool(a.v)
+	return ok
+}
+
+func (a *annotated) IsFloat64() bool {
+	_, ok := AsFloat64(a.v)
+	return ok
+}
+
+func (a *annotated) Float64() float64 {
+	f, _ := AsFloat64(a.v)
+	return f
+}
+
+func (a *annotated) IsString() bool {
+	_, ok := AsString(a.v)
+	return ok
+}
+
+func (a *annotated) String() string {
+	if s, ok := AsString(a.v); ok {
+		return s
+	}
+	return fmt.Sprint(a.v)
+}
+
+func (a *annotated) IsBigNumber() bool {
+	_, ok := AsBigNumber(a.v)
+	return ok
+}
+
+func (a *annotated) Text() string {
+	s, _ := AsBigNumber(a.v)
+	return s
+}
+
+func (a *annotated) IsBytes() bool {
+	_, ok := AsBytes(a.v)
+	return ok
+}
+
+func (a *annotated) Bytes() []byte {
+	b, _ := AsBytes(a.v)
+	return b
+}
+
+func (a *annotated) IsTime() bool {
+	_, ok := AsTime(a.v)
+	return ok
+}
+
+func (a *annotated) Time() time.Time {
+	t, _ := AsTime(a.v)
+	return t
+}
+
+type annotatedAttr struct{ annotated }
+
+func (a *annotatedAttr) Keys() []Value                { return a.v.(Attr).Keys() }
+func (a *annotatedAttr) Attr(key Value) (Value, bool) { return a.v.(Attr).Attr(key) }
+
+type annotatedIndex struct{ annotated }
+
+func (a *annotatedIndex) Length() int               { return a.v.(Index).Length() }
+func (a *annotatedIndex) Index(i int) (Value, bool) { return a.v.(Index).Index(i) }
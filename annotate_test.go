@@ -0,0 +1,46 @@
+package sift_test
+
+import (
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestAnnotate(t *testing.T) {
+	a := sift.Annotations{Source: "in.json", Line: 3, Column: 7}
+	if got, want := a.String(), "in.json:3:7"; got != want {
+		t.Errorf("String: got %q; want %q", got, want)
+	}
+
+	for _, text := range []string{`null`, `false`, `1.5`, `"x"`, `[1, {"a": 2}]`, `{"a": [null]}`} {
+		v := decodeJSON(t, text)
+		av := sift.Annotate(v, a)
+		if got, ok := sift.AnnotationsOf(av); !ok || got != a {
+			t.Errorf("%s: AnnotationsOf: got %v, %v; want %v, true", text, got, ok, a)
+		}
+		if !sift.Equal(av, v) || sift.Compare(av, v) != 0 || sift.Hash(av) != sift.Hash(v) {
+			t.Errorf("%s: annotated value is not the same as the original", text)
+		}
+		if got, want := encodeJSON(t, av), encodeJSON(t, v); got != want {
+			t.Errorf("%s: encoded as %s; want %s", text, got, want)
+		}
+		if _, ok := sift.Strip(av).(sift.Annotated); ok {
+			t.Errorf("%s: Strip returned an annotated value", text)
+		}
+	}
+
+	f := sift.PropagateAnnotations(func(v sift.Value) ([]sift.Value, error) {
+		e, _ := sift.GetStringAttr(v, "a")
+		return []sift.Value{e, sift.Annotate(sift.NullValue, sift.Annotations{Source: "other"})}, nil
+	})
+	vs, err := f(sift.Annotate(decodeJSON(t, `{"a": 1}`), a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := sift.AnnotationsOf(vs[0]); got != a {
+		t.Errorf("propagated annotations: got %v; want %v", got, a)
+	}
+	if got, _ := sift.AnnotationsOf(vs[1]); got.Source != "other" {
+		t.Errorf("existing annotations: got %v; want other", got)
+	}
+}
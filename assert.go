@@ -0,0 +1,247 @@
+package sift
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An AssertionError is returned by encoders wrapped with AssertSortedBy or
+// AssertUnique when a value breaks the stream's invariant.
+type AssertionError struct {
+	// Position is the number of values written before the offending value,
+	// so the first value in the stream is at position 0.
+	Position int64
+
+	// Value is the offending value. It is not written.
+	Value Value
+
+	// Msg describes the invariant that was broken.
+	Msg string
+}
+
+func (e *AssertionError) Error() string {
+	return fmt.Sprintf("value at position %d: %s", e.Position, e.Msg)
+}
+
+// AssertSortedBy returns middleware that checks that values are written in
+// non-decreasing order of the key produced by key for each value. Keys are
+// ordered by Compare. If a value's key is less than the previous value's
+// key, the value is not written, and Encode returns an *AssertionError. key
+// must produce exactly one value for each value.
+func AssertSortedBy(key Filter) EncoderMiddleware {
+	return func(enc Encoder) Encoder {
+		var pos int64
+		var last Value
+		return middlewareEncoder{
+			next: enc,
+			encode: func(next Encoder, v Value) error {
+				k, err := assertionKey(key, v, pos)
+				if err != nil {
+					return err
+				}
+				if last != nil && Compare(k, last) < 0 {
+					return &AssertionError{
+						Position: pos,
+						Value:    v,
+						Msg:      fmt.Sprintf("not sorted: key %s is less than previous key %s", keyString(k), keyString(last)),
+					}
+				}
+				if err := next.Encode(v); err != nil {
+					return err
+				}
+				last = k
+				pos++
+				return nil
+			},
+		}
+	}
+}
+
+// AssertUnique returns middleware that checks that no two values written
+// have equal keys, as produced by key. If a value's key was already seen,
+// the value is not written, and Encode returns an *AssertionError. key must
+// produce exactly one value for each value. The encoder remembers every key,
+// so its memory use grows with the number of distinct keys.
+func AssertUnique(key Filter) EncoderMiddleware {
+	return func(enc Encoder) Encoder {
+		var pos int64
+		seen := make(map[string]int64)
+		return middlewareEncoder{
+			next: enc,
+			encode: func(next Encoder, v Value) error {
+				k, err := assertionKey(key, v, pos)
+				if err != nil {
+					return err
+				}
+				ks := keyString(k)
+				if prev, ok := seen[ks]; ok {
+					return &AssertionError{
+						Position: pos,
+						Value:    v,
+						Msg:      fmt.Sprintf("not unique: key %s was seen at position %d", ks, prev),
+					}
+				}
+				if err := next.Encode(v); err != nil {
+					return err
+				}
+				seen[ks] = pos
+				pos++
+				return nil
+			},
+		}
+	}
+}
+
+// assertionKey applies key to v and checks that it produces one value.
+func assertionKey(key Filter, v Value, pos int64) (Value, error) {
+	ks, err := key(v)
+	if err != nil {
+		return nil, fmt.Errorf("value at position %d: computing key: %w", pos, err)
+	}
+	if len(ks) != 1 {
+		return nil, &AssertionError{
+			Position: pos,
+			Value:    v,
+			Msg:      fmt.Sprintf("key produced %d values; want 1", len(ks)),
+		}
+	}
+	return ks[0], nil
+}
+
+// keyString returns a canonical, JSON-like representation of a key. Keys
+// that compare equal have the same representation.
+func keyString(v Value) string {
+	b := &strings.Builder{}
+	writeKey(b, v)
+	return b.String()
+}
+
+// keyWriter is written to by writeKey. It's implemented by
+// *strings.Builder and by hasher.
+type keyWriter interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
+}
+
+// writeKey writes the canonical representation of v to b. Objects and
+// arrays that contain themselves are written as "..." where they're
+// reached again, as are values nested more deeply than
+// DefaultMaxEqualDepth, so writeKey terminates on any value.
+func writeKey(b keyWriter, v Value) {
+	var s keyState
+	s.write(b, v, 0)
+}
+
+// keyState records the objects and arrays writeKey is in the middle of
+// writing.
+type keyState struct {
+	active map[keyVisit]bool
+}
+
+// keyVisit identifies an object or array by its underlying map, pointer,
+// or slice, and its type.
+type keyVisit struct {
+	id  identity
+	typ reflect.Type
+}
+
+// visitKey returns the keyVisit for v, if v has a reference type.
+func visitKey(v Value) (keyVisit, bool) {
+	id, ok := identify(v)
+	return keyVisit{id: id, typ: reflect.TypeOf(v)}, ok
+}
+
+// enter records that v is being written. It returns false if v is already
+// being written, or if it's nested too deeply. If enter returns true, leave
+// must be called after v is written.
+func (s *keyState) enter(v Value, depth int) bool {
+	if depth > DefaultMaxEqualDepth {
+		return false
+	}
+	k, ok := visitKey(v)
+	if !ok {
+		return true
+	}
+	if s.active[k] {
+		return false
+	}
+	if s.active == nil {
+		s.active = make(map[keyVisit]bool)
+	}
+	s.active[k] = true
+	return true
+}
+
+func (s *keyState) leave(v Value) {
+	if k, ok := visitKey(v); ok {
+		delete(s.active, k)
+	}
+}
+
+func (s *keyState) write(b keyWriter, v Value, depth int) {
+	switch kindOrder(v) {
+	case kindNull:
+		b.WriteString("null")
+	case kindFalse:
+		b.WriteString("false")
+	case kindTrue:
+		b.WriteString("true")
+	case kindNumber:
+		b.WriteString(numberKey(v))
+	case kindTime:
+		t, _ := AsTime(v)
+		b.WriteByte('t')
+		b.WriteString(strconv.Quote(t.UTC().Format(time.RFC3339Nano)))
+	case kindString:
+		if bs, ok := AsBytes(v); ok {
+			b.WriteByte('b')
+			b.WriteString(strconv.Quote(string(bs)))
+			break
+		}
+		s, _ := AsString(v)
+		b.WriteString(strconv.Quote(s))
+	case kindArray:
+		if !s.enter(v, depth) {
+			b.WriteString("...")
+			return
+		}
+		defer s.leave(v)
+		ix := v.(Index)
+		b.WriteByte('[')
+		for i := 0; i < ix.Length(); i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			e, _ := ix.Index(i)
+			s.write(b, e, depth+1)
+		}
+		b.WriteByte(']')
+	default:
+		a, ok := v.(Attr)
+		if !ok {
+			fmt.Fprintf(b, "%v", v)
+			return
+		}
+		if !s.enter(v, depth) {
+			b.WriteString("...")
+			return
+		}
+		defer s.leave(v)
+		b.WriteByte('{')
+		for i, key := range sortedKeys(a) {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			s.write(b, key, depth+1)
+			b.WriteByte(':')
+			e, _ := a.Attr(key)
+			s.write(b, e, depth+1)
+		}
+		b.WriteByte('}')
+	}
+}
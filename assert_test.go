@@ -0,0 +1,108 @@
+package sift_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestAssertSortedBy(t *testing.T) {
+	ts := func(v sift.Value) ([]sift.Value, error) {
+		k, _ := sift.GetStringAttr(v, "ts")
+		return []sift.Value{k}, nil
+	}
+	for _, test := range []struct {
+		name    string
+		values  []interface{}
+		wantPos int64
+	}{
+		{name: "sorted", values: []interface{}{1, 2, 2, 3}, wantPos: -1},
+		{name: "kinds", values: []interface{}{nil, false, true, 0, "a", []interface{}{}, map[string]interface{}{}}, wantPos: -1},
+		{name: "decrease", values: []interface{}{1, 3, 2, 4}, wantPos: 2},
+		{name: "strings", values: []interface{}{"a", "b", "B"}, wantPos: 2},
+		{name: "arrays", values: []interface{}{[]interface{}{1, 2}, []interface{}{1, 2, 0}, []interface{}{1}}, wantPos: 2},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rec := &recordEncoder{}
+			enc := sift.WrapEncoder(rec, sift.AssertSortedBy(ts))
+			err := encodeAll(enc, test.values, "ts")
+			checkAssertion(t, err, test.wantPos)
+			if test.wantPos >= 0 && int64(len(rec.values)) != test.wantPos {
+				t.Errorf("wrote %d values; want %d", len(rec.values), test.wantPos)
+			}
+		})
+	}
+}
+
+func TestAssertUnique(t *testing.T) {
+	id := func(v sift.Value) ([]sift.Value, error) {
+		k, _ := sift.GetStringAttr(v, "id")
+		return []sift.Value{k}, nil
+	}
+	for _, test := range []struct {
+		name    string
+		values  []interface{}
+		wantPos int64
+	}{
+		{name: "unique", values: []interface{}{1, "1", []byte("1"), []interface{}{1}, nil}, wantPos: -1},
+		{name: "numbers", values: []interface{}{1, 2, 1.0}, wantPos: 2},
+		{name: "times", values: []interface{}{
+			time.Unix(0, 0).UTC(),
+			time.Unix(1, 0).UTC(),
+			time.Unix(0, 0).In(time.FixedZone("", 60*60)),
+		}, wantPos: 2},
+		{name: "big_numbers", values: []interface{}{
+			sift.Must(sift.NewBigNumber("12345678901234567890")),
+			sift.Must(sift.NewBigNumber("12345678901234567891")),
+			sift.Must(sift.NewBigNumber("1.5e2")),
+			150,
+		}, wantPos: 3},
+		{name: "objects", values: []interface{}{
+			map[string]interface{}{"a": 1, "b": 2},
+			map[string]interface{}{"a": 1},
+			map[string]interface{}{"b": 2, "a": 1},
+		}, wantPos: 2},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			enc := sift.WrapEncoder(&recordEncoder{}, sift.AssertUnique(id))
+			checkAssertion(t, encodeAll(enc, test.values, "id"), test.wantPos)
+		})
+	}
+
+	many := func(sift.Value) ([]sift.Value, error) { return nil, nil }
+	enc := sift.WrapEncoder(&recordEncoder{}, sift.AssertUnique(many))
+	checkAssertion(t, enc.Encode(sift.NullValue), 0)
+}
+
+// encodeAll wraps each of keys in an object with the given field name and
+// encodes it.
+func encodeAll(enc sift.Encoder, keys []interface{}, field string) error {
+	for _, k := range keys {
+		v := sift.Must(sift.ToValue(map[string]interface{}{field: k}))
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkAssertion checks that err is an *AssertionError at position pos,
+// or nil if pos is negative.
+func checkAssertion(t *testing.T, err error, pos int64) {
+	t.Helper()
+	if pos < 0 {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return
+	}
+	var aerr *sift.AssertionError
+	if !errors.As(err, &aerr) {
+		t.Fatalf("got error %v; want *AssertionError", err)
+	}
+	if aerr.Position != pos {
+		t.Errorf("got error at position %d; want %d: %v", aerr.Position, pos, err)
+	}
+}
@@ -0,0 +1,165 @@
+package sift
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// BatchOptions controls how a BatchEncoder groups values into batches.
+// A batch is written when any of the configured limits is reached. Zero
+// values mean the corresponding limit is not used.
+type BatchOptions struct {
+	// Count is the maximum number of values in a batch.
+	Count int
+
+	// Bytes is the maximum total size of values in a batch, as reported
+	// by Size. A value that is larger than Bytes on its own is written
+	// in a batch by itself.
+	Bytes int
+
+	// Size returns the size of a value in bytes. It must be set if Bytes
+	// is set. Usually, this is the length of the value's encoded form.
+	Size func(Value) (int, error)
+
+	// Interval is the maximum amount of time a value may wait in a batch
+	// before the batch is written.
+	Interval time.Duration
+}
+
+// BatchEncoder is an Encoder that groups values into arrays before passing
+// them to another Encoder. This is useful for sinks that accept values in
+// bulk, for example, APIs that insert many records in one request.
+//
+// BatchEncoder implements Flusher. Flush must be called after the last
+// value is encoded to write the final batch. Sift does this automatically.
+type BatchEncoder struct {
+	enc  Encoder
+	opts BatchOptions
+
+	mu    sync.Mutex
+	batch []Value
+	bytes int
+	timer *time.Timer
+	gen   int   // incremented when timer is stopped, so stale callbacks do nothing
+	err   error // error from a flush triggered by the timer
+}
+
+var _ Flusher = (*BatchEncoder)(nil)
+
+// NewBatchEncoder returns a BatchEncoder that writes batches of values
+// to enc according to opts.
+func NewBatchEncoder(enc Encoder, opts BatchOptions) *BatchEncoder {
+	return &BatchEncoder{enc: enc, opts: opts}
+}
+
+// Encode adds v to the current batch. If the batch is full, it is written
+// to the underlying encoder.
+func (b *BatchEncoder) Encode(v Value) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+
+	size := 0
+	if b.opts.Bytes > 0 {
+		if b.opts.Size == nil {
+			return errors.New("batch size limit set without size function")
+		}
+		var err error
+		size, err = b.opts.Size(v)
+		if err != nil {
+			return err
+		}
+		if len(b.batch) > 0 && b.bytes+size > b.opts.Bytes {
+			if err := b.flushLocked(); err != nil {
+				return err
+			}
+		}
+	}
+
+	b.batch = append(b.batch, v)
+	b.bytes += size
+	if b.opts.Count > 0 && len(b.batch) >= b.opts.Count ||
+		b.opts.Bytes > 0 && b.bytes >= b.opts.Bytes {
+		return b.flushLocked()
+	}
+	if b.opts.Interval > 0 && b.timer == nil {
+		gen := b.gen
+		b.timer = time.AfterFunc(b.opts.Interval, func() { b.flushTimer(gen) })
+	}
+	return nil
+}
+
+// Flush writes the current batch to the underlying encoder, if the batch is
+// not empty. If the underlying encoder is a Flusher, it is flushed, too.
+func (b *BatchEncoder) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	if err := b.flushLocked(); err != nil {
+		return err
+	}
+	if f, ok := b.enc.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// ByteCount returns the number of bytes written by the wrapped encoder, if
+// it's a ByteCounter.
+func (b *BatchEncoder) ByteCount() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return byteCount(b.enc)
+}
+
+// Drain writes the current batch to the underlying encoder, if the batch is
+// not empty, then drains the underlying encoder. Unlike Flush, Drain
+// doesn't flush the underlying encoder, so it may be called before the end
+// of the stream.
+func (b *BatchEncoder) Drain() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	if err := b.flushLocked(); err != nil {
+		return err
+	}
+	return drain(b.enc)
+}
+
+// flushTimer is called when the timer started for generation gen fires.
+// If the timer was stopped after it fired but before flushTimer acquired
+// the lock, the batch it was started for has already been written, and
+// flushTimer does nothing.
+func (b *BatchEncoder) flushTimer(gen int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if gen != b.gen {
+		return
+	}
+	b.timer = nil
+	if b.err == nil {
+		b.err = b.flushLocked()
+	}
+}
+
+func (b *BatchEncoder) flushLocked() error {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+		b.gen++
+	}
+	if len(b.batch) == 0 {
+		return nil
+	}
+	batch := indexType(b.batch)
+	b.batch = nil
+	b.bytes = 0
+	return b.enc.Encode(batch)
+}
@@ -0,0 +1,150 @@
+package sift_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"go.jayconrod.com/sift"
+)
+
+type recordEncoder struct {
+	values []sift.Value
+}
+
+func (e *recordEncoder) Encode(v sift.Value) error {
+	e.values = append(e.values, v)
+	return nil
+}
+
+func TestBatchEncoder(t *testing.T) {
+	for _, tc := range []struct {
+		desc  string
+		opts  sift.BatchOptions
+		input []interface{}
+		want  []interface{}
+	}{
+		{
+			desc:  "count",
+			opts:  sift.BatchOptions{Count: 2},
+			input: []interface{}{1, 2, 3, 4, 5},
+			want: []interface{}{
+				[]interface{}{1, 2},
+				[]interface{}{3, 4},
+				[]interface{}{5},
+			},
+		}, {
+			desc: "bytes",
+			opts: sift.BatchOptions{
+				Bytes: 4,
+				Size: func(v sift.Value) (int, error) {
+					s, _ := sift.AsString(v)
+					return len(s), nil
+				},
+			},
+			input: []interface{}{"a", "bb", "ccc", "ddddd", "e"},
+			want: []interface{}{
+				[]interface{}{"a", "bb"},
+				[]interface{}{"ccc"},
+				[]interface{}{"ddddd"},
+				[]interface{}{"e"},
+			},
+		}, {
+			desc:  "empty",
+			opts:  sift.BatchOptions{Count: 2},
+			input: nil,
+			want:  nil,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			rec := &recordEncoder{}
+			enc := sift.NewBatchEncoder(rec, tc.opts)
+			for _, i := range tc.input {
+				if err := enc.Encode(sift.Must(sift.ToValue(i))); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := enc.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if len(rec.values) != len(tc.want) {
+				t.Fatalf("got %d batches; want %d", len(rec.values), len(tc.want))
+			}
+			for i, w := range tc.want {
+				want := sift.Must(sift.ToValue(w))
+				if !sift.Equal(rec.values[i], want) {
+					t.Errorf("batch %d: got %v; want %v", i, rec.values[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchEncoderInterval(t *testing.T) {
+	rec := &recordEncoder{}
+	enc := sift.NewBatchEncoder(rec, sift.BatchOptions{Interval: time.Millisecond})
+	if err := enc.Encode(sift.Must(sift.ToValue(1))); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	// Flush takes the lock, so the timer's write is visible afterward.
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.values) != 1 {
+		t.Fatalf("got %d batches; want 1", len(rec.values))
+	}
+}
+
+// countEncoder counts batches. It's safe for concurrent use.
+type countEncoder struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (e *countEncoder) Encode(sift.Value) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.n++
+	return nil
+}
+
+func (e *countEncoder) batches() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.n
+}
+
+func TestBatchEncoderStaleTimer(t *testing.T) {
+	// The second value doesn't fit in the first batch, so Encode writes
+	// the first batch, then starts a new batch with the second value.
+	// Measuring the second value is slow, so the first batch's timer fires
+	// while Encode holds the lock. The timer's callback must not write the
+	// new batch early.
+	const interval = 50 * time.Millisecond
+	count := &countEncoder{}
+	enc := sift.NewBatchEncoder(count, sift.BatchOptions{
+		Bytes: 4,
+		Size: func(v sift.Value) (int, error) {
+			n, _ := sift.AsFloat64(v)
+			if n == 2 {
+				time.Sleep(2 * interval)
+			}
+			return int(n), nil
+		},
+		Interval: interval,
+	})
+	for _, size := range []int{3, 2} {
+		if err := enc.Encode(sift.Must(sift.ToValue(size))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(interval / 5)
+	if n := count.batches(); n != 1 {
+		t.Fatalf("got %d batches before the second batch's interval; want 1", n)
+	}
+	time.Sleep(2 * interval)
+	if n := count.batches(); n != 2 {
+		t.Fatalf("got %d batches after the second batch's interval; want 2", n)
+	}
+}
@@ -0,0 +1,303 @@
+package sift
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// BigNumber is implemented by numbers that may not be exactly representable
+// as float64, like 64-bit integer IDs or decimals with many digits. Values
+// that implement BigNumber should also implement Float64, returning the
+// nearest float64, so that filters that only understand Float64 still
+// treat them as numbers.
+//
+// Encoders should check for BigNumber before Float64 and write the exact
+// text, so that values pass through sift without losing precision.
+type BigNumber interface {
+	Value
+
+	// IsBigNumber returns whether the value is a big number.
+	IsBigNumber() bool
+
+	// Text returns the exact decimal representation of the number, using
+	// JSON number syntax, like "12345678901234567890" or "1.5e-400".
+	Text() string
+}
+
+// AsBigNumber returns the exact text of a number and true if v implements
+// BigNumber. Otherwise, "" and false are returned.
+func AsBigNumber(v Value) (string, bool) {
+	if n, ok := v.(BigNumber); ok && n.IsBigNumber() {
+		return n.Text(), true
+	}
+	return "", false
+}
+
+// Number is implemented by numbers that keep their exact text, so they may
+// be decoded, filtered, and encoded without losing precision, while
+// filters that only understand Float64 still treat them as numbers.
+// BigNumbers returned by this package, including those ToValue returns for
+// json.Number, implement Number.
+type Number interface {
+	BigNumber
+	Float64
+}
+
+var _ Number = bigNumberType{}
+
+// AsNumber returns the text of v in JSON number syntax and true if v is
+// a number. The exact text of a BigNumber is returned. Other numbers are
+// formatted with the fewest digits that represent them exactly. If v isn't
+// a finite number, "" and false are returned.
+func AsNumber(v Value) (json.Number, bool) {
+	if text, ok := AsBigNumber(v); ok {
+		return json.Number(text), true
+	}
+	f, ok := AsFloat64(v)
+	if !ok || math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", false
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), true
+}
+
+// numberValue converts a json.Number to a value. If the number's value is
+// exactly representable as float64, a float64 value is returned, since
+// those are cheaper to operate on. Otherwise, a BigNumber is returned with
+// the number's text unchanged.
+func numberValue(n json.Number) (Value, error) {
+	d, err := parseDecimal(string(n))
+	if err != nil {
+		return nil, err
+	}
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err == nil {
+		if fd, ok := asDecimal(floatValue(f)); ok && fd == d {
+			return floatValue(f), nil
+		}
+	}
+	return bigNumberType{text: string(n), d: d}, nil
+}
+
+// NewBigNumber returns a BigNumber for text, which must use JSON number
+// syntax. The value's Text method returns text unchanged.
+func NewBigNumber(text string) (Value, error) {
+	d, err := parseDecimal(text)
+	if err != nil {
+		return nil, err
+	}
+	return bigNumberType{text: text, d: d}, nil
+}
+
+// AsBigInt returns the value of v as a big.Int and true if v is a BigNumber
+// or Float64 with an integer value. Otherwise, nil and false are returned.
+func AsBigInt(v Value) (*big.Int, bool) {
+	d, ok := asDecimal(v)
+	if !ok || d.exp < len(d.digits) {
+		return nil, false
+	}
+	if d.digits == "" {
+		return new(big.Int), true
+	}
+	if d.exp > 100000 {
+		// Avoid allocating huge integers for numbers like 1e999999999.
+		return nil, false
+	}
+	i, _ := new(big.Int).SetString(d.digits+strings.Repeat("0", d.exp-len(d.digits)), 10)
+	if d.neg {
+		i.Neg(i)
+	}
+	return i, true
+}
+
+type bigNumberType struct {
+	text string
+	d    decimal
+}
+
+var (
+	_ BigNumber = bigNumberType{}
+	_ Float64   = bigNumberType{}
+)
+
+func (n bigNumberType) Truth() bool       { return n.d.digits != "" }
+func (n bigNumberType) IsBigNumber() bool { return true }
+func (n bigNumberType) Text() string      { return n.text }
+func (n bigNumberType) IsFloat64() bool   { return true }
+
+func (n bigNumberType) Float64() float64 {
+	// Text is valid syntax, so the only possible error is a range error,
+	// for which ParseFloat returns an infinity or zero.
+	f, _ := strconv.ParseFloat(n.text, 64)
+	return f
+}
+
+func (n bigNumberType) String() string { return n.text }
+
+// bigInt returns a BigNumber for an integer that can't be represented
+// exactly as float64.
+func bigInt(text string) Value {
+	return Must(NewBigNumber(text))
+}
+
+// A decimal is a number in a canonical form that may be compared exactly:
+// the value is 0.digits × 10^exp. digits has no leading or trailing zeros
+// and is empty for zero.
+type decimal struct {
+	neg    bool
+	digits string
+	exp    int
+}
+
+// parseDecimal parses a number in JSON syntax.
+func parseDecimal(text string) (decimal, error) {
+	var d decimal
+	s := text
+	if strings.HasPrefix(s, "-") {
+		d.neg = true
+		s = s[1:]
+	}
+	intEnd := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if intEnd < 0 {
+		intEnd = len(s)
+	}
+	intPart, s := s[:intEnd], s[intEnd:]
+	if intPart == "" || len(intPart) > 1 && intPart[0] == '0' {
+		return decimal{}, fmt.Errorf("invalid number %q", text)
+	}
+	var fracPart string
+	if strings.HasPrefix(s, ".") {
+		fracEnd := strings.IndexFunc(s[1:], func(r rune) bool { return r < '0' || r > '9' })
+		if fracEnd < 0 {
+			fracEnd = len(s) - 1
+		}
+		fracPart, s = s[1:1+fracEnd], s[1+fracEnd:]
+		if fracPart == "" {
+			return decimal{}, fmt.Errorf("invalid number %q", text)
+		}
+	}
+	exp := 0
+	if s != "" {
+		if s[0] != 'e' && s[0] != 'E' {
+			return decimal{}, fmt.Errorf("invalid number %q", text)
+		}
+		es := s[1:]
+		if es != "" && (es[0] == '+' || es[0] == '-') {
+			es = es[1:]
+		}
+		if es == "" || strings.Trim(es, "0123456789") != "" {
+			return decimal{}, fmt.Errorf("invalid number %q", text)
+		}
+		var err error
+		if exp, err = strconv.Atoi(s[1:]); err != nil || exp > math.MaxInt32 || exp < math.MinInt32 {
+			return decimal{}, fmt.Errorf("invalid number %q: exponent out of range", text)
+		}
+	}
+
+	digits := intPart + fracPart
+	exp += len(intPart)
+	trimmed := strings.TrimLeft(digits, "0")
+	exp -= len(digits) - len(trimmed)
+	d.digits = strings.TrimRight(trimmed, "0")
+	if d.digits == "" {
+		return decimal{}, nil
+	}
+	d.exp = exp
+	return d, nil
+}
+
+// asDecimal returns the value of a BigNumber or a finite Float64 as
+// a decimal.
+func asDecimal(v Value) (decimal, bool) {
+	if n, ok := v.(bigNumberType); ok {
+		return n.d, true
+	}
+	if text, ok := AsBigNumber(v); ok {
+		d, err := parseDecimal(text)
+		return d, err == nil
+	}
+	f, ok := AsFloat64(v)
+	if !ok || math.IsNaN(f) || math.IsInf(f, 0) {
+		return decimal{}, false
+	}
+	d, err := parseDecimal(strconv.FormatFloat(f, 'g', -1, 64))
+	return d, err == nil
+}
+
+func compareDecimals(l, r decimal) int {
+	if l.neg != r.neg {
+		if l.neg {
+			return -1
+		}
+		return 1
+	}
+	c := compareMagnitudes(l, r)
+	if l.neg {
+		return -c
+	}
+	return c
+}
+
+func compareMagnitudes(l, r decimal) int {
+	switch {
+	case l.digits == "" || r.digits == "":
+		return compareInts(len(l.digits), len(r.digits))
+	case l.exp != r.exp:
+		return compareInts(l.exp, r.exp)
+	}
+	return strings.Compare(l.digits, r.digits)
+}
+
+// compareNumbers compares two numbers. If either is a BigNumber, they're
+// compared exactly; otherwise, they're compared as float64, and NaN sorts
+// before other numbers.
+func compareNumbers(l, r Value) int {
+	_, lbig := AsBigNumber(l)
+	_, rbig := AsBigNumber(r)
+	if lbig || rbig {
+		ld, lok := asDecimal(l)
+		rd, rok := asDecimal(r)
+		if lok && rok {
+			return compareDecimals(ld, rd)
+		}
+	}
+	lf, _ := AsFloat64(l)
+	rf, _ := AsFloat64(r)
+	switch {
+	case lf < rf || math.IsNaN(lf) && !math.IsNaN(rf):
+		return -1
+	case lf > rf || !math.IsNaN(lf) && math.IsNaN(rf):
+		return 1
+	}
+	return 0
+}
+
+// numberKey returns a canonical representation of a number, the same for
+// all numbers that are equal. Numbers that may be represented exactly as
+// float64 are formatted as float64; other numbers are formatted in
+// normalized scientific notation.
+func numberKey(v Value) string {
+	f, _ := AsFloat64(v)
+	if _, ok := AsBigNumber(v); ok {
+		if d, ok := asDecimal(v); ok && compareNumbers(v, floatValue(f)) != 0 {
+			b := &strings.Builder{}
+			if d.neg {
+				b.WriteByte('-')
+			}
+			b.WriteString(d.digits[:1])
+			if len(d.digits) > 1 {
+				b.WriteByte('.')
+				b.WriteString(d.digits[1:])
+			}
+			fmt.Fprintf(b, "e%d", d.exp-1)
+			return b.String()
+		}
+	}
+	if f == 0 {
+		f = 0 // normalize -0
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
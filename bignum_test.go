@@ -0,0 +1,118 @@
+package sift_test
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestBigNumber(t *testing.T) {
+	for _, text := range []string{"01", "1.", ".5", "1e", "1e+", "1e++5", "+1", "1x", "--1", "1e99999999999"} {
+		if _, err := sift.NewBigNumber(text); err == nil {
+			t.Errorf("NewBigNumber(%q): got success; want error", text)
+		}
+	}
+
+	bn := func(text string) sift.Value { return sift.Must(sift.NewBigNumber(text)) }
+	num := func(f float64) sift.Value { return sift.Must(sift.ToValue(f)) }
+	for _, tc := range []struct {
+		desc  string
+		l, r  sift.Value
+		equal bool
+	}{
+		{desc: "same", l: bn("12345678901234567890"), r: bn("12345678901234567890"), equal: true},
+		{desc: "different_digits", l: bn("12345678901234567890"), r: bn("12345678901234567891")},
+		{desc: "forms", l: bn("1.50e2"), r: bn("150.0"), equal: true},
+		{desc: "float", l: bn("0.5"), r: num(0.5), equal: true},
+		{desc: "float_reversed", l: num(0.5), r: bn("5e-1"), equal: true},
+		{desc: "float_inexact", l: bn("9007199254740993"), r: num(9007199254740992)},
+		{desc: "zeros", l: bn("-0.0"), r: num(0), equal: true},
+		{desc: "not_number", l: bn("1"), r: sift.Must(sift.ToValue("1"))},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := sift.Equal(tc.l, tc.r); got != tc.equal {
+				t.Errorf("Equal(%v, %v) = %v; want %v", tc.l, tc.r, got, tc.equal)
+			}
+		})
+	}
+
+	v := bn("-1e400")
+	if f, ok := sift.AsFloat64(v); !ok || !math.IsInf(f, -1) {
+		t.Errorf("AsFloat64(%v) = %v, %v; want -Inf, true", v, f, ok)
+	}
+	if text, ok := sift.AsBigNumber(v); !ok || text != "-1e400" {
+		t.Errorf("AsBigNumber(%v) = %q, %v; want %q, true", v, text, ok, "-1e400")
+	}
+	if _, ok := sift.AsBigNumber(num(1)); ok {
+		t.Error("AsBigNumber(1): got true; want false")
+	}
+
+	for _, tc := range []struct {
+		v    sift.Value
+		want string
+	}{
+		{v: bn("1.2e20"), want: "120000000000000000000"},
+		{v: bn("-7"), want: "-7"},
+		{v: num(3), want: "3"},
+		{v: num(0), want: "0"},
+		{v: bn("1.5")},
+		{v: num(math.Inf(1))},
+	} {
+		i, ok := sift.AsBigInt(tc.v)
+		if tc.want == "" {
+			if ok {
+				t.Errorf("AsBigInt(%v) = %v; want false", tc.v, i)
+			}
+		} else if !ok || i.String() != tc.want {
+			t.Errorf("AsBigInt(%v) = %v, %v; want %s, true", tc.v, i, ok, tc.want)
+		}
+	}
+
+	u := sift.Must(sift.ToValue(uint64(math.MaxUint64)))
+	if text, ok := sift.AsBigNumber(u); !ok || text != "18446744073709551615" {
+		t.Errorf("ToValue(MaxUint64) = %v; want big number", u)
+	}
+	b := new(big.Int).Lsh(big.NewInt(1), 100)
+	if text, _ := sift.AsBigNumber(sift.Must(sift.ToValue(b))); text != b.String() {
+		t.Errorf("ToValue(2^100): got %q; want %q", text, b.String())
+	}
+	if _, ok := sift.AsBigNumber(sift.Must(sift.ToValue(big.NewInt(5)))); ok {
+		t.Error("ToValue(big.NewInt(5)): got big number; want float64")
+	}
+}
+
+func TestNumber(t *testing.T) {
+	for _, tc := range []struct {
+		in   json.Number
+		big  bool
+		want json.Number
+	}{
+		{in: "1", want: "1"},
+		{in: "1.50", want: "1.5"},
+		{in: "-2e3", want: "-2000"},
+		{in: "12345678901234567890", big: true, want: "12345678901234567890"},
+		{in: "0.1000000000000000000001", big: true, want: "0.1000000000000000000001"},
+		{in: "1e400", big: true, want: "1e400"},
+	} {
+		v, err := sift.ToValue(tc.in)
+		if err != nil {
+			t.Errorf("ToValue(%s): %v", tc.in, err)
+			continue
+		}
+		if _, ok := v.(sift.Number); ok != tc.big {
+			t.Errorf("ToValue(%s): Number is %v; want %v", tc.in, ok, tc.big)
+		}
+		if got, ok := sift.AsNumber(v); !ok || got != tc.want {
+			t.Errorf("AsNumber(%s) = %q, %v; want %q, true", tc.in, got, ok, tc.want)
+		}
+	}
+	if _, err := sift.ToValue(json.Number("1x")); err == nil {
+		t.Error("ToValue(1x): got success; want error")
+	}
+	if _, ok := sift.AsNumber(sift.Must(sift.ToValue(math.NaN()))); ok {
+		t.Error("AsNumber(NaN): got true; want false")
+	}
+}
@@ -0,0 +1,108 @@
+package sift
+
+import "fmt"
+
+// ObjectBuilder assembles an object one attribute at a time. The object's
+// keys are in the order they're first set. Create an ObjectBuilder with
+// NewObject.
+//
+// Set and SetPath return the builder, so calls may be chained. If a value
+// can't be converted or a path can't be set, the builder records the first
+// error, ignores later calls, and returns the error from Build.
+type ObjectBuilder struct {
+	a   *orderedAttrType
+	err error
+}
+
+// NewObject returns a builder for a new, empty object.
+func NewObject() *ObjectBuilder {
+	return &ObjectBuilder{a: &orderedAttrType{values: make(map[string]Value)}}
+}
+
+// Set sets the attribute named key to v, which is converted with ToValue.
+// If the attribute was already set, its value is replaced, and it keeps its
+// position.
+func (b *ObjectBuilder) Set(key string, v interface{}) *ObjectBuilder {
+	if b.err != nil {
+		return b
+	}
+	e, err := ToValue(v)
+	if err != nil {
+		b.err = fmt.Errorf("attribute %q: %w", key, err)
+		return b
+	}
+	b.a.set(key, e)
+	return b
+}
+
+// SetPath sets the value at path p within the object to v, which is
+// converted with ToValue. Nested objects and arrays are created as needed,
+// as with Path.Set. p must not be empty.
+func (b *ObjectBuilder) SetPath(p Path, v interface{}) *ObjectBuilder {
+	if b.err != nil {
+		return b
+	}
+	if len(p) == 0 {
+		b.err = fmt.Errorf("cannot set empty path in object")
+		return b
+	}
+	e, err := ToValue(v)
+	if err != nil {
+		b.err = fmt.Errorf("at %s: %w", p, err)
+		return b
+	}
+	out, err := p.Set(b.a, e)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	b.a = out.(*orderedAttrType)
+	return b
+}
+
+// Build returns the object or the first error encountered while building
+// it. The builder must not be used after Build is called.
+func (b *ObjectBuilder) Build() (Value, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	a := b.a
+	b.a = nil
+	return a, nil
+}
+
+// ArrayBuilder assembles an array one element at a time. Create an
+// ArrayBuilder with NewArray.
+type ArrayBuilder struct {
+	ix indexType
+}
+
+// NewArray returns a builder for a new, empty array with room for
+// capacity elements before it needs to grow.
+func NewArray(capacity int) *ArrayBuilder {
+	return &ArrayBuilder{ix: make(indexType, 0, capacity)}
+}
+
+// Append adds vs to the end of the array. Nil values are added as null.
+func (b *ArrayBuilder) Append(vs ...Value) *ArrayBuilder {
+	for _, v := range vs {
+		if v == nil {
+			v = NullValue
+		}
+		b.ix = append(b.ix, v)
+	}
+	return b
+}
+
+// Len returns the number of elements appended so far.
+func (b *ArrayBuilder) Len() int {
+	return len(b.ix)
+}
+
+// Build returns the array. The builder must not be used after Build is
+// called.
+func (b *ArrayBuilder) Build() Index {
+	ix := b.ix
+	b.ix = nil
+	return ix
+}
@@ -0,0 +1,63 @@
+package sift_test
+
+import (
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestObjectBuilder(t *testing.T) {
+	v, err := sift.NewObject().
+		Set("b", 1).
+		Set("a", []string{"x"}).
+		SetPath(path("c", "d", 1), true).
+		Set("b", 2).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := encodeJSON(t, v), `{"b":2,"a":["x"],"c":{"d":[null,true]}}`; got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+
+	for _, tc := range []struct {
+		desc string
+		b    *sift.ObjectBuilder
+		want string
+	}{
+		{
+			desc: "bad_value",
+			b:    sift.NewObject().Set("a", make(chan int)).Set("b", 1),
+			want: `attribute "a": cannot represent as value`,
+		}, {
+			desc: "bad_path",
+			b:    sift.NewObject().Set("a", 1).SetPath(path("a", "b"), 2),
+			want: `at .a: cannot index number with string "b"`,
+		}, {
+			desc: "empty_path",
+			b:    sift.NewObject().SetPath(path(), 1),
+			want: "cannot set empty path in object",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := tc.b.Build()
+			if err == nil || len(err.Error()) < len(tc.want) || err.Error()[:len(tc.want)] != tc.want {
+				t.Errorf("got error %v; want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestArrayBuilder(t *testing.T) {
+	b := sift.NewArray(2)
+	b.Append(sift.Must(sift.ToValue(1))).Append(nil, sift.Must(sift.ToValue("x")))
+	if b.Len() != 3 {
+		t.Errorf("Len: got %d; want 3", b.Len())
+	}
+	if got, want := encodeJSON(t, b.Build()), `[1,null,"x"]`; got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+	if got, want := encodeJSON(t, sift.NewArray(0).Build()), `[]`; got != want {
+		t.Errorf("empty: got %s; want %s", got, want)
+	}
+}
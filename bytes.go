@@ -0,0 +1,67 @@
+package sift
+
+import "bytes"
+
+// Bytes is implemented by byte strings: binary data that may not be valid
+// text. Encodings with a binary type, like MessagePack, CBOR, and BSON, may
+// decode byte strings as Bytes, so they may be written back without
+// conversion. Encodings without one, like JSON, write Bytes as base64
+// strings.
+//
+// Bytes are ordered with strings, by their contents, and are never equal
+// to strings.
+type Bytes interface {
+	Value
+
+	// IsBytes returns whether the value is a byte string.
+	IsBytes() bool
+
+	// Bytes returns the bytes this value represents. The caller must not
+	// modify the returned slice.
+	Bytes() []byte
+}
+
+// AsBytes returns a byte slice and true if v implements Bytes. Otherwise,
+// nil and false are returned. The caller must not modify the returned
+// slice.
+func AsBytes(v Value) ([]byte, bool) {
+	if b, ok := v.(Bytes); ok && b.IsBytes() {
+		return b.Bytes(), true
+	}
+	return nil, false
+}
+
+type bytesType []byte
+
+var _ Bytes = bytesType(nil)
+
+func (b bytesType) Truth() bool   { return len(b) > 0 }
+func (b bytesType) IsBytes() bool { return true }
+func (b bytesType) Bytes() []byte { return []byte(b) }
+
+// compareStrings compares two values that are strings or byte strings by
+// their contents. If the contents are the same, strings sort before byte
+// strings.
+func compareStrings(l, r Value) int {
+	lb, lbytes := AsBytes(l)
+	rb, rbytes := AsBytes(r)
+	if !lbytes {
+		s, _ := AsString(l)
+		lb = []byte(s)
+	}
+	if !rbytes {
+		s, _ := AsString(r)
+		rb = []byte(s)
+	}
+	if c := bytes.Compare(lb, rb); c != 0 {
+		return c
+	}
+	switch {
+	case lbytes == rbytes:
+		return 0
+	case lbytes:
+		return 1
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,43 @@
+package sift_test
+
+import (
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestBytes(t *testing.T) {
+	b := sift.Must(sift.ToValue([]byte("abc")))
+	if got, ok := sift.AsBytes(b); !ok || string(got) != "abc" {
+		t.Errorf("AsBytes: got %q, %v; want %q, true", got, ok, "abc")
+	}
+	if _, ok := sift.AsBytes(sift.Must(sift.ToValue("abc"))); ok {
+		t.Error("AsBytes(string): got true; want false")
+	}
+	if n, ok := sift.Length(b); !ok || n != 3 {
+		t.Errorf("Length: got %d, %v; want 3, true", n, ok)
+	}
+	if sift.Truthy(b) != true || sift.Must(sift.ToValue([]byte{})).Truth() {
+		t.Error("Truth: got wrong result for byte strings")
+	}
+
+	for _, tc := range []struct {
+		desc  string
+		l, r  interface{}
+		equal bool
+	}{
+		{desc: "same", l: []byte("abc"), r: []byte("abc"), equal: true},
+		{desc: "different", l: []byte("abc"), r: []byte("abd")},
+		{desc: "empty", l: []byte{}, r: []byte(nil), equal: true},
+		{desc: "string", l: []byte("abc"), r: "abc"},
+		{desc: "string_reversed", l: "abc", r: []byte("abc")},
+		{desc: "null", l: nil, r: []byte{}},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			l, r := sift.Must(sift.ToValue(tc.l)), sift.Must(sift.ToValue(tc.r))
+			if got := sift.Equal(l, r); got != tc.equal {
+				t.Errorf("Equal(%v, %v) = %v; want %v", l, r, got, tc.equal)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package sift
+
+import "sync"
+
+// Cached returns a value that remembers the results of v's Keys, Attr,
+// Length, and Index methods, so an expensive implementation, like one that
+// makes an RPC or decodes lazily, does the work for each attribute or
+// element at most once. Objects and arrays nested in v are cached the same
+// way when they're accessed. Other values are returned unchanged.
+//
+// Cached values may be used concurrently, even if v may not be. The
+// underlying value must not change after Cached is called.
+func Cached(v Value) Value {
+	switch v := v.(type) {
+	case *cachedAttr, *cachedIndex:
+		return v
+	case Attr:
+		return &cachedAttr{v: v, values: make(map[string]cachedEntry)}
+	case Index:
+		return &cachedIndex{v: v, length: -1}
+	default:
+		return v
+	}
+}
+
+type cachedEntry struct {
+	v  Value
+	ok bool
+}
+
+type cachedAttr struct {
+	v Attr
+
+	mu     sync.Mutex
+	keys   []Value
+	values map[string]cachedEntry
+}
+
+func (a *cachedAttr) Truth() bool { return a.v.Truth() }
+
+func (a *cachedAttr) Keys() []Value {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.keys == nil {
+		a.keys = a.v.Keys()
+		if a.keys == nil {
+			a.keys = []Value{}
+		}
+	}
+	return append([]Value(nil), a.keys...)
+}
+
+func (a *cachedAttr) Attr(key Value) (Value, bool) {
+	k := keyString(key)
+	a.mu.Lock()
+	e, ok := a.values[k]
+	a.mu.Unlock()
+	if ok {
+		return e.v, e.ok
+	}
+
+	// Call the underlying value without holding the lock, so a slow call
+	// doesn't block access to other attributes. Concurrent first accesses
+	// to the same attribute may both call it; the first result is kept.
+	v, ok := a.v.Attr(key)
+	if ok {
+		v = Cached(v)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if e, ok := a.values[k]; ok {
+		return e.v, e.ok
+	}
+	a.values[k] = cachedEntry{v: v, ok: ok}
+	return v, ok
+}
+
+type cachedIndex struct {
+	v Index
+
+	mu     sync.Mutex
+	length int
+	elems  map[int]cachedEntry
+}
+
+func (ix *cachedIndex) Truth() bool { return ix.v.Truth() }
+
+func (ix *cachedIndex) Length() int {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.length < 0 {
+		ix.length = ix.v.Length()
+	}
+	return ix.length
+}
+
+func (ix *cachedIndex) Index(i int) (Value, bool) {
+	ix.mu.Lock()
+	e, ok := ix.elems[i]
+	ix.mu.Unlock()
+	if ok {
+		return e.v, e.ok
+	}
+
+	v, ok := ix.v.Index(i)
+	if ok {
+		v = Cached(v)
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if e, ok := ix.elems[i]; ok {
+		return e.v, e.ok
+	}
+	if ix.elems == nil {
+		ix.elems = make(map[int]cachedEntry)
+	}
+	ix.elems[i] = cachedEntry{v: v, ok: ok}
+	return v, ok
+}
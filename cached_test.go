@@ -0,0 +1,77 @@
+package sift_test
+
+import (
+	"sync"
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+// countingAttr is an object whose attributes are arrays, counting calls to
+// its methods.
+type countingAttr struct {
+	mu                sync.Mutex
+	keys, attr, index int
+}
+
+func (a *countingAttr) Truth() bool { return true }
+
+func (a *countingAttr) Keys() []sift.Value {
+	a.mu.Lock()
+	a.keys++
+	a.mu.Unlock()
+	return []sift.Value{sift.Must(sift.ToValue("a"))}
+}
+
+func (a *countingAttr) Attr(key sift.Value) (sift.Value, bool) {
+	a.mu.Lock()
+	a.attr++
+	a.mu.Unlock()
+	if s, _ := sift.AsString(key); s != "a" {
+		return nil, false
+	}
+	return countingIndex{a}, true
+}
+
+type countingIndex struct{ a *countingAttr }
+
+func (countingIndex) Truth() bool { return true }
+func (countingIndex) Length() int { return 2 }
+
+func (ix countingIndex) Index(i int) (sift.Value, bool) {
+	ix.a.mu.Lock()
+	ix.a.index++
+	ix.a.mu.Unlock()
+	return sift.Must(sift.ToValue(i)), i >= 0 && i < 2
+}
+
+func TestCached(t *testing.T) {
+	src := &countingAttr{}
+	v := sift.Cached(src)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				v.(sift.Attr).Keys()
+				sift.GetStringAttr(v, "missing")
+				e, _ := sift.GetStringAttr(v, "a")
+				sift.GetIntIndex(e, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got, want := encodeJSON(t, v), `{"a":[0,1]}`; got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+
+	// Concurrent first accesses may each call the underlying value, but
+	// later accesses don't.
+	if src.keys > 4 || src.attr > 8 || src.index > 8 {
+		t.Errorf("got %d Keys, %d Attr, %d Index calls; want at most 4, 8, 8", src.keys, src.attr, src.index)
+	}
+	if s := sift.Must(sift.ToValue("x")); sift.Cached(s) != s {
+		t.Error("Cached changed a scalar")
+	}
+}
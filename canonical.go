@@ -0,0 +1,70 @@
+package sift
+
+// Canonical returns middleware that rewrites each value into a canonical
+// form before it's written, so that equal values are written the same way
+// on every run, whatever order their keys were built in. For example,
+// Canonical may be used to produce output that's byte-for-byte reproducible
+// in a build system.
+//
+// In the canonical form, object keys are sorted, as by Compare. Big numbers
+// that a float64 represents exactly are converted to float64, so 1.50 and
+// 1.5 are written alike. Times are converted to UTC. Values nested more
+// deeply than DefaultMaxEqualDepth are written as they are.
+func Canonical() EncoderMiddleware {
+	return func(enc Encoder) Encoder {
+		return middlewareEncoder{
+			next: enc,
+			encode: func(next Encoder, v Value) error {
+				return next.Encode(canonical(v, 0))
+			},
+		}
+	}
+}
+
+// canonical returns v in the form described by Canonical.
+func canonical(v Value, depth int) Value {
+	switch kindOrder(v) {
+	case kindNumber:
+		if _, ok := AsBigNumber(v); ok {
+			f, _ := AsFloat64(v)
+			if fv := floatValue(f); compareNumbers(v, fv) == 0 {
+				return fv
+			}
+		}
+		return v
+	case kindTime:
+		t, _ := AsTime(v)
+		return timeType(t.UTC())
+	case kindArray:
+		if depth > DefaultMaxEqualDepth {
+			return v
+		}
+		ix := v.(Index)
+		c := make(indexType, ix.Length())
+		for i := range c {
+			e, ok := ix.Index(i)
+			if !ok {
+				return v
+			}
+			c[i] = canonical(e, depth+1)
+		}
+		return c
+	}
+
+	a, ok := v.(Attr)
+	if !ok || depth > DefaultMaxEqualDepth {
+		return v
+	}
+	keys := sortedKeys(a)
+	c := &orderedAttrType{values: make(map[string]Value, len(keys))}
+	for _, key := range keys {
+		name, ok := AsString(key)
+		if !ok {
+			return v
+		}
+		if e, ok := a.Attr(key); ok {
+			c.set(name, canonical(e, depth+1))
+		}
+	}
+	return c
+}
@@ -0,0 +1,34 @@
+package sift_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+)
+
+func TestCanonical(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		v    interface{}
+		want string
+	}{
+		{desc: "keys", v: []sift.KeyValue{{Key: "b", Value: 1}, {Key: "a", Value: []sift.KeyValue{{Key: "y", Value: 2}, {Key: "x", Value: 3}}}}, want: `{"a":{"x":3,"y":2},"b":1}`},
+		{desc: "numbers", v: []sift.Value{sift.Must(sift.NewBigNumber("1.50")), sift.Must(sift.NewBigNumber("12345678901234567891"))}, want: `[1.5,12345678901234567891]`},
+		{desc: "time", v: time.Date(2024, 1, 2, 4, 0, 0, 0, time.FixedZone("X", 3600)), want: `"2024-01-02T03:00:00Z"`},
+		{desc: "scalar", v: "b", want: `"b"`},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			w := &strings.Builder{}
+			enc := sift.WrapEncoder(json.NewEncoder(w), sift.Canonical())
+			if err := enc.Encode(sift.Must(sift.ToValue(tc.v))); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(w.String()); got != tc.want {
+				t.Errorf("got %s; want %s", got, tc.want)
+			}
+		})
+	}
+}
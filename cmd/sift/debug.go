@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+	"go.jayconrod.com/sift/filter/jq"
+)
+
+const debugHelp = `Commands:
+	step, s              stop at the next expression
+	next, n              stop at the next expression, skipping those nested
+	                     within the current one
+	continue, c          run until a breakpoint is reached
+	break, b LOC         set a breakpoint; LOC is LINE, LINE:COL, or the
+	                     name of a function, which breaks on calls to it
+	delete, d N          delete breakpoint N
+	breakpoints          list breakpoints
+	print, p             print the current input value
+	vars                 print variables and their values
+	list, l              print the program, marking the current line
+	quit, q              stop debugging
+`
+
+// errQuit is returned by the debugger's trace function when the user
+// quits, which stops evaluation.
+var errQuit = errors.New("quit")
+
+// runDebug implements "sift debug PROGRAM FILE", which evaluates a program
+// with each value in FILE, pausing before expressions so the user can
+// inspect inputs and variables. Commands are read from standard input.
+func runDebug(args []string) error {
+	fs := flag.NewFlagSet("sift debug", flag.ExitOnError)
+	nullSafe := fs.Bool("null-safe", false, "produce null instead of errors when indexing or iterating values of the wrong type")
+	var searchPath stringList
+	fs.Var(&searchPath, "L", "search `dir` for modules named in import and include directives (may be repeated)")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: sift debug [flags] PROGRAM FILE\n\tto run a program that uses the debug builtin, use sift -- PROGRAM")
+	}
+	src, inputFile := fs.Arg(0), fs.Arg(1)
+
+	in, err := os.Open(inputFile)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	d := &debugger{
+		src:  src,
+		in:   bufio.NewScanner(os.Stdin),
+		out:  os.Stdout,
+		mode: stepMode,
+	}
+	opts := jq.CompileOptions{
+		SearchPath: searchPath,
+		NullSafe:   *nullSafe,
+		Trace:      d.trace,
+	}
+	filter, err := jq.CompileWithOptions("program", src, opts)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(in)
+	for {
+		v, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		vs, err := filter(v)
+		if errors.Is(err, errQuit) {
+			return nil
+		} else if err != nil {
+			fmt.Fprintf(d.out, "error: %v\n", err)
+			continue
+		}
+		for _, v := range vs {
+			fmt.Fprint(d.out, "=> ")
+			d.printValue(v)
+		}
+	}
+}
+
+type debugMode int
+
+const (
+	stepMode debugMode = iota
+	nextMode
+	continueMode
+)
+
+// debugger pauses a program before expressions are evaluated and reads
+// commands from the user.
+type debugger struct {
+	src string
+	in  *bufio.Scanner
+	out io.Writer
+
+	mode debugMode
+
+	// nextDepth is the depth of the expression where next was entered.
+	// In nextMode, the debugger stops at the next expression at or below
+	// that depth.
+	nextDepth int
+
+	breakpoints []*breakpoint
+}
+
+// A breakpoint stops the debugger at expressions that start at a location.
+// If col is zero, any expression on the line matches. If name is set, the
+// breakpoint matches calls to functions with that name instead.
+type breakpoint struct {
+	line, col int
+	name      string
+}
+
+func (b *breakpoint) String() string {
+	switch {
+	case b.name != "":
+		return "calls to " + b.name
+	case b.col == 0:
+		return fmt.Sprintf("line %d", b.line)
+	default:
+		return fmt.Sprintf("%d:%d", b.line, b.col)
+	}
+}
+
+func (b *breakpoint) matches(s *jq.Step) bool {
+	if b.name != "" {
+		call, ok := s.Node.(*jq.Call)
+		return ok && call.Name == b.name
+	}
+	return s.Position.Line == b.line && (b.col == 0 || s.Position.Column == b.col)
+}
+
+func parseBreakpoint(loc string) (*breakpoint, error) {
+	if loc == "" {
+		return nil, errors.New("expected location")
+	}
+	if c := loc[0]; c < '0' || c > '9' {
+		return &breakpoint{name: loc}, nil
+	}
+	lineStr, colStr := loc, ""
+	if i := strings.IndexByte(loc, ':'); i >= 0 {
+		lineStr, colStr = loc[:i], loc[i+1:]
+	}
+	b := &breakpoint{}
+	var err error
+	if b.line, err = strconv.Atoi(lineStr); err != nil || b.line <= 0 {
+		return nil, fmt.Errorf("invalid line in %q", loc)
+	}
+	if colStr != "" {
+		if b.col, err = strconv.Atoi(colStr); err != nil || b.col <= 0 {
+			return nil, fmt.Errorf("invalid column in %q", loc)
+		}
+	}
+	return b, nil
+}
+
+// trace is called before each expression in the program is evaluated.
+// It decides whether to stop, and if so, reads commands until the user
+// resumes evaluation.
+func (d *debugger) trace(s *jq.Step) error {
+	if !d.shouldStop(s) {
+		return nil
+	}
+	d.show(s)
+	for {
+		fmt.Fprint(d.out, "(debug) ")
+		if !d.in.Scan() {
+			if err := d.in.Err(); err != nil {
+				return err
+			}
+			return errQuit
+		}
+		fields := strings.Fields(d.in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		cmd, args := fields[0], fields[1:]
+		switch cmd {
+		case "step", "s":
+			d.mode = stepMode
+			return nil
+		case "next", "n":
+			d.mode = nextMode
+			d.nextDepth = s.Depth
+			return nil
+		case "continue", "c":
+			d.mode = continueMode
+			return nil
+		case "break", "b":
+			if len(args) != 1 {
+				fmt.Fprintln(d.out, "usage: break LOC")
+				continue
+			}
+			b, err := parseBreakpoint(args[0])
+			if err != nil {
+				fmt.Fprintln(d.out, err)
+				continue
+			}
+			d.breakpoints = append(d.breakpoints, b)
+			fmt.Fprintf(d.out, "breakpoint %d at %v\n", len(d.breakpoints), b)
+		case "delete", "d":
+			n := 0
+			if len(args) == 1 {
+				n, _ = strconv.Atoi(args[0])
+			}
+			if n <= 0 || n > len(d.breakpoints) || d.breakpoints[n-1] == nil {
+				fmt.Fprintln(d.out, "usage: delete N, where N is a breakpoint number")
+				continue
+			}
+			d.breakpoints[n-1] = nil
+		case "breakpoints":
+			for i, b := range d.breakpoints {
+				if b != nil {
+					fmt.Fprintf(d.out, "%d: %v\n", i+1, b)
+				}
+			}
+		case "print", "p":
+			d.printValue(s.Input)
+		case "vars":
+			d.printVars(s)
+		case "list", "l":
+			d.list(s.Position.Line)
+		case "quit", "q":
+			return errQuit
+		case "help", "h":
+			fmt.Fprint(d.out, debugHelp)
+		default:
+			fmt.Fprintf(d.out, "unknown command %q; type help for a list of commands\n", cmd)
+		}
+	}
+}
+
+func (d *debugger) shouldStop(s *jq.Step) bool {
+	switch {
+	case d.mode == stepMode:
+		return true
+	case d.mode == nextMode && s.Depth <= d.nextDepth:
+		return true
+	}
+	for _, b := range d.breakpoints {
+		if b != nil && b.matches(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// show prints the expression the program stopped at, its input, and the
+// variables in scope.
+func (d *debugger) show(s *jq.Step) {
+	fmt.Fprintf(d.out, "%d:%d: %s\n", s.Position.Line, s.Position.Column, jq.Format(s.Node))
+	fmt.Fprint(d.out, "input: ")
+	d.printValue(s.Input)
+	d.printVars(s)
+}
+
+func (d *debugger) printVars(s *jq.Step) {
+	for _, b := range s.Vars() {
+		fmt.Fprintf(d.out, "$%s: ", b.Name)
+		d.printValue(b.Value)
+	}
+}
+
+func (d *debugger) printValue(v sift.Value) {
+	if err := json.NewEncoder(d.out).Encode(v); err != nil {
+		fmt.Fprintf(d.out, "%v\n", err)
+	}
+}
+
+// list prints the program's source with line numbers, marking line.
+func (d *debugger) list(line int) {
+	for i, text := range strings.Split(d.src, "\n") {
+		marker := " "
+		if i+1 == line {
+			marker = ">"
+		}
+		fmt.Fprintf(d.out, "%s %3d  %s\n", marker, i+1, text)
+	}
+}
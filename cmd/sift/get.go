@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+	"go.jayconrod.com/sift/filter/jq"
+)
+
+// runGet implements "sift get -key FILTER FILE VALUE...", which prints the
+// values in FILE whose key, produced by FILTER, equals each VALUE. FILE
+// must contain one JSON value per line, sorted by key, for example, with
+// "sift -assert-sorted-by FILTER". FILE is binary searched, so lookups in
+// large files read only a small part of them.
+//
+// Each VALUE is parsed as JSON if possible; otherwise, it's a string.
+// Values are printed as JSON, one per line, in file order. If no value has
+// some key, get reports an error after looking up the other keys.
+func runGet(args []string) error {
+	flags := flag.NewFlagSet("sift get", flag.ExitOnError)
+	keyFilter := flags.String("key", "", "jq `filter` producing the key each line of the file is sorted by")
+	flags.Parse(args)
+	if *keyFilter == "" {
+		return fmt.Errorf("sift get: -key is required")
+	}
+	if flags.NArg() < 2 {
+		return fmt.Errorf("sift get: expected a file and at least one key")
+	}
+	key, err := jq.Compile("key", *keyFilter)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	enc := json.NewEncoder(out)
+	newDecoder := func(r io.Reader) sift.Decoder { return json.NewDecoder(r) }
+	var notFound error
+	for _, arg := range flags.Args()[1:] {
+		want := parseKey(arg)
+		vs, err := sift.SearchSorted(f, fi.Size(), newDecoder, key, want)
+		if err != nil {
+			return fmt.Errorf("%s: %w", flags.Arg(0), err)
+		}
+		if len(vs) == 0 && notFound == nil {
+			notFound = fmt.Errorf("%s: no value with key %s", flags.Arg(0), arg)
+		}
+		for _, v := range vs {
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+		}
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	return notFound
+}
+
+// parseKey parses a key given on the command line as JSON, or returns it as
+// a string if it isn't valid JSON.
+func parseKey(arg string) sift.Value {
+	dec := json.NewDecoder(strings.NewReader(arg))
+	if v, err := dec.Decode(); err == nil {
+		if _, err := dec.Decode(); err == io.EOF {
+			return v
+		}
+	}
+	return sift.Must(sift.ToValue(arg))
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"time"
+
+	"go.jayconrod.com/sift"
+)
+
+// progress tracks how much of the input has been processed. It is updated
+// by the decoder wrapper below and by sift.CountValues on the encoder side,
+// and it may be reported at any time from a signal handler.
+type progress struct {
+	start    time.Time
+	decoded  int64
+	encoded  int64
+	stopping int32
+}
+
+func (p *progress) String() string {
+	elapsed := time.Since(p.start).Round(time.Millisecond)
+	return fmt.Sprintf("%d values decoded, %d values encoded, %v elapsed",
+		atomic.LoadInt64(&p.decoded), atomic.LoadInt64(&p.encoded), elapsed)
+}
+
+// stop requests that processing end after the current value. It returns
+// false if a stop was already requested.
+func (p *progress) stop() bool {
+	return atomic.CompareAndSwapInt32(&p.stopping, 0, 1)
+}
+
+// stopped reports whether a stop was requested.
+func (p *progress) stopped() bool {
+	return atomic.LoadInt32(&p.stopping) != 0
+}
+
+// errInterrupted is returned by run when processing stopped early because
+// of an interrupt. main exits with status 130, as shells do for a command
+// killed by SIGINT, so scripts don't mistake the partial output for
+// a complete result.
+var errInterrupted = errors.New("interrupted")
+
+type progressDecoder struct {
+	dec sift.Decoder
+	p   *progress
+}
+
+// Decode returns io.EOF once a stop is requested, so sift.Sift returns
+// normally and flushes the encoder instead of truncating output. A value
+// decoded after the request, while Decode was blocked reading input, is
+// dropped instead of being filtered and emitted.
+func (d progressDecoder) Decode() (sift.Value, error) {
+	if d.p.stopped() {
+		return nil, io.EOF
+	}
+	v, err := d.dec.Decode()
+	if d.p.stopped() {
+		return nil, io.EOF
+	}
+	if err == nil {
+		atomic.AddInt64(&d.p.decoded, 1)
+	}
+	return v, err
+}
+
+func (d progressDecoder) ByteCount() int64 {
+	if bc, ok := d.dec.(sift.ByteCounter); ok {
+		return bc.ByteCount()
+	}
+	return 0
+}
+
+// handleSignals reports progress on standard error when a status signal
+// (SIGUSR1, where supported) is received. When an interrupt is received,
+// it requests that processing stop after the current value, so encoders
+// can be flushed; run then returns errInterrupted. A second interrupt exits
+// immediately. The returned
+// function stops signal handling.
+func handleSignals(p *progress) (stop func()) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, append(statusSignals, os.Interrupt)...)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-c:
+				if sig != os.Interrupt {
+					fmt.Fprintf(os.Stderr, "sift: %v\n", p)
+				} else if p.stop() {
+					fmt.Fprintf(os.Stderr, "sift: interrupted; stopping after current value (%v)\n", p)
+				} else {
+					os.Exit(130)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(c)
+		close(done)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.jayconrod.com/sift/encoding/json"
+	"go.jayconrod.com/sift/filter/jq"
+	"go.jayconrod.com/sift/filter/jq/repl"
+)
+
+const replHelp = `Enter a jq program to evaluate it with the current input. Commands:
+	:input    print the current input
+	:keep     make the last program's output the current input
+	:next     read the next value from the input file
+	:quit     exit
+`
+
+// runRepl implements "sift repl FILE", which evaluates programs typed on
+// standard input against values read from FILE.
+func runRepl(args []string) error {
+	fs := flag.NewFlagSet("sift repl", flag.ExitOnError)
+	var searchPath stringList
+	fs.Var(&searchPath, "L", "search `dir` for modules named in import and include directives (may be repeated)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: sift repl [flags] FILE")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	s := repl.NewSession(jq.CompileOptions{SearchPath: searchPath})
+	if err := s.ReadInput(dec); err != nil && err != io.EOF {
+		return err
+	}
+
+	out := os.Stdout
+	enc := json.NewEncoder(out)
+	in := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Fprint(out, "> ")
+		if !in.Scan() {
+			fmt.Fprintln(out)
+			return in.Err()
+		}
+		line := strings.TrimSpace(in.Text())
+		switch line {
+		case "":
+			continue
+		case ":quit", ":q":
+			return nil
+		case ":help", ":h":
+			fmt.Fprint(out, replHelp)
+		case ":input":
+			if v, ok := s.Input(); ok {
+				enc.Encode(v)
+			} else {
+				fmt.Fprintln(out, "no input")
+			}
+		case ":keep":
+			if err := s.Keep(); err != nil {
+				fmt.Fprintln(out, err)
+			}
+		case ":next":
+			if err := s.ReadInput(dec); err == io.EOF {
+				fmt.Fprintln(out, "no more input")
+			} else if err != nil {
+				return err
+			}
+		default:
+			r := s.Eval(line)
+			for _, e := range r.SyntaxErrors {
+				fmt.Fprintln(out, e)
+			}
+			if r.Err != nil {
+				fmt.Fprintln(out, r.Err)
+			}
+			for _, v := range r.Values {
+				enc.Encode(v)
+			}
+		}
+	}
+}
@@ -1,13 +1,23 @@
 package main
 
 import (
+	"bufio"
+	stdjson "encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/charset"
 	"go.jayconrod.com/sift/encoding/json"
+	"go.jayconrod.com/sift/encoding/jsonseq"
 	"go.jayconrod.com/sift/filter/jq"
 )
 
@@ -15,24 +25,329 @@ func main() {
 	log.SetPrefix("sift: ")
 	log.SetFlags(0)
 	if err := run(os.Args[1:]); err != nil {
+		if err == errInterrupted {
+			os.Exit(130)
+		}
+		var errs jq.ErrorList
+		if errors.As(err, &errs) {
+			for _, e := range errs {
+				log.Print(e)
+			}
+			os.Exit(1)
+		}
+		var inputErrs sift.InputErrors
+		if errors.As(err, &inputErrs) {
+			for _, e := range inputErrs {
+				log.Print(e)
+			}
+			os.Exit(1)
+		}
 		log.Fatal(err)
 	}
 }
 
+// subcommand returns the name of the subcommand args run, or "" if they
+// run a program. Only a first argument of exactly "debug", "get", "repl",
+// or "test" names a subcommand. A program that's spelled the same, like
+// the debug builtin, is run after "--" or any flag, as in "sift -- debug".
+func subcommand(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	switch args[0] {
+	case "debug", "get", "repl", "test":
+		return args[0]
+	}
+	return ""
+}
+
+// sortMaxValues is the number of values -sort-by holds in memory before
+// spilling them to a temporary file.
+const sortMaxValues = 1 << 20
+
 func run(args []string) error {
+	switch subcommand(args) {
+	case "debug":
+		return runDebug(args[1:])
+	case "get":
+		return runGet(args[1:])
+	case "repl":
+		return runRepl(args[1:])
+	case "test":
+		return runTest(args[1:])
+	}
+
 	fs := flag.NewFlagSet("sift", flag.ExitOnError)
+	batch := fs.Int("batch", 0, "group output values into arrays of up to `n` values")
+	deterministic := fs.Bool("deterministic", false, "produce reproducible output: sort object keys, write numbers and times in a canonical form, and make now return the time in SOURCE_DATE_EPOCH, or 0 if unset")
+	profile := fs.String("profile", "full", "restrict the program to a language `profile`: full, no-io, or pure")
+	cover := fs.Bool("cover", false, "report which expressions in the program were not evaluated on standard error")
+	maxDepth := fs.Int("max-depth", 10000, "fail if the program makes more than `n` nested function calls, not counting tail calls; 0 means no limit")
+	nullSafe := fs.Bool("null-safe", false, "produce null instead of errors when indexing or iterating values of the wrong type")
+	integrity := fs.Bool("integrity", false, "write output as a JSON text sequence with per-record and stream checksums")
+	verify := fs.Bool("verify", false, "read input as a JSON text sequence with checksums, and fail if it's corrupt or truncated")
+	sortBy := fs.String("sort-by", "", "write output values sorted by the key jq `filter` produces for each value")
+	sortedBy := fs.String("assert-sorted-by", "", "fail if output values are not sorted by the key jq `filter` produces for each value")
+	uniqueBy := fs.String("assert-unique", "", "fail if two output values have the same key, produced by the jq `filter`")
+	recordTypes := fs.String("record-types", "", "write a profile of the types of fields in output values to `file`")
+	checkTypes := fs.String("check-types", "", "warn when the types of fields in output values drift from the profile in `file`")
+	driftThreshold := fs.Float64("drift-threshold", 0.1, "with -check-types, the largest allowed change in the `fraction` of values where a field has a type")
+	driftFail := fs.Bool("drift-fail", false, "with -check-types, fail instead of warning when types drift")
+	slurp := fs.Bool("slurp", false, "read all input values into an array and run the program once with the array as input")
+	keepGoing := fs.Bool("keep-going", false, "skip input values that can't be decoded or filtered, and report the errors at the end")
+	maxErrors := fs.Int("max-errors", 0, "with -keep-going, stop after skipping `n` errors; 0 means no limit")
+	maxRate := fs.Float64("max-rate", 0, "write at most `n` values per second; 0 means no limit")
+	stats := fs.Bool("stats", false, "report the number of values and bytes read and written on standard error when done")
+	inputEncoding := fs.String("input-encoding", "auto", "character `encoding` of the input; one of "+strings.Join(charset.Names(), ", "))
+	var searchPath stringList
+	fs.Var(&searchPath, "L", "search `dir` for modules named in import and include directives (may be repeated)")
+	vars := make(map[string]sift.Value)
+	fs.Func("arg", "bind `name=value` as the string variable $name (may be repeated)", func(arg string) error {
+		name, value, err := splitVar(arg)
+		if err != nil {
+			return err
+		}
+		vars[name] = sift.Must(sift.ToValue(value))
+		return nil
+	})
+	fs.Func("argjson", "bind `name=json` as the variable $name with a JSON value (may be repeated)", func(arg string) error {
+		name, text, err := splitVar(arg)
+		if err != nil {
+			return err
+		}
+		value, err := json.NewDecoder(strings.NewReader(text)).Decode()
+		if err != nil {
+			return fmt.Errorf("invalid JSON for $%s: %v", name, err)
+		}
+		vars[name] = value
+		return nil
+	})
 	fs.Parse(args)
-	if fs.NArg() != 1 {
-		return fmt.Errorf("expected exactly 1 argument; got %d", fs.NArg())
+	if fs.NArg() < 1 {
+		return fmt.Errorf("usage: sift [flags] [--] PROGRAM [FILE...]")
+	}
+
+	p := &progress{start: time.Now()}
+	stopSignals := handleSignals(p)
+	defer stopSignals()
+
+	// Values are read from each file in turn, or from standard input if
+	// there are no files.
+	inputs := []io.Reader{os.Stdin}
+	if files := fs.Args()[1:]; len(files) > 0 {
+		inputs = inputs[:0]
+		for _, name := range files {
+			f, err := os.Open(name)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			inputs = append(inputs, f)
+		}
+	}
+	decs := make([]sift.Decoder, len(inputs))
+	for i, r := range inputs {
+		in, err := charset.NewReader(r, *inputEncoding)
+		if err != nil {
+			return err
+		}
+		if *verify {
+			decs[i] = jsonseq.NewDecoder(in, jsonseq.Options{Integrity: true})
+		} else {
+			decs[i] = json.NewDecoder(in)
+		}
+	}
+	out := bufio.NewWriter(os.Stdout)
+	dec := sift.ChainDecoders(decs...)
+	dec = progressDecoder{dec: dec, p: p}
+	var enc sift.Encoder
+	if *integrity {
+		enc = jsonseq.NewEncoder(out, jsonseq.Options{Integrity: true})
+	} else {
+		enc = json.NewEncoder(out)
+	}
+	if *batch > 0 {
+		enc = sift.NewBatchEncoder(enc, sift.BatchOptions{Count: *batch})
+	}
+	var mw []sift.EncoderMiddleware
+	if *deterministic {
+		mw = append(mw, sift.Canonical())
+	}
+	if *sortBy != "" {
+		key, err := jq.Compile("sort-by", *sortBy)
+		if err != nil {
+			return err
+		}
+		mw = append(mw, sift.SortStream(key, sift.SortStreamOptions{
+			MaxValues:  sortMaxValues,
+			NewEncoder: func(w io.Writer) sift.Encoder { return json.NewEncoder(w) },
+			NewDecoder: func(r io.Reader) sift.Decoder { return json.NewDecoder(r) },
+		}))
+	}
+	if *sortedBy != "" {
+		key, err := jq.Compile("assert-sorted-by", *sortedBy)
+		if err != nil {
+			return err
+		}
+		mw = append(mw, sift.AssertSortedBy(key))
+	}
+	if *uniqueBy != "" {
+		key, err := jq.Compile("assert-unique", *uniqueBy)
+		if err != nil {
+			return err
+		}
+		mw = append(mw, sift.AssertUnique(key))
+	}
+	if *checkTypes != "" {
+		profile, err := readTypeProfile(*checkTypes)
+		if err != nil {
+			return err
+		}
+		opts := sift.DriftOptions{Threshold: *driftThreshold}
+		if !*driftFail {
+			opts.Warn = func(e *sift.DriftError) { log.Printf("warning: %v", e) }
+		}
+		mw = append(mw, sift.DetectDrift(profile, opts))
+	}
+	var types *sift.TypeProfile
+	if *recordTypes != "" {
+		types = sift.NewTypeProfile()
+		mw = append(mw, sift.RecordTypes(types))
+	}
+	mw = append(mw, sift.CountValues(&p.encoded))
+	enc = sift.WrapEncoder(enc, mw...)
+
+	opts := jq.CompileOptions{
+		SearchPath: searchPath,
+		NullSafe:   *nullSafe,
+		MaxDepth:   *maxDepth,
+		Vars:       vars,
+		Input:      dec,
+	}
+	var err error
+	if opts.Profile, err = jq.ParseProfile(*profile); err != nil {
+		return err
+	}
+	if *cover {
+		opts.Coverage = jq.NewCoverage()
+	}
+	if *deterministic {
+		epoch, err := sourceDateEpoch()
+		if err != nil {
+			return err
+		}
+		opts.Now = func() time.Time { return epoch }
+	}
+	prog, err := jq.CompileProgram("command-line", fs.Arg(0), opts)
+	if err != nil {
+		return err
 	}
 
-	dec := json.NewDecoder(os.Stdin)
-	enc := json.NewEncoder(os.Stdout)
+	var siftErr error
+	if *slurp {
+		f := sift.ComposeStream(sift.Slurp(), sift.Stateless(prog.Filter(nil)))
+		siftErr = sift.SiftStream(dec, f, enc)
+	} else if *keepGoing || *stats || *maxRate > 0 {
+		var st sift.SiftStats
+		opts := sift.SiftOptions{
+			ContinueOnError: *keepGoing,
+			MaxErrors:       *maxErrors,
+			ValuesPerSecond: *maxRate,
+			Stats:           &st,
+		}
+		siftErr = sift.SiftWithOptions(dec, prog.Filter(nil), enc, opts)
+		if *stats {
+			fmt.Fprintf(os.Stderr, "sift: %d values decoded, %d values emitted, %d bytes read, %d bytes written, %d errors skipped, %v elapsed\n",
+				st.Decoded, st.Emitted, st.BytesRead, st.BytesWritten, st.Skipped, st.Elapsed.Round(time.Millisecond))
+		}
+	} else {
+		siftErr = sift.SiftEmit(dec, prog.Emit(nil), enc)
+	}
+	var aerr *sift.AssertionError
+	if errors.As(siftErr, &aerr) {
+		siftErr = fmt.Errorf("%w\n\tvalue: %s", siftErr, valueJSON(aerr.Value))
+	}
+	if err := out.Flush(); err != nil && siftErr == nil {
+		return err
+	}
+	if opts.Coverage != nil {
+		if err := opts.Coverage.WriteReport(os.Stderr); err != nil && siftErr == nil {
+			return err
+		}
+	}
+	if types != nil && siftErr == nil {
+		if err := writeTypeProfile(*recordTypes, types); err != nil {
+			return err
+		}
+	}
+	if siftErr == nil && p.stopped() {
+		return errInterrupted
+	}
+	return siftErr
+}
 
-	filter, err := jq.Compile("command-line", fs.Arg(0))
+// readTypeProfile reads a profile written with -record-types.
+func readTypeProfile(file string) (*sift.TypeProfile, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	profile := sift.NewTypeProfile()
+	if err := stdjson.Unmarshal(data, profile); err != nil {
+		return nil, fmt.Errorf("reading type profile %s: %v", file, err)
+	}
+	return profile, nil
+}
+
+func writeTypeProfile(file string, profile *sift.TypeProfile) error {
+	data, err := stdjson.MarshalIndent(profile, "", "  ")
 	if err != nil {
 		return err
 	}
+	return ioutil.WriteFile(file, append(data, '\n'), 0666)
+}
+
+// valueJSON returns v as compact JSON text, for error messages.
+func valueJSON(v sift.Value) string {
+	b := &strings.Builder{}
+	if err := json.NewEncoder(b).Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// sourceDateEpoch returns the time set by the SOURCE_DATE_EPOCH environment
+// variable, used by reproducible build systems. If the variable is not set,
+// the Unix epoch is returned.
+func sourceDateEpoch() (time.Time, error) {
+	s := os.Getenv("SOURCE_DATE_EPOCH")
+	if s == "" {
+		return time.Unix(0, 0), nil
+	}
+	secs, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH: %v", err)
+	}
+	return time.Unix(secs, 0), nil
+}
+
+// splitVar splits a variable flag argument like "name=value".
+func splitVar(arg string) (name, value string, err error) {
+	i := strings.IndexByte(arg, '=')
+	if i < 0 {
+		return "", "", fmt.Errorf("expected name=value; got %q", arg)
+	}
+	return arg[:i], arg[i+1:], nil
+}
+
+// stringList is a flag.Value that accumulates strings from repeated flags.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
 
-	return sift.Sift(dec, filter, enc)
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
 }
@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestSubcommand(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{args: nil, want: ""},
+		{args: []string{"."}, want: ""},
+		{args: []string{"debug", "prog.jq", "in.json"}, want: "debug"},
+		{args: []string{"test", "tests.jq"}, want: "test"},
+		{args: []string{"--", "debug"}, want: ""},
+		{args: []string{"-null-safe", "debug"}, want: ""},
+		{args: []string{"debug | .a"}, want: ""},
+	} {
+		if got := subcommand(tc.args); got != tc.want {
+			t.Errorf("subcommand(%q) = %q; want %q", tc.args, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package main
+
+import "os"
+
+// statusSignals are signals that cause progress to be reported. There are
+// none on this platform.
+var statusSignals []os.Signal
@@ -0,0 +1,12 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// statusSignals are signals that cause progress to be reported.
+var statusSignals = []os.Signal{syscall.SIGUSR1}
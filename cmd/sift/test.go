@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.jayconrod.com/sift/encoding/json"
+	"go.jayconrod.com/sift/filter/jq"
+)
+
+// runTest implements "sift test DIR...", which runs golden tests for jq
+// programs. Each test is a program NAME.jq with an input file NAME.in.json
+// and an expected output file NAME.out.json in the same directory.
+// Directories are searched recursively. Programs without an input file are
+// modules, which tests may import; the directory containing a test is on
+// its module search path.
+//
+// The program is evaluated with each value in the input file. Outputs are
+// written as JSON, one value per line. If evaluation fails, the line
+// "error: " followed by the message is written, and evaluation stops, so
+// tests may expect errors. With -update, the output files are rewritten
+// instead of compared.
+func runTest(args []string) error {
+	flags := flag.NewFlagSet("sift test", flag.ExitOnError)
+	update := flags.Bool("update", false, "write actual outputs to NAME.out.json files instead of comparing")
+	verbose := flags.Bool("v", false, "print the name of each test as it runs")
+	flags.Parse(args)
+	dirs := flags.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+
+	var tests []string
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(path, ".jq") {
+				return nil
+			}
+			if _, err := os.Stat(testFile(path, ".in.json")); err == nil {
+				tests = append(tests, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(tests) == 0 {
+		return fmt.Errorf("no tests found in %s", strings.Join(dirs, ", "))
+	}
+	sort.Strings(tests)
+
+	failed := 0
+	for _, test := range tests {
+		if *verbose {
+			fmt.Printf("=== RUN %s\n", test)
+		}
+		got, err := runGolden(test)
+		if err != nil {
+			return err
+		}
+		outFile := testFile(test, ".out.json")
+		if *update {
+			if err := ioutil.WriteFile(outFile, got, 0666); err != nil {
+				return err
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(outFile)
+		if errors.Is(err, fs.ErrNotExist) {
+			want = nil
+		} else if err != nil {
+			return err
+		}
+		if !bytes.Equal(want, got) {
+			failed++
+			fmt.Printf("--- FAIL: %s\n", test)
+			fmt.Print(diffLines(splitLines(want), splitLines(got)))
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tests failed", failed, len(tests))
+	}
+	if !*update {
+		fmt.Printf("ok: %d tests passed\n", len(tests))
+	}
+	return nil
+}
+
+// testFile returns the name of a file belonging to the test whose program
+// is in file program.
+func testFile(program, suffix string) string {
+	return strings.TrimSuffix(program, ".jq") + suffix
+}
+
+// runGolden evaluates a test's program with its inputs and returns the
+// output as it would appear in the test's output file. The returned error
+// is non-nil only if the test's files cannot be read.
+func runGolden(program string) ([]byte, error) {
+	src, err := ioutil.ReadFile(program)
+	if err != nil {
+		return nil, err
+	}
+	in, err := os.Open(testFile(program, ".in.json"))
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	out := &bytes.Buffer{}
+	opts := jq.CompileOptions{SearchPath: []string{filepath.Dir(program)}}
+	filter, err := jq.CompileWithOptions(program, string(src), opts)
+	if err != nil {
+		fmt.Fprintf(out, "error: %v\n", err)
+		return out.Bytes(), nil
+	}
+	dec := json.NewDecoder(in)
+	enc := json.NewEncoder(out)
+	for {
+		v, err := dec.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %v", testFile(program, ".in.json"), err)
+		}
+		vs, err := filter(v)
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+			break
+		}
+		for _, v := range vs {
+			if err := enc.Encode(v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out.Bytes(), nil
+}
+
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// diffLines returns a line-by-line diff between want and got. Lines only
+// in want are prefixed with "-", and lines only in got are prefixed with "+".
+func diffLines(want, got []string) string {
+	// lcs[i][j] is the length of the longest common subsequence of
+	// want[i:] and got[j:].
+	lcs := make([][]int, len(want)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(got)+1)
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		for j := len(got) - 1; j >= 0; j-- {
+			if want[i] == got[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	b := &strings.Builder{}
+	line := func(prefix, text string) {
+		b.WriteString(prefix)
+		b.WriteString(strings.TrimSuffix(text, "\n"))
+		b.WriteString("\n")
+	}
+	i, j := 0, 0
+	for i < len(want) || j < len(got) {
+		switch {
+		case i < len(want) && j < len(got) && want[i] == got[j]:
+			line("  ", want[i])
+			i++
+			j++
+		case j == len(got) || i < len(want) && lcs[i+1][j] >= lcs[i][j+1]:
+			line("- ", want[i])
+			i++
+		default:
+			line("+ ", got[j])
+			j++
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,136 @@
+package sift
+
+import "sort"
+
+// Kinds of values, in the order values of each kind sort. Apart from times,
+// which jq doesn't have, this is jq's ordering of values.
+const (
+	kindNull = iota
+	kindFalse
+	kindTrue
+	kindNumber
+	kindTime
+	kindString
+	kindArray
+	kindObject
+)
+
+// kindOrder returns the kind of v, which is its rank in the ordering of
+// values. Byte strings have the same kind as strings.
+func kindOrder(v Value) int {
+	if IsNull(v) {
+		return kindNull
+	} else if b, ok := AsBool(v); ok {
+		if b {
+			return kindTrue
+		}
+		return kindFalse
+	} else if _, ok := AsFloat64(v); ok {
+		return kindNumber
+	} else if _, ok := AsTime(v); ok {
+		return kindTime
+	} else if _, ok := AsString(v); ok {
+		return kindString
+	} else if _, ok := AsBytes(v); ok {
+		return kindString
+	} else if _, ok := v.(Index); ok {
+		return kindArray
+	}
+	return kindObject
+}
+
+// Compare returns -1, 0, or 1 depending on whether l sorts before, with, or
+// after r. Compare defines a total order on values that matches jq's:
+//
+//   - Values of different kinds are ordered by kind: null, false, true,
+//     numbers, times, strings, arrays, then objects. jq has no times; they
+//     sort between numbers and strings. Byte strings sort with strings.
+//   - Numbers are ordered numerically. NaN sorts before all other numbers.
+//     A BigNumber is compared exactly with other numbers.
+//   - Times are ordered chronologically.
+//   - Strings and byte strings are ordered by their bytes. A string sorts
+//     before a byte string with the same bytes.
+//   - Arrays are compared element by element. Missing elements compare as
+//     null. If one is a prefix of the other, the shorter array sorts first.
+//   - Objects are compared by their sorted keys, then by their values in
+//     key order.
+//
+// Values that are Equal compare as 0. Compare may be used to sort values
+// and to find minimum and maximum values.
+func Compare(l, r Value) int {
+	lk, rk := kindOrder(l), kindOrder(r)
+	if lk != rk {
+		return compareInts(lk, rk)
+	}
+	switch lk {
+	case kindNumber:
+		return compareNumbers(l, r)
+	case kindTime:
+		lt, _ := AsTime(l)
+		rt, _ := AsTime(r)
+		switch {
+		case lt.Before(rt):
+			return -1
+		case lt.After(rt):
+			return 1
+		}
+		return 0
+	case kindString:
+		return compareStrings(l, r)
+	case kindArray:
+		li, ri := l.(Index), r.(Index)
+		for i := 0; i < li.Length() && i < ri.Length(); i++ {
+			lv, lok := li.Index(i)
+			rv, rok := ri.Index(i)
+			if !lok {
+				lv = NullValue
+			}
+			if !rok {
+				rv = NullValue
+			}
+			if c := Compare(lv, rv); c != 0 {
+				return c
+			}
+		}
+		return compareInts(li.Length(), ri.Length())
+	case kindObject:
+		la, lok := l.(Attr)
+		ra, rok := r.(Attr)
+		if !lok || !rok {
+			return 0
+		}
+		lkeys, rkeys := sortedKeys(la), sortedKeys(ra)
+		for i := 0; i < len(lkeys) && i < len(rkeys); i++ {
+			if c := Compare(lkeys[i], rkeys[i]); c != 0 {
+				return c
+			}
+		}
+		if c := compareInts(len(lkeys), len(rkeys)); c != 0 {
+			return c
+		}
+		for _, key := range lkeys {
+			lv, _ := la.Attr(key)
+			rv, _ := ra.Attr(key)
+			if c := Compare(lv, rv); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
+
+func compareInts(l, r int) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	}
+	return 0
+}
+
+func sortedKeys(a Attr) []Value {
+	keys := append([]Value(nil), a.Keys()...)
+	sort.Slice(keys, func(i, j int) bool { return Compare(keys[i], keys[j]) < 0 })
+	return keys
+}
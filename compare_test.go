@@ -0,0 +1,81 @@
+package sift_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestCompare(t *testing.T) {
+	t0 := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	big := sift.Must(sift.NewBigNumber("12345678901234567891"))
+	bigger := sift.Must(sift.NewBigNumber("12345678901234567892"))
+
+	// Each value sorts after the one before it.
+	ordered := []interface{}{
+		nil,
+		false,
+		true,
+		math.NaN(),
+		math.Inf(-1),
+		-1,
+		0,
+		1.5,
+		big,
+		bigger,
+		math.Inf(1),
+		t0,
+		t0.Add(time.Nanosecond),
+		"",
+		"a",
+		[]byte("a"),
+		"ab",
+		[]byte("b"),
+		[]interface{}{},
+		[]interface{}{nil},
+		[]interface{}{1},
+		[]interface{}{1, 2},
+		[]interface{}{2},
+		map[string]interface{}{},
+		map[string]interface{}{"a": 2},
+		map[string]interface{}{"a": 1, "b": 1},
+		map[string]interface{}{"a": 2, "b": 1},
+		map[string]interface{}{"b": 0},
+	}
+	values := make([]sift.Value, len(ordered))
+	for i, o := range ordered {
+		values[i] = sift.Must(sift.ToValue(o))
+	}
+	for i, l := range values {
+		for j, r := range values {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if got := sift.Compare(l, r); got != want {
+				t.Errorf("Compare(%v, %v) = %d; want %d", l, r, got, want)
+			}
+		}
+	}
+
+	for _, tc := range []struct {
+		desc string
+		l, r interface{}
+	}{
+		{desc: "big_float", l: sift.Must(sift.NewBigNumber("1.50")), r: 1.5},
+		{desc: "time_zone", l: t0, r: t0.In(time.FixedZone("X", 3600))},
+		{desc: "nan", l: math.NaN(), r: math.NaN()},
+		{desc: "object_order", l: map[string]interface{}{"a": 1, "b": 2}, r: map[string]sift.Value{"b": sift.Must(sift.ToValue(2)), "a": sift.Must(sift.ToValue(1))}},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			l, r := sift.Must(sift.ToValue(tc.l)), sift.Must(sift.ToValue(tc.r))
+			if got := sift.Compare(l, r); got != 0 {
+				t.Errorf("Compare(%v, %v) = %d; want 0", l, r, got)
+			}
+		})
+	}
+}
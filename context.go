@@ -0,0 +1,65 @@
+package sift
+
+import (
+	"context"
+	"io"
+)
+
+// A FilterCtx is like a Filter, but it also receives a context. Filters
+// that may run for a long time, or that call out to other services, should
+// stop and return the context's error when the context is canceled or its
+// deadline passes.
+type FilterCtx func(ctx context.Context, v Value) ([]Value, error)
+
+// WithContext returns a FilterCtx that applies f. f can't observe the
+// context, but the returned filter returns the context's error instead of
+// applying f if the context is already done.
+func WithContext(f Filter) FilterCtx {
+	return func(ctx context.Context, v Value) ([]Value, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return f(v)
+	}
+}
+
+// BindContext returns a Filter that applies f with ctx. This lets
+// a FilterCtx be used with functions that accept a Filter, like Compose.
+func BindContext(ctx context.Context, f FilterCtx) Filter {
+	return func(v Value) ([]Value, error) {
+		return f(ctx, v)
+	}
+}
+
+// SiftContext is like Sift, but it applies a FilterCtx with ctx. SiftContext
+// checks ctx before reading each value and before writing each result;
+// if ctx is done, SiftContext stops and returns the context's error without
+// flushing enc.
+func SiftContext(ctx context.Context, dec Decoder, f FilterCtx, enc Encoder) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		vin, err := dec.Decode()
+		if err == io.EOF {
+			if fl, ok := enc.(Flusher); ok {
+				return fl.Flush()
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+		vouts, err := f(ctx, vin)
+		if err != nil {
+			return err
+		}
+		for _, vout := range vouts {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := enc.Encode(vout); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package sift_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestSiftContext(t *testing.T) {
+	input := sift.Must(sift.ToValue([]interface{}{1, 2, 3}))
+
+	t.Run("complete", func(t *testing.T) {
+		dec, _ := sift.Iterate(input)
+		enc := &recordEncoder{}
+		f := sift.WithContext(sift.Map(func(v sift.Value) sift.Value { return v }))
+		if err := sift.SiftContext(context.Background(), dec, f, enc); err != nil {
+			t.Fatal(err)
+		}
+		if got := sift.Must(sift.ToValue(enc.values)); !sift.Equal(got, input) {
+			t.Errorf("got %v; want %v", got, input)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		dec, _ := sift.Iterate(input)
+		enc := &recordEncoder{}
+		f := func(ctx context.Context, v sift.Value) ([]sift.Value, error) {
+			if n, _ := sift.AsFloat64(v); n == 2 {
+				cancel()
+			}
+			return []sift.Value{v}, nil
+		}
+		err := sift.SiftContext(ctx, dec, f, enc)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("got error %v; want %v", err, context.Canceled)
+		}
+		if len(enc.values) != 1 {
+			t.Errorf("got %d values written; want 1", len(enc.values))
+		}
+	})
+
+	t.Run("bound", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		f := sift.BindContext(ctx, sift.WithContext(sift.Literal(sift.NullValue)))
+		if _, err := f(sift.NullValue); !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v; want %v", err, context.Canceled)
+		}
+	})
+}
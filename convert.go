@@ -0,0 +1,45 @@
+package sift
+
+import "sync"
+
+// A Valuer is implemented by Go types that convert themselves to values.
+// ToValue calls SiftValue instead of converting the type itself.
+type Valuer interface {
+	SiftValue() (Value, error)
+}
+
+// A Converter converts Go values of types that ToValue doesn't handle
+// itself, like types defined in other packages. If the converter doesn't
+// handle x, it returns false.
+type Converter func(x interface{}) (v Value, ok bool, err error)
+
+var converters struct {
+	mu   sync.RWMutex
+	list []Converter
+}
+
+// RegisterConverter adds a converter used by ToValue. Converters are only
+// called for values that don't implement Value or Valuer and that have
+// types ToValue doesn't convert directly, like time.Duration, uuid.UUID,
+// or structs. Converters are called in the order they were registered,
+// until one returns true.
+//
+// RegisterConverter is normally called in an init function.
+func RegisterConverter(c Converter) {
+	converters.mu.Lock()
+	defer converters.mu.Unlock()
+	converters.list = append(converters.list, c)
+}
+
+// convert calls registered converters on x.
+func convert(x interface{}) (Value, bool, error) {
+	converters.mu.RLock()
+	list := converters.list
+	converters.mu.RUnlock()
+	for _, c := range list {
+		if v, ok, err := c(x); ok || err != nil {
+			return v, true, err
+		}
+	}
+	return nil, false, nil
+}
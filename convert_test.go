@@ -0,0 +1,68 @@
+package sift_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"go.jayconrod.com/sift"
+)
+
+type celsius float64
+
+func (c celsius) SiftValue() (sift.Value, error) {
+	return sift.ToValue(fmt.Sprintf("%gC", float64(c)))
+}
+
+type badValuer struct{}
+
+func (badValuer) SiftValue() (sift.Value, error) {
+	return nil, fmt.Errorf("bad valuer")
+}
+
+func init() {
+	sift.RegisterConverter(func(x interface{}) (sift.Value, bool, error) {
+		d, ok := x.(time.Duration)
+		if !ok {
+			return nil, false, nil
+		}
+		if d < 0 {
+			return nil, true, fmt.Errorf("negative duration")
+		}
+		v, err := sift.ToValue(d.String())
+		return v, true, err
+	})
+}
+
+func TestConverter(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		v       interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{desc: "valuer", v: celsius(21.5), want: "21.5C"},
+		{desc: "valuer_nested", v: []celsius{1}, want: []interface{}{"1C"}},
+		{desc: "valuer_error", v: badValuer{}, wantErr: true},
+		{desc: "converter", v: time.Minute, want: "1m0s"},
+		{desc: "converter_field", v: struct{ D time.Duration }{time.Second}, want: map[string]interface{}{"D": "1s"}},
+		{desc: "converter_error", v: -time.Second, wantErr: true},
+		{desc: "not_converted", v: int64(5), want: 5},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := sift.ToValue(tc.v)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("got %v; want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := sift.Must(sift.ToValue(tc.want)); !sift.Equal(got, want) {
+				t.Errorf("got %v; want %v", got, want)
+			}
+		})
+	}
+}
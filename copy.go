@@ -0,0 +1,107 @@
+package sift
+
+// Copy returns a deep copy of v made of this package's default value types.
+// The copy doesn't share memory with v, so it remains valid after v's
+// underlying storage is reused, for example, by a decoder reading the next
+// value. The caller owns the copy, so it may be updated in place with
+// WithAttr, WithoutAttr, and WithIndex.
+//
+// Objects keep the order of their keys. Missing array elements stay
+// missing. Values Copy can't represent with the default types, like objects
+// with keys that aren't strings, iterators that don't implement Index, and
+// values of unknown kinds, are not copied; they're shared by v and the copy.
+//
+// An object or array that appears more than once in v, including one that
+// contains itself, is copied once, and the copy appears in the same places.
+// Values nested more deeply than DefaultMaxEqualDepth are shared, not
+// copied.
+func Copy(v Value) Value {
+	var c copier
+	return c.copy(v, 0)
+}
+
+// copier records the copies of objects and arrays made by Copy, so each is
+// copied once.
+type copier struct {
+	copies map[keyVisit]Value
+}
+
+// record records that cv is the copy of the value with key k.
+func (c *copier) record(k keyVisit, cv Value) {
+	if c.copies == nil {
+		c.copies = make(map[keyVisit]Value)
+	}
+	c.copies[k] = cv
+}
+
+func (c *copier) copy(v Value, depth int) Value {
+	kind := kindOrder(v)
+	switch kind {
+	case kindNull:
+		return NullValue
+	case kindFalse:
+		return boolValue(false)
+	case kindTrue:
+		return boolValue(true)
+	case kindNumber:
+		if text, ok := AsBigNumber(v); ok {
+			if n, err := NewBigNumber(text); err == nil {
+				return n
+			}
+		}
+		f, _ := AsFloat64(v)
+		return floatValue(f)
+	case kindTime:
+		t, _ := AsTime(v)
+		return timeType(t)
+	case kindString:
+		if b, ok := AsBytes(v); ok {
+			return bytesType(append([]byte{}, b...))
+		}
+		s, _ := AsString(v)
+		return stringValue(s)
+	}
+	if depth > DefaultMaxEqualDepth {
+		return v
+	}
+	k, hasKey := visitKey(v)
+	if cv, ok := c.copies[k]; hasKey && ok {
+		return cv
+	}
+
+	if kind == kindArray {
+		ix := v.(Index)
+		cix := make(indexType, ix.Length())
+		if hasKey {
+			c.record(k, cix)
+		}
+		for i := range cix {
+			if e, ok := ix.Index(i); ok {
+				cix[i] = c.copy(e, depth+1)
+			}
+		}
+		return cix
+	}
+
+	a, ok := v.(Attr)
+	if !ok {
+		return v
+	}
+	keys := a.Keys()
+	for _, key := range keys {
+		if _, ok := AsString(key); !ok {
+			return v
+		}
+	}
+	ca := &orderedAttrType{values: make(map[string]Value, len(keys))}
+	if hasKey {
+		c.record(k, ca)
+	}
+	for _, key := range keys {
+		name, _ := AsString(key)
+		if e, ok := a.Attr(key); ok {
+			ca.set(name, c.copy(e, depth+1))
+		}
+	}
+	return ca
+}
@@ -0,0 +1,105 @@
+package sift_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+)
+
+func TestCopy(t *testing.T) {
+	data := []byte("data")
+	src := sift.Must(sift.ToValue([]sift.KeyValue{
+		{Key: "z", Value: []interface{}{1, "a", nil, true, false}},
+		{Key: "bytes", Value: data},
+		{Key: "big", Value: sift.Must(sift.NewBigNumber("123456789012345678901"))},
+		{Key: "time", Value: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{Key: "a", Value: map[string]interface{}{"nested": []interface{}{}}},
+	}))
+	c := sift.Copy(src)
+	if !sift.Equal(c, src) {
+		t.Fatalf("got %v; want %v", c, src)
+	}
+	encode := func(v sift.Value) string {
+		w := &strings.Builder{}
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		return w.String()
+	}
+	if got, want := encode(c), encode(src); got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+
+	// Changes to the original don't affect the copy, and vice versa.
+	data[0] = 'D'
+	if b, _ := sift.AsBytes(mustAttr(t, c, "bytes")); string(b) != "data" {
+		t.Errorf("copied bytes changed to %q", b)
+	}
+	nested := mustAttr(t, c, "a")
+	if _, err := sift.WithAttr(nested, sift.Must(sift.ToValue("new")), sift.NullValue); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sift.GetStringAttr(mustAttr(t, src, "a"), "new"); ok {
+		t.Error("modifying copy changed original")
+	}
+
+	// Values from other implementations are copied into default types.
+	dec := json.NewDecoder(strings.NewReader(`{"a": [1, {"b": null}]}`))
+	v, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := sift.Copy(v); !sift.Equal(c, v) {
+		t.Errorf("got %v; want %v", c, v)
+	}
+
+	// Missing elements stay missing.
+	holes := sift.Copy(sift.Must(sift.ToValue([]sift.Value{nil, sift.NullValue})))
+	if _, ok := sift.GetIntIndex(holes, 0); ok {
+		t.Error("missing element was copied")
+	}
+}
+
+func mustAttr(t *testing.T, v sift.Value, name string) sift.Value {
+	t.Helper()
+	a, ok := sift.GetStringAttr(v, name)
+	if !ok {
+		t.Fatalf("%v has no attribute %q", v, name)
+	}
+	return a
+}
+
+func TestCopyCycle(t *testing.T) {
+	// The copy of an object that contains itself contains itself.
+	c := sift.Copy(&loop{"a"})
+	a, ok := c.(sift.Attr)
+	if !ok {
+		t.Fatalf("got %T; want Attr", c)
+	}
+	if self, _ := sift.GetStringAttr(a, "a"); self != c {
+		t.Errorf("copy's attribute is %v; want the copy itself", self)
+	}
+	if !sift.Equal(c, &loop{"a"}) {
+		t.Errorf("copy is not equal to the original")
+	}
+
+	// An array that appears twice is copied once.
+	inner := sift.Must(sift.ToValue([]interface{}{1}))
+	c = sift.Copy(sift.Must(sift.ToValue([]sift.Value{inner, inner})))
+	e0, _ := c.(sift.Index).Index(0)
+	e1, _ := c.(sift.Index).Index(1)
+	if sift.Hash(e0) != sift.Hash(inner) || !sameArray(e0, e1) {
+		t.Errorf("got %v and %v; want the same copy twice", e0, e1)
+	}
+
+	sift.Copy(nest(2 * sift.DefaultMaxEqualDepth))
+}
+
+// sameArray reports whether x and y are arrays backed by the same memory.
+func sameArray(x, y sift.Value) bool {
+	return fmt.Sprintf("%p", x) == fmt.Sprintf("%p", y)
+}
@@ -0,0 +1,40 @@
+package sift
+
+import "sync"
+
+// Distinct returns a Filter that produces each value it consumes unless
+// it's Equal to a value it consumed earlier, in which case it produces
+// nothing. Used with Sift, it removes duplicates from a stream.
+//
+// The filter remembers every distinct value it has seen, so its memory use
+// grows with the number of distinct values. It's safe for concurrent use.
+func Distinct() Filter {
+	return DistinctBy(func(v Value) (Value, error) { return v, nil })
+}
+
+// DistinctBy is like Distinct, but values are duplicates if key returns
+// Equal keys for them. Only keys are remembered, so a key that's smaller
+// than its value, like an ID, saves memory.
+func DistinctBy(key func(Value) (Value, error)) Filter {
+	var mu sync.Mutex
+	seen := make(map[uint64][]Value)
+	return func(v Value) ([]Value, error) {
+		k, err := key(v)
+		if err != nil {
+			return nil, err
+		}
+		if k == nil {
+			k = NullValue
+		}
+		h := Hash(k)
+		mu.Lock()
+		defer mu.Unlock()
+		for _, e := range seen[h] {
+			if Equal(e, k) {
+				return nil, nil
+			}
+		}
+		seen[h] = append(seen[h], k)
+		return []Value{v}, nil
+	}
+}
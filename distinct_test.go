@@ -0,0 +1,68 @@
+package sift_test
+
+import (
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestDistinct(t *testing.T) {
+	id := func(v sift.Value) (sift.Value, error) {
+		a, _ := v.(sift.Attr)
+		if a == nil {
+			return sift.NullValue, nil
+		}
+		k, _ := a.Attr(sift.Must(sift.ToValue("id")))
+		return k, nil
+	}
+
+	for _, tc := range []struct {
+		desc  string
+		f     sift.Filter
+		input []interface{}
+		want  []interface{}
+	}{
+		{
+			desc:  "scalars",
+			f:     sift.Distinct(),
+			input: []interface{}{1, "1", 1, 1.0, nil, nil, true},
+			want:  []interface{}{1, "1", nil, true},
+		}, {
+			desc: "objects",
+			f:    sift.Distinct(),
+			input: []interface{}{
+				map[string]interface{}{"a": []interface{}{1}},
+				map[string]interface{}{"a": []interface{}{1}},
+				map[string]interface{}{"a": []interface{}{2}},
+			},
+			want: []interface{}{
+				map[string]interface{}{"a": []interface{}{1}},
+				map[string]interface{}{"a": []interface{}{2}},
+			},
+		}, {
+			desc: "by_key",
+			f:    sift.DistinctBy(id),
+			input: []interface{}{
+				map[string]interface{}{"id": 1, "v": "a"},
+				map[string]interface{}{"id": 2, "v": "b"},
+				map[string]interface{}{"id": 1, "v": "c"},
+			},
+			want: []interface{}{
+				map[string]interface{}{"id": 1, "v": "a"},
+				map[string]interface{}{"id": 2, "v": "b"},
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dec, _ := sift.Iterate(sift.Must(sift.ToValue(tc.input)))
+			enc := &recordEncoder{}
+			if err := sift.Sift(dec, tc.f, enc); err != nil {
+				t.Fatal(err)
+			}
+			want := sift.Must(sift.ToValue(tc.want))
+			if got := sift.Must(sift.ToValue(enc.values)); !sift.Equal(got, want) {
+				t.Errorf("got %v; want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package sift
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// A TypeProfile summarizes the shape of a stream of values: for each field,
+// the number of values in which the field had each type. A profile recorded
+// from known-good data may be compared with a live stream using DetectDrift.
+//
+// TypeProfile may be stored with encoding/json.
+type TypeProfile struct {
+	// Count is the number of values observed.
+	Count int64 `json:"count"`
+
+	// Fields maps field paths to the number of values in which the field
+	// had each type. Paths are written as in jq: "." is a value itself,
+	// ".a.b" is field b of field a, and ".a[]" is any element of array a.
+	// Types are named as by jq's type function: "null", "boolean",
+	// "number", "string", "array", and "object". A value is counted once
+	// for each field and type, even if an array has many elements of that
+	// type.
+	Fields map[string]map[string]int64 `json:"fields"`
+}
+
+// NewTypeProfile returns an empty profile.
+func NewTypeProfile() *TypeProfile {
+	return &TypeProfile{Fields: make(map[string]map[string]int64)}
+}
+
+// Observe adds v to the profile.
+func (p *TypeProfile) Observe(v Value) {
+	p.add(fieldTypes(v))
+}
+
+func (p *TypeProfile) add(fts map[fieldType]bool) {
+	p.Count++
+	for ft := range fts {
+		types := p.Fields[ft.field]
+		if types == nil {
+			types = make(map[string]int64)
+			p.Fields[ft.field] = types
+		}
+		types[ft.typ]++
+	}
+}
+
+// fraction returns the fraction of values in which field had type typ.
+func (p *TypeProfile) fraction(field, typ string) float64 {
+	if p.Count == 0 {
+		return 0
+	}
+	return float64(p.Fields[field][typ]) / float64(p.Count)
+}
+
+// RecordTypes returns middleware that adds each value written successfully
+// to p.
+func RecordTypes(p *TypeProfile) EncoderMiddleware {
+	return func(enc Encoder) Encoder {
+		return middlewareEncoder{
+			next: enc,
+			encode: func(next Encoder, v Value) error {
+				if err := next.Encode(v); err != nil {
+					return err
+				}
+				p.Observe(v)
+				return nil
+			},
+		}
+	}
+}
+
+// DriftOptions controls how DetectDrift compares a stream with a profile.
+type DriftOptions struct {
+	// Window is the number of values compared with the profile at a time.
+	// If Window is 0, 1000 is used.
+	Window int
+
+	// Threshold is the largest allowed difference between the fraction of
+	// values in the profile and the fraction of values in a window where
+	// a field has a type. If Threshold is 0, 0.1 is used.
+	Threshold float64
+
+	// Warn is called for each drift detected. If Warn is nil, drift is an
+	// error, returned by Encode or Flush.
+	Warn func(*DriftError)
+}
+
+// A DriftError describes a field whose type distribution in a stream differs
+// from a profile.
+type DriftError struct {
+	// Position is the number of values written before drift was detected.
+	Position int64
+
+	// Field and Type identify the field and type that drifted, as in
+	// TypeProfile.
+	Field, Type string
+
+	// Want is the fraction of values in the profile where Field has Type.
+	// Got is the fraction of values in the window where drift was detected.
+	//
+	// If Want is 0, Field never had Type in the profile. This is reported
+	// as soon as a value where Field has Type is written, without waiting
+	// for the end of the window, and Got is 0.
+	Want, Got float64
+}
+
+func (e *DriftError) Error() string {
+	if e.Want == 0 {
+		return fmt.Sprintf("value at position %d: field %s has type %s, which is not in the profile", e.Position, e.Field, e.Type)
+	}
+	return fmt.Sprintf("values before position %d: field %s has type %s in %.1f%% of values; profile has %.1f%%", e.Position, e.Field, e.Type, 100*e.Got, 100*e.Want)
+}
+
+// DetectDrift returns middleware that compares the types of fields in
+// values written with profile, a cheap check that a stream still matches
+// the data the profile was recorded from.
+//
+// When a value has a field with a type the profile has never seen for that
+// field, drift is reported immediately; in this case, if opts.Warn is nil,
+// the value is not written. Otherwise, values are compared in windows of
+// opts.Window values: at the end of each window and when the encoder is
+// flushed, drift is reported for each field and type whose fraction of
+// values in the window differs from the profile by more than
+// opts.Threshold. Each new type is reported once; distribution drift is
+// reported for every window where it occurs.
+func DetectDrift(profile *TypeProfile, opts DriftOptions) EncoderMiddleware {
+	size := opts.Window
+	if size <= 0 {
+		size = 1000
+	}
+	threshold := opts.Threshold
+	if threshold <= 0 {
+		threshold = 0.1
+	}
+	report := func(e *DriftError) error {
+		if opts.Warn != nil {
+			opts.Warn(e)
+			return nil
+		}
+		return e
+	}
+
+	var known []fieldType
+	for field, types := range profile.Fields {
+		for typ := range types {
+			known = append(known, fieldType{field, typ})
+		}
+	}
+	sortFieldTypes(known)
+
+	return func(enc Encoder) Encoder {
+		var pos int64
+		window := NewTypeProfile()
+		reported := make(map[fieldType]bool)
+		check := func() error {
+			w := window
+			window = NewTypeProfile()
+			for _, ft := range known {
+				want, got := profile.fraction(ft.field, ft.typ), w.fraction(ft.field, ft.typ)
+				if math.Abs(got-want) <= threshold {
+					continue
+				}
+				if err := report(&DriftError{Position: pos, Field: ft.field, Type: ft.typ, Want: want, Got: got}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		return middlewareEncoder{
+			next: enc,
+			encode: func(next Encoder, v Value) error {
+				fts := fieldTypes(v)
+				var unknown []fieldType
+				for ft := range fts {
+					if profile.Fields[ft.field][ft.typ] == 0 && !reported[ft] {
+						unknown = append(unknown, ft)
+					}
+				}
+				sortFieldTypes(unknown)
+				for _, ft := range unknown {
+					reported[ft] = true
+					if err := report(&DriftError{Position: pos, Field: ft.field, Type: ft.typ}); err != nil {
+						return err
+					}
+				}
+				if err := next.Encode(v); err != nil {
+					return err
+				}
+				pos++
+				window.add(fts)
+				if window.Count == int64(size) {
+					return check()
+				}
+				return nil
+			},
+			flush: func() error {
+				if window.Count == 0 {
+					return nil
+				}
+				return check()
+			},
+		}
+	}
+}
+
+// A fieldType is a field path and the name of a type it had.
+type fieldType struct {
+	field, typ string
+}
+
+// fieldTypes returns the set of fields and types in v.
+func fieldTypes(v Value) map[fieldType]bool {
+	fts := make(map[fieldType]bool)
+	var visit func(v Value, path string)
+	visit = func(v Value, path string) {
+		field := path
+		if field == "" || field[0] == '[' {
+			field = "." + field
+		}
+		typ := typeName(v)
+		fts[fieldType{field, typ}] = true
+		switch typ {
+		case "array":
+			ix := v.(Index)
+			for i := 0; i < ix.Length(); i++ {
+				if elem, ok := ix.Index(i); ok {
+					visit(elem, path+"[]")
+				}
+			}
+		case "object":
+			a, ok := v.(Attr)
+			if !ok {
+				return
+			}
+			for _, key := range a.Keys() {
+				name, ok := AsString(key)
+				if !ok {
+					continue
+				}
+				if elem, ok := a.Attr(key); ok {
+					visit(elem, path+fieldSuffix(name))
+				}
+			}
+		}
+	}
+	visit(v, "")
+	return fts
+}
+
+// typeName returns the name of v's type, as returned by jq's type function.
+func typeName(v Value) string {
+	return TypeOf(v).String()
+}
+
+// fieldSuffix returns the suffix added to a path for the field name, like
+// ".name" or `["a b"]` if name is not an identifier.
+func fieldSuffix(name string) string {
+	for i, r := range name {
+		if r != '_' && !('a' <= r && r <= 'z') && !('A' <= r && r <= 'Z') && (i == 0 || !('0' <= r && r <= '9')) {
+			return "[" + strconv.Quote(name) + "]"
+		}
+	}
+	if name == "" {
+		return `[""]`
+	}
+	return "." + name
+}
+
+// sortFieldTypes sorts fts, so drift is reported in a deterministic order.
+func sortFieldTypes(fts []fieldType) {
+	sort.Slice(fts, func(i, j int) bool {
+		if fts[i].field != fts[j].field {
+			return fts[i].field < fts[j].field
+		}
+		return fts[i].typ < fts[j].typ
+	})
+}
@@ -0,0 +1,103 @@
+package sift_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestTypeProfile(t *testing.T) {
+	p := sift.NewTypeProfile()
+	for _, x := range []interface{}{
+		map[string]interface{}{"id": 1, "tags": []interface{}{"a", "b"}, "a b": nil},
+		map[string]interface{}{"id": "2", "tags": []interface{}{}},
+	} {
+		p.Observe(sift.Must(sift.ToValue(x)))
+	}
+	want := map[string]map[string]int64{
+		".":        {"object": 2},
+		".id":      {"number": 1, "string": 1},
+		".tags":    {"array": 2},
+		".tags[]":  {"string": 1},
+		`.["a b"]`: {"null": 1},
+	}
+	if p.Count != 2 {
+		t.Errorf("got count %d; want 2", p.Count)
+	}
+	if got, want := fmt.Sprint(p.Fields), fmt.Sprint(want); got != want {
+		t.Errorf("got fields %s; want %s", got, want)
+	}
+}
+
+func TestDetectDrift(t *testing.T) {
+	profile := sift.NewTypeProfile()
+	for i := 0; i < 10; i++ {
+		var id interface{} = i
+		if i%2 == 0 {
+			id = nil
+		}
+		profile.Observe(sift.Must(sift.ToValue(map[string]interface{}{"id": id})))
+	}
+
+	for _, test := range []struct {
+		name   string
+		values []interface{}
+		want   []string
+	}{
+		{
+			name:   "same",
+			values: []interface{}{1, nil, 2, nil},
+		},
+		{
+			name:   "new type",
+			values: []interface{}{1, "x", nil, "y"},
+			want:   []string{".id string at 1", ".id null at 4", ".id number at 4"},
+		},
+		{
+			name:   "distribution",
+			values: []interface{}{1, 2, 3, nil},
+			want:   []string{".id null at 4", ".id number at 4"},
+		},
+		{
+			name:   "partial window",
+			values: []interface{}{1, nil, 2, nil, 3, 4},
+			want:   []string{".id null at 6", ".id number at 6"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			opts := sift.DriftOptions{
+				Window:    4,
+				Threshold: 0.2,
+				Warn: func(e *sift.DriftError) {
+					got = append(got, fmt.Sprintf("%s %s at %d", e.Field, e.Type, e.Position))
+				},
+			}
+			enc := sift.WrapEncoder(&recordEncoder{}, sift.DetectDrift(profile, opts))
+			if err := encodeAll(enc, test.values, "id"); err != nil {
+				t.Fatal(err)
+			}
+			if err := enc.(sift.Flusher).Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("got drift %q; want %q", got, test.want)
+			}
+		})
+	}
+
+	// Without Warn, drift is an error, and a value with a new type is not
+	// written.
+	rec := &recordEncoder{}
+	enc := sift.WrapEncoder(rec, sift.DetectDrift(profile, sift.DriftOptions{}))
+	err := encodeAll(enc, []interface{}{1, true}, "id")
+	var derr *sift.DriftError
+	if !errors.As(err, &derr) || derr.Position != 1 || derr.Type != "boolean" {
+		t.Errorf("got error %v; want *DriftError for boolean at position 1", err)
+	}
+	if len(rec.values) != 1 {
+		t.Errorf("wrote %d values; want 1", len(rec.values))
+	}
+}
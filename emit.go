@@ -0,0 +1,122 @@
+package sift
+
+import "io"
+
+// An EmitFilter is an alternative form of Filter that passes each value it
+// produces to emit instead of returning a slice. This lets a filter that
+// produces many values, like one that generates a range of numbers, run in
+// bounded memory: each value may be encoded or discarded before the next is
+// produced.
+//
+// If emit returns an error, the filter should stop and return that error.
+type EmitFilter func(v Value, emit func(Value) error) error
+
+// Emitting returns an EmitFilter that applies f and emits each of its
+// values in order.
+func Emitting(f Filter) EmitFilter {
+	return func(v Value, emit func(Value) error) error {
+		vs, err := f(v)
+		if err != nil {
+			return err
+		}
+		return emitAll(vs, emit)
+	}
+}
+
+// Collecting returns a Filter that applies f and returns a slice of the
+// values it emits.
+func Collecting(f EmitFilter) Filter {
+	return func(v Value) ([]Value, error) {
+		var outs []Value
+		err := f(v, func(v Value) error {
+			outs = append(outs, v)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return outs, nil
+	}
+}
+
+// ComposeEmit is like Compose for EmitFilters: it applies f to a value,
+// then applies g to each value f emits as soon as it's emitted.
+func ComposeEmit(f, g EmitFilter) EmitFilter {
+	return func(v Value, emit func(Value) error) error {
+		return f(v, func(fv Value) error {
+			return g(fv, emit)
+		})
+	}
+}
+
+// BinaryEmit is like Binary for EmitFilters. x and y are applied to an input
+// value, and their values are kept, so each may be paired with every value of
+// the other. op is then applied to each pair, and it emits its results
+// directly, so the Cartesian product is never held in memory.
+func BinaryEmit(x, y EmitFilter, op func(xv, yv Value, emit func(Value) error) error) EmitFilter {
+	return func(v Value, emit func(Value) error) error {
+		var xvs, yvs []Value
+		if err := x(v, appendTo(&xvs)); err != nil {
+			return err
+		}
+		if err := y(v, appendTo(&yvs)); err != nil {
+			return err
+		}
+		for _, xv := range xvs {
+			for _, yv := range yvs {
+				if err := op(xv, yv, emit); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// ConcatEmit is like Concat for EmitFilters: it emits the values of x
+// followed by the values of y.
+func ConcatEmit(x, y EmitFilter) EmitFilter {
+	return func(v Value, emit func(Value) error) error {
+		if err := x(v, emit); err != nil {
+			return err
+		}
+		return y(v, emit)
+	}
+}
+
+// SiftEmit is like Sift, but it applies an EmitFilter, so each value is
+// encoded as soon as the filter emits it.
+func SiftEmit(dec Decoder, f EmitFilter, enc Encoder) error {
+	for {
+		vin, err := dec.Decode()
+		if err == io.EOF {
+			if fl, ok := enc.(Flusher); ok {
+				return fl.Flush()
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := f(vin, enc.Encode); err != nil {
+			return err
+		}
+	}
+}
+
+// appendTo returns an emit function that appends values to *vs.
+func appendTo(vs *[]Value) func(Value) error {
+	return func(v Value) error {
+		*vs = append(*vs, v)
+		return nil
+	}
+}
+
+// emitAll passes each value in vs to emit, stopping at the first error.
+func emitAll(vs []Value, emit func(Value) error) error {
+	for _, v := range vs {
+		if err := emit(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
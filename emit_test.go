@@ -0,0 +1,87 @@
+package sift_test
+
+import (
+	"errors"
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestEmit(t *testing.T) {
+	lit := func(i interface{}) sift.Filter { return sift.Literal(sift.Must(sift.ToValue(i))) }
+	upTo := func(n int) sift.EmitFilter {
+		return func(_ sift.Value, emit func(sift.Value) error) error {
+			for i := 0; i < n; i++ {
+				if err := emit(sift.Must(sift.ToValue(i))); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	double := sift.Emitting(sift.Map(func(v sift.Value) sift.Value {
+		f, _ := sift.AsFloat64(v)
+		return sift.Must(sift.ToValue(2 * f))
+	}))
+	add := func(xv, yv sift.Value, emit func(sift.Value) error) error {
+		x, _ := sift.AsFloat64(xv)
+		y, _ := sift.AsFloat64(yv)
+		return emit(sift.Must(sift.ToValue(x + y)))
+	}
+
+	for _, tc := range []struct {
+		desc string
+		f    sift.EmitFilter
+		want []interface{}
+	}{
+		{
+			desc: "emitting",
+			f:    sift.Emitting(sift.Concat(lit(1), lit(2))),
+			want: []interface{}{1, 2},
+		}, {
+			desc: "compose",
+			f:    sift.ComposeEmit(upTo(3), double),
+			want: []interface{}{0, 2, 4},
+		}, {
+			desc: "binary",
+			f:    sift.BinaryEmit(upTo(2), sift.Emitting(sift.Concat(lit(10), lit(20))), add),
+			want: []interface{}{10, 20, 11, 21},
+		}, {
+			desc: "concat",
+			f:    sift.ConcatEmit(upTo(2), upTo(1)),
+			want: []interface{}{0, 1, 0},
+		}, {
+			desc: "empty",
+			f:    upTo(0),
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := sift.Collecting(tc.f)(sift.NullValue)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := sift.Must(sift.ToValue(tc.want))
+			if !sift.Equal(sift.Must(sift.ToValue(got)), want) {
+				t.Errorf("got %v; want %v", got, want)
+			}
+		})
+	}
+
+	t.Run("stop", func(t *testing.T) {
+		errStop := errors.New("stop")
+		n := 0
+		err := sift.ComposeEmit(upTo(1e9), double)(sift.NullValue, func(sift.Value) error {
+			n++
+			if n == 3 {
+				return errStop
+			}
+			return nil
+		})
+		if err != errStop {
+			t.Fatalf("got error %v; want %v", err, errStop)
+		}
+		if n != 3 {
+			t.Errorf("got %d values; want 3", n)
+		}
+	})
+}
@@ -0,0 +1,199 @@
+// Package charset converts text in other character encodings to UTF-8.
+//
+// Decoders in sift expect UTF-8 input, but data exported by other tools is
+// frequently encoded in UTF-16 or in a legacy single-byte encoding. Readers
+// returned by NewReader may be placed in front of any decoder.
+package charset
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Names returns the names of supported encodings. Other names may be
+// accepted as aliases.
+func Names() []string {
+	return []string{"auto", "utf-8", "utf-16", "utf-16le", "utf-16be", "latin1", "windows-1252"}
+}
+
+// NewReader returns a Reader that reads text from r in the named encoding
+// and produces UTF-8.
+//
+// If encoding is "auto" or empty, the encoding is detected from a byte order
+// mark at the beginning of r. UTF-8, UTF-16LE, and UTF-16BE are detected this
+// way; without a byte order mark, UTF-8 is assumed. "utf-16" also relies on
+// a byte order mark but assumes big-endian if there is none. Byte order marks
+// are not included in the output.
+func NewReader(r io.Reader, encoding string) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	switch normalize(encoding) {
+	case "", "auto":
+		return detect(br, "utf8"), nil
+	case "utf8":
+		skipBOM(br, []byte{0xEF, 0xBB, 0xBF})
+		return br, nil
+	case "utf16":
+		return detect(br, "utf16be"), nil
+	case "utf16le":
+		skipBOM(br, []byte{0xFF, 0xFE})
+		return &reader{br: br, next: nextUTF16(false)}, nil
+	case "utf16be":
+		skipBOM(br, []byte{0xFE, 0xFF})
+		return &reader{br: br, next: nextUTF16(true)}, nil
+	case "latin1", "iso88591":
+		return &reader{br: br, next: nextLatin1}, nil
+	case "windows1252", "cp1252":
+		return &reader{br: br, next: nextWindows1252}, nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
+
+// DecodeString converts s, which contains text in the named encoding,
+// to UTF-8.
+func DecodeString(s, encoding string) (string, error) {
+	r, err := NewReader(strings.NewReader(s), encoding)
+	if err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadAll(r)
+	return string(b), err
+}
+
+func normalize(encoding string) string {
+	encoding = strings.ToLower(encoding)
+	encoding = strings.ReplaceAll(encoding, "-", "")
+	return strings.ReplaceAll(encoding, "_", "")
+}
+
+// detect chooses an encoding based on the byte order mark at the beginning
+// of br, falling back to def if there is no byte order mark.
+func detect(br *bufio.Reader, def string) io.Reader {
+	if skipBOM(br, []byte{0xEF, 0xBB, 0xBF}) {
+		return br
+	} else if skipBOM(br, []byte{0xFF, 0xFE}) {
+		return &reader{br: br, next: nextUTF16(false)}
+	} else if skipBOM(br, []byte{0xFE, 0xFF}) || def == "utf16be" {
+		return &reader{br: br, next: nextUTF16(true)}
+	}
+	return br
+}
+
+// skipBOM discards bom from the beginning of br and returns true if
+// br starts with bom.
+func skipBOM(br *bufio.Reader, bom []byte) bool {
+	b, _ := br.Peek(len(bom))
+	if !bytes.Equal(b, bom) {
+		return false
+	}
+	br.Discard(len(bom))
+	return true
+}
+
+// reader converts text to UTF-8 one rune at a time. next reads the next
+// rune from br.
+type reader struct {
+	br   *bufio.Reader
+	next func(br *bufio.Reader) (rune, error)
+	buf  [utf8.UTFMax]byte
+	pend []byte // encoded bytes of a rune not yet returned by Read
+	err  error
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.pend) > 0 {
+			c := copy(p[n:], r.pend)
+			r.pend = r.pend[c:]
+			n += c
+			continue
+		}
+		if r.err != nil {
+			break
+		}
+		ch, err := r.next(r.br)
+		if err != nil {
+			r.err = err
+			continue
+		}
+		w := utf8.EncodeRune(r.buf[:], ch)
+		r.pend = r.buf[:w]
+	}
+	if n > 0 {
+		return n, nil
+	}
+	return 0, r.err
+}
+
+func nextLatin1(br *bufio.Reader) (rune, error) {
+	b, err := br.ReadByte()
+	return rune(b), err
+}
+
+// windows1252 maps bytes 0x80-0x9F to the characters they represent in
+// Windows-1252. Other bytes represent the same characters as in Latin-1.
+// Undefined bytes are mapped to the corresponding C1 control characters.
+var windows1252 = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+func nextWindows1252(br *bufio.Reader) (rune, error) {
+	b, err := br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if 0x80 <= b && b < 0xA0 {
+		return windows1252[b-0x80], nil
+	}
+	return rune(b), nil
+}
+
+func nextUTF16(bigEndian bool) func(*bufio.Reader) (rune, error) {
+	readUnit := func(br *bufio.Reader) (rune, error) {
+		var b [2]byte
+		n, err := io.ReadFull(br, b[:])
+		if err == io.ErrUnexpectedEOF && n == 1 {
+			// Odd number of bytes: the last one can't be decoded.
+			return utf8.RuneError, nil
+		} else if err != nil {
+			return 0, err
+		}
+		if bigEndian {
+			return rune(b[0])<<8 | rune(b[1]), nil
+		}
+		return rune(b[1])<<8 | rune(b[0]), nil
+	}
+	return func(br *bufio.Reader) (rune, error) {
+		u, err := readUnit(br)
+		if err != nil || !utf16.IsSurrogate(u) {
+			return u, err
+		}
+		// Surrogate pair: peek at the next unit without consuming it, in case
+		// it's not a low surrogate.
+		b, _ := br.Peek(2)
+		if len(b) < 2 {
+			return utf8.RuneError, nil
+		}
+		var u2 rune
+		if bigEndian {
+			u2 = rune(b[0])<<8 | rune(b[1])
+		} else {
+			u2 = rune(b[1])<<8 | rune(b[0])
+		}
+		r := utf16.DecodeRune(u, u2)
+		if r != utf8.RuneError {
+			br.Discard(2)
+		}
+		return r, nil
+	}
+}
@@ -0,0 +1,75 @@
+package charset_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"go.jayconrod.com/sift/encoding/charset"
+)
+
+func TestNewReader(t *testing.T) {
+	for _, tc := range []struct {
+		desc, encoding, input, want, wantErr string
+	}{
+		{
+			desc:  "auto_plain",
+			input: `{"a":"é"}`,
+			want:  `{"a":"é"}`,
+		}, {
+			desc:  "auto_utf8_bom",
+			input: "\xEF\xBB\xBF\"é\"",
+			want:  `"é"`,
+		}, {
+			desc:  "auto_utf16le",
+			input: "\xFF\xFE\"\x00\xE9\x00=\xD8\x00\xDE\"\x00",
+			want:  "\"é😀\"",
+		}, {
+			desc:  "auto_utf16be",
+			input: "\xFE\xFF\x00\"\x00\xE9\x00\"",
+			want:  `"é"`,
+		}, {
+			desc:     "utf16le_no_bom",
+			encoding: "UTF-16LE",
+			input:    "a\x00b\x00",
+			want:     "ab",
+		}, {
+			desc:     "utf16_unpaired_surrogate",
+			encoding: "utf-16be",
+			input:    "\xD8\x3D\x00a\x00",
+			want:     "�a�",
+		}, {
+			desc:     "latin1",
+			encoding: "ISO-8859-1",
+			input:    "caf\xE9 \x80",
+			want:     "café \u0080",
+		}, {
+			desc:     "windows1252",
+			encoding: "windows-1252",
+			input:    "\x93quoted\x94 \x80",
+			want:     "“quoted” €",
+		}, {
+			desc:     "unknown",
+			encoding: "ebcdic",
+			wantErr:  "unsupported encoding",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			r, err := charset.NewReader(iotest.OneByteReader(strings.NewReader(tc.input)), tc.encoding)
+			if err != nil {
+				if tc.wantErr == "" || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("got error %v; want %q", err, tc.wantErr)
+				}
+				return
+			}
+			b, err := ioutil.ReadAll(iotest.HalfReader(r))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
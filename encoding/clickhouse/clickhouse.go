@@ -0,0 +1,177 @@
+// Package clickhouse provides an encoder that inserts values into
+// a ClickHouse table using the HTTP interface.
+package clickhouse
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+)
+
+// A Column maps a value to a column of the destination table.
+type Column struct {
+	// Name is the name of the column.
+	Name string
+
+	// Value is applied to each value to produce the column's value.
+	// It must produce exactly one value.
+	Value sift.Filter
+}
+
+// SinkOptions controls how a Sink inserts values into ClickHouse.
+type SinkOptions struct {
+	// URL is the address of the ClickHouse HTTP interface, for example,
+	// "http://localhost:8123/". It may include parameters like database
+	// and user.
+	URL string
+
+	// Table is the name of the destination table.
+	Table string
+
+	// Columns maps values to table columns. If Columns is empty, each value
+	// must be an object, and its attributes are inserted into the columns
+	// with matching names.
+	Columns []Column
+
+	// BatchSize is the maximum number of rows sent in one request.
+	// If zero, 10000 is used.
+	BatchSize int
+
+	// Client is used to send requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// Sink is an Encoder that inserts values into a ClickHouse table in
+// batches. Rows are sent in the JSONEachRow format. Sink implements
+// sift.Flusher; Flush must be called after the last value is encoded to
+// send the final batch.
+type Sink struct {
+	opts  SinkOptions
+	query string
+	buf   bytes.Buffer
+	enc   sift.Encoder
+	n     int
+}
+
+var _ sift.Flusher = (*Sink)(nil)
+
+// NewSink returns a Sink configured with opts.
+func NewSink(opts SinkOptions) (*Sink, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("clickhouse sink URL not set")
+	}
+	if opts.Table == "" {
+		return nil, fmt.Errorf("clickhouse sink table not set")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 10000
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	s := &Sink{opts: opts, query: insertQuery(opts.Table, opts.Columns)}
+	s.enc = json.NewEncoder(&s.buf)
+	return s, nil
+}
+
+// insertQuery returns the INSERT statement that precedes the rows in the
+// body of each request.
+func insertQuery(table string, columns []Column) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "INSERT INTO %s", quoteIdentifier(table))
+	if len(columns) > 0 {
+		b.WriteString(" (")
+		for i, c := range columns {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(quoteIdentifier(c.Name))
+		}
+		b.WriteString(")")
+	}
+	b.WriteString(" FORMAT JSONEachRow")
+	return b.String()
+}
+
+// quoteIdentifier quotes a table or column name. Names with a "." are
+// quoted in parts, so a database may be specified along with a table.
+func quoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		p = strings.ReplaceAll(p, "\\", "\\\\")
+		parts[i] = "`" + strings.ReplaceAll(p, "`", "\\`") + "`"
+	}
+	return strings.Join(parts, ".")
+}
+
+// Encode converts v to a row and adds it to the current batch, sending
+// the batch if it is full.
+func (s *Sink) Encode(v sift.Value) error {
+	row, err := s.row(v)
+	if err != nil {
+		return err
+	}
+	if err := s.enc.Encode(row); err != nil {
+		return err
+	}
+	s.n++
+	if s.n >= s.opts.BatchSize {
+		return s.Flush()
+	}
+	return nil
+}
+
+func (s *Sink) row(v sift.Value) (sift.Value, error) {
+	if len(s.opts.Columns) == 0 {
+		if _, ok := v.(sift.Attr); !ok {
+			return nil, fmt.Errorf("cannot insert value %v as row: not an object", v)
+		}
+		return v, nil
+	}
+	row := make(map[string]sift.Value, len(s.opts.Columns))
+	for _, c := range s.opts.Columns {
+		vs, err := c.Value(v)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", c.Name, err)
+		}
+		if len(vs) != 1 {
+			return nil, fmt.Errorf("column %s: filter produced %d values; want 1", c.Name, len(vs))
+		}
+		row[c.Name] = vs[0]
+	}
+	return sift.ToValue(row)
+}
+
+// Flush sends the current batch, if it is not empty.
+func (s *Sink) Flush() error {
+	if s.n == 0 {
+		return nil
+	}
+	u, err := url.Parse(s.opts.URL)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("query", s.query)
+	u.RawQuery = q.Encode()
+
+	body := s.buf.Bytes()
+	resp, err := s.opts.Client.Post(u.String(), "application/x-ndjson", bytes.NewReader(body))
+	s.buf.Reset()
+	s.n = 0
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("clickhouse insert failed: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package clickhouse_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/clickhouse"
+	"go.jayconrod.com/sift/filter/jq"
+)
+
+func TestSink(t *testing.T) {
+	var queries, bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		queries = append(queries, r.URL.Query().Get("query"))
+		bodies = append(bodies, string(data))
+	}))
+	defer srv.Close()
+
+	name, err := jq.Compile("name", `.user.name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := jq.Compile("count", `.n + 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := clickhouse.NewSink(clickhouse.SinkOptions{
+		URL:   srv.URL + "/?database=test",
+		Table: "db.events",
+		Columns: []clickhouse.Column{
+			{Name: "name", Value: name},
+			{Name: "count", Value: count},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []interface{}{
+		map[string]interface{}{"user": map[string]interface{}{"name": "a"}, "n": 1},
+		map[string]interface{}{"user": map[string]interface{}{"name": "b"}, "n": 2},
+	} {
+		if err := s.Encode(sift.Must(sift.ToValue(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(queries) != 1 {
+		t.Fatalf("got %d requests; want 1", len(queries))
+	}
+	if want := "INSERT INTO `db`.`events` (`name`, `count`) FORMAT JSONEachRow"; queries[0] != want {
+		t.Errorf("got query %q; want %q", queries[0], want)
+	}
+	want := `
+{"count":2,"name":"a"}
+{"count":3,"name":"b"}
+`
+	if got, want := strings.TrimSpace(bodies[0]), strings.TrimSpace(want); got != want {
+		t.Errorf("got body:\n%s\n\nwant:\n%s", got, want)
+	}
+}
+
+func TestSinkError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Code: 60. DB::Exception: Table doesn't exist", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	s, err := clickhouse.NewSink(clickhouse.SinkOptions{URL: srv.URL, Table: "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Encode(sift.Must(sift.ToValue(1))); err == nil {
+		t.Error("encoding non-object: got success; want error")
+	}
+	if err := s.Encode(sift.Must(sift.ToValue(map[string]interface{}{"a": 1}))); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err == nil || !strings.Contains(err.Error(), "Table doesn't exist") {
+		t.Errorf("got error %v; want error from server", err)
+	}
+}
@@ -0,0 +1,190 @@
+// Package elasticsearch provides encoders that load values into
+// Elasticsearch using the _bulk API.
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"go.jayconrod.com/sift"
+	siftjson "go.jayconrod.com/sift/encoding/json"
+)
+
+// BulkOptions controls how values are written in the _bulk format.
+type BulkOptions struct {
+	// Action is the bulk action performed for each value: "index", "create",
+	// "update", or "delete". If empty, "index" is used.
+	Action string
+
+	// Meta is applied to each value to produce the action's metadata, for
+	// example, {"_index": "logs", "_id": .id}. It must produce exactly one
+	// object. If Meta is nil, empty metadata is written, and the target index
+	// must be part of the request URL.
+	Meta sift.Filter
+}
+
+type bulkEncoder struct {
+	enc  sift.Encoder
+	opts BulkOptions
+}
+
+// NewBulkEncoder returns an Encoder that writes values to w as newline
+// delimited JSON in the format accepted by the Elasticsearch _bulk API.
+// Each value is written as an action line followed by a source line
+// (except for "delete" actions, which have no source).
+func NewBulkEncoder(w io.Writer, opts BulkOptions) (sift.Encoder, error) {
+	switch opts.Action {
+	case "":
+		opts.Action = "index"
+	case "index", "create", "update", "delete":
+	default:
+		return nil, fmt.Errorf("unknown bulk action %q", opts.Action)
+	}
+	return &bulkEncoder{enc: siftjson.NewEncoder(w), opts: opts}, nil
+}
+
+func (e *bulkEncoder) Encode(v sift.Value) error {
+	meta := sift.Must(sift.ToValue(map[string]sift.Value{}))
+	if e.opts.Meta != nil {
+		metas, err := e.opts.Meta(v)
+		if err != nil {
+			return err
+		}
+		if len(metas) != 1 {
+			return fmt.Errorf("bulk metadata filter produced %d values; want 1", len(metas))
+		}
+		if _, ok := metas[0].(sift.Attr); !ok {
+			return fmt.Errorf("bulk metadata %v is not an object", metas[0])
+		}
+		meta = metas[0]
+	}
+	action := sift.Must(sift.ToValue(map[string]sift.Value{e.opts.Action: meta}))
+	if err := e.enc.Encode(action); err != nil {
+		return err
+	}
+
+	switch e.opts.Action {
+	case "delete":
+		return nil
+	case "update":
+		v = sift.Must(sift.ToValue(map[string]sift.Value{"doc": v}))
+	}
+	return e.enc.Encode(v)
+}
+
+// SinkOptions controls how a Sink sends values to Elasticsearch.
+type SinkOptions struct {
+	BulkOptions
+
+	// URL is the address of the _bulk endpoint, for example,
+	// "http://localhost:9200/_bulk" or "http://localhost:9200/logs/_bulk".
+	URL string
+
+	// BatchSize is the maximum number of values sent in one request.
+	// If zero, 1000 is used.
+	BatchSize int
+
+	// Client is used to send requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// Sink is an Encoder that sends values to Elasticsearch in batches using
+// the _bulk API. Sink implements sift.Flusher; Flush must be called after the
+// last value is encoded to send the final batch.
+type Sink struct {
+	opts SinkOptions
+	buf  bytes.Buffer
+	enc  sift.Encoder
+	n    int
+}
+
+var _ sift.Flusher = (*Sink)(nil)
+
+// NewSink returns a Sink configured with opts.
+func NewSink(opts SinkOptions) (*Sink, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("elasticsearch sink URL not set")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	s := &Sink{opts: opts}
+	enc, err := NewBulkEncoder(&s.buf, opts.BulkOptions)
+	if err != nil {
+		return nil, err
+	}
+	s.enc = enc
+	return s, nil
+}
+
+// Encode adds v to the current batch, sending the batch if it is full.
+func (s *Sink) Encode(v sift.Value) error {
+	if err := s.enc.Encode(v); err != nil {
+		return err
+	}
+	s.n++
+	if s.n >= s.opts.BatchSize {
+		return s.Flush()
+	}
+	return nil
+}
+
+// Flush sends the current batch, if it is not empty. An error is returned
+// if the request fails or if Elasticsearch reports an error for any item.
+func (s *Sink) Flush() error {
+	if s.n == 0 {
+		return nil
+	}
+	body := s.buf.Bytes()
+	resp, err := s.opts.Client.Post(s.opts.URL, "application/x-ndjson", bytes.NewReader(body))
+	s.buf.Reset()
+	s.n = 0
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("elasticsearch bulk request failed: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return bulkResponseError(data)
+}
+
+// bulkResponseError returns an error describing the first failed item in
+// a _bulk response, if any item failed.
+func bulkResponseError(data []byte) error {
+	var r struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct{ Error json.RawMessage }
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return fmt.Errorf("parsing elasticsearch bulk response: %v", err)
+	}
+	if !r.Errors {
+		return nil
+	}
+	failed := 0
+	var first json.RawMessage
+	for _, item := range r.Items {
+		for _, result := range item {
+			if len(result.Error) > 0 {
+				if failed == 0 {
+					first = result.Error
+				}
+				failed++
+			}
+		}
+	}
+	return fmt.Errorf("elasticsearch bulk request: %d of %d items failed; first error: %s", failed, len(r.Items), first)
+}
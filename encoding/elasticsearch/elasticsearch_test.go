@@ -0,0 +1,104 @@
+package elasticsearch_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/elasticsearch"
+	"go.jayconrod.com/sift/filter/jq"
+)
+
+func TestBulkEncoder(t *testing.T) {
+	meta, err := jq.Compile("meta", `{_index: "logs", _id: .id}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		desc, action, want string
+	}{
+		{
+			desc: "index",
+			want: `
+{"index":{"_index":"logs","_id":1}}
+{"id":1,"x":"a"}
+`,
+		}, {
+			desc:   "update",
+			action: "update",
+			want: `
+{"update":{"_index":"logs","_id":1}}
+{"doc":{"id":1,"x":"a"}}
+`,
+		}, {
+			desc:   "delete",
+			action: "delete",
+			want: `
+{"delete":{"_index":"logs","_id":1}}
+`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			w := &strings.Builder{}
+			enc, err := elasticsearch.NewBulkEncoder(w, elasticsearch.BulkOptions{Action: tc.action, Meta: meta})
+			if err != nil {
+				t.Fatal(err)
+			}
+			v := sift.Must(sift.ToValue(map[string]interface{}{"id": 1, "x": "a"}))
+			if err := enc.Encode(v); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := strings.TrimSpace(w.String()), strings.TrimSpace(tc.want); got != want {
+				t.Errorf("got:\n%s\n\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestSink(t *testing.T) {
+	var bodies []string
+	failItems := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+		if failItems {
+			w.Write([]byte(`{"errors":true,"items":[{"index":{"error":{"type":"mapper_parsing_exception"}}}]}`))
+		} else {
+			w.Write([]byte(`{"errors":false,"items":[]}`))
+		}
+	}))
+	defer srv.Close()
+
+	s, err := elasticsearch.NewSink(elasticsearch.SinkOptions{URL: srv.URL + "/logs/_bulk", BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := s.Encode(sift.Must(sift.ToValue(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"{\"index\":{}}\n0\n{\"index\":{}}\n1\n", "{\"index\":{}}\n2\n"}
+	if len(bodies) != len(want) {
+		t.Fatalf("got %d requests; want %d", len(bodies), len(want))
+	}
+	for i := range want {
+		if bodies[i] != want[i] {
+			t.Errorf("request %d: got %q; want %q", i, bodies[i], want[i])
+		}
+	}
+
+	failItems = true
+	if err := s.Encode(sift.Must(sift.ToValue(3))); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err == nil || !strings.Contains(err.Error(), "mapper_parsing_exception") {
+		t.Errorf("got error %v; want item error", err)
+	}
+}
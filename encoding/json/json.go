@@ -1,10 +1,11 @@
 package json
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
-	"sort"
+	"math"
 
 	"go.jayconrod.com/sift"
 )
@@ -69,24 +70,22 @@ func (v value) String() string {
 	}
 }
 
-type attrValue map[string]interface{}
+// attrValue is a decoded JSON object. Keys returns its keys in the order
+// they first appear in the input.
+type attrValue struct {
+	keys   []string
+	values map[string]interface{}
+}
 
-var _ sift.Attr = attrValue(nil)
+var _ sift.Attr = (*attrValue)(nil)
 
-func (v attrValue) Truth() bool {
+func (v *attrValue) Truth() bool {
 	return true
 }
 
-func (v attrValue) Keys() []sift.Value {
-	// TODO: should this return the keys in the order they appeared in source?
-	// The JSON decoder doesn't give us that.
-	keyStrings := make([]string, 0, len(v))
-	for keyString := range v {
-		keyStrings = append(keyStrings, keyString)
-	}
-	sort.Strings(keyStrings)
-	keys := make([]sift.Value, len(v))
-	for i, keyString := range keyStrings {
+func (v *attrValue) Keys() []sift.Value {
+	keys := make([]sift.Value, len(v.keys))
+	for i, keyString := range v.keys {
 		key, err := sift.ToValue(keyString)
 		if err != nil {
 			panic(err)
@@ -96,16 +95,16 @@ func (v attrValue) Keys() []sift.Value {
 	return keys
 }
 
-func (v attrValue) Attr(key sift.Value) (sift.Value, bool) {
+func (v *attrValue) Attr(key sift.Value) (sift.Value, bool) {
 	s, ok := sift.AsString(key)
 	if !ok {
 		return nil, false
 	}
-	i, ok := v[s]
+	i, ok := v.values[s]
 	if !ok {
 		return nil, false
 	}
-	value, err := sift.ToValue(i)
+	value, err := toValue(i)
 	if err != nil {
 		panic(err) // all JSON values should be representable
 	}
@@ -128,45 +127,192 @@ func (v indexValue) Index(i int) (sift.Value, bool) {
 	if i < 0 || len(v) <= i {
 		return nil, false
 	}
-	elem, err := sift.ToValue(v[i])
+	elem, err := toValue(v[i])
 	if err != nil {
 		return nil, false
 	}
 	return elem, true
 }
 
+// toValue converts a value returned by decodeValue to a Value.
+func toValue(i interface{}) (sift.Value, error) {
+	switch i := i.(type) {
+	case *attrValue:
+		return i, nil
+	case indexValue:
+		return i, nil
+	case json.Number:
+		return sift.ToValue(i)
+	default:
+		return value{i}, nil
+	}
+}
+
+// decodeValue reads the next JSON value from dec. Objects are decoded as
+// *attrValue, so the order of their keys is kept, and arrays are decoded as
+// indexValue. Other values are returned as Token returns them. If a key
+// appears more than once in an object, the last value is used, in the
+// position of the first, as with sift.KeyValue.
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := &attrValue{values: make(map[string]interface{})}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, noEOF(err)
+			}
+			key := tok.(string) // Token only returns strings for keys
+			elem, err := decodeValue(dec)
+			if err != nil {
+				return nil, noEOF(err)
+			}
+			if _, ok := obj.values[key]; !ok {
+				obj.keys = append(obj.keys, key)
+			}
+			obj.values[key] = elem
+		}
+		if _, err := dec.Token(); err != nil { // }
+			return nil, noEOF(err)
+		}
+		return obj, nil
+
+	case json.Delim('['):
+		arr := indexValue{}
+		for dec.More() {
+			elem, err := decodeValue(dec)
+			if err != nil {
+				return nil, noEOF(err)
+			}
+			arr = append(arr, elem)
+		}
+		if _, err := dec.Token(); err != nil { // ]
+			return nil, noEOF(err)
+		}
+		return arr, nil
+
+	default:
+		return tok, nil
+	}
+}
+
+// noEOF converts io.EOF, which Token returns at the end of the input, to
+// io.ErrUnexpectedEOF for input that ends inside a value.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
 type decoder struct {
 	dec *json.Decoder
+
+	// annotate indicates values should be annotated with source and
+	// their offsets.
+	annotate bool
+	source   string
+
+	// err is the first error returned by decode.
+	err error
 }
 
 // NewDecoder returns a JSON decoder that reads from r and returns
 // sift elements until it reaches the end of the input.
+//
+// Objects keep their keys in the order they appear in the input, so the
+// encoder writes them back in the same order.
+//
+// Numbers that can't be represented exactly as float64, like large
+// integers and decimals with many digits, are decoded as sift.Number
+// values, which the encoder writes with their original text.
 func NewDecoder(r io.Reader) sift.Decoder {
-	return &decoder{dec: json.NewDecoder(r)}
+	return newDecoder(r)
+}
+
+func newDecoder(r io.Reader) *decoder {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return &decoder{dec: dec}
+}
+
+// NewAnnotatingDecoder is like NewDecoder, but each value it returns is
+// annotated with source and the byte offset where the value starts in r.
+// See sift.Annotate.
+func NewAnnotatingDecoder(r io.Reader, source string) sift.Decoder {
+	d := newDecoder(r)
+	d.annotate, d.source = true, source
+	return d
 }
 
 func (d *decoder) Decode() (sift.Value, error) {
-	var raw interface{}
-	if err := d.dec.Decode(&raw); err != nil {
+	if !d.annotate {
+		return d.decode()
+	}
+	var msg json.RawMessage
+	if err := d.dec.Decode(&msg); err != nil {
 		return nil, err
 	}
-	if obj, ok := raw.(map[string]interface{}); ok {
-		return attrValue(obj), nil
-	} else if arr, ok := raw.([]interface{}); ok {
-		return indexValue(arr), nil
-	} else {
-		return value{raw}, nil
+	offset := d.dec.InputOffset() - int64(len(msg))
+	v, err := newDecoder(bytes.NewReader(msg)).decode()
+	if err != nil {
+		return nil, err
+	}
+	return sift.Annotate(v, sift.Annotations{Source: d.source, Offset: offset}), nil
+}
+
+// ByteCount returns the number of bytes of input consumed by values
+// decoded so far.
+func (d *decoder) ByteCount() int64 {
+	return d.dec.InputOffset()
+}
+
+func (d *decoder) decode() (sift.Value, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	i, err := decodeValue(d.dec)
+	if err != nil {
+		// The input can't be resynchronized after an error, so return the
+		// same error from later calls, as json.Decoder.Decode does.
+		d.err = err
+		return nil, err
 	}
+	return toValue(i)
 }
 
 type encoder struct {
 	enc *json.Encoder
+	w   countWriter
 }
 
 // NewEncoder returns a JSON encoder that encodes sift elements
 // as JSON, which is written to w.
 func NewEncoder(w io.Writer) sift.Encoder {
-	return &encoder{enc: json.NewEncoder(w)}
+	e := &encoder{w: countWriter{w: w}}
+	e.enc = json.NewEncoder(&e.w)
+	return e
+}
+
+// ByteCount returns the number of bytes written.
+func (e *encoder) ByteCount() int64 {
+	return e.w.n
+}
+
+// countWriter counts bytes written to w.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
 }
 
 func (e *encoder) Encode(v sift.Value) error {
@@ -180,17 +326,27 @@ func (e *encoder) Encode(v sift.Value) error {
 func toJSONValue(v sift.Value) (interface{}, error) {
 	if jsonValue, ok := v.(value); ok {
 		return jsonValue.i, nil
+	} else if ev, ok := sift.Strip(v).(*sift.ErrorValue); ok {
+		// An error is not data, so don't write its payload as though it
+		// were. Callers may find the payload with sift.AsErrorValue.
+		return nil, fmt.Errorf("cannot encode error value: %w", ev)
 	} else if sift.IsNull(v) {
 		return nil, nil
 	} else if b, ok := sift.AsBool(v); ok {
 		return b, nil
+	} else if text, ok := sift.AsBigNumber(v); ok {
+		return json.Number(text), nil
 	} else if f, ok := sift.AsFloat64(v); ok {
-		return f, nil
+		return finite(f), nil
 	} else if s, ok := sift.AsString(v); ok {
 		return s, nil
+	} else if b, ok := sift.AsBytes(v); ok {
+		return b, nil // encoded in base64
+	} else if t, ok := sift.AsTime(v); ok {
+		return t, nil // encoded in RFC 3339 format
 	} else if a, ok := v.(sift.Attr); ok {
 		keys := a.Keys()
-		m := make(map[string]interface{})
+		m := object{names: make([]string, 0, len(keys)), values: make([]interface{}, 0, len(keys))}
 		for _, key := range keys {
 			s, ok := sift.AsString(key)
 			if !ok {
@@ -204,7 +360,8 @@ func toJSONValue(v sift.Value) (interface{}, error) {
 			if err != nil {
 				return nil, err
 			}
-			m[s] = value
+			m.names = append(m.names, s)
+			m.values = append(m.values, value)
 		}
 		return m, nil
 	} else if i, ok := v.(sift.Index); ok {
@@ -226,3 +383,46 @@ func toJSONValue(v sift.Value) (interface{}, error) {
 		return nil, fmt.Errorf("cannot represent value %#v in JSON", v)
 	}
 }
+
+// object is a JSON object whose attributes are written in the order of the
+// Attr's keys, rather than sorted, as encoding/json does with maps.
+type object struct {
+	names  []string
+	values []interface{}
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, name := range o.names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		data, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+		buf.WriteByte(':')
+		if data, err = json.Marshal(o.values[i]); err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// finite converts special floating point values to values that may be
+// represented in JSON. Like jq, infinities are clamped to the largest finite
+// numbers, and NaN is written as null.
+func finite(f float64) interface{} {
+	if math.IsNaN(f) {
+		return nil
+	} else if math.IsInf(f, 1) {
+		return math.MaxFloat64
+	} else if math.IsInf(f, -1) {
+		return -math.MaxFloat64
+	}
+	return f
+}
@@ -1,8 +1,11 @@
 package json_test
 
 import (
+	"io"
+	"math"
 	"strings"
 	"testing"
+	"time"
 
 	"go.jayconrod.com/sift"
 	"go.jayconrod.com/sift/encoding/json"
@@ -117,10 +120,34 @@ func TestEncode(t *testing.T) {
 			desc:  "float64",
 			value: sift.Must(sift.ToValue(float64(12.3))),
 			want:  "12.3",
+		}, {
+			desc:  "float64_inf",
+			value: sift.Must(sift.ToValue(math.Inf(-1))),
+			want:  "-1.7976931348623157e+308",
+		}, {
+			desc:  "float64_nan",
+			value: sift.Must(sift.ToValue(math.NaN())),
+			want:  "null",
+		}, {
+			desc:  "big_number",
+			value: sift.Must(sift.NewBigNumber("12345678901234567890.50")),
+			want:  "12345678901234567890.50",
+		}, {
+			desc:  "big_int64",
+			value: sift.Must(sift.ToValue(int64(1<<62 + 1))),
+			want:  "4611686018427387905",
 		}, {
 			desc:  "string",
 			value: sift.Must(sift.ToValue("foo")),
 			want:  `"foo"`,
+		}, {
+			desc:  "time",
+			value: sift.Must(sift.ToValue(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))),
+			want:  `"2024-01-02T03:04:05Z"`,
+		}, {
+			desc:  "bytes",
+			value: sift.Must(sift.ToValue([]byte("foo"))),
+			want:  `"Zm9v"`,
 		}, {
 			desc: "object",
 			value: sift.Must(sift.ToValue(map[string]interface{}{
@@ -128,6 +155,13 @@ func TestEncode(t *testing.T) {
 				"bar": 34,
 			})),
 			want: `{"bar":34,"foo":12}`,
+		}, {
+			desc: "object_ordered",
+			value: sift.Must(sift.ToValue([]sift.KeyValue{
+				{Key: "foo", Value: 12},
+				{Key: "bar", Value: []sift.KeyValue{{Key: "z", Value: "<"}, {Key: "a", Value: nil}}},
+			})),
+			want: `{"foo":12,"bar":{"z":"\u003c","a":null}}`,
 		},
 	} {
 		t.Run(tc.desc, func(t *testing.T) {
@@ -144,3 +178,122 @@ func TestEncode(t *testing.T) {
 		})
 	}
 }
+
+func TestEncodeErrorValue(t *testing.T) {
+	payload := sift.Must(sift.ToValue("bad"))
+	for _, tc := range []struct {
+		desc  string
+		value sift.Value
+	}{
+		{desc: "top", value: &sift.ErrorValue{Value: payload}},
+		{desc: "nested", value: sift.Must(sift.ToValue([]interface{}{1, &sift.ErrorValue{Value: payload}}))},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			w := &strings.Builder{}
+			err := json.NewEncoder(w).Encode(tc.value)
+			ev, ok := sift.AsErrorValue(err)
+			if !ok {
+				t.Fatalf("got error %v; want *sift.ErrorValue", err)
+			}
+			if !sift.Equal(ev.Value, payload) {
+				t.Errorf("got payload %v; want %v", ev.Value, payload)
+			}
+			if w.Len() > 0 {
+				t.Errorf("got output %q; want none", w.String())
+			}
+		})
+	}
+}
+
+func TestDecodeKeyOrder(t *testing.T) {
+	for _, tc := range []struct {
+		desc, input, want string
+		wantErr           bool
+	}{
+		{
+			desc:  "nested",
+			input: `{"z": 1, "b": {"y": [{"d": 1, "c": 2}], "a": null}, "m": {}}`,
+			want:  `{"z":1,"b":{"y":[{"d":1,"c":2}],"a":null},"m":{}}`,
+		}, {
+			desc:  "duplicate",
+			input: `{"z": 1, "b": 2, "z": 3}`,
+			want:  `{"z":3,"b":2}`,
+		}, {
+			desc:    "truncated",
+			input:   `{"z": [1`,
+			wantErr: true,
+		}, {
+			desc:    "syntax",
+			input:   `{"z": }`,
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dec := json.NewDecoder(strings.NewReader(tc.input))
+			v, err := dec.Decode()
+			if tc.wantErr {
+				if err == nil || err == io.EOF {
+					t.Fatalf("got error %v; want syntax error", err)
+				}
+				if _, again := dec.Decode(); again != err {
+					t.Errorf("decoding again: got error %v; want %v", again, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := &strings.Builder{}
+			if err := json.NewEncoder(w).Encode(v); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(w.String()); got != tc.want {
+				t.Errorf("got %s; want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNumberRoundTrip(t *testing.T) {
+	const input = `[1.0,12345678901234567890,-0.30000000000000000001,{"a":1e400}]`
+	v, err := json.NewDecoder(strings.NewReader(input)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &strings.Builder{}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	want := `[1,12345678901234567890,-0.30000000000000000001,{"a":1e400}]`
+	if got := strings.TrimSpace(w.String()); got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+}
+
+func TestAnnotatingDecoder(t *testing.T) {
+	const input = "{\"a\": 1}\n  [2]\n\"x\""
+	dec := json.NewAnnotatingDecoder(strings.NewReader(input), "in.json")
+	for _, want := range []struct {
+		offset int64
+		text   string
+	}{{0, `{"a":1}`}, {11, `[2]`}, {15, `"x"`}} {
+		v, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, ok := sift.AnnotationsOf(v)
+		if !ok {
+			t.Fatalf("value %v is not annotated", v)
+		}
+		if a.Source != "in.json" || a.Offset != want.offset {
+			t.Errorf("got annotations %v; want in.json@%d", a, want.offset)
+		}
+		w := &strings.Builder{}
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(w.String()); got != want.text {
+			t.Errorf("got %s; want %s", got, want.text)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// Package jsonseq provides an encoder and decoder for JSON text sequences
+// (RFC 7464), where each value is written as a record that begins with
+// an ASCII record separator (0x1E) and ends with a line feed.
+//
+// In integrity mode, each record also carries a CRC-32C checksum of its
+// JSON text, written after the text as " #" and eight hexadecimal digits.
+// When the encoder is flushed, it writes a checkpoint record: "!", the
+// number of values written so far, a space, and a checksum of all their
+// JSON texts. A verifying decoder checks every record and checkpoint, and
+// reports ErrTruncated if the stream doesn't end with a checkpoint, so
+// pipelines moving data between machines can detect truncation and
+// corruption.
+package jsonseq
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+)
+
+const (
+	recordSeparator = 0x1E
+	checksumMark    = " #"
+	checkpointMark  = '!'
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Errors reported by a decoder in integrity mode. Errors returned by the
+// decoder wrap these; use errors.Is to check for them.
+var (
+	ErrChecksum  = errors.New("checksum mismatch")
+	ErrTruncated = errors.New("stream truncated")
+)
+
+// Options controls how values are encoded and decoded.
+type Options struct {
+	// Integrity adds checksums to encoded records and checkpoints when the
+	// encoder is flushed. Decoders with Integrity set verify checksums and
+	// require the stream to end with a checkpoint. Decoders without
+	// Integrity ignore checksums and checkpoints.
+	Integrity bool
+}
+
+type encoder struct {
+	w    io.Writer
+	opts Options
+	buf  bytes.Buffer
+
+	// count and sum cover all values written so far.
+	count int
+	sum   uint32
+
+	// written is the number of bytes written to w.
+	written int64
+}
+
+// NewEncoder returns an Encoder that writes values to w as a JSON text
+// sequence. The returned Encoder implements sift.Flusher. In integrity
+// mode, Flush writes a checkpoint, and it must be called after the last
+// value is encoded; sift.Sift does this automatically.
+func NewEncoder(w io.Writer, opts Options) sift.Encoder {
+	return &encoder{w: w, opts: opts}
+}
+
+func (e *encoder) Encode(v sift.Value) error {
+	e.buf.Reset()
+	e.buf.WriteByte(recordSeparator)
+	if err := json.NewEncoder(&e.buf).Encode(v); err != nil {
+		return err
+	}
+	if e.opts.Integrity {
+		e.buf.Truncate(e.buf.Len() - 1) // newline
+		text := e.buf.Bytes()[1:]
+		e.count++
+		e.sum = crc32.Update(e.sum, castagnoli, text)
+		fmt.Fprintf(&e.buf, "%s%08x\n", checksumMark, crc32.Checksum(text, castagnoli))
+	}
+	n, err := e.w.Write(e.buf.Bytes())
+	e.written += int64(n)
+	return err
+}
+
+// Drain does nothing: values are written as they're encoded. Unlike Flush,
+// it doesn't write a checkpoint.
+func (e *encoder) Drain() error {
+	return nil
+}
+
+// ByteCount returns the number of bytes written, including checkpoints.
+func (e *encoder) ByteCount() int64 {
+	return e.written
+}
+
+// Flush writes a checkpoint in integrity mode. Otherwise, it does nothing.
+func (e *encoder) Flush() error {
+	if !e.opts.Integrity {
+		return nil
+	}
+	n, err := fmt.Fprintf(e.w, "%c%c%d %08x\n", recordSeparator, checkpointMark, e.count, e.sum)
+	e.written += int64(n)
+	return err
+}
+
+type decoder struct {
+	r    *bufio.Reader
+	opts Options
+
+	// started is set after the first record separator is read.
+	started bool
+
+	// n is the number of records read, for error messages.
+	n int
+
+	// count and sum cover all values decoded so far. checked is the count
+	// at the most recent checkpoint, or -1 if there hasn't been one.
+	count, checked int
+	sum            uint32
+
+	// read is the number of bytes read from r.
+	read int64
+}
+
+// NewDecoder returns a Decoder that reads a JSON text sequence from r.
+// Decode returns io.EOF at the end of the stream.
+func NewDecoder(r io.Reader, opts Options) sift.Decoder {
+	return &decoder{r: bufio.NewReader(r), opts: opts, checked: -1}
+}
+
+func (d *decoder) Decode() (sift.Value, error) {
+	for {
+		record, err := d.next()
+		if err == io.EOF {
+			if d.opts.Integrity && d.checked != d.count {
+				if d.checked < 0 {
+					return nil, fmt.Errorf("%w: no checkpoint after %d values", ErrTruncated, d.count)
+				}
+				return nil, fmt.Errorf("%w: %d values after the last checkpoint", ErrTruncated, d.count-d.checked)
+			}
+			return nil, io.EOF
+		} else if err != nil {
+			return nil, err
+		}
+		if len(record) == 0 {
+			continue
+		}
+		if record[0] == checkpointMark {
+			if err := d.checkpoint(record); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return d.value(record)
+	}
+}
+
+// ByteCount returns the number of bytes read from the stream.
+func (d *decoder) ByteCount() int64 {
+	return d.read
+}
+
+// next returns the contents of the next record, without the record
+// separator or trailing whitespace.
+func (d *decoder) next() ([]byte, error) {
+	if !d.started {
+		data, err := d.r.ReadBytes(recordSeparator)
+		d.read += int64(len(data))
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(bytes.TrimSuffix(data, []byte{recordSeparator}))) > 0 {
+			return nil, errors.New("data before first record separator")
+		}
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		d.started = true
+	}
+
+	// Reading through the next separator consumes the start of the next
+	// record, which is fine: every record starts with one.
+	data, err := d.r.ReadBytes(recordSeparator)
+	d.read += int64(len(data))
+	if err == io.EOF && len(data) == 0 {
+		return nil, io.EOF
+	} else if err != nil && err != io.EOF {
+		return nil, err
+	}
+	data = bytes.TrimSuffix(data, []byte{recordSeparator})
+	d.n++
+	if d.opts.Integrity && !bytes.HasSuffix(data, []byte{'\n'}) {
+		return nil, fmt.Errorf("record %d: %w: record does not end with a newline", d.n, ErrTruncated)
+	}
+	return bytes.TrimRight(data, " \t\r\n"), nil
+}
+
+// value decodes a record's JSON text, verifying its checksum in integrity
+// mode.
+func (d *decoder) value(record []byte) (sift.Value, error) {
+	text, sum, hasSum := splitChecksum(record)
+	if d.opts.Integrity {
+		if !hasSum {
+			return nil, fmt.Errorf("record %d: no checksum", d.n)
+		}
+		if got := crc32.Checksum(text, castagnoli); got != sum {
+			return nil, fmt.Errorf("record %d: %w: got %08x; want %08x", d.n, ErrChecksum, got, sum)
+		}
+		d.count++
+		d.sum = crc32.Update(d.sum, castagnoli, text)
+	}
+	v, err := json.NewDecoder(bytes.NewReader(text)).Decode()
+	if err != nil {
+		return nil, fmt.Errorf("record %d: %v", d.n, err)
+	}
+	return v, nil
+}
+
+// checkpoint verifies a checkpoint record in integrity mode.
+func (d *decoder) checkpoint(record []byte) error {
+	if !d.opts.Integrity {
+		return nil
+	}
+	fields := bytes.Fields(record[1:])
+	if len(fields) != 2 {
+		return fmt.Errorf("record %d: malformed checkpoint", d.n)
+	}
+	count, err := strconv.Atoi(string(fields[0]))
+	if err != nil {
+		return fmt.Errorf("record %d: malformed checkpoint", d.n)
+	}
+	sum, err := strconv.ParseUint(string(fields[1]), 16, 32)
+	if err != nil {
+		return fmt.Errorf("record %d: malformed checkpoint", d.n)
+	}
+	if count != d.count {
+		return fmt.Errorf("record %d: %w: checkpoint counts %d values; read %d", d.n, ErrTruncated, count, d.count)
+	}
+	if uint32(sum) != d.sum {
+		return fmt.Errorf("record %d: %w: stream checksum %08x; want %08x", d.n, ErrChecksum, d.sum, sum)
+	}
+	d.checked = d.count
+	return nil
+}
+
+// splitChecksum splits a record into its JSON text and checksum, if it has
+// one. A JSON text can't end with the checksum mark followed by hex digits,
+// so there's no ambiguity.
+func splitChecksum(record []byte) (text []byte, sum uint32, ok bool) {
+	n := len(checksumMark) + 8
+	if len(record) < n || string(record[len(record)-n:len(record)-8]) != checksumMark {
+		return record, 0, false
+	}
+	s, err := strconv.ParseUint(string(record[len(record)-8:]), 16, 32)
+	if err != nil {
+		return record, 0, false
+	}
+	return record[:len(record)-n], uint32(s), true
+}
@@ -0,0 +1,172 @@
+package jsonseq_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+	"go.jayconrod.com/sift/encoding/jsonseq"
+)
+
+var testValues = []interface{}{
+	map[string]interface{}{"a": 1, "s": "x #0000000a"},
+	[]interface{}{"\x1e", "line\nbreak"},
+	"str",
+	nil,
+}
+
+func encode(t *testing.T, opts jsonseq.Options, flushEvery int) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	enc := jsonseq.NewEncoder(buf, opts)
+	for i, x := range testValues {
+		if err := enc.Encode(sift.Must(sift.ToValue(x))); err != nil {
+			t.Fatal(err)
+		}
+		if flushEvery > 0 && (i+1)%flushEvery == 0 {
+			if err := enc.(sift.Flusher).Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := enc.(sift.Flusher).Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decodeAll(data []byte, opts jsonseq.Options) ([]sift.Value, error) {
+	dec := jsonseq.NewDecoder(bytes.NewReader(data), opts)
+	var vs []sift.Value
+	for {
+		v, err := dec.Decode()
+		if err == io.EOF {
+			return vs, nil
+		} else if err != nil {
+			return vs, err
+		}
+		vs = append(vs, v)
+	}
+}
+
+func checkValues(t *testing.T, vs []sift.Value) {
+	t.Helper()
+	if len(vs) != len(testValues) {
+		t.Fatalf("got %d values; want %d", len(vs), len(testValues))
+	}
+	for i, v := range vs {
+		got, want := jsonString(t, v), jsonString(t, sift.Must(sift.ToValue(testValues[i])))
+		if got != want {
+			t.Errorf("value %d: got %s; want %s", i, got, want)
+		}
+	}
+}
+
+func jsonString(t *testing.T, v sift.Value) string {
+	t.Helper()
+	b := &strings.Builder{}
+	if err := json.NewEncoder(b).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestPlain(t *testing.T) {
+	data := encode(t, jsonseq.Options{}, 0)
+	if got, want := bytes.Count(data, []byte{0x1e}), len(testValues); got != want {
+		t.Errorf("got %d record separators; want %d", got, want)
+	}
+	vs, err := decodeAll(data, jsonseq.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkValues(t, vs)
+
+	if _, err := decodeAll(data, jsonseq.Options{Integrity: true}); err == nil {
+		t.Error("verifying stream without checksums: got success; want error")
+	}
+}
+
+func TestIntegrity(t *testing.T) {
+	for _, flushEvery := range []int{0, 1, 3} {
+		data := encode(t, jsonseq.Options{Integrity: true}, flushEvery)
+
+		vs, err := decodeAll(data, jsonseq.Options{Integrity: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkValues(t, vs)
+
+		// Decoders that don't verify ignore checksums and checkpoints.
+		vs, err = decodeAll(data, jsonseq.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkValues(t, vs)
+
+		// Every proper prefix of the stream is detected as truncated, except
+		// those that end just after a checkpoint.
+		for n := 0; n < len(data); n++ {
+			if endsWithCheckpoint(data[:n]) {
+				continue
+			}
+			if _, err := decodeAll(data[:n], jsonseq.Options{Integrity: true}); !errors.Is(err, jsonseq.ErrTruncated) {
+				t.Errorf("flush every %d, truncated to %d bytes: got error %v; want ErrTruncated", flushEvery, n, err)
+			}
+		}
+	}
+}
+
+func endsWithCheckpoint(data []byte) bool {
+	data = bytes.TrimSuffix(data, []byte{0x1e})
+	i := bytes.LastIndexByte(data, 0x1e)
+	return i >= 0 && bytes.HasPrefix(data[i:], []byte("\x1e!")) && bytes.HasSuffix(data, []byte("\n"))
+}
+
+func TestCorruption(t *testing.T) {
+	data := encode(t, jsonseq.Options{Integrity: true}, 0)
+	corrupt := bytes.Replace(data, []byte(`"str"`), []byte(`"stR"`), 1)
+	_, err := decodeAll(corrupt, jsonseq.Options{Integrity: true})
+	if !errors.Is(err, jsonseq.ErrChecksum) || !strings.Contains(err.Error(), "record 3") {
+		t.Errorf("got error %v; want ErrChecksum in record 3", err)
+	}
+
+	// Dropping a whole record is caught by the checkpoint.
+	records := bytes.SplitAfter(data, []byte("\n"))
+	dropped := bytes.Join(append(records[:1:1], records[2:]...), nil)
+	if _, err := decodeAll(dropped, jsonseq.Options{Integrity: true}); !errors.Is(err, jsonseq.ErrTruncated) {
+		t.Errorf("dropped record: got error %v; want ErrTruncated", err)
+	}
+}
+
+func TestByteCount(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := jsonseq.NewEncoder(buf, jsonseq.Options{Integrity: true})
+	for _, x := range testValues {
+		if err := enc.Encode(sift.Must(sift.ToValue(x))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.(sift.Flusher).Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := enc.(sift.ByteCounter).ByteCount(), int64(buf.Len()); got != want {
+		t.Errorf("encoder wrote %d bytes; counted %d", want, got)
+	}
+
+	dec := jsonseq.NewDecoder(bytes.NewReader(buf.Bytes()), jsonseq.Options{Integrity: true})
+	for {
+		if _, err := dec.Decode(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := dec.(sift.ByteCounter).ByteCount(), int64(buf.Len()); got != want {
+		t.Errorf("decoder read %d bytes; counted %d", want, got)
+	}
+}
@@ -0,0 +1,300 @@
+// Package sql provides encoders that convert objects into SQL INSERT
+// statements, either written as text or executed against a database.
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.jayconrod.com/sift"
+)
+
+// Placeholder is a style of parameter placeholder used in statements.
+type Placeholder int
+
+const (
+	// Question placeholders are written as "?", as in MySQL and SQLite.
+	Question Placeholder = iota
+
+	// Dollar placeholders are numbered, like "$1", as in PostgreSQL.
+	Dollar
+)
+
+// Options controls how INSERT statements are generated.
+type Options struct {
+	// Table is the name of the table rows are inserted into.
+	Table string
+
+	// Columns is the list of columns to insert. Each value must be an
+	// object; its attributes with these names are inserted, and missing
+	// attributes are inserted as NULL. If Columns is empty, the keys of the
+	// first value are used.
+	Columns []string
+
+	// Placeholder is the style of parameter placeholders.
+	Placeholder Placeholder
+
+	// BatchSize is the maximum number of rows inserted by one statement.
+	// If zero, one row is inserted per statement by NewEncoder, and 100 rows
+	// are inserted per statement by NewExecEncoder.
+	BatchSize int
+}
+
+// batch accumulates rows for INSERT statements.
+type batch struct {
+	opts Options
+	rows [][]interface{}
+
+	// literals is true if values are written into statements as SQL
+	// literals instead of being passed as arguments.
+	literals bool
+}
+
+func (b *batch) add(v sift.Value) error {
+	a, ok := v.(sift.Attr)
+	if !ok {
+		return fmt.Errorf("cannot insert value %v: not an object", v)
+	}
+	if len(b.opts.Columns) == 0 {
+		for _, key := range a.Keys() {
+			name, ok := sift.AsString(key)
+			if !ok {
+				return fmt.Errorf("cannot insert object with non-string key %v", key)
+			}
+			b.opts.Columns = append(b.opts.Columns, name)
+		}
+		if len(b.opts.Columns) == 0 {
+			return fmt.Errorf("cannot insert empty object")
+		}
+	}
+
+	row := make([]interface{}, len(b.opts.Columns))
+	for i, name := range b.opts.Columns {
+		cv, ok := sift.GetStringAttr(a, name)
+		if !ok {
+			continue
+		}
+		arg, err := toArg(cv)
+		if err != nil {
+			return fmt.Errorf("column %s: %v", name, err)
+		}
+		if f, ok := arg.(float64); ok && b.literals && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			return fmt.Errorf("column %s: cannot write %v as a SQL literal", name, f)
+		}
+		row[i] = arg
+	}
+	b.rows = append(b.rows, row)
+	return nil
+}
+
+// number is the text of a number that doesn't fit in an int64 or float64.
+// It's passed to a database driver as a string, so the database parses it
+// without losing precision, and it's written unquoted as a literal.
+type number string
+
+// toArg converts a scalar value to a Go value that may be passed to
+// a database driver as a statement argument, except that big numbers are
+// converted to number.
+func toArg(v sift.Value) (interface{}, error) {
+	if sift.IsNull(v) {
+		return nil, nil
+	} else if b, ok := sift.AsBool(v); ok {
+		return b, nil
+	} else if text, ok := sift.AsBigNumber(v); ok {
+		// Pass integers exactly if the driver can accept them. Otherwise,
+		// let the database parse the number, so no precision is lost.
+		if i, ok := sift.AsBigInt(v); ok && i.IsInt64() {
+			return i.Int64(), nil
+		}
+		return number(text), nil
+	} else if f, ok := sift.AsFloat64(v); ok {
+		if i := int64(f); float64(i) == f && math.Abs(f) < 1<<53 {
+			return i, nil
+		}
+		return f, nil
+	} else if s, ok := sift.AsString(v); ok {
+		return s, nil
+	} else if t, ok := sift.AsTime(v); ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("cannot insert value %v: not a scalar", v)
+}
+
+// statement returns an INSERT statement for the rows in the batch. If
+// b.literals is true, values are written into the statement as SQL
+// literals. Otherwise, the statement has a placeholder for each value, and
+// the values are returned as arguments.
+func (b *batch) statement() (string, []interface{}) {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "INSERT INTO %s (", quoteIdentifier(b.opts.Table))
+	for i, name := range b.opts.Columns {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(quoteIdentifier(name))
+	}
+	sb.WriteString(") VALUES ")
+	var args []interface{}
+	for i, row := range b.rows {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(")
+		for j, arg := range row {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			if b.literals {
+				sb.WriteString(literal(arg))
+				continue
+			}
+			if n, ok := arg.(number); ok {
+				arg = string(n)
+			}
+			args = append(args, arg)
+			if b.opts.Placeholder == Dollar {
+				fmt.Fprintf(sb, "$%d", len(args))
+			} else {
+				sb.WriteString("?")
+			}
+		}
+		sb.WriteString(")")
+	}
+	return sb.String(), args
+}
+
+func literal(arg interface{}) string {
+	switch arg := arg.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if arg {
+			return "TRUE"
+		}
+		return "FALSE"
+	case int64:
+		return strconv.FormatInt(arg, 10)
+	case float64:
+		return strconv.FormatFloat(arg, 'g', -1, 64)
+	case number:
+		return string(arg)
+	case string:
+		return "'" + strings.ReplaceAll(arg, "'", "''") + "'"
+	case time.Time:
+		return "'" + arg.UTC().Format("2006-01-02 15:04:05.999999999Z07:00") + "'"
+	default:
+		panic(fmt.Sprintf("unexpected argument %#v", arg))
+	}
+}
+
+func quoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = `"` + strings.ReplaceAll(p, `"`, `""`) + `"`
+	}
+	return strings.Join(parts, ".")
+}
+
+type encoder struct {
+	w io.Writer
+	b batch
+}
+
+// NewEncoder returns an Encoder that writes INSERT statements to w, one per
+// line, with values written as SQL literals. Values must be objects with
+// scalar attributes. The returned Encoder implements sift.Flusher; Flush
+// must be called after the last value is encoded to write the final
+// statement.
+func NewEncoder(w io.Writer, opts Options) (sift.Encoder, error) {
+	if opts.Table == "" {
+		return nil, fmt.Errorf("table not set")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1
+	}
+	return &encoder{w: w, b: batch{opts: opts, literals: true}}, nil
+}
+
+func (e *encoder) Encode(v sift.Value) error {
+	if err := e.b.add(v); err != nil {
+		return err
+	}
+	if len(e.b.rows) >= e.b.opts.BatchSize {
+		return e.Flush()
+	}
+	return nil
+}
+
+func (e *encoder) Flush() error {
+	if len(e.b.rows) == 0 {
+		return nil
+	}
+	stmt, _ := e.b.statement()
+	e.b.rows = e.b.rows[:0]
+	_, err := fmt.Fprintf(e.w, "%s;\n", stmt)
+	return err
+}
+
+// ExecEncoder is an Encoder that inserts values into a database table.
+// Rows are inserted in batches; each batch is inserted by a single
+// statement within a transaction. ExecEncoder implements sift.Flusher;
+// Flush must be called after the last value is encoded to insert the
+// final batch.
+type ExecEncoder struct {
+	db *sql.DB
+	b  batch
+}
+
+var _ sift.Flusher = (*ExecEncoder)(nil)
+
+// NewExecEncoder returns an ExecEncoder that inserts values into db
+// according to opts.
+func NewExecEncoder(db *sql.DB, opts Options) (*ExecEncoder, error) {
+	if opts.Table == "" {
+		return nil, fmt.Errorf("table not set")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	return &ExecEncoder{db: db, b: batch{opts: opts}}, nil
+}
+
+// Encode adds v to the current batch, inserting the batch if it is full.
+func (e *ExecEncoder) Encode(v sift.Value) error {
+	if err := e.b.add(v); err != nil {
+		return err
+	}
+	if len(e.b.rows) >= e.b.opts.BatchSize {
+		return e.Flush()
+	}
+	return nil
+}
+
+// Flush inserts the current batch, if it is not empty. If the insert fails,
+// the transaction is rolled back.
+func (e *ExecEncoder) Flush() (err error) {
+	if len(e.b.rows) == 0 {
+		return nil
+	}
+	query, args := e.b.statement()
+	e.b.rows = e.b.rows[:0]
+
+	tx, err := e.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
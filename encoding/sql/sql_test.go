@@ -0,0 +1,199 @@
+package sql_test
+
+import (
+	dbsql "database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/sql"
+)
+
+func TestEncoder(t *testing.T) {
+	for _, tc := range []struct {
+		desc   string
+		opts   sql.Options
+		values []interface{}
+		want   string
+	}{
+		{
+			desc: "keys",
+			opts: sql.Options{Table: "t"},
+			values: []interface{}{
+				map[string]interface{}{"a": 1, "b": "x'y"},
+				map[string]interface{}{"a": 2.5, "c": true},
+			},
+			want: `
+INSERT INTO "t" ("a", "b") VALUES (1, 'x''y');
+INSERT INTO "t" ("a", "b") VALUES (2.5, NULL);
+`,
+		}, {
+			desc: "columns_batch",
+			opts: sql.Options{Table: "s.t", Columns: []string{"b", "a"}, BatchSize: 2},
+			values: []interface{}{
+				map[string]interface{}{"a": 1, "b": nil},
+				map[string]interface{}{"a": 2, "b": false},
+				map[string]interface{}{"a": 3},
+			},
+			want: `
+INSERT INTO "s"."t" ("b", "a") VALUES (NULL, 1), (FALSE, 2);
+INSERT INTO "s"."t" ("b", "a") VALUES (NULL, 3);
+`,
+		}, {
+			desc: "time_big",
+			opts: sql.Options{Table: "t", Columns: []string{"t", "n"}},
+			values: []interface{}{
+				map[string]interface{}{
+					"t": time.Date(2024, 1, 2, 3, 4, 5, 500000000, time.FixedZone("", 2*60*60)),
+					"n": sift.Must(sift.NewBigNumber("123456789012345678901234567890")),
+				},
+			},
+			want: `
+INSERT INTO "t" ("t", "n") VALUES ('2024-01-02 01:04:05.5Z', 123456789012345678901234567890);
+`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			w := &strings.Builder{}
+			enc, err := sql.NewEncoder(w, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, i := range tc.values {
+				if err := enc.Encode(sift.Must(sift.ToValue(i))); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := enc.(sift.Flusher).Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := strings.TrimSpace(w.String()), strings.TrimSpace(tc.want); got != want {
+				t.Errorf("got:\n%s\n\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestEncoderNotScalar(t *testing.T) {
+	enc, err := sql.NewEncoder(&strings.Builder{}, sql.Options{Table: "t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := sift.Must(sift.ToValue(map[string]interface{}{"a": []interface{}{1}}))
+	if err := enc.Encode(v); err == nil || !strings.Contains(err.Error(), "not a scalar") {
+		t.Errorf("got error %v; want error about scalar", err)
+	}
+}
+
+func TestEncoderNonFinite(t *testing.T) {
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		enc, err := sql.NewEncoder(&strings.Builder{}, sql.Options{Table: "t"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := sift.Must(sift.ToValue(map[string]interface{}{"a": f}))
+		if err := enc.Encode(v); err == nil || !strings.Contains(err.Error(), "SQL literal") {
+			t.Errorf("encoding %v: got error %v; want error about SQL literal", f, err)
+		}
+	}
+}
+
+func TestExecEncoder(t *testing.T) {
+	var log []string
+	dbsql.Register("siftfake", &fakeDriver{log: &log})
+	db, err := dbsql.Open("siftfake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	enc, err := sql.NewExecEncoder(db, sql.Options{Table: "t", Placeholder: sql.Dollar, BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []interface{}{
+		map[string]interface{}{"a": 1, "b": "x"},
+		map[string]interface{}{"a": 2, "b": "y"},
+		map[string]interface{}{"a": 3, "b": "fail"},
+	} {
+		if err := enc.Encode(sift.Must(sift.ToValue(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Flush(); err == nil {
+		t.Error("Flush: got success; want error")
+	}
+
+	want := []string{
+		"begin",
+		`exec INSERT INTO "t" ("a", "b") VALUES ($1, $2), ($3, $4) [1 x 2 y]`,
+		"commit",
+		"begin",
+		`exec INSERT INTO "t" ("a", "b") VALUES ($1, $2) [3 fail]`,
+		"rollback",
+	}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("got:\n%s\n\nwant:\n%s", strings.Join(log, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// fakeDriver is a database driver that records the statements it executes.
+// Statements with an argument "fail" return an error.
+type fakeDriver struct {
+	log *[]string
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{log: d.log}, nil }
+
+type fakeConn struct {
+	log *[]string
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{log: c.log, query: query}, nil
+}
+func (c *fakeConn) Close() error { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	*c.log = append(*c.log, "begin")
+	return &fakeTx{log: c.log}, nil
+}
+
+type fakeTx struct {
+	log *[]string
+}
+
+func (tx *fakeTx) Commit() error {
+	*tx.log = append(*tx.log, "commit")
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	*tx.log = append(*tx.log, "rollback")
+	return nil
+}
+
+type fakeStmt struct {
+	log   *[]string
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	*s.log = append(*s.log, fmt.Sprintf("exec %s %v", s.query, args))
+	for _, arg := range args {
+		if arg == "fail" {
+			return nil, errors.New("fail")
+		}
+	}
+	return driver.RowsAffected(len(args)), nil
+}
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not implemented")
+}
@@ -0,0 +1,228 @@
+package sift
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// DefaultMaxEqualDepth is the default limit on how deeply nested values
+// compared by Equal may be.
+const DefaultMaxEqualDepth = 10000
+
+// ErrTooDeep is returned by CheckEqual when values are nested more deeply
+// than the limit set with MaxEqualDepth.
+var ErrTooDeep = errors.New("values are nested too deeply")
+
+// An EqualOption changes how Equal and CheckEqual compare values.
+type EqualOption func(*equalOptions)
+
+type equalOptions struct {
+	tolerance float64
+	maxDepth  int
+}
+
+// Tolerance returns an option that makes numbers equal if they differ by
+// no more than t. Big numbers are compared approximately, as float64, when
+// t is positive.
+func Tolerance(t float64) EqualOption {
+	return func(o *equalOptions) { o.tolerance = t }
+}
+
+// MaxEqualDepth returns an option that limits how deeply nested values may
+// be. Equal reports that values nested more deeply than n are not equal;
+// CheckEqual returns an error wrapping ErrTooDeep. The default is
+// DefaultMaxEqualDepth.
+func MaxEqualDepth(n int) EqualOption {
+	return func(o *equalOptions) { o.maxDepth = n }
+}
+
+// Equal returns whether two values are equivalent.
+//
+// Objects are equal if they have equal values for the same keys, in any
+// order. Arrays are equal if they have equal elements in the same order.
+// Equal terminates on values that contain themselves, like an object
+// implementation whose attribute is the object itself: when the same pair
+// of objects or arrays is reached again, they're assumed to be equal.
+// Values nested more deeply than a limit are reported as not equal; use
+// CheckEqual to distinguish that case.
+func Equal(l, r Value, opts ...EqualOption) bool {
+	eq, err := CheckEqual(l, r, opts...)
+	return eq && err == nil
+}
+
+// CheckEqual is like Equal, but it returns an error instead of false if
+// the values are nested too deeply to compare.
+func CheckEqual(l, r Value, opts ...EqualOption) (bool, error) {
+	e := equalState{equalOptions: equalOptions{maxDepth: DefaultMaxEqualDepth}}
+	for _, opt := range opts {
+		opt(&e.equalOptions)
+	}
+	return e.equal(l, r, 0)
+}
+
+type equalState struct {
+	equalOptions
+
+	// visited records pairs of objects or arrays that have been compared or
+	// are being compared, so that cycles and shared values are only
+	// compared once.
+	visited map[equalVisit]bool
+}
+
+// equalVisit identifies a pair of objects or arrays by their underlying
+// maps, pointers, or slices.
+type equalVisit struct {
+	l, r   identity
+	lt, rt reflect.Type
+}
+
+type identity struct {
+	ptr uintptr
+	len int
+}
+
+// identify returns an identity for values with reference types.
+func identify(v Value) (identity, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Ptr:
+		return identity{ptr: rv.Pointer()}, !rv.IsNil()
+	case reflect.Slice:
+		return identity{ptr: rv.Pointer(), len: rv.Len()}, rv.Len() > 0
+	}
+	return identity{}, false
+}
+
+// seen records that l and r are being compared and returns whether they
+// were already.
+func (e *equalState) seen(l, r Value) bool {
+	li, lok := identify(l)
+	ri, rok := identify(r)
+	if !lok || !rok {
+		return false
+	}
+	v := equalVisit{l: li, r: ri, lt: reflect.TypeOf(l), rt: reflect.TypeOf(r)}
+	if e.visited[v] {
+		return true
+	}
+	if e.visited == nil {
+		e.visited = make(map[equalVisit]bool)
+	}
+	e.visited[v] = true
+	return false
+}
+
+func (e *equalState) equal(l, r Value, depth int) (bool, error) {
+	if IsNull(l) {
+		return IsNull(r), nil
+	} else if _, ok := AsBigNumber(l); ok {
+		return e.equalNumbers(l, r), nil
+	} else if _, ok := AsBigNumber(r); ok {
+		return e.equalNumbers(l, r), nil
+	} else if lb, ok := AsBytes(l); ok {
+		rb, ok := AsBytes(r)
+		return ok && bytes.Equal(lb, rb), nil
+	} else if _, ok := AsBytes(r); ok {
+		return false, nil
+	} else if lt, ok := AsTime(l); ok {
+		rt, ok := AsTime(r)
+		return ok && lt.Equal(rt), nil
+	} else if _, ok := AsTime(r); ok {
+		return false, nil
+	} else if lb, ok := AsBool(l); ok {
+		rb, ok := AsBool(r)
+		return ok && lb == rb, nil
+	} else if _, ok := AsFloat64(l); ok {
+		return e.equalNumbers(l, r), nil
+	} else if ls, ok := AsString(l); ok {
+		rs, ok := AsString(r)
+		return ok && ls == rs, nil
+	}
+
+	la, lattr := l.(Attr)
+	li, lindex := l.(Index)
+	if !lattr && !lindex {
+		return false, nil
+	}
+	if depth > e.maxDepth {
+		return false, fmt.Errorf("%w: more than %d levels", ErrTooDeep, e.maxDepth)
+	}
+	if lattr {
+		ra, ok := r.(Attr)
+		if !ok {
+			return false, nil
+		}
+		if e.seen(l, r) {
+			return true, nil
+		}
+		lkeys := la.Keys()
+		if len(lkeys) != len(ra.Keys()) {
+			return false, nil
+		}
+		for _, key := range lkeys {
+			lvalue, ok := la.Attr(key)
+			if !ok {
+				return false, nil
+			}
+			rvalue, ok := ra.Attr(key)
+			if !ok {
+				return false, nil
+			}
+			if eq, err := e.equal(lvalue, rvalue, depth+1); !eq || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	ri, ok := r.(Index)
+	if !ok {
+		return false, nil
+	}
+	if e.seen(l, r) {
+		return true, nil
+	}
+	ln, rn := li.Length(), ri.Length()
+	if ln != rn {
+		return false, nil
+	}
+	for i := 0; i < ln; i++ {
+		le, lok := li.Index(i)
+		re, rok := ri.Index(i)
+		if lok != rok {
+			return false, nil
+		}
+		if !lok {
+			continue
+		}
+		if eq, err := e.equal(le, re, depth+1); !eq || err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// equalNumbers compares numbers, exactly if either is a big number and
+// there's no tolerance.
+func (e *equalState) equalNumbers(l, r Value) bool {
+	lf, ok := AsFloat64(l)
+	if !ok {
+		return false
+	}
+	rf, ok := AsFloat64(r)
+	if !ok {
+		return false
+	}
+	if e.tolerance > 0 {
+		return lf == rf || math.Abs(lf-rf) <= e.tolerance
+	}
+	_, lbig := AsBigNumber(l)
+	_, rbig := AsBigNumber(r)
+	if lbig || rbig {
+		return compareNumbers(l, r) == 0
+	}
+	return lf == rf
+}
@@ -0,0 +1,79 @@
+package sift_test
+
+import (
+	"errors"
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+// loop is an object whose only attribute is itself.
+type loop struct{ name string }
+
+func (l *loop) Truth() bool        { return true }
+func (l *loop) Keys() []sift.Value { return []sift.Value{sift.Must(sift.ToValue(l.name))} }
+
+func (l *loop) Attr(key sift.Value) (sift.Value, bool) {
+	if s, ok := sift.AsString(key); ok && s == l.name {
+		return l, true
+	}
+	return nil, false
+}
+
+// nest returns an array nested n levels deep.
+func nest(n int) sift.Value {
+	v := sift.Must(sift.ToValue([]interface{}{}))
+	for i := 0; i < n; i++ {
+		v = sift.Must(sift.ToValue([]sift.Value{v}))
+	}
+	return v
+}
+
+func TestEqual(t *testing.T) {
+	num := func(f float64) sift.Value { return sift.Must(sift.ToValue(f)) }
+	tenth, fifth := 0.1, 0.2
+	shared := sift.Must(sift.ToValue([]interface{}{1, 2}))
+	for _, tc := range []struct {
+		desc    string
+		l, r    sift.Value
+		opts    []sift.EqualOption
+		want    bool
+		wantErr error
+	}{
+		{desc: "cycle", l: &loop{"a"}, r: &loop{"a"}, want: true},
+		{desc: "cycle_different", l: &loop{"a"}, r: &loop{"b"}, want: false},
+		{desc: "cycle_self", l: &loop{"a"}, r: sift.Must(sift.ToValue(map[string]interface{}{"a": 1})), want: false},
+		{desc: "shared", l: sift.Must(sift.ToValue([]sift.Value{shared, shared})), r: sift.Must(sift.ToValue([]interface{}{[]interface{}{1, 2}, []interface{}{1, 2}})), want: true},
+		{desc: "deep", l: nest(100), r: nest(100), want: true},
+		{desc: "too_deep", l: nest(5), r: nest(5), opts: []sift.EqualOption{sift.MaxEqualDepth(3)}, wantErr: sift.ErrTooDeep},
+		{desc: "not_too_deep", l: nest(3), r: nest(3), opts: []sift.EqualOption{sift.MaxEqualDepth(3)}, want: true},
+		{desc: "exact", l: num(tenth + fifth), r: num(0.3), want: false},
+		{desc: "tolerance", l: num(tenth + fifth), r: num(0.3), opts: []sift.EqualOption{sift.Tolerance(1e-9)}, want: true},
+		{desc: "tolerance_nested", l: sift.Must(sift.ToValue([]interface{}{1.0})), r: sift.Must(sift.ToValue([]interface{}{1.05})), opts: []sift.EqualOption{sift.Tolerance(0.1)}, want: true},
+		{desc: "tolerance_exceeded", l: num(1), r: num(1.5), opts: []sift.EqualOption{sift.Tolerance(0.1)}, want: false},
+		{desc: "tolerance_big", l: sift.Must(sift.NewBigNumber("1.00000000000000000001")), r: num(1), opts: []sift.EqualOption{sift.Tolerance(1e-9)}, want: true},
+		{desc: "big_exact", l: sift.Must(sift.NewBigNumber("1.00000000000000000001")), r: num(1), want: false},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := sift.CheckEqual(tc.l, tc.r, tc.opts...)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("got error %v; want %v", err, tc.wantErr)
+				}
+				if sift.Equal(tc.l, tc.r, tc.opts...) {
+					t.Error("Equal: got true; want false")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("CheckEqual: got %v; want %v", got, tc.want)
+			}
+			if got := sift.Equal(tc.l, tc.r, tc.opts...); got != tc.want {
+				t.Errorf("Equal: got %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package sift
+
+import "errors"
+
+// ErrorValue is an error raised with a value as its payload, like the
+// argument of jq's error builtin. It's both an error and a Value, so it may
+// be returned from a filter to stop evaluation, or produced as an output
+// by code that reports errors alongside data, like a LazyAttr field that
+// couldn't be produced. The JSON encoder returns an error wrapping it
+// instead of writing its payload as data, and jq raises it when it's
+// accessed, so it may be caught with try. Callers may use AsErrorValue to
+// find it in an error's chain.
+type ErrorValue struct {
+	// Value is the error's payload. It's usually a string message, but it
+	// may be any value, like an object describing what went wrong.
+	Value Value
+
+	// Position optionally describes where the error was raised, like
+	// "prog.jq:3:7". It's empty if the location is unknown.
+	Position string
+}
+
+// Error returns the payload if it's a string. Other payloads are formatted
+// like jq does, as "(not a string): " followed by the value. Position is
+// not included, since errors that wrap an ErrorValue usually report it.
+func (e *ErrorValue) Error() string {
+	if msg, ok := AsString(e.Value); ok {
+		return msg
+	}
+	return "(not a string): " + keyString(e.Value)
+}
+
+func (e *ErrorValue) Truth() bool { return true }
+
+// AsErrorValue returns the first *ErrorValue in err's chain and true.
+// If there's none, nil and false are returned.
+func AsErrorValue(err error) (*ErrorValue, bool) {
+	var e *ErrorValue
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
@@ -1,7 +1,11 @@
 package sift
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"reflect"
+	"time"
 )
 
 // A Decoder reads values from a stream of data in an unspecified format.
@@ -17,6 +21,71 @@ type Encoder interface {
 	Encode(Value) error
 }
 
+// A Flusher is implemented by Encoders that buffer values before writing
+// them. Flush writes any buffered values.
+type Flusher interface {
+	Flush() error
+}
+
+// A Drainer is implemented by Encoders that hold values before writing
+// them, like BatchEncoder, and that can write the values they hold without
+// ending the stream. Some Encoders treat Flush as the end of the stream:
+// SortStream sorts and writes everything it has seen, for example. Drain
+// may be called at any point without changing what's eventually written,
+// other than how values are grouped into batches.
+type Drainer interface {
+	Drain() error
+}
+
+// drain calls enc's Drain method. An Encoder that's a Flusher but not
+// a Drainer may hold values it can't write early, so drain returns an error
+// for it. Other Encoders are assumed to write values as they're encoded.
+func drain(enc Encoder) error {
+	if d, ok := enc.(Drainer); ok {
+		return d.Drain()
+	}
+	if _, ok := enc.(Flusher); ok {
+		return fmt.Errorf("cannot write values held by encoder %T before the end of the stream", enc)
+	}
+	return nil
+}
+
+// A ByteCounter is implemented by Decoders that report how many bytes of
+// input they've consumed and by Encoders that report how many bytes they've
+// written. SiftWithOptions uses ByteCount to fill in SiftStats.
+type ByteCounter interface {
+	ByteCount() int64
+}
+
+// byteCount returns x's byte count if it's a ByteCounter, or 0 otherwise.
+// Decoders and Encoders that wrap others use it to report the byte counts
+// of the values they wrap.
+func byteCount(x interface{}) int64 {
+	if bc, ok := x.(ByteCounter); ok {
+		return bc.ByteCount()
+	}
+	return 0
+}
+
+// sameError reports whether a decoder returned the same error twice in
+// a row, meaning it can't continue after the error. Errors of comparable
+// types are compared with ==. Comparing other errors, like slices of
+// errors, with == would panic, so they're the same if they have the same
+// type and message.
+func sameError(a, b error) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	t := reflect.TypeOf(a)
+	if t != reflect.TypeOf(b) {
+		return false
+	}
+	if !t.Comparable() {
+		return a.Error() == b.Error()
+	}
+	return a == b
+}
+
 // A Filter reads and transforms a value. The value may have been produced
 // by a Decoder or another Filter, so its representation may not be known.
 // Zero or more values may be emitted.
@@ -58,53 +127,107 @@ func FlatMap(f func(Value) []Value) Filter {
 	}
 }
 
-// Compose returns a Filter that applies f to a value, then applies g to
-// each value of the result. An error is returned if either f or g return
-// an error for any value.
-func Compose(f, g Filter) Filter {
+// Select returns a Filter that produces each value it consumes for which
+// pred returns true and produces nothing for other values.
+func Select(pred func(Value) (bool, error)) Filter {
 	return func(v Value) ([]Value, error) {
-		vfs, err := f(v)
-		if err != nil {
+		ok, err := pred(v)
+		if err != nil || !ok {
 			return nil, err
 		}
-		var vout []Value
-		for _, vf := range vfs {
-			vg, err := g(vf)
-			if err != nil {
-				return nil, err
-			}
-			vout = append(vout, vg...)
-		}
-		return vout, nil
+		return []Value{v}, nil
 	}
 }
 
+// Reject returns a Filter that produces each value it consumes for which
+// pred returns false and produces nothing for other values.
+func Reject(pred func(Value) (bool, error)) Filter {
+	return Select(func(v Value) (bool, error) {
+		ok, err := pred(v)
+		return !ok, err
+	})
+}
+
+// Compose returns a Filter that applies f to a value, then applies g to
+// each value of the result. An error is returned if either f or g return
+// an error for any value.
+func Compose(f, g Filter) Filter {
+	return Collecting(ComposeEmit(Emitting(f), Emitting(g)))
+}
+
 // Binary returns a filter that applies x and y to an input value, then applies
 // op to the Cartesian product of the outputs of x and y.
 func Binary(x, y Filter, op func(xv, yv Value) ([]Value, error)) Filter {
-	return func(v Value) ([]Value, error) {
-		xvs, err := x(v)
+	return Collecting(BinaryEmit(Emitting(x), Emitting(y), func(xv, yv Value, emit func(Value) error) error {
+		vs, err := op(xv, yv)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		yvs, err := y(v)
+		return emitAll(vs, emit)
+	}))
+}
+
+// ShortCircuit returns a filter that applies x to an input value, then
+// applies op to each output of x. Unlike Binary, y is not applied eagerly:
+// op receives a function that applies y to the input value, and op may call
+// it any number of times, including zero. This lets op skip evaluating y
+// (and any side effects y may have) when x alone determines the result.
+func ShortCircuit(x, y Filter, op func(xv Value, y func() ([]Value, error)) ([]Value, error)) Filter {
+	return func(v Value) ([]Value, error) {
+		xvs, err := x(v)
 		if err != nil {
 			return nil, err
 		}
+		applyY := func() ([]Value, error) { return y(v) }
 		var outs []Value
 		for _, xv := range xvs {
-			for _, yv := range yvs {
-				opvs, err := op(xv, yv)
-				if err != nil {
-					return nil, err
-				}
-				outs = append(outs, opvs...)
+			opvs, err := op(xv, applyY)
+			if err != nil {
+				return nil, err
 			}
+			outs = append(outs, opvs...)
 		}
 		return outs, nil
 	}
 }
 
+// And returns a filter that produces the logical conjunction of the outputs
+// of x and y, as determined by Truthy. y is only applied for outputs of x
+// that are truthy; false is produced for other outputs without applying y.
+// When y is applied, a result is produced for each of its outputs.
+func And(x, y Filter) Filter {
+	return ShortCircuit(x, y, func(xv Value, y func() ([]Value, error)) ([]Value, error) {
+		if !Truthy(xv) {
+			return []Value{boolValue(false)}, nil
+		}
+		return truthValues(y())
+	})
+}
+
+// Or returns a filter that produces the logical disjunction of the outputs
+// of x and y, as determined by Truthy. y is only applied for outputs of x
+// that are not truthy; true is produced for other outputs without applying y.
+// When y is applied, a result is produced for each of its outputs.
+func Or(x, y Filter) Filter {
+	return ShortCircuit(x, y, func(xv Value, y func() ([]Value, error)) ([]Value, error) {
+		if Truthy(xv) {
+			return []Value{boolValue(true)}, nil
+		}
+		return truthValues(y())
+	})
+}
+
+func truthValues(vs []Value, err error) ([]Value, error) {
+	if err != nil {
+		return nil, err
+	}
+	outs := make([]Value, len(vs))
+	for i, v := range vs {
+		outs[i] = boolValue(Truthy(v))
+	}
+	return outs, nil
+}
+
 // Ternary returns a filter that applies x, y, and z to an input value, then
 // applies op to the Cartesian product of the outputs of x, y, and z.
 func Ternary(x, y, z Filter, op func(xv, yv, zv Value) ([]Value, error)) Filter {
@@ -212,23 +335,247 @@ func Concat(x, y Filter) Filter {
 
 // Sift reads values from dec, transforms them with f, and encodes the results
 // with enc until an error occurs. When dec returns io.EOF, Sift stops and
-// returns nil.
+// returns nil. If enc is a Flusher, Sift flushes it before returning.
 func Sift(dec Decoder, f Filter, enc Encoder) error {
-	for {
+	return SiftWithOptions(dec, f, enc, SiftOptions{})
+}
+
+// SiftOptions controls how SiftWithOptions handles errors, limits the rate
+// of output, and reports statistics.
+type SiftOptions struct {
+	// ContinueOnError causes errors decoding or filtering a value to be
+	// skipped instead of stopping the stream. Skipped errors are returned
+	// together in an InputErrors after the stream ends.
+	//
+	// A decode error may only be skipped if the decoder can continue after
+	// it, like a decoder that reads one value per line. If the decoder
+	// returns the same error twice in a row, the stream ends as if the
+	// decoder had returned io.EOF, and the skipped errors are returned.
+	ContinueOnError bool
+
+	// OnError, if not nil, is called for each error decoding or filtering
+	// a value. If it returns nil, the error is skipped, as with
+	// ContinueOnError. Otherwise, the stream stops, and SiftWithOptions
+	// returns the error OnError returned. OnError may be used to log errors
+	// as they happen or to decide which errors may be skipped.
+	OnError func(err *InputError) error
+
+	// MaxErrors, if positive, is the number of errors that may be skipped.
+	// The stream stops after one more error.
+	MaxErrors int
+
+	// ValuesPerSecond, if positive, limits the rate at which values are
+	// written. Encoding blocks as needed so that values are written at
+	// most 1/ValuesPerSecond seconds apart.
+	ValuesPerSecond float64
+
+	// BytesPerSecond, if positive, limits the rate at which bytes are
+	// written. After each value is written, encoding the next value blocks
+	// until the bytes written so far fit within the rate. It has no effect
+	// unless the encoder implements ByteCounter.
+	BytesPerSecond float64
+
+	// MaxBuffered, if positive, is the number of values that may be written
+	// to the encoder between drains. When that many values have been
+	// written, the encoder is drained, so an encoder that buffers values,
+	// like a BatchEncoder, holds at most MaxBuffered at once. The encoder
+	// must implement Drainer; SiftWithOptions returns an error if it
+	// doesn't, or if it wraps an encoder that can't be drained, like one
+	// returned by SortStream or ReduceStream.
+	MaxBuffered int
+
+	// Context, if not nil, stops the stream when it's done. SiftWithOptions
+	// checks it before decoding each value, and waits for rate limits end
+	// early. SiftWithOptions then returns the context's error without
+	// flushing the encoder, as SiftContext does.
+	Context context.Context
+
+	// Stats, if not nil, is filled in when SiftWithOptions returns, whether
+	// or not the stream ended successfully.
+	Stats *SiftStats
+}
+
+// SiftStats describes a stream processed by SiftWithOptions.
+type SiftStats struct {
+	// Decoded is the number of values decoded successfully, and Emitted is
+	// the number of values encoded successfully.
+	Decoded, Emitted int64
+
+	// BytesRead is the number of bytes the decoder consumed, and
+	// BytesWritten is the number of bytes the encoder wrote. Each is zero
+	// unless the decoder or encoder implements ByteCounter.
+	BytesRead, BytesWritten int64
+
+	// Skipped is the number of errors skipped, including the error that
+	// exceeded MaxErrors, if any. It's the number of errors in the
+	// InputErrors SiftWithOptions returns.
+	Skipped int
+
+	// Elapsed is the time from when SiftWithOptions was called to when it
+	// returned.
+	Elapsed time.Duration
+}
+
+// An InputError is an error decoding or filtering one value of a stream.
+type InputError struct {
+	// Position is the number of values decoded before the value, so the
+	// first value in the stream is at position 0.
+	Position int64
+
+	// Value is the value that could not be filtered. It's nil if the value
+	// could not be decoded.
+	Value Value
+
+	Err error
+}
+
+func (e *InputError) Error() string {
+	return fmt.Sprintf("value at position %d: %v", e.Position, e.Err)
+}
+
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
+
+// InputErrors is a list of errors skipped by SiftWithOptions, in the order
+// they happened.
+type InputErrors []*InputError
+
+func (l InputErrors) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	case 2:
+		return fmt.Sprintf("%s (and 1 more error)", l[0])
+	default:
+		return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+	}
+}
+
+// SiftWithOptions is like Sift, but errors decoding or filtering a value
+// may be skipped according to opts. If any errors were skipped,
+// SiftWithOptions returns them in an InputErrors after the stream ends
+// and enc is flushed. If too many errors are skipped, the stream stops
+// early, and the InputErrors includes the last error. Errors encoding
+// values are never skipped.
+func SiftWithOptions(dec Decoder, f Filter, enc Encoder, opts SiftOptions) error {
+	var skipped InputErrors
+	var pos int64
+	var lastDecodeErr error
+	// values limits the rate of values written. bytes limits the rate of
+	// bytes written; since the size of a value isn't known until it's
+	// written, bytes has no burst, and writing waits for the debt from
+	// earlier values to be repaid.
+	var values, bytes *Limiter
+	var written int64
+	if opts.ValuesPerSecond > 0 {
+		values = NewLimiter(opts.ValuesPerSecond, 1, time.Now())
+	}
+	if _, ok := enc.(ByteCounter); ok && opts.BytesPerSecond > 0 {
+		bytes, written = NewLimiter(opts.BytesPerSecond, 0, time.Now()), byteCount(enc)
+	}
+	// dr is set if enc must be drained periodically.
+	var dr Drainer
+	if opts.MaxBuffered > 0 {
+		var ok bool
+		if dr, ok = enc.(Drainer); !ok {
+			return fmt.Errorf("MaxBuffered is set, but encoder %T does not implement Drainer", enc)
+		}
+	}
+	var stats SiftStats
+	if opts.Stats != nil {
+		start := time.Now()
+		defer func() {
+			stats.Elapsed = time.Since(start)
+			stats.BytesRead, stats.BytesWritten = byteCount(dec), byteCount(enc)
+			*opts.Stats = stats
+		}()
+	}
+	// skip reports whether the stream may continue after err. If not,
+	// it returns the error to stop with.
+	skip := func(err error, v Value) (bool, error) {
+		ierr := &InputError{Position: pos, Value: v, Err: err}
+		if opts.OnError != nil {
+			if err := opts.OnError(ierr); err != nil {
+				return false, err
+			}
+		} else if !opts.ContinueOnError {
+			return false, err
+		}
+		skipped = append(skipped, ierr)
+		stats.Skipped++
+		if opts.MaxErrors > 0 && len(skipped) > opts.MaxErrors {
+			return false, skipped
+		}
+		return true, nil
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for ; ; pos++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		vin, err := dec.Decode()
 		if err == io.EOF {
-			return nil
+			break
 		} else if err != nil {
-			return err
+			if sameError(err, lastDecodeErr) {
+				break
+			}
+			lastDecodeErr = err
+			if ok, err := skip(err, nil); !ok {
+				return err
+			}
+			continue
 		}
+		lastDecodeErr = nil
+		stats.Decoded++
 		vouts, err := f(vin)
 		if err != nil {
-			return err
+			if ok, err := skip(err, vin); !ok {
+				return err
+			}
+			continue
 		}
 		for _, vout := range vouts {
+			if values != nil {
+				if err := values.Wait(ctx, 1); err != nil {
+					return err
+				}
+			}
+			if bytes != nil {
+				if err := bytes.Wait(ctx, 0); err != nil {
+					return err
+				}
+			}
 			if err := enc.Encode(vout); err != nil {
 				return err
 			}
+			stats.Emitted++
+			if bytes != nil {
+				n := byteCount(enc)
+				bytes.Spend(time.Now(), float64(n-written))
+				written = n
+			}
+			if dr != nil && stats.Emitted%int64(opts.MaxBuffered) == 0 {
+				if err := dr.Drain(); err != nil {
+					return err
+				}
+			}
 		}
 	}
+	if fl, ok := enc.(Flusher); ok {
+		if err := fl.Flush(); err != nil {
+			return err
+		}
+	}
+	if len(skipped) > 0 {
+		return skipped
+	}
+	return nil
 }
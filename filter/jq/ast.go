@@ -0,0 +1,439 @@
+package jq
+
+import (
+	gotoken "go/token"
+
+	"go.jayconrod.com/sift"
+)
+
+// A Node is a node in the syntax tree of a jq program, as returned by Parse.
+// Positions are relative to the FileSet passed to Parse.
+type Node interface {
+	// Pos returns the position of the first character of the node.
+	Pos() gotoken.Pos
+}
+
+// An Expr is an expression node.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// A Pattern is a destructuring pattern in an "as" binding.
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+// File is the syntax tree of a program or module. A program has a Body;
+// a module has Defs instead.
+type File struct {
+	// Name is the file name passed to Parse.
+	Name string
+
+	Imports []*Import
+	Defs    []*FuncDef
+	Body    Expr // nil for modules and empty programs
+}
+
+// Import is an import or include directive.
+type Import struct {
+	ImportPos gotoken.Pos
+	Path      string
+
+	// Name is the name functions from the module are qualified with, as in
+	// import "path" as name. Name is empty for include directives.
+	Name string
+}
+
+// FuncDef is a function definition like "def f(g; $x): body;".
+type FuncDef struct {
+	DefPos gotoken.Pos
+	Name   string
+	Params []*Param
+	Body   Expr
+}
+
+// Param is a function parameter. Parameters declared with $ are variables.
+type Param struct {
+	NamePos  gotoken.Pos
+	Name     string
+	Variable bool
+}
+
+// Op is a binary operator.
+type Op int
+
+const (
+	OpPipe Op = iota
+	OpComma
+	OpOr
+	OpAnd
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+)
+
+var opNames = [...]string{
+	OpPipe:  "|",
+	OpComma: ",",
+	OpOr:    "or",
+	OpAnd:   "and",
+	OpAdd:   "+",
+	OpSub:   "-",
+	OpMul:   "*",
+	OpDiv:   "/",
+	OpMod:   "%",
+}
+
+func (op Op) String() string {
+	return opNames[op]
+}
+
+// precedence returns the binding strength of the operator. Operators with
+// higher precedence bind more tightly.
+func (op Op) precedence() int {
+	switch op {
+	case OpPipe:
+		return 0
+	case OpComma:
+		return 1
+	case OpOr:
+		return 2
+	case OpAnd:
+		return 3
+	case OpAdd, OpSub:
+		return 4
+	default:
+		return 5
+	}
+}
+
+type (
+	// Identity is the expression ".".
+	Identity struct {
+		Dot gotoken.Pos
+	}
+
+	// Recurse is the expression "..".
+	Recurse struct {
+		DotDot gotoken.Pos
+	}
+
+	// Literal is null, true, false, a number, or a string.
+	Literal struct {
+		ValuePos gotoken.Pos
+		Value    sift.Value
+	}
+
+	// Var is a variable reference like $x.
+	Var struct {
+		NamePos gotoken.Pos
+		Name    string // without $
+	}
+
+	// Neg is a negation like -x.
+	Neg struct {
+		OpPos gotoken.Pos
+		X     Expr
+	}
+
+	// Binary is an expression with a binary operator, including pipes
+	// and commas.
+	Binary struct {
+		X     Expr
+		OpPos gotoken.Pos
+		Op    Op
+		Y     Expr
+	}
+
+	// Paren is a parenthesized expression.
+	Paren struct {
+		Lparen gotoken.Pos
+		X      Expr
+	}
+
+	// Field is a field access like .a or x.a. X is nil when the field is
+	// accessed on the input, as in .a. Optional is set for .a?.
+	Field struct {
+		X        Expr
+		Dot      gotoken.Pos
+		Name     string
+		Optional bool
+	}
+
+	// Index is an index expression like .[i]. X is nil when the input is
+	// indexed.
+	Index struct {
+		X      Expr
+		Lbrack gotoken.Pos
+		Index  Expr
+	}
+
+	// Slice is a slice expression like .[i:j]. X is nil when the input is
+	// sliced. Begin or End may be nil, but not both.
+	Slice struct {
+		X      Expr
+		Lbrack gotoken.Pos
+		Begin  Expr
+		End    Expr
+	}
+
+	// Iterate is an iteration like .[] or .[]?. X is nil when the input is
+	// iterated.
+	Iterate struct {
+		X        Expr
+		Lbrack   gotoken.Pos
+		Optional bool
+	}
+
+	// Try is an expression followed by ?, which suppresses its errors, or
+	// an expression like try x catch h. TryPos is set only in the second
+	// form. If Catch is not nil, it's applied to the payload of an error
+	// instead of suppressing it.
+	Try struct {
+		TryPos   gotoken.Pos
+		X        Expr
+		Question gotoken.Pos
+		Catch    Expr
+	}
+
+	// Array is an array construction like [x].
+	Array struct {
+		Lbrack gotoken.Pos
+		Elems  []Expr
+	}
+
+	// Object is an object construction like {a: x, (k): y}.
+	Object struct {
+		Lbrace  gotoken.Pos
+		Entries []*ObjectEntry
+	}
+
+	// Call is a call to a function, parameter, or builtin.
+	Call struct {
+		NamePos gotoken.Pos
+		Name    string
+		Args    []Expr
+	}
+
+	// As is a binding like "x as $v | body". When there are several
+	// patterns, they are alternatives separated by ?//.
+	As struct {
+		X        Expr
+		AsPos    gotoken.Pos
+		Patterns []Pattern
+		Body     Expr
+	}
+
+	// FuncDefExpr is a function definition followed by the expression in
+	// which the function is visible.
+	FuncDefExpr struct {
+		Def  *FuncDef
+		Rest Expr
+	}
+)
+
+// ObjectEntry is an entry in an Object. A key written as an identifier or
+// string is a *Literal; other keys are *Paren expressions.
+type ObjectEntry struct {
+	Key   Expr
+	Value Expr
+}
+
+type (
+	// VarPattern is a pattern like $x.
+	VarPattern struct {
+		NamePos gotoken.Pos
+		Name    string // without $
+	}
+
+	// ArrayPattern is a pattern like [$a, $b].
+	ArrayPattern struct {
+		Lbrack gotoken.Pos
+		Elems  []Pattern
+	}
+
+	// ObjectPattern is a pattern like {a: $a, $b, (k): $c}.
+	ObjectPattern struct {
+		Lbrace  gotoken.Pos
+		Entries []*ObjectPatternEntry
+	}
+)
+
+// ObjectPatternEntry is an entry in an ObjectPattern. Either Var or Key is
+// set. If Var is set, the attribute with the variable's name is bound to it,
+// and Value may be nil. A Key written as an identifier or string is
+// a *Literal; other keys are *Paren expressions.
+type ObjectPatternEntry struct {
+	Var   *VarPattern
+	Key   Expr
+	Value Pattern
+}
+
+func (n *File) Pos() gotoken.Pos {
+	switch {
+	case len(n.Imports) > 0:
+		return n.Imports[0].Pos()
+	case len(n.Defs) > 0:
+		return n.Defs[0].Pos()
+	case n.Body != nil:
+		return n.Body.Pos()
+	default:
+		return gotoken.NoPos
+	}
+}
+
+func (n *Try) Pos() gotoken.Pos {
+	if n.TryPos.IsValid() {
+		return n.TryPos
+	}
+	return n.X.Pos()
+}
+
+func (n *Import) Pos() gotoken.Pos        { return n.ImportPos }
+func (n *FuncDef) Pos() gotoken.Pos       { return n.DefPos }
+func (n *Param) Pos() gotoken.Pos         { return n.NamePos }
+func (n *Identity) Pos() gotoken.Pos      { return n.Dot }
+func (n *Recurse) Pos() gotoken.Pos       { return n.DotDot }
+func (n *Literal) Pos() gotoken.Pos       { return n.ValuePos }
+func (n *Var) Pos() gotoken.Pos           { return n.NamePos }
+func (n *Neg) Pos() gotoken.Pos           { return n.OpPos }
+func (n *Binary) Pos() gotoken.Pos        { return n.X.Pos() }
+func (n *Paren) Pos() gotoken.Pos         { return n.Lparen }
+func (n *Field) Pos() gotoken.Pos         { return postfixPos(n.X, n.Dot) }
+func (n *Index) Pos() gotoken.Pos         { return postfixPos(n.X, n.Lbrack) }
+func (n *Slice) Pos() gotoken.Pos         { return postfixPos(n.X, n.Lbrack) }
+func (n *Iterate) Pos() gotoken.Pos       { return postfixPos(n.X, n.Lbrack) }
+func (n *Array) Pos() gotoken.Pos         { return n.Lbrack }
+func (n *Object) Pos() gotoken.Pos        { return n.Lbrace }
+func (n *Call) Pos() gotoken.Pos          { return n.NamePos }
+func (n *As) Pos() gotoken.Pos            { return n.X.Pos() }
+func (n *FuncDefExpr) Pos() gotoken.Pos   { return n.Def.Pos() }
+func (n *VarPattern) Pos() gotoken.Pos    { return n.NamePos }
+func (n *ArrayPattern) Pos() gotoken.Pos  { return n.Lbrack }
+func (n *ObjectPattern) Pos() gotoken.Pos { return n.Lbrace }
+
+func postfixPos(x Expr, pos gotoken.Pos) gotoken.Pos {
+	if x != nil {
+		return x.Pos()
+	}
+	return pos
+}
+
+func (*Identity) exprNode()    {}
+func (*Recurse) exprNode()     {}
+func (*Literal) exprNode()     {}
+func (*Var) exprNode()         {}
+func (*Neg) exprNode()         {}
+func (*Binary) exprNode()      {}
+func (*Paren) exprNode()       {}
+func (*Field) exprNode()       {}
+func (*Index) exprNode()       {}
+func (*Slice) exprNode()       {}
+func (*Iterate) exprNode()     {}
+func (*Try) exprNode()         {}
+func (*Array) exprNode()       {}
+func (*Object) exprNode()      {}
+func (*Call) exprNode()        {}
+func (*As) exprNode()          {}
+func (*FuncDefExpr) exprNode() {}
+
+func (*VarPattern) patternNode()    {}
+func (*ArrayPattern) patternNode()  {}
+func (*ObjectPattern) patternNode() {}
+
+// Inspect traverses the syntax tree rooted at n in depth-first order.
+// It calls f for each node; if f returns true, Inspect visits the node's
+// children, then calls f(nil).
+func Inspect(n Node, f func(Node) bool) {
+	if n == nil || !f(n) {
+		return
+	}
+	visit := func(children ...Node) {
+		for _, c := range children {
+			Inspect(c, f)
+		}
+	}
+	switch n := n.(type) {
+	case *File:
+		for _, imp := range n.Imports {
+			visit(imp)
+		}
+		for _, def := range n.Defs {
+			visit(def)
+		}
+		if n.Body != nil {
+			visit(n.Body)
+		}
+	case *FuncDef:
+		for _, prm := range n.Params {
+			visit(prm)
+		}
+		visit(n.Body)
+	case *Neg:
+		visit(n.X)
+	case *Binary:
+		visit(n.X, n.Y)
+	case *Paren:
+		visit(n.X)
+	case *Field:
+		visitExpr(visit, n.X)
+	case *Index:
+		visitExpr(visit, n.X)
+		visit(n.Index)
+	case *Slice:
+		visitExpr(visit, n.X, n.Begin, n.End)
+	case *Iterate:
+		visitExpr(visit, n.X)
+	case *Try:
+		visitExpr(visit, n.X, n.Catch)
+	case *Array:
+		for _, elem := range n.Elems {
+			visit(elem)
+		}
+	case *Object:
+		for _, entry := range n.Entries {
+			visit(entry.Key, entry.Value)
+		}
+	case *Call:
+		for _, arg := range n.Args {
+			visit(arg)
+		}
+	case *As:
+		visit(n.X)
+		for _, pat := range n.Patterns {
+			visit(pat)
+		}
+		visit(n.Body)
+	case *FuncDefExpr:
+		visit(n.Def, n.Rest)
+	case *ArrayPattern:
+		for _, elem := range n.Elems {
+			visit(elem)
+		}
+	case *ObjectPattern:
+		for _, entry := range n.Entries {
+			if entry.Var != nil {
+				visit(entry.Var)
+			} else {
+				visit(entry.Key)
+			}
+			if entry.Value != nil {
+				visit(entry.Value)
+			}
+		}
+	}
+	f(nil)
+}
+
+// visitExpr visits expressions that may be nil.
+func visitExpr(visit func(...Node), exprs ...Expr) {
+	for _, x := range exprs {
+		if x != nil {
+			visit(x)
+		}
+	}
+}
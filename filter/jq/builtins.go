@@ -0,0 +1,862 @@
+package jq
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/charset"
+	"go.jayconrod.com/sift/encoding/json"
+)
+
+// A builtin is a function implemented in Go that may be called from
+// a jq program. Builtins are identified by name and arity, so a single name
+// may refer to several builtins that accept different numbers of arguments.
+type builtin struct {
+	name  string
+	arity int
+
+	// variadic indicates the builtin accepts arity or more arguments.
+	variadic bool
+
+	// effects describes what the builtin does besides computing its
+	// outputs from its input and arguments. Profiles may reject builtins
+	// with effects.
+	effects effects
+
+	// requires is a capability the builtin needs that may be disabled
+	// in CompileOptions.
+	requires capability
+
+	// impl returns a filter implementing the builtin, given the options
+	// the program is compiled with and filters for each of the builtin's
+	// arguments. len(args) is equal to arity, or at least arity if the
+	// builtin is variadic.
+	impl func(opts *CompileOptions, args []sift.Filter) sift.Filter
+
+	// gen is set instead of impl for builtins that evaluate their arguments
+	// and produce their outputs lazily, like first and range.
+	gen func(opts *CompileOptions, args []genFilter) genFilter
+}
+
+func (b builtin) key() string {
+	key := funcKey(b.name, b.arity)
+	if b.variadic {
+		key += "+"
+	}
+	return key
+}
+
+var builtins = make(map[string]builtin)
+
+// lookupBuiltin returns the builtin with the given name that accepts arity
+// arguments. A builtin with exactly that arity is preferred over
+// a variadic builtin.
+func lookupBuiltin(name string, arity int) (builtin, bool) {
+	if b, ok := builtins[funcKey(name, arity)]; ok {
+		return b, ok
+	}
+	for i := arity; i >= 0; i-- {
+		if b, ok := builtins[funcKey(name, i)+"+"]; ok {
+			return b, ok
+		}
+	}
+	return builtin{}, false
+}
+
+// Builtins returns a sorted list of the builtin functions that may be called
+// from jq programs. Each function is described by its name and arity, like
+// "walk/1". Functions with the same name and different arities are
+// listed separately. Functions that accept a variable number of arguments
+// are described by their minimum arity followed by "+", like "dig/1+".
+func Builtins() []string {
+	names := make([]string, 0, len(builtins))
+	for key := range builtins {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	for _, b := range []builtin{
+		{name: "infinite", impl: literal(math.Inf(1))},
+		{name: "nan", impl: literal(math.NaN())},
+		{name: "isinfinite", impl: numPredicate("isinfinite", func(n float64) bool {
+			return math.IsInf(n, 0)
+		})},
+		{name: "isnan", impl: numPredicate("isnan", math.IsNaN)},
+		{name: "isnormal", impl: numPredicate("isnormal", isNormal)},
+		{name: "combinations", impl: combinations},
+		{name: "combinations", arity: 1, impl: combinationsN},
+		{name: "transpose", impl: transpose},
+		{name: "now", impl: now, effects: effectNondeterministic},
+		{name: "walk", arity: 1, impl: walk},
+		{name: "depth", impl: measure(depth)},
+		{name: "node_count", impl: measure(nodeCount)},
+		{name: "byte_size", impl: measure(byteSize)},
+		{name: "reachable", arity: 2, impl: reachable},
+		{name: "topo_sort", arity: 1, impl: topoSort},
+		{name: "to_tree", arity: 2, impl: toTree},
+		{name: "from_tree", arity: 1, impl: fromTree},
+		{name: "iconv", arity: 1, impl: iconv},
+		{name: "builtins", impl: builtinsBuiltin},
+		{name: "dig", arity: 1, variadic: true, impl: dig},
+		{name: "getpointer", arity: 1, impl: getPointer},
+		{name: "call", arity: 1, variadic: true, impl: call},
+		{name: "error", impl: raise},
+		{name: "error", arity: 1, impl: raise},
+		{name: "debug", impl: debug},
+		{name: "type", impl: typeBuiltin},
+		{name: "sort", impl: sortBuiltin},
+		{name: "sort_by", arity: 1, impl: sortBy},
+		{name: "not", impl: not},
+		{name: "select", arity: 1, impl: selectBuiltin},
+		{name: "abs", impl: abs},
+		{name: "toarray", impl: toArray},
+		{name: "ltrimstr", arity: 1, impl: trimStr(strings.TrimPrefix)},
+		{name: "rtrimstr", arity: 1, impl: trimStr(strings.TrimSuffix)},
+		{name: "splits", arity: 1, impl: splits},
+		{name: "splits", arity: 2, impl: splits},
+		{name: "getpath", arity: 1, impl: getPath},
+		// Numbers in the input keep their original text, as in jq 1.7, but
+		// arithmetic is done with float64.
+		{name: "have_literal_numbers", impl: literal(true)},
+		{name: "have_decnum", impl: literal(false)},
+		// pick/1 is compiled specially by compilePick, since its argument
+		// is a path expression. It's listed here so Builtins includes it.
+		{name: "pick", arity: 1},
+		{name: "env", impl: envBuiltin, effects: effectIO, requires: capEnv},
+		{name: "input", impl: input, effects: effectIO, requires: capInput},
+		{name: "inputs", impl: inputs, effects: effectIO, requires: capInput},
+	} {
+		builtins[b.key()] = b
+	}
+}
+
+// literal returns a builtin implementation that produces a constant value.
+func literal(i interface{}) func(*CompileOptions, []sift.Filter) sift.Filter {
+	v := sift.Must(sift.ToValue(i))
+	return func(*CompileOptions, []sift.Filter) sift.Filter {
+		return sift.Literal(v)
+	}
+}
+
+// numPredicate returns a builtin implementation that applies pred to
+// its numeric input and produces a boolean. An error is returned for
+// non-numeric inputs.
+func numPredicate(name string, pred func(float64) bool) func(*CompileOptions, []sift.Filter) sift.Filter {
+	return func(*CompileOptions, []sift.Filter) sift.Filter {
+		return sift.MapError(func(v sift.Value) (sift.Value, error) {
+			n, ok := sift.AsFloat64(v)
+			if !ok {
+				return nil, fmt.Errorf("%s: value %v is not a number", name, v)
+			}
+			return sift.ToValue(pred(n))
+		})
+	}
+}
+
+// isNormal returns whether n is a normal floating point number, that is,
+// n is not zero, subnormal, infinite, or NaN.
+func isNormal(n float64) bool {
+	if n == 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return false
+	}
+	const minNormal = 0x1p-1022
+	return math.Abs(n) >= minNormal
+}
+
+// combinations produces each combination of elements from the arrays in
+// the input array. Combinations are produced in the same order as
+// nested loops over the input arrays, with the last array innermost.
+func combinations(_ *CompileOptions, _ []sift.Filter) sift.Filter {
+	return func(v sift.Value) ([]sift.Value, error) {
+		elems, err := iterate(v)
+		if err != nil {
+			return nil, err
+		}
+		return combine(elems)
+	}
+}
+
+// combinationsN produces each combination of n elements from the
+// input array.
+func combinationsN(_ *CompileOptions, args []sift.Filter) sift.Filter {
+	return sift.Binary(id, args[0], func(v, nv sift.Value) ([]sift.Value, error) {
+		n, ok := sift.AsFloat64(nv)
+		if !ok {
+			return nil, fmt.Errorf("combinations: count %v is not a number", nv)
+		}
+		arrays := make([]sift.Value, 0, int(math.Max(n, 0)))
+		for i := 0; float64(i) < n; i++ {
+			arrays = append(arrays, v)
+		}
+		return combine(arrays)
+	})
+}
+
+func combine(arrays []sift.Value) ([]sift.Value, error) {
+	sets := make([][]sift.Value, len(arrays))
+	for i, a := range arrays {
+		elems, err := iterate(a)
+		if err != nil {
+			return nil, err
+		}
+		if len(elems) == 0 {
+			return nil, nil
+		}
+		sets[i] = elems
+	}
+
+	var outs []sift.Value
+	indices := make([]int, len(sets))
+	for {
+		combo := make([]sift.Value, len(sets))
+		for i, j := range indices {
+			combo[i] = sets[i][j]
+		}
+		outs = append(outs, sift.Must(sift.ToValue(combo)))
+
+		i := len(indices) - 1
+		for ; i >= 0; i-- {
+			indices[i]++
+			if indices[i] < len(sets[i]) {
+				break
+			}
+			indices[i] = 0
+		}
+		if i < 0 {
+			return outs, nil
+		}
+	}
+}
+
+// transpose treats its input as a matrix, an array of rows, and produces
+// its transpose. Short rows are padded with null.
+func transpose(_ *CompileOptions, _ []sift.Filter) sift.Filter {
+	return sift.MapError(func(v sift.Value) (sift.Value, error) {
+		rows, err := iterate(v)
+		if err != nil {
+			return nil, err
+		}
+		width := 0
+		for _, row := range rows {
+			ix, ok := row.(sift.Index)
+			if !ok {
+				return nil, fmt.Errorf("transpose: row %v is not an array", row)
+			}
+			if n := ix.Length(); n > width {
+				width = n
+			}
+		}
+		cols := make([]sift.Value, width)
+		for j := range cols {
+			col := make([]sift.Value, len(rows))
+			for i, row := range rows {
+				elem, ok := sift.GetIntIndex(row, j)
+				if !ok {
+					elem = sift.NullValue
+				}
+				col[i] = elem
+			}
+			cols[j] = sift.Must(sift.ToValue(col))
+		}
+		return sift.ToValue(cols)
+	})
+}
+
+// now produces the current time in seconds since the Unix epoch. The time
+// is obtained from CompileOptions.Now if set.
+func now(opts *CompileOptions, _ []sift.Filter) sift.Filter {
+	clock := time.Now
+	if opts.Now != nil {
+		clock = opts.Now
+	}
+	return func(sift.Value) ([]sift.Value, error) {
+		t := clock()
+		secs := float64(t.Unix()) + float64(t.Nanosecond())/1e9
+		return []sift.Value{sift.Must(sift.ToValue(secs))}, nil
+	}
+}
+
+// walk applies its argument f to every component of its input, bottom-up,
+// rebuilding arrays and objects from the results. Like map, all values f
+// produces for an array element are included in the rebuilt array. Like
+// map_values, only the first value f produces for an object attribute is
+// used, and the attribute is omitted if f produces no values.
+func walk(_ *CompileOptions, args []sift.Filter) sift.Filter {
+	f := args[0]
+	var w sift.Filter
+	w = func(v sift.Value) ([]sift.Value, error) {
+		switch v := v.(type) {
+		case sift.Attr:
+			m := make(map[string]sift.Value)
+			for _, key := range v.Keys() {
+				name, ok := sift.AsString(key)
+				if !ok {
+					return nil, fmt.Errorf("walk: object has non-string key %v", key)
+				}
+				elem, ok := v.Attr(key)
+				if !ok {
+					continue
+				}
+				outs, err := w(elem)
+				if err != nil {
+					return nil, err
+				}
+				if len(outs) > 0 {
+					m[name] = outs[0]
+				}
+			}
+			return f(sift.Must(sift.ToValue(m)))
+
+		case sift.Index:
+			elems, err := iterate(v)
+			if err != nil {
+				return nil, err
+			}
+			var outs []sift.Value
+			for _, elem := range elems {
+				elemOuts, err := w(elem)
+				if err != nil {
+					return nil, err
+				}
+				outs = append(outs, elemOuts...)
+			}
+			return f(sift.Must(sift.ToValue(outs)))
+
+		default:
+			return f(v)
+		}
+	}
+	return w
+}
+
+// measure returns a builtin implementation that produces a number
+// describing the structure of its input.
+func measure(m func(sift.Value) (int, error)) func(*CompileOptions, []sift.Filter) sift.Filter {
+	return func(*CompileOptions, []sift.Filter) sift.Filter {
+		return sift.MapError(func(v sift.Value) (sift.Value, error) {
+			n, err := m(v)
+			if err != nil {
+				return nil, err
+			}
+			return sift.ToValue(n)
+		})
+	}
+}
+
+// children returns the attribute values of an object or the elements of
+// an array. It returns nil for other values.
+func children(v sift.Value) []sift.Value {
+	switch v := v.(type) {
+	case sift.Attr:
+		var elems []sift.Value
+		for _, key := range v.Keys() {
+			if elem, ok := v.Attr(key); ok {
+				elems = append(elems, elem)
+			}
+		}
+		return elems
+	case sift.Index:
+		elems, _ := iterate(v)
+		return elems
+	default:
+		return nil
+	}
+}
+
+// depth returns the maximum nesting depth of arrays and objects in v.
+// Scalars have depth 0, and empty arrays and objects have depth 1.
+func depth(v sift.Value) (int, error) {
+	switch v.(type) {
+	case sift.Attr, sift.Index:
+	default:
+		return 0, nil
+	}
+	max := 0
+	for _, elem := range children(v) {
+		d, _ := depth(elem)
+		if d > max {
+			max = d
+		}
+	}
+	return max + 1, nil
+}
+
+// nodeCount returns the number of values in v, including v itself and
+// all values nested within it. This is the number of values .. produces.
+func nodeCount(v sift.Value) (int, error) {
+	n := 1
+	for _, elem := range children(v) {
+		c, _ := nodeCount(elem)
+		n += c
+	}
+	return n, nil
+}
+
+// byteSize returns the length of v encoded as compact JSON.
+func byteSize(v sift.Value) (int, error) {
+	w := &countWriter{}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		return 0, err
+	}
+	return w.n - 1, nil // don't count trailing newline
+}
+
+type countWriter struct {
+	n int
+}
+
+func (w *countWriter) Write(b []byte) (int, error) {
+	w.n += len(b)
+	return len(b), nil
+}
+
+// iconv converts its input string from the encoding named by its argument
+// to UTF-8. This repairs strings containing raw bytes in another encoding,
+// for example, Latin-1 text read from a system that did not convert it.
+func iconv(_ *CompileOptions, args []sift.Filter) sift.Filter {
+	return sift.Binary(id, args[0], func(v, encv sift.Value) ([]sift.Value, error) {
+		s, ok := sift.AsString(v)
+		if !ok {
+			return nil, fmt.Errorf("iconv: value %v is not a string", v)
+		}
+		enc, ok := sift.AsString(encv)
+		if !ok {
+			return nil, fmt.Errorf("iconv: encoding %v is not a string", encv)
+		}
+		out, err := charset.DecodeString(s, enc)
+		if err != nil {
+			return nil, fmt.Errorf("iconv: %v", err)
+		}
+		return []sift.Value{sift.Must(sift.ToValue(out))}, nil
+	})
+}
+
+// builtinsBuiltin produces an array of the names of all builtins, including
+// functions provided with CompileOptions.Funcs.
+func builtinsBuiltin(opts *CompileOptions, _ []sift.Filter) sift.Filter {
+	return func(sift.Value) ([]sift.Value, error) {
+		names := Builtins()
+		for key := range opts.Funcs {
+			if _, ok := builtins[key]; !ok {
+				names = append(names, key)
+			}
+		}
+		sort.Strings(names)
+		vs := make([]sift.Value, len(names))
+		for i, name := range names {
+			vs[i] = sift.Must(sift.ToValue(name))
+		}
+		return []sift.Value{sift.Must(sift.ToValue(vs))}, nil
+	}
+}
+
+// dig follows a path of keys and indices, one per argument, starting from
+// its input. Unlike .a.b[0], dig never reports an error: it produces null if
+// any value along the path is missing or has the wrong type. If an argument
+// produces multiple keys, dig produces a result for each combination.
+func dig(_ *CompileOptions, args []sift.Filter) sift.Filter {
+	operands := append([]sift.Filter{id}, args...)
+	return sift.Nary(operands, func(vs []sift.Value) ([]sift.Value, error) {
+		v := vs[0]
+		for _, key := range vs[1:] {
+			v = digStep(v, key)
+		}
+		return []sift.Value{v}, nil
+	})
+}
+
+func digStep(v, key sift.Value) sift.Value {
+	switch v := v.(type) {
+	case sift.Attr:
+		if _, ok := sift.AsString(key); ok {
+			if elem, ok := v.Attr(key); ok {
+				return elem
+			}
+		}
+	case sift.Index:
+		if f, ok := sift.AsFloat64(key); ok && f == math.Trunc(f) {
+			i := int(f)
+			if i < 0 {
+				i += v.Length()
+			}
+			if elem, ok := v.Index(i); ok {
+				return elem
+			}
+		}
+	}
+	return sift.NullValue
+}
+
+// getPath produces the value within its input at each path its argument
+// produces. A path is an array of keys and indices, like ["a", 0]. Like
+// jq, getpath produces null if there's no value at the path, but indexing
+// a value of the wrong type, like a string, is an error.
+func getPath(_ *CompileOptions, args []sift.Filter) sift.Filter {
+	return sift.Binary(id, args[0], func(v, pv sift.Value) ([]sift.Value, error) {
+		p, ok := pv.(sift.Index)
+		if !ok {
+			return nil, fmt.Errorf("getpath: path %v is not an array", pv)
+		}
+		for i, n := 0, p.Length(); i < n && !sift.IsNull(v); i++ {
+			key, _ := p.Index(i)
+			vs, err := index(v, key)
+			if err != nil {
+				return nil, fmt.Errorf("getpath: %w", err)
+			}
+			if len(vs) == 0 {
+				return []sift.Value{sift.NullValue}, nil
+			}
+			v = vs[0]
+		}
+		return []sift.Value{v}, nil
+	})
+}
+
+// getPointer produces the value within its input identified by a JSON
+// Pointer like "/a/0". Unlike dig, it's an error if there's no such value.
+func getPointer(_ *CompileOptions, args []sift.Filter) sift.Filter {
+	return sift.Binary(id, args[0], func(v, pv sift.Value) ([]sift.Value, error) {
+		ptr, ok := sift.AsString(pv)
+		if !ok {
+			return nil, fmt.Errorf("getpointer: pointer %v is not a string", pv)
+		}
+		e, err := sift.ResolvePointer(v, ptr)
+		if err != nil {
+			return nil, fmt.Errorf("getpointer: %w", err)
+		}
+		return []sift.Value{e}, nil
+	})
+}
+
+// call calls the function values produced by its first argument with its
+// input and the values of its other arguments, once for each combination.
+// Function values are typically bound by the host application, as in
+// call($lookup; "id").
+func call(_ *CompileOptions, args []sift.Filter) sift.Filter {
+	operands := append([]sift.Filter{id}, args...)
+	return sift.Nary(operands, func(vs []sift.Value) ([]sift.Value, error) {
+		fn, ok := vs[1].(sift.Func)
+		if !ok {
+			return nil, fmt.Errorf("call: value %v is not a function", vs[1])
+		}
+		return fn.Call(vs[0], vs[2:])
+	})
+}
+
+// raise implements error/0 and error/1, which raise an error with their
+// input or argument as the payload. The error may be caught with ? or with
+// try, whose handler receives the payload, and Go callers may find the
+// payload with sift.AsErrorValue.
+func raise(_ *CompileOptions, args []sift.Filter) sift.Filter {
+	var payload sift.Filter = id
+	if len(args) > 0 {
+		payload = args[0]
+	}
+	return func(v sift.Value) ([]sift.Value, error) {
+		vs, err := payload(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(vs) == 0 {
+			return nil, nil
+		}
+		return nil, &sift.ErrorValue{Value: vs[0]}
+	}
+}
+
+// debug writes a description of its input to CompileOptions.Debug and
+// produces its input unchanged.
+func debug(opts *CompileOptions, _ []sift.Filter) sift.Filter {
+	return func(v sift.Value) ([]sift.Value, error) {
+		var w io.Writer = os.Stderr
+		if opts.Debug != nil {
+			w = opts.Debug
+		}
+		if _, err := io.WriteString(w, "DEBUG: "+sift.Sdump(v)); err != nil {
+			return nil, err
+		}
+		return []sift.Value{v}, nil
+	}
+}
+
+// typeBuiltin produces the name of its input's type, like "object".
+func typeBuiltin(*CompileOptions, []sift.Filter) sift.Filter {
+	return sift.Map(func(v sift.Value) sift.Value {
+		return sift.Must(sift.ToValue(sift.TypeOf(v).String()))
+	})
+}
+
+// sortBuiltin sorts its input array with sift.Compare.
+func sortBuiltin(*CompileOptions, []sift.Filter) sift.Filter {
+	return sift.MapError(func(v sift.Value) (sift.Value, error) {
+		if !isArray(v) {
+			return nil, fmt.Errorf("sort: value %v is not an array", v)
+		}
+		ix, err := sift.Collect(v)
+		if err != nil {
+			return nil, err
+		}
+		return sift.Sort(ix), nil
+	})
+}
+
+// sortBy sorts its input array by the values its argument produces for
+// each element. Like jq, the key for an element is an array of all the
+// values the argument produces, so elements may be sorted by several
+// fields, as in sort_by(.a, .b).
+func sortBy(_ *CompileOptions, args []sift.Filter) sift.Filter {
+	f := args[0]
+	return sift.MapError(func(v sift.Value) (sift.Value, error) {
+		if !isArray(v) {
+			return nil, fmt.Errorf("sort_by: value %v is not an array", v)
+		}
+		ix, err := sift.Collect(v)
+		if err != nil {
+			return nil, err
+		}
+		return sift.SortBy(ix, func(e sift.Value) (sift.Value, error) {
+			keys, err := f(e)
+			if err != nil {
+				return nil, err
+			}
+			return sift.ToValue(keys)
+		})
+	})
+}
+
+// not produces false if its input is truthy and true otherwise.
+func not(*CompileOptions, []sift.Filter) sift.Filter {
+	return sift.Map(func(v sift.Value) sift.Value {
+		return sift.Must(sift.ToValue(!sift.Truthy(v)))
+	})
+}
+
+// selectBuiltin produces its input once for each truthy output of its
+// argument, like jq's select. Lower translates calls to it into ir.Select
+// nodes, which compile to the same filter.
+func selectBuiltin(_ *CompileOptions, args []sift.Filter) sift.Filter {
+	pred := args[0]
+	return func(v sift.Value) ([]sift.Value, error) {
+		pvs, err := pred(v)
+		if err != nil {
+			return nil, err
+		}
+		var outs []sift.Value
+		for _, pv := range pvs {
+			if sift.Truthy(pv) {
+				outs = append(outs, v)
+			}
+		}
+		return outs, nil
+	}
+}
+
+// abs produces the absolute value of its numeric input.
+func abs(*CompileOptions, []sift.Filter) sift.Filter {
+	return sift.MapError(func(v sift.Value) (sift.Value, error) {
+		n, ok := sift.AsFloat64(v)
+		if !ok {
+			return nil, fmt.Errorf("abs: value %v is not a number", v)
+		}
+		return sift.ToValue(math.Abs(n))
+	})
+}
+
+// toArray produces its input if it's an array, or an array containing its
+// input otherwise.
+func toArray(*CompileOptions, []sift.Filter) sift.Filter {
+	return sift.Map(func(v sift.Value) sift.Value {
+		if _, ok := v.(sift.Index); ok {
+			return v
+		}
+		return sift.Must(sift.ToValue([]sift.Value{v}))
+	})
+}
+
+// trimStr returns the implementation of ltrimstr or rtrimstr, which remove
+// a prefix or suffix from their input. Like jq 1.7, if the input or the
+// argument is not a string, the input is produced unchanged.
+func trimStr(trim func(s, affix string) string) func(*CompileOptions, []sift.Filter) sift.Filter {
+	return func(_ *CompileOptions, args []sift.Filter) sift.Filter {
+		return sift.Binary(id, args[0], func(v, affixv sift.Value) ([]sift.Value, error) {
+			s, ok := sift.AsString(v)
+			affix, affixOK := sift.AsString(affixv)
+			if !ok || !affixOK {
+				return []sift.Value{v}, nil
+			}
+			return []sift.Value{sift.Must(sift.ToValue(trim(s, affix)))}, nil
+		})
+	}
+}
+
+// splits produces the substrings of its input between matches of the
+// regular expression re. splits(re; flags) accepts jq's regular expression
+// flags: g (ignored, since splits always finds every match), i
+// (case-insensitive), x (extended syntax, ignoring whitespace and
+// comments), s (. matches newlines), p (both s and x), l (prefer longest
+// matches), and n (ignore empty matches).
+func splits(_ *CompileOptions, args []sift.Filter) sift.Filter {
+	operands := append([]sift.Filter{id}, args...)
+	return sift.Nary(operands, func(vs []sift.Value) ([]sift.Value, error) {
+		s, ok := sift.AsString(vs[0])
+		if !ok {
+			return nil, fmt.Errorf("splits: value %v is not a string", vs[0])
+		}
+		re, ok := sift.AsString(vs[1])
+		if !ok {
+			return nil, fmt.Errorf("splits: regular expression %v is not a string", vs[1])
+		}
+		flags := ""
+		if len(vs) > 2 && !sift.IsNull(vs[2]) {
+			if flags, ok = sift.AsString(vs[2]); !ok {
+				return nil, fmt.Errorf("splits: flags %v are not a string", vs[2])
+			}
+		}
+		rx, ignoreEmpty, err := compileRegexp(re, flags)
+		if err != nil {
+			return nil, fmt.Errorf("splits: %v", err)
+		}
+		var outs []sift.Value
+		start := 0
+		for _, m := range rx.FindAllStringIndex(s, -1) {
+			if ignoreEmpty && m[0] == m[1] {
+				continue
+			}
+			outs = append(outs, sift.Must(sift.ToValue(s[start:m[0]])))
+			start = m[1]
+		}
+		outs = append(outs, sift.Must(sift.ToValue(s[start:])))
+		return outs, nil
+	})
+}
+
+// compileRegexp compiles a regular expression with jq's flags, described
+// in splits. ignoreEmpty is true if the n flag is set.
+func compileRegexp(re, flags string) (rx *regexp.Regexp, ignoreEmpty bool, err error) {
+	var prefix string
+	var extended, longest bool
+	for _, f := range flags {
+		switch f {
+		case 'g':
+		case 'i':
+			prefix += "i"
+		case 's':
+			prefix += "s"
+		case 'x':
+			extended = true
+		case 'p':
+			prefix += "s"
+			extended = true
+		case 'l':
+			longest = true
+		case 'n':
+			ignoreEmpty = true
+		default:
+			return nil, false, fmt.Errorf("%q is not a valid modifier string", flags)
+		}
+	}
+	if extended {
+		re = stripExtended(re)
+	}
+	if prefix != "" {
+		re = "(?" + prefix + ")" + re
+	}
+	if rx, err = regexp.Compile(re); err != nil {
+		return nil, false, err
+	}
+	if longest {
+		rx.Longest()
+	}
+	return rx, ignoreEmpty, nil
+}
+
+// stripExtended removes unescaped whitespace and comments, which start with
+// # and continue to the end of the line, from a regular expression written
+// with extended syntax. Whitespace and # in character classes are kept.
+func stripExtended(re string) string {
+	b := &strings.Builder{}
+	inClass, inComment := false, false
+	for i := 0; i < len(re); i++ {
+		c := re[i]
+		switch {
+		case inComment:
+			inComment = c != '\n'
+		case c == '\\' && i+1 < len(re):
+			b.WriteByte(c)
+			i++
+			b.WriteByte(re[i])
+		case inClass:
+			inClass = c != ']'
+			b.WriteByte(c)
+		case c == '[':
+			inClass = true
+			b.WriteByte(c)
+		case c == '#':
+			inComment = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// envBuiltin produces an object containing the process's environment
+// variables.
+func envBuiltin(*CompileOptions, []sift.Filter) sift.Filter {
+	return func(sift.Value) ([]sift.Value, error) {
+		m := make(map[string]sift.Value)
+		for _, kv := range os.Environ() {
+			if i := strings.IndexByte(kv, '='); i > 0 {
+				m[kv[:i]] = sift.Must(sift.ToValue(kv[i+1:]))
+			}
+		}
+		return []sift.Value{sift.Must(sift.ToValue(m))}, nil
+	}
+}
+
+// input reads and produces the next value from CompileOptions.Input.
+func input(opts *CompileOptions, _ []sift.Filter) sift.Filter {
+	return func(sift.Value) ([]sift.Value, error) {
+		if opts.Input == nil {
+			return nil, errors.New("input: no input available")
+		}
+		v, err := opts.Input.Decode()
+		if err == io.EOF {
+			return nil, errors.New("input: no more inputs")
+		} else if err != nil {
+			return nil, err
+		}
+		return []sift.Value{v}, nil
+	}
+}
+
+// inputs reads and produces all remaining values from CompileOptions.Input.
+func inputs(opts *CompileOptions, _ []sift.Filter) sift.Filter {
+	return func(sift.Value) ([]sift.Value, error) {
+		if opts.Input == nil {
+			return nil, errors.New("inputs: no input available")
+		}
+		var vs []sift.Value
+		for {
+			v, err := opts.Input.Decode()
+			if err == io.EOF {
+				return vs, nil
+			} else if err != nil {
+				return nil, err
+			}
+			vs = append(vs, v)
+		}
+	}
+}
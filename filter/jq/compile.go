@@ -0,0 +1,755 @@
+package jq
+
+import (
+	"fmt"
+	gotoken "go/token"
+	"math"
+	"sort"
+
+	"go.jayconrod.com/sift"
+)
+
+// CompileFile compiles a syntax tree returned by Parse, which may have been
+// modified since it was parsed. fset must be the FileSet passed to Parse;
+// it is used to report positions in errors.
+func CompileFile(fset *gotoken.FileSet, f *File, opts CompileOptions) (prog *Program, err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		} else if e, ok := r.(error); ok {
+			prog, err = nil, e
+		} else {
+			panic(r)
+		}
+	}()
+	return compileFile(fset, f, &opts), nil
+}
+
+// compileFile compiles a program's syntax tree. Variables in opts.Vars are
+// stored in the first locals of the main program's frame. Errors are
+// reported by panicking; callers must recover them.
+func compileFile(fset *gotoken.FileSet, f *File, opts *CompileOptions) *Program {
+	if len(opts.Plugins) > 0 {
+		opts.Funcs = pluginFuncs(opts.Funcs, opts.Plugins)
+	}
+	c := newCompiler(fset, newLoader(fset, opts), opts, "")
+
+	names := make([]string, 0, len(opts.Vars))
+	for name := range opts.Vars {
+		if !isIdentifier(name) {
+			panic(fmt.Errorf("invalid variable name %q", name))
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]sift.Value, len(names))
+	for i, name := range names {
+		values[i] = opts.Vars[name]
+		c.scope = c.scope.define("$"+name, c.newVariable(name))
+	}
+
+	c.compileProgram(f)
+	return &Program{main: c.code, vars: values, opts: opts}
+}
+
+// pluginFuncs negotiates with each plugin and returns a map containing its
+// functions and funcs, which take precedence. funcs is not modified.
+func pluginFuncs(funcs map[string]HostFunc, plugins []Plugin) map[string]HostFunc {
+	merged := make(map[string]HostFunc)
+	provider := make(map[string]string)
+	for _, p := range plugins {
+		version, err := sift.Negotiate(p.Requirements)
+		if err != nil {
+			panic(err)
+		}
+		for key, fn := range p.Funcs(version) {
+			if other, ok := provider[key]; ok {
+				panic(fmt.Errorf("%s is provided by plugins %s and %s", key, other, p.Requirements.Name))
+			}
+			provider[key] = p.Requirements.Name
+			merged[key] = fn
+		}
+	}
+	for key, fn := range funcs {
+		merged[key] = fn
+	}
+	return merged
+}
+
+// A compiler translates syntax trees into code blocks. It resolves names to
+// the functions, parameters, and variables they refer to and loads imported
+// modules.
+type compiler struct {
+	fset   *gotoken.FileSet
+	loader *loader
+	opts   *CompileOptions
+
+	// dir is the directory containing the file being compiled. Modules
+	// imported by the file are searched for here first.
+	dir string
+
+	// scope contains names of functions, parameters, and variables visible
+	// at the expression being compiled.
+	scope *scope
+
+	// code is the code block instructions are emitted into.
+	code *code
+
+	// pos is the position attributed to errors in the instructions being
+	// emitted, or nil if they should be attributed to the caller.
+	pos *gotoken.Position
+
+	// depth is the trace depth of the expression being compiled, relative
+	// to the top level of the code block.
+	depth int
+
+	// coverParent is the index in opts.Coverage of the expression enclosing
+	// the one being compiled, or -1.
+	coverParent int
+}
+
+func newCompiler(fset *gotoken.FileSet, l *loader, opts *CompileOptions, dir string) *compiler {
+	return &compiler{
+		fset:        fset,
+		loader:      l,
+		opts:        opts,
+		dir:         dir,
+		code:        &code{name: "main"},
+		coverParent: -1,
+	}
+}
+
+func (c *compiler) compileProgram(f *File) {
+	c.compileImports(f.Imports)
+	if f.Body != nil {
+		c.compileExpr(f.Body)
+	}
+	c.emit(inst{op: opRet})
+}
+
+// compileModule compiles a module file. The returned module exports the
+// functions defined in the file (but not those it imports).
+func (c *compiler) compileModule(f *File) *module {
+	c.compileImports(f.Imports)
+	var defs []*function
+	for _, d := range f.Defs {
+		fn := c.compileFuncDef(d)
+		c.scope = c.scope.define(funcKey(fn.name, len(fn.params)), fn)
+		defs = append(defs, fn)
+	}
+	return &module{defs: defs}
+}
+
+// compileImports loads modules named in import and include directives.
+// Functions from imported modules are added to the compiler's scope.
+func (c *compiler) compileImports(imports []*Import) {
+	for _, imp := range imports {
+		m, err := c.loader.load(imp.Path, c.dir)
+		if err != nil {
+			c.panicf(imp.ImportPos, "%v", err)
+		}
+		prefix := ""
+		if imp.Name != "" {
+			prefix = imp.Name + "::"
+		}
+		for _, fn := range m.defs {
+			c.scope = c.scope.define(funcKey(prefix+fn.name, len(fn.params)), fn)
+		}
+	}
+}
+
+// compileFuncDef compiles a function definition into a new code block. The
+// function is visible in its own body, but it is not added to the
+// compiler's scope afterward.
+func (c *compiler) compileFuncDef(d *FuncDef) *function {
+	fn := &function{
+		name:  d.Name,
+		level: c.code.level,
+		code:  &code{name: funcKey(d.Name, len(d.Params)), level: c.code.level + 1},
+	}
+	savedCode, savedPos, savedDepth := c.code, c.pos, c.depth
+	c.code, c.pos, c.depth = fn.code, nil, 0
+	for i, prm := range d.Params {
+		p := &param{name: prm.Name, level: fn.code.level, index: i}
+		if prm.Variable {
+			p.variable = c.newVariable(prm.Name)
+		}
+		fn.params = append(fn.params, p)
+	}
+
+	saved := c.scope
+	c.scope = c.scope.define(funcKey(fn.name, len(fn.params)), fn)
+	for _, prm := range fn.params {
+		c.scope = c.scope.define(funcKey(prm.name, 0), prm)
+		if prm.variable != nil {
+			c.scope = c.scope.define("$"+prm.name, prm.variable)
+		}
+	}
+
+	// A variable parameter is bound to each value its argument produces,
+	// as if the body were "arg as $param | body".
+	for _, prm := range fn.params {
+		if prm.variable != nil {
+			c.emit(inst{op: opDup})
+			c.emit(inst{op: opCallParam, aux: &paramSite{index: prm.index}})
+			c.emit(inst{op: opStore, a: prm.variable.slot})
+		}
+	}
+	c.compileExpr(d.Body)
+	c.emit(inst{op: opRet})
+
+	c.scope = saved
+	c.code, c.pos, c.depth = savedCode, savedPos, savedDepth
+	return fn
+}
+
+// compileClosure compiles an argument to a function into a new code block.
+// The argument may be evaluated any number of times, each time in a new
+// frame whose parent is the caller's frame.
+func (c *compiler) compileClosure(x Expr) *code {
+	savedCode, savedPos, savedDepth := c.code, c.pos, c.depth
+	c.code = &code{name: savedCode.name + " argument", level: savedCode.level + 1}
+	c.pos, c.depth = nil, 0
+	c.compileExpr(x)
+	c.emit(inst{op: opRet})
+	cl := c.code
+	c.code, c.pos, c.depth = savedCode, savedPos, savedDepth
+	return cl
+}
+
+var binaryOps = map[Op]func(x, y sift.Value) (sift.Value, error){
+	OpAdd: add,
+	OpSub: sub,
+	OpMul: mul,
+	OpDiv: div,
+	OpMod: numOp(math.Mod),
+}
+
+// compileExpr compiles an expression, adding coverage counters and tracing
+// if they're enabled.
+func (c *compiler) compileExpr(x Expr) {
+	if _, ok := x.(*Paren); ok {
+		c.compileNode(x)
+		return
+	}
+	pos := c.fset.Position(x.Pos())
+	if c.opts.Trace != nil {
+		c.emit(inst{op: opTrace, aux: &traceSite{
+			node:     x,
+			position: pos,
+			depth:    c.depth,
+			vars:     c.visibleVars(),
+		}})
+	}
+	c.depth++
+	if cov := c.opts.Coverage; cov == nil {
+		c.compileNode(x)
+	} else {
+		i := cov.add(x, pos, c.coverParent)
+		c.emit(inst{op: opCover, aux: cov.nodes[i]})
+		saved := c.coverParent
+		c.coverParent = i
+		c.compileNode(x)
+		c.coverParent = saved
+	}
+	c.depth--
+}
+
+func (c *compiler) compileNode(x Expr) {
+	switch x := x.(type) {
+	case *Identity:
+
+	case *Recurse:
+		c.emit(inst{op: opGen, gen: recurseGen})
+
+	case *Literal:
+		c.emit(inst{op: opConst, v: x.Value})
+
+	case *Var:
+		sym, ok := c.scope.lookup("$" + x.Name)
+		if !ok {
+			c.panicf(x.NamePos, "$%s is not defined", x.Name)
+		}
+		v := sym.(*variable)
+		c.emit(inst{op: opLoad, a: v.slot, b: c.code.level - v.level})
+
+	case *Neg:
+		c.positioned(x.OpPos, func() {
+			c.compileExpr(x.X)
+			c.emit(inst{op: opApply, filter: sift.MapError(neg)})
+		})
+
+	case *Binary:
+		c.compileBinary(x)
+
+	case *Paren:
+		c.compileExpr(x.X)
+
+	case *Field:
+		c.compileBase(x.X)
+		c.emit(inst{op: opApply, filter: attrLit(x.Name, !x.Optional)})
+
+	case *Index:
+		indexOp := index
+		if c.opts.NullSafe {
+			indexOp = indexSafe
+		}
+		c.positioned(x.Lbrack, func() {
+			c.compileOperands(x.X, x.Index)
+			c.emit(inst{op: opNary, a: 2, nary: func(vs []sift.Value) ([]sift.Value, error) {
+				return indexOp(vs[0], vs[1])
+			}})
+		})
+
+	case *Slice:
+		c.compileSlice(x)
+
+	case *Iterate:
+		gen := iterateGen
+		if x.Optional || c.opts.NullSafe {
+			gen = iterateOptGen
+		}
+		c.positioned(x.Lbrack, func() {
+			c.compileBase(x.X)
+			c.emit(inst{op: opGen, gen: gen})
+		})
+
+	case *Try:
+		// Errors in the body stop it from producing more values. Errors in
+		// the expressions consuming its values are not caught. A handler
+		// is applied to the error's payload; errors in the handler are not
+		// caught either.
+		try := c.newAux()
+		begin := c.emit(inst{op: opTryBegin, b: try, aux: x.Catch != nil})
+		c.compileExpr(x.X)
+		c.emit(inst{op: opTryEnd, b: try})
+		end := c.emit(inst{op: opJump})
+		c.patch(begin)
+		if x.Catch != nil {
+			c.compileExpr(x.Catch)
+		} else {
+			c.emit(inst{op: opBacktrack})
+		}
+		c.patch(end)
+
+	case *Array:
+		c.compileArray(x)
+
+	case *Object:
+		c.compileObject(x)
+
+	case *Call:
+		c.compileCall(x)
+
+	case *As:
+		c.compileAs(x)
+
+	case *FuncDefExpr:
+		saved := c.scope
+		fn := c.compileFuncDef(x.Def)
+		c.scope = c.scope.define(funcKey(fn.name, len(fn.params)), fn)
+		c.compileExpr(x.Rest)
+		c.scope = saved
+
+	default:
+		panic(fmt.Sprintf("unexpected expression of type %T", x))
+	}
+}
+
+func (c *compiler) compileBinary(x *Binary) {
+	switch x.Op {
+	case OpPipe:
+		c.compileExpr(x.X)
+		c.compileExpr(x.Y)
+
+	case OpComma:
+		fork := c.emit(inst{op: opFork})
+		c.compileExpr(x.X)
+		end := c.emit(inst{op: opJump})
+		c.patch(fork)
+		c.compileExpr(x.Y)
+		c.patch(end)
+
+	case OpOr, OpAnd:
+		// The right operands of or and and are evaluated lazily, only when
+		// the left operand does not determine the result. This is
+		// a guarantee: programs may rely on it to avoid errors and
+		// side effects, as in .list and .list[0].
+		op := opOr
+		if x.Op == OpAnd {
+			op = opAnd
+		}
+		c.positioned(x.OpPos, func() {
+			c.emit(inst{op: opDup})
+			c.compileExpr(x.X)
+			short := c.emit(inst{op: op})
+			c.compileExpr(x.Y)
+			c.emit(inst{op: opTruth})
+			c.patch(short)
+		})
+
+	default:
+		op, ok := binaryOps[x.Op]
+		if !ok {
+			c.panicf(x.OpPos, "unknown operator %v", x.Op)
+		}
+		c.positioned(x.OpPos, func() {
+			c.compileOperands(x.X, x.Y)
+			c.emit(inst{op: opNary, a: 2, nary: func(vs []sift.Value) ([]sift.Value, error) {
+				v, err := op(vs[0], vs[1])
+				if err != nil {
+					return nil, err
+				}
+				return []sift.Value{v}, nil
+			}})
+		})
+	}
+}
+
+// compileBase compiles the operand of a postfix expression, which is the
+// input when x is nil.
+func (c *compiler) compileBase(x Expr) {
+	if x != nil {
+		c.compileExpr(x)
+	}
+}
+
+// compileOperands compiles expressions that are each evaluated with the
+// same input, leaving one value from each on the stack. Operands are
+// evaluated in order, and the last operand's values vary fastest, so an
+// operation on the values is applied to their Cartesian product. A nil
+// operand is the input.
+func (c *compiler) compileOperands(xs ...Expr) {
+	for i, x := range xs {
+		last := i == len(xs)-1
+		if !last {
+			c.emit(inst{op: opDup})
+		}
+		c.compileBase(x)
+		if !last {
+			c.emit(inst{op: opSwap})
+		}
+	}
+}
+
+func (c *compiler) compileSlice(x *Slice) {
+	sliceOp := slice
+	if c.opts.NullSafe {
+		sliceOp = sliceSafe
+	}
+	var nary func([]sift.Value) ([]sift.Value, error)
+	var operands []Expr
+	switch {
+	case x.Begin == nil && x.End == nil:
+		c.panicf(x.Lbrack, "slice must have a beginning or an end")
+	case x.Begin == nil:
+		operands = []Expr{x.X, x.End}
+		nary = func(vs []sift.Value) ([]sift.Value, error) {
+			return sliceOp(vs[0], nil, vs[1])
+		}
+	case x.End == nil:
+		operands = []Expr{x.X, x.Begin}
+		nary = func(vs []sift.Value) ([]sift.Value, error) {
+			return sliceOp(vs[0], vs[1], nil)
+		}
+	default:
+		operands = []Expr{x.X, x.Begin, x.End}
+		nary = func(vs []sift.Value) ([]sift.Value, error) {
+			return sliceOp(vs[0], vs[1], vs[2])
+		}
+	}
+	c.positioned(x.Lbrack, func() {
+		c.compileOperands(operands...)
+		c.emit(inst{op: opNary, a: len(operands), nary: nary})
+	})
+}
+
+// compileArray compiles an array constructor. Each value produced by the
+// elements is appended to an array builder; when the elements have no more
+// values, the machine backtracks to the fork before them and builds the
+// array.
+func (c *compiler) compileArray(x *Array) {
+	acc := c.newAux()
+	c.emit(inst{op: opArrayStart, a: acc})
+	if len(x.Elems) > 0 {
+		end := c.emit(inst{op: opFork})
+		for i, elem := range x.Elems {
+			next := -1
+			if i < len(x.Elems)-1 {
+				next = c.emit(inst{op: opFork})
+			}
+			c.compileExpr(elem)
+			c.emit(inst{op: opAppend, a: acc})
+			c.emit(inst{op: opBacktrack})
+			if next >= 0 {
+				c.patch(next)
+			}
+		}
+		c.patch(end)
+	}
+	c.emit(inst{op: opArrayEnd, a: acc})
+}
+
+func (c *compiler) compileObject(x *Object) {
+	if len(x.Entries) == 0 {
+		c.emit(inst{op: opConst, v: sift.Must(sift.ToValue(map[string]sift.Value{}))})
+		return
+	}
+	operands := make([]Expr, 0, 2*len(x.Entries))
+	for _, entry := range x.Entries {
+		operands = append(operands, entry.Key, entry.Value)
+	}
+	c.positioned(x.Lbrace, func() {
+		c.compileOperands(operands...)
+		c.emit(inst{op: opNary, a: len(operands), nary: constructObject})
+	})
+}
+
+func (c *compiler) compileCall(x *Call) {
+	if c.callsPick(x) {
+		c.compilePick(x)
+		return
+	}
+	args := make([]*code, len(x.Args))
+	for i, arg := range x.Args {
+		args[i] = c.compileClosure(arg)
+	}
+
+	key := funcKey(x.Name, len(args))
+	if sym, ok := c.scope.lookup(key); ok {
+		switch sym := sym.(type) {
+		case *function:
+			forward := make([]*paramSite, len(args))
+			for i, arg := range args {
+				if len(arg.insts) == 2 && arg.insts[0].op == opCallParam && arg.insts[1].op == opRet {
+					p := *arg.insts[0].aux.(*paramSite)
+					p.up-- // relative to the caller instead of the closure
+					forward[i] = &p
+				}
+			}
+			c.emit(inst{op: opCall, aux: &callSite{
+				fn:      sym,
+				args:    args,
+				forward: forward,
+				pos:     c.fset.Position(x.NamePos),
+				depth:   c.depth,
+			}})
+			return
+		case *param:
+			c.emit(inst{op: opCallParam, aux: &paramSite{
+				up:    c.code.level - sym.level,
+				index: sym.index,
+				depth: c.depth,
+			}})
+			return
+		}
+	}
+	for _, hostKey := range c.opts.HostFuncs {
+		if key == hostKey {
+			c.checkEffects(x.NamePos, key, effectIO)
+			c.positioned(x.NamePos, func() {
+				c.emitGo(args, func(run *runState, args []sift.Filter) sift.Filter {
+					return callHost(run, key, args)
+				})
+			})
+			return
+		}
+	}
+	if key == "host_data/0" {
+		c.emitGo(nil, hostData)
+		return
+	}
+	if fn, ok := c.opts.Funcs[key]; ok {
+		c.checkEffects(x.NamePos, key, effectIO)
+		c.positioned(x.NamePos, func() {
+			c.emitGo(args, func(run *runState, args []sift.Filter) sift.Filter {
+				return callFunc(run, fn, args)
+			})
+		})
+		return
+	}
+	b, ok := lookupBuiltin(x.Name, len(args))
+	if !ok {
+		c.panicf(x.NamePos, "%s is not defined", key)
+	}
+	c.checkEffects(x.NamePos, key, b.effects)
+	if c.opts.disabled(b.requires) {
+		c.panicf(x.NamePos, "%s is disabled", key)
+	}
+	opts := c.opts
+	c.positioned(x.NamePos, func() {
+		switch {
+		case b.gen != nil && len(args) == 0:
+			c.emit(inst{op: opGen, gen: b.gen(opts, nil)})
+		case b.gen != nil:
+			c.emit(inst{op: opCallGen, aux: &genSite{
+				args:  args,
+				depth: c.depth,
+				build: func(_ *runState, args []genFilter) genFilter {
+					return b.gen(opts, args)
+				},
+			}})
+		case len(args) == 0:
+			c.emit(inst{op: opApply, filter: b.impl(opts, nil)})
+		default:
+			c.emitGo(args, func(_ *runState, args []sift.Filter) sift.Filter {
+				return b.impl(opts, args)
+			})
+		}
+	})
+}
+
+// emitGo emits a call to a function implemented in Go. build returns
+// a filter implementing the call, given filters for its arguments.
+func (c *compiler) emitGo(args []*code, build func(*runState, []sift.Filter) sift.Filter) {
+	c.emit(inst{op: opCallGo, aux: &goSite{args: args, depth: c.depth, build: build}})
+}
+
+// compileAs compiles a binding like "source as $x ?// [$x] | body".
+// The variables in all patterns are visible in body. A variable that
+// appears in several patterns is the same variable in each.
+//
+// For each value produced by source, body is evaluated with the variables
+// in the first pattern bound. If binding fails or body reports an error,
+// the next pattern is tried. Variables that don't appear in the pattern
+// being tried are bound to null. Body is evaluated with the same input as
+// source.
+func (c *compiler) compileAs(x *As) {
+	c.positioned(x.AsPos, func() {
+		inSlot := -1
+		if patternsUseInput(x.Patterns) {
+			inSlot = c.newLocal()
+			c.emit(inst{op: opDup})
+			c.emit(inst{op: opStore, a: inSlot})
+		}
+		c.emit(inst{op: opDup})
+		c.compileExpr(x.X)
+
+		vars := make(map[string]*variable)
+		var varList []*variable
+		try := -1
+		if len(x.Patterns) == 1 {
+			c.compilePattern(x.Patterns[0], vars, &varList, inSlot)
+		} else {
+			valueSlot := c.newLocal()
+			c.emit(inst{op: opStore, a: valueSlot})
+			try = c.newAux()
+			var clears, jumps []int
+			for i, pat := range x.Patterns {
+				last := i == len(x.Patterns)-1
+				begin := -1
+				if last {
+					c.emit(inst{op: opTryNone, b: try})
+				} else {
+					begin = c.emit(inst{op: opTryBegin, b: try})
+				}
+				clears = append(clears, c.emit(inst{op: opClear}))
+				c.emit(inst{op: opPush, a: valueSlot})
+				c.compilePattern(pat, vars, &varList, inSlot)
+				if !last {
+					jumps = append(jumps, c.emit(inst{op: opJump}))
+					c.patch(begin)
+				}
+			}
+			for _, j := range jumps {
+				c.patch(j)
+			}
+			slots := make([]int, len(varList))
+			for i, v := range varList {
+				slots[i] = v.slot
+			}
+			for _, i := range clears {
+				c.code.insts[i].aux = slots
+			}
+		}
+
+		saved := c.scope
+		for _, v := range varList {
+			c.scope = c.scope.define("$"+v.name, v)
+		}
+		c.compileExpr(x.Body)
+		c.scope = saved
+		if try >= 0 {
+			c.emit(inst{op: opTryEnd, b: try})
+		}
+	})
+}
+
+// checkEffects reports an error if the function named by key has effects
+// the program's profile does not allow.
+func (c *compiler) checkEffects(pos gotoken.Pos, key string, e effects) {
+	if !c.opts.Profile.allows(e) {
+		c.panicf(pos, "%s is not allowed in the %s profile", key, c.opts.Profile)
+	}
+}
+
+// positioned calls compile, attributing errors reported by the
+// instructions it emits to pos, unless they're attributed to a more deeply
+// nested expression.
+func (c *compiler) positioned(pos gotoken.Pos, compile func()) {
+	saved := c.pos
+	p := c.fset.Position(pos)
+	c.pos = &p
+	compile()
+	c.pos = saved
+}
+
+// emit appends an instruction to the current code block and returns its
+// index.
+func (c *compiler) emit(in inst) int {
+	in.pos = c.pos
+	c.code.insts = append(c.code.insts, in)
+	return len(c.code.insts) - 1
+}
+
+// patch sets the target of the jump or fork instruction at index i to the
+// next instruction to be emitted.
+func (c *compiler) patch(i int) {
+	c.code.insts[i].a = len(c.code.insts)
+}
+
+func (c *compiler) newLocal() int {
+	c.code.nlocals++
+	return c.code.nlocals - 1
+}
+
+func (c *compiler) newAux() int {
+	c.code.naux++
+	return c.code.naux - 1
+}
+
+// newVariable allocates a variable in the current code block.
+func (c *compiler) newVariable(name string) *variable {
+	return &variable{name: name, level: c.code.level, slot: c.newLocal()}
+}
+
+// visibleVars returns the variables visible in the current scope,
+// innermost first, for Step.Vars.
+func (c *compiler) visibleVars() []traceVar {
+	var vars []traceVar
+	seen := make(map[string]bool)
+	for s := c.scope; s != nil; s = s.parent {
+		v, ok := s.sym.(*variable)
+		if !ok || s.name[0] != '$' || seen[s.name] {
+			continue
+		}
+		seen[s.name] = true
+		vars = append(vars, traceVar{name: v.name, up: c.code.level - v.level, slot: v.slot})
+	}
+	return vars
+}
+
+func (c *compiler) panicf(pos gotoken.Pos, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	panic(compileError{c.fset.Position(pos), message})
+}
+
+type compileError struct {
+	position gotoken.Position
+	message  string
+}
+
+func (e compileError) Error() string {
+	return fmt.Sprintf("%s: %s", e.position, e.message)
+}
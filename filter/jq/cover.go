@@ -0,0 +1,97 @@
+package jq
+
+import (
+	"fmt"
+	gotoken "go/token"
+	"io"
+	"sync/atomic"
+)
+
+// Coverage records which expressions in a program were evaluated. To
+// collect coverage, set CompileOptions.Coverage to the value returned by
+// NewCoverage, then evaluate the program with a set of test inputs.
+// Expressions in imported modules are included.
+//
+// A Coverage may be read while the program is being evaluated, but it
+// should only be used to compile one program.
+type Coverage struct {
+	nodes []*coverNode
+}
+
+type coverNode struct {
+	expr     Expr
+	position gotoken.Position
+	parent   int // index of the enclosing expression, or -1
+	count    int64
+}
+
+// NewCoverage returns an empty Coverage.
+func NewCoverage() *Coverage {
+	return &Coverage{}
+}
+
+// An UncoveredExpr is an expression that was never evaluated.
+type UncoveredExpr struct {
+	Position gotoken.Position
+	Node     Expr
+}
+
+// Covered returns the number of expressions that were evaluated at least
+// once and the total number of expressions.
+func (c *Coverage) Covered() (covered, total int) {
+	for _, n := range c.nodes {
+		if atomic.LoadInt64(&n.count) > 0 {
+			covered++
+		}
+	}
+	return covered, len(c.nodes)
+}
+
+// Uncovered returns the expressions that were never evaluated, in the
+// order they appear in the program. Expressions nested within an
+// uncovered expression are not listed separately, so each result
+// typically identifies a branch no test input reached, like the right
+// operand of or, a pattern alternative, or a function that was never
+// called.
+func (c *Coverage) Uncovered() []UncoveredExpr {
+	var uncovered []UncoveredExpr
+	for _, n := range c.nodes {
+		if atomic.LoadInt64(&n.count) > 0 {
+			continue
+		}
+		if n.parent >= 0 && atomic.LoadInt64(&c.nodes[n.parent].count) == 0 {
+			continue
+		}
+		uncovered = append(uncovered, UncoveredExpr{Position: n.position, Node: n.expr})
+	}
+	return uncovered
+}
+
+// WriteReport writes a summary of the coverage and a list of uncovered
+// expressions to w.
+func (c *Coverage) WriteReport(w io.Writer) error {
+	covered, total := c.Covered()
+	percent := 100.0
+	if total > 0 {
+		percent = 100 * float64(covered) / float64(total)
+	}
+	if _, err := fmt.Fprintf(w, "coverage: %d of %d expressions (%.1f%%)\n", covered, total, percent); err != nil {
+		return err
+	}
+	for _, u := range c.Uncovered() {
+		text := Format(u.Node)
+		if len(text) > 60 {
+			text = text[:57] + "..."
+		}
+		if _, err := fmt.Fprintf(w, "%s: not evaluated: %s\n", u.Position, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add registers an expression and returns its index.
+func (c *Coverage) add(x Expr, pos gotoken.Position, parent int) int {
+	c.nodes = append(c.nodes, &coverNode{expr: x, position: pos, parent: parent})
+	return len(c.nodes) - 1
+}
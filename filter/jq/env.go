@@ -0,0 +1,110 @@
+package jq
+
+import (
+	"go.jayconrod.com/sift"
+)
+
+// A function is a function defined with def in a jq program.
+type function struct {
+	name   string
+	params []*param
+
+	// level is the nesting level of the code block containing the
+	// definition. When the function is called, its frame's parent is the
+	// frame at this level.
+	level int
+
+	// code is the compiled body of the function. It is allocated before
+	// the body is compiled, so recursive calls may refer to it.
+	code *code
+}
+
+// A param is a parameter of a function. All parameters may be called like
+// functions with no arguments. Parameters declared with $ may also be
+// referenced as variables.
+type param struct {
+	name     string
+	variable *variable
+
+	// level is the nesting level of the function's code, and index is the
+	// parameter's position in the function's parameter list. Together,
+	// they locate the closure bound to the parameter at run-time.
+	level, index int
+}
+
+// A variable is a name bound to a single value. Variables are stored in
+// a slot in the locals of the frame for the code block at level.
+type variable struct {
+	name        string
+	level, slot int
+}
+
+// A scope maps names visible at some point in a program to the symbols they
+// refer to (*function, *param, *variable). Functions and parameters are
+// named by their name and arity, like "f/1". Variables are named with
+// a leading "$". Scopes are immutable; defining a name creates a new scope.
+type scope struct {
+	parent *scope
+	name   string
+	sym    interface{}
+}
+
+func (s *scope) define(name string, sym interface{}) *scope {
+	return &scope{parent: s, name: name, sym: sym}
+}
+
+func (s *scope) lookup(name string) (interface{}, bool) {
+	for ; s != nil; s = s.parent {
+		if s.name == name {
+			return s.sym, true
+		}
+	}
+	return nil, false
+}
+
+// A frame holds the run-time state of one activation of a code block: the
+// main program, a function body, or a closure. Frames are linked to the
+// frame of the lexically enclosing code block, so a code block at level n
+// finds variables at level m by following n-m parent links.
+type frame struct {
+	parent *frame
+
+	// locals holds the values of variables, indexed by slot.
+	locals []sift.Value
+
+	// aux holds array builders and try states, indexed by slot.
+	aux []interface{}
+
+	// args holds the closures bound to a function's parameters.
+	args []closure
+
+	// base is the trace depth of expressions at the top level of the
+	// code block. See Step.Depth.
+	base int
+}
+
+func newFrame(c *code, parent *frame, args []closure, base int) *frame {
+	fr := &frame{parent: parent, args: args, base: base}
+	if c.nlocals > 0 {
+		fr.locals = make([]sift.Value, c.nlocals)
+	}
+	if c.naux > 0 {
+		fr.aux = make([]interface{}, c.naux)
+	}
+	return fr
+}
+
+// up returns the frame n levels above fr.
+func (fr *frame) up(n int) *frame {
+	for ; n > 0; n-- {
+		fr = fr.parent
+	}
+	return fr
+}
+
+// A closure is an argument bound to a function parameter: a code block
+// together with the frame of the caller, where it must be evaluated.
+type closure struct {
+	code *code
+	fr   *frame
+}
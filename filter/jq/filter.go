@@ -2,8 +2,12 @@ package jq
 
 import (
 	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
 
 	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
 )
 
 func id(v sift.Value) ([]sift.Value, error) {
@@ -19,18 +23,29 @@ func attrLit(lit string, required bool) sift.Filter {
 				return nil, nil
 			}
 		} else {
-			return []sift.Value{value}, nil
+			return accessed(value)
 		}
 	}
 }
 
+// accessed returns v, an attribute or element of another value, as the
+// only output of an access. If v is an *sift.ErrorValue, like a LazyAttr
+// field that couldn't be produced, it's raised as an error instead, so it
+// may be caught.
+func accessed(v sift.Value) ([]sift.Value, error) {
+	if ev, ok := v.(*sift.ErrorValue); ok {
+		return nil, ev
+	}
+	return []sift.Value{v}, nil
+}
+
 func index(base, idx sift.Value) ([]sift.Value, error) {
 	switch base := base.(type) {
 	case sift.Index:
 		n := base.Length()
 		f, ok := sift.AsFloat64(idx)
 		if !ok {
-			return nil, fmt.Errorf("cannot index array with value %#v", idx)
+			return nil, fmt.Errorf("cannot index array with %s", describe(idx))
 		}
 		i := int(f)
 		if f != float64(i) {
@@ -43,14 +58,14 @@ func index(base, idx sift.Value) ([]sift.Value, error) {
 		if !ok {
 			v = sift.Must(sift.ToValue(nil))
 		}
-		return []sift.Value{v}, nil
+		return accessed(v)
 
 	case sift.Attr:
 		v, ok := base.Attr(idx)
 		if !ok {
 			v = sift.Must(sift.ToValue(nil))
 		}
-		return []sift.Value{v}, nil
+		return accessed(v)
 
 	default:
 		if !sift.IsNull(base) {
@@ -61,6 +76,14 @@ func index(base, idx sift.Value) ([]sift.Value, error) {
 	}
 }
 
+// indexSafe is like index but produces null instead of an error.
+func indexSafe(base, idx sift.Value) ([]sift.Value, error) {
+	if vs, err := index(base, idx); err == nil {
+		return vs, nil
+	}
+	return []sift.Value{sift.NullValue}, nil
+}
+
 func slice(base, begin, end sift.Value) ([]sift.Value, error) {
 	if sift.IsNull(base) {
 		return []sift.Value{sift.NullValue}, nil
@@ -90,15 +113,14 @@ func slice(base, begin, end sift.Value) ([]sift.Value, error) {
 	}
 
 	if baseIndex, ok := base.(sift.Index); ok {
-		elems := make([]sift.Value, 0, endI-beginI)
+		b := sift.NewArray(endI - beginI)
 		for i := beginI; i < endI; i++ {
 			elem, ok := baseIndex.Index(i)
 			if ok {
-				elems = append(elems, elem)
+				b.Append(elem)
 			}
 		}
-		list := sift.Must(sift.ToValue(elems))
-		return []sift.Value{list}, nil
+		return []sift.Value{b.Build()}, nil
 	} else if baseString, ok := sift.AsString(base); ok {
 		sub := sift.Must(sift.ToValue(baseString[beginI:endI]))
 		return []sift.Value{sub}, nil
@@ -107,6 +129,14 @@ func slice(base, begin, end sift.Value) ([]sift.Value, error) {
 	}
 }
 
+// sliceSafe is like slice but produces null instead of an error.
+func sliceSafe(base, begin, end sift.Value) ([]sift.Value, error) {
+	if vs, err := slice(base, begin, end); err == nil {
+		return vs, nil
+	}
+	return []sift.Value{sift.NullValue}, nil
+}
+
 func clampIndex(idx sift.Value, n int) (int, error) {
 	f, ok := sift.AsFloat64(idx)
 	if !ok {
@@ -125,10 +155,33 @@ func clampIndex(idx sift.Value, n int) (int, error) {
 	return i, nil
 }
 
+// describe returns a short description of v for error messages: its type
+// and its JSON text, truncated if it's long, like `object ({"a":1})`.
+func describe(v sift.Value) string {
+	const maxText = 30
+	b := &strings.Builder{}
+	text := fmt.Sprint(v)
+	if err := json.NewEncoder(b).Encode(v); err == nil {
+		text = strings.TrimSuffix(b.String(), "\n")
+	}
+	if len(text) > maxText {
+		// Cut at a rune boundary.
+		n := maxText
+		for n > 0 && !utf8.RuneStart(text[n]) {
+			n--
+		}
+		text = text[:n] + "..."
+	}
+	return fmt.Sprintf("%s (%s)", sift.TypeOf(v), text)
+}
+
 func iterate(v sift.Value) ([]sift.Value, error) {
-	idx, ok := v.(sift.Index)
-	if !ok {
-		return nil, fmt.Errorf("cannot iterate over value %#v", v)
+	if !isArray(v) {
+		return nil, fmt.Errorf("cannot iterate over %s", describe(v))
+	}
+	idx, err := sift.Collect(v)
+	if err != nil {
+		return nil, err
 	}
 	n := idx.Length()
 	elems := make([]sift.Value, n)
@@ -142,26 +195,24 @@ func iterate(v sift.Value) ([]sift.Value, error) {
 	return elems, nil
 }
 
-func iterateOpt(v sift.Value) ([]sift.Value, error) {
-	if _, ok := v.(sift.Index); !ok {
-		return nil, nil
-	}
-	return iterate(v)
-}
-
+// constructObject builds an object from alternating keys and values.
+// Keys are in the order they first appear, as in jq.
 func constructObject(attrs []sift.Value) ([]sift.Value, error) {
 	if len(attrs)%2 != 0 {
 		panic("constructObject with odd number of operands")
 	}
-	m := make(map[string]sift.Value)
+	b := sift.NewObject()
 	for ; len(attrs) > 0; attrs = attrs[2:] {
 		key, ok := sift.AsString(attrs[0])
 		if !ok {
 			return nil, fmt.Errorf("cannot use value %v as object key", attrs[0])
 		}
-		m[key] = attrs[1]
+		b.Set(key, attrs[1])
+	}
+	out, err := b.Build()
+	if err != nil {
+		return nil, err
 	}
-	out := sift.Must(sift.ToValue(m))
 	return []sift.Value{out}, nil
 }
 
@@ -174,18 +225,6 @@ func neg(v sift.Value) (sift.Value, error) {
 	return out, nil
 }
 
-func binop(op func(xv, yv sift.Value) (sift.Value, error)) func(xf, yf sift.Filter) sift.Filter {
-	return func(xf, yf sift.Filter) sift.Filter {
-		return sift.Binary(xf, yf, func(x, y sift.Value) ([]sift.Value, error) {
-			v, err := op(x, y)
-			if err != nil {
-				return nil, err
-			}
-			return []sift.Value{v}, nil
-		})
-	}
-}
-
 func add(x, y sift.Value) (sift.Value, error) {
 	if xn, ok := sift.AsFloat64(x); ok {
 		yn, ok := sift.AsFloat64(y)
@@ -264,8 +303,17 @@ func sub(x, y sift.Value) (sift.Value, error) {
 		if !ok {
 			return nil, fmt.Errorf("cannot substract value %v from list", y)
 		}
-		xlen := xl.Length()
+		// Group the elements of y by hash, so each element of x is only
+		// compared with elements of y that may be equal.
 		ylen := yl.Length()
+		byHash := make(map[uint64][]sift.Value, ylen)
+		for yi := 0; yi < ylen; yi++ {
+			if yelem, ok := yl.Index(yi); ok {
+				h := sift.Hash(yelem)
+				byHash[h] = append(byHash[h], yelem)
+			}
+		}
+		xlen := xl.Length()
 		outs := make([]sift.Value, 0, xlen)
 	Outer:
 		for xi := 0; xi < xlen; xi++ {
@@ -273,11 +321,7 @@ func sub(x, y sift.Value) (sift.Value, error) {
 			if !ok {
 				continue
 			}
-			for yi := 0; yi < ylen; yi++ {
-				yelem, ok := yl.Index(yi)
-				if !ok {
-					continue
-				}
+			for _, yelem := range byHash[sift.Hash(xelem)] {
 				if sift.Equal(xelem, yelem) {
 					continue Outer
 				}
@@ -290,46 +334,109 @@ func sub(x, y sift.Value) (sift.Value, error) {
 	}
 }
 
-func numOp(op func(xn, yn float64) float64) func(x, y sift.Filter) sift.Filter {
-	return func(x, y sift.Filter) sift.Filter {
-		return sift.Binary(x, y, func(xv, yv sift.Value) ([]sift.Value, error) {
-			xn, ok := sift.AsFloat64(xv)
-			if !ok {
-				return nil, fmt.Errorf("cannot use numeric operator on value %v", xv)
-			}
-			yn, ok := sift.AsFloat64(yv)
-			if !ok {
-				return nil, fmt.Errorf("cannot use numeric operator on value %v", yv)
-			}
-			v := sift.Must(sift.ToValue(op(xn, yn)))
-			return []sift.Value{v}, nil
-		})
+func mul(x, y sift.Value) (sift.Value, error) {
+	if xn, ok := sift.AsFloat64(x); ok {
+		if ys, ok := sift.AsString(y); ok {
+			return repeat(ys, xn)
+		}
+		yn, ok := sift.AsFloat64(y)
+		if !ok {
+			return nil, fmt.Errorf("cannot use numeric operator on value %v", y)
+		}
+		return sift.Must(sift.ToValue(xn * yn)), nil
+	} else if xs, ok := sift.AsString(x); ok {
+		yn, ok := sift.AsFloat64(y)
+		if !ok {
+			return nil, fmt.Errorf("cannot use numeric operator on values %v and %v", x, y)
+		}
+		return repeat(xs, yn)
+	} else if _, ok := x.(sift.Attr); ok {
+		if _, ok := y.(sift.Attr); !ok {
+			return nil, fmt.Errorf("cannot merge object with value %v", y)
+		}
+		return sift.Merge(x, y, sift.MergeRecurse)
+	} else {
+		return nil, fmt.Errorf("cannot use numeric operator on values %v and %v", x, y)
 	}
 }
 
-func walk(v sift.Value) ([]sift.Value, error) {
-	var outs []sift.Value
-	var visit func(v sift.Value)
-	visit = func(v sift.Value) {
-		outs = append(outs, v)
-		if attr, ok := v.(sift.Attr); ok {
-			for _, key := range attr.Keys() {
-				value, ok := attr.Attr(key)
-				if ok {
-					visit(value)
-				}
-			}
+// maxRepeatLength is the length in bytes of the longest string repeat
+// may produce.
+const maxRepeatLength = 1 << 28
+
+// repeat returns a string containing n copies of s. Fractional counts are
+// rounded up. If n is not positive, null is returned. An error is returned
+// if the result would be longer than maxRepeatLength.
+func repeat(s string, n float64) (sift.Value, error) {
+	if !(n > 0) {
+		return sift.NullValue, nil
+	}
+	count := math.Ceil(n)
+	if float64(len(s))*count > maxRepeatLength {
+		return nil, fmt.Errorf("cannot repeat string %g times: result would be longer than %d bytes", count, maxRepeatLength)
+	}
+	if len(s) == 0 {
+		return sift.Must(sift.ToValue("")), nil
+	}
+	return sift.Must(sift.ToValue(strings.Repeat(s, int(count)))), nil
+}
+
+func div(x, y sift.Value) (sift.Value, error) {
+	if xn, ok := sift.AsFloat64(x); ok {
+		yn, ok := sift.AsFloat64(y)
+		if !ok {
+			return nil, fmt.Errorf("cannot use numeric operator on value %v", y)
 		}
-		if index, ok := v.(sift.Index); ok {
-			n := index.Length()
-			for i := 0; i < n; i++ {
-				value, ok := index.Index(i)
-				if ok {
-					visit(value)
-				}
-			}
+		return sift.Must(sift.ToValue(xn / yn)), nil
+	} else if xs, ok := sift.AsString(x); ok {
+		ys, ok := sift.AsString(y)
+		if !ok {
+			return nil, fmt.Errorf("cannot split string with value %v", y)
+		}
+		return splitString(xs, ys), nil
+	} else {
+		return nil, fmt.Errorf("cannot use numeric operator on values %v and %v", x, y)
+	}
+}
+
+// splitString returns an array of the substrings of s separated by sep.
+// An empty string is split into an empty array.
+func splitString(s, sep string) sift.Value {
+	if s == "" {
+		return sift.Must(sift.ToValue([]sift.Value{}))
+	}
+	parts := strings.Split(s, sep)
+	elems := make([]sift.Value, len(parts))
+	for i, p := range parts {
+		elems[i] = sift.Must(sift.ToValue(p))
+	}
+	return sift.Must(sift.ToValue(elems))
+}
+
+// copyAttrs copies the attributes of a into m.
+func copyAttrs(m map[string]sift.Value, a sift.Attr) error {
+	for _, key := range a.Keys() {
+		keyStr, ok := sift.AsString(key)
+		if !ok {
+			return fmt.Errorf("object has non-string key %v", key)
+		}
+		if value, ok := a.Attr(key); ok {
+			m[keyStr] = value
+		}
+	}
+	return nil
+}
+
+func numOp(op func(xn, yn float64) float64) func(x, y sift.Value) (sift.Value, error) {
+	return func(x, y sift.Value) (sift.Value, error) {
+		xn, ok := sift.AsFloat64(x)
+		if !ok {
+			return nil, fmt.Errorf("cannot use numeric operator on value %v", x)
+		}
+		yn, ok := sift.AsFloat64(y)
+		if !ok {
+			return nil, fmt.Errorf("cannot use numeric operator on value %v", y)
 		}
+		return sift.Must(sift.ToValue(op(xn, yn))), nil
 	}
-	visit(v)
-	return outs, nil
 }
@@ -0,0 +1,351 @@
+package jq
+
+import (
+	"fmt"
+	"strings"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+)
+
+// Format returns jq source text for the syntax tree rooted at n. The text
+// parses to an equivalent tree, though layout and redundant parentheses
+// written in the original program are not preserved. Parentheses are added
+// where needed, so trees built or rewritten by tools may be formatted, too.
+func Format(n Node) string {
+	b := &strings.Builder{}
+	f := formatter{b}
+	switch n := n.(type) {
+	case *File:
+		f.file(n)
+	case *FuncDef:
+		f.funcDef(n)
+	case Expr:
+		f.expr(n, precLowest)
+	case Pattern:
+		f.pattern(n)
+	default:
+		panic(fmt.Sprintf("cannot format node of type %T", n))
+	}
+	return b.String()
+}
+
+// Precedence levels used when formatting expressions. An expression is
+// parenthesized when it binds less tightly than its context requires.
+// Binary operator levels are between precLowest and precPostfix.
+const (
+	precLowest  = -1 // bindings and definitions, which extend to the end
+	precPostfix = 6  // postfix expressions like x[i] and x.a
+	precPrimary = 7  // everything else
+)
+
+type formatter struct {
+	b *strings.Builder
+}
+
+func (f formatter) print(args ...interface{}) {
+	for _, arg := range args {
+		fmt.Fprint(f.b, arg)
+	}
+}
+
+func (f formatter) file(n *File) {
+	sep := ""
+	for _, imp := range n.Imports {
+		f.print(sep)
+		if imp.Name == "" {
+			f.print("include ", quote(imp.Path), ";")
+		} else {
+			f.print("import ", quote(imp.Path), " as ", imp.Name, ";")
+		}
+		sep = " "
+	}
+	for _, def := range n.Defs {
+		f.print(sep)
+		f.funcDef(def)
+		sep = " "
+	}
+	if n.Body != nil {
+		f.print(sep)
+		f.expr(n.Body, precLowest)
+	}
+}
+
+func (f formatter) funcDef(n *FuncDef) {
+	f.print("def ", n.Name)
+	if len(n.Params) > 0 {
+		f.print("(")
+		for i, prm := range n.Params {
+			if i > 0 {
+				f.print("; ")
+			}
+			if prm.Variable {
+				f.print("$")
+			}
+			f.print(prm.Name)
+		}
+		f.print(")")
+	}
+	f.print(": ")
+	f.expr(n.Body, precLowest)
+	f.print(";")
+}
+
+// precedence returns how tightly x binds.
+func precedence(x Expr) int {
+	switch x := x.(type) {
+	case *As, *FuncDefExpr:
+		return precLowest
+	case *Try:
+		if x.TryPos.IsValid() {
+			// The handler is a postfix expression, so parenthesize try
+			// everywhere but at the top of a pipeline.
+			return precLowest
+		}
+		return precPostfix
+	case *Binary:
+		return x.Op.precedence()
+	case *Neg, *Field, *Index, *Slice, *Iterate:
+		return precPostfix
+	default:
+		return precPrimary
+	}
+}
+
+// expr formats x, adding parentheses if x binds less tightly than prec.
+func (f formatter) expr(x Expr, prec int) {
+	if precedence(x) < prec {
+		f.print("(")
+		defer f.print(")")
+	}
+
+	switch x := x.(type) {
+	case *Identity:
+		f.print(".")
+	case *Recurse:
+		f.print("..")
+	case *Literal:
+		f.print(literalText(x.Value))
+	case *Var:
+		f.print("$", x.Name)
+	case *Neg:
+		f.print("-")
+		f.expr(x.X, precPrimary)
+	case *Binary:
+		p := x.Op.precedence()
+		lp, rp := p, p+1 // left-associative
+		if x.Op == OpPipe {
+			lp, rp = p+1, p // right-associative
+		}
+		f.expr(x.X, lp)
+		if x.Op == OpComma {
+			f.print(", ")
+		} else {
+			f.print(" ", x.Op, " ")
+		}
+		f.expr(x.Y, rp)
+	case *Paren:
+		f.print("(")
+		f.expr(x.X, precLowest)
+		f.print(")")
+	case *Field:
+		// The field's own dot suffices when it applies to the input.
+		// Literals are parenthesized so a number doesn't absorb the dot.
+		switch base := x.X.(type) {
+		case nil, *Identity:
+		case *Literal:
+			f.print("(")
+			f.expr(base, precLowest)
+			f.print(")")
+		default:
+			f.expr(base, precPostfix)
+		}
+		f.print(".", fieldName(x.Name))
+		if x.Optional {
+			f.print("?")
+		}
+	case *Index:
+		f.postfixBase(x.X)
+		f.print("[")
+		f.expr(x.Index, precLowest)
+		f.print("]")
+	case *Slice:
+		f.postfixBase(x.X)
+		f.print("[")
+		if x.Begin != nil {
+			f.expr(x.Begin, precLowest)
+		}
+		f.print(":")
+		if x.End != nil {
+			f.expr(x.End, precLowest)
+		}
+		f.print("]")
+	case *Iterate:
+		f.postfixBase(x.X)
+		f.print("[]")
+		if x.Optional {
+			f.print("?")
+		}
+	case *Try:
+		if x.TryPos.IsValid() {
+			f.print("try ")
+			f.expr(x.X, precPostfix)
+			if x.Catch != nil {
+				f.print(" catch ")
+				f.expr(x.Catch, precPostfix)
+			}
+			return
+		}
+		// A ? directly after a field or iteration would make it optional,
+		// which has different semantics, so parenthesize those.
+		switch x.X.(type) {
+		case *Field, *Iterate:
+			f.print("(")
+			f.expr(x.X, precLowest)
+			f.print(")")
+		default:
+			f.expr(x.X, precPostfix)
+		}
+		f.print("?")
+	case *Array:
+		f.print("[")
+		for i, elem := range x.Elems {
+			if i > 0 {
+				f.print(", ")
+			}
+			f.expr(elem, precLowest)
+		}
+		f.print("]")
+	case *Object:
+		f.print("{")
+		for i, entry := range x.Entries {
+			if i > 0 {
+				f.print(", ")
+			}
+			f.objectKey(entry.Key)
+			f.print(": ")
+			// Object values may not contain commas or pipes at the top level
+			// without parentheses.
+			f.expr(entry.Value, OpOr.precedence())
+		}
+		f.print("}")
+	case *Call:
+		f.print(x.Name)
+		if len(x.Args) > 0 {
+			f.print("(")
+			for i, arg := range x.Args {
+				if i > 0 {
+					f.print("; ")
+				}
+				f.expr(arg, precLowest)
+			}
+			f.print(")")
+		}
+	case *As:
+		f.expr(x.X, precPostfix)
+		f.print(" as ")
+		for i, pat := range x.Patterns {
+			if i > 0 {
+				f.print(" ?// ")
+			}
+			f.pattern(pat)
+		}
+		f.print(" | ")
+		f.expr(x.Body, precLowest)
+	case *FuncDefExpr:
+		f.funcDef(x.Def)
+		f.print(" ")
+		f.expr(x.Rest, precLowest)
+	default:
+		panic(fmt.Sprintf("unexpected expression of type %T", x))
+	}
+}
+
+// postfixBase formats the operand of a postfix expression. A nil operand
+// or the identity is written as a single dot.
+func (f formatter) postfixBase(x Expr) {
+	if x == nil {
+		f.print(".")
+		return
+	}
+	if _, ok := x.(*Identity); ok {
+		f.print(".")
+		return
+	}
+	f.expr(x, precPostfix)
+}
+
+func (f formatter) objectKey(key Expr) {
+	if lit, ok := key.(*Literal); ok {
+		if s, ok := sift.AsString(lit.Value); ok {
+			if isIdentifier(s) {
+				f.print(s)
+			} else {
+				f.print(quote(s))
+			}
+			return
+		}
+	}
+	if _, ok := key.(*Paren); ok {
+		f.expr(key, precLowest)
+		return
+	}
+	f.print("(")
+	f.expr(key, precLowest)
+	f.print(")")
+}
+
+func (f formatter) pattern(pat Pattern) {
+	switch pat := pat.(type) {
+	case *VarPattern:
+		f.print("$", pat.Name)
+	case *ArrayPattern:
+		f.print("[")
+		for i, elem := range pat.Elems {
+			if i > 0 {
+				f.print(", ")
+			}
+			f.pattern(elem)
+		}
+		f.print("]")
+	case *ObjectPattern:
+		f.print("{")
+		for i, entry := range pat.Entries {
+			if i > 0 {
+				f.print(", ")
+			}
+			if entry.Var != nil {
+				f.pattern(entry.Var)
+			} else {
+				f.objectKey(entry.Key)
+			}
+			if entry.Value != nil {
+				f.print(": ")
+				f.pattern(entry.Value)
+			}
+		}
+		f.print("}")
+	default:
+		panic(fmt.Sprintf("unexpected pattern of type %T", pat))
+	}
+}
+
+func fieldName(name string) string {
+	if isIdentifier(name) {
+		return name
+	}
+	return quote(name)
+}
+
+func quote(s string) string {
+	return literalText(sift.Must(sift.ToValue(s)))
+}
+
+// literalText returns v formatted as a JSON literal.
+func literalText(v sift.Value) string {
+	b := &strings.Builder{}
+	if err := json.NewEncoder(b).Encode(v); err != nil {
+		panic(err)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
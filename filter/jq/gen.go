@@ -0,0 +1,263 @@
+package jq
+
+import (
+	"fmt"
+	"io"
+
+	"go.jayconrod.com/sift"
+)
+
+// A generator produces values one at a time. ok is false when there are no
+// more values. Generators let the machine evaluate expressions like .[] and
+// range lazily, so that first and limit can stop them early.
+type generator func() (v sift.Value, ok bool, err error)
+
+// A genFilter is like a sift.Filter, but it produces its outputs lazily.
+type genFilter func(sift.Value) generator
+
+func noValues() (sift.Value, bool, error) {
+	return nil, false, nil
+}
+
+func errorGen(err error) generator {
+	return func() (sift.Value, bool, error) {
+		return nil, false, err
+	}
+}
+
+func valuesGen(vs ...sift.Value) generator {
+	return func() (sift.Value, bool, error) {
+		if len(vs) == 0 {
+			return nil, false, nil
+		}
+		v := vs[0]
+		vs = vs[1:]
+		return v, true, nil
+	}
+}
+
+// flatMap returns a generator that produces the values of f(v) for each
+// value v produced by g.
+func flatMap(g generator, f func(sift.Value) generator) generator {
+	var inner generator = noValues
+	return func() (sift.Value, bool, error) {
+		for {
+			v, ok, err := inner()
+			if err != nil || ok {
+				return v, ok, err
+			}
+			outer, ok, err := g()
+			if err != nil || !ok {
+				return nil, false, err
+			}
+			inner = f(outer)
+		}
+	}
+}
+
+// collect returns a filter that produces all the values of f.
+func collect(f genFilter) sift.Filter {
+	return func(v sift.Value) ([]sift.Value, error) {
+		g := f(v)
+		var outs []sift.Value
+		for {
+			out, ok, err := g()
+			if err != nil {
+				return nil, err
+			} else if !ok {
+				return outs, nil
+			}
+			outs = append(outs, out)
+		}
+	}
+}
+
+// iterateGen produces the elements of an array. Arrays that implement
+// sift.Iterator are read one element at a time.
+func iterateGen(v sift.Value) generator {
+	elems, ok := sift.Iterate(v)
+	if !ok {
+		return errorGen(fmt.Errorf("cannot iterate over %s", describe(v)))
+	}
+	return func() (sift.Value, bool, error) {
+		elem, err := elems.Decode()
+		if err == io.EOF {
+			return nil, false, nil
+		} else if err != nil {
+			return nil, false, err
+		}
+		return elem, true, nil
+	}
+}
+
+// iterateOptGen is like iterateGen, but it produces no values instead of an
+// error for values that aren't arrays.
+func iterateOptGen(v sift.Value) generator {
+	if !isArray(v) {
+		return noValues
+	}
+	return iterateGen(v)
+}
+
+// recurseGen produces v and all the values nested in it, in pre-order.
+func recurseGen(v sift.Value) generator {
+	stack := []sift.Value{v}
+	return func() (sift.Value, bool, error) {
+		if len(stack) == 0 {
+			return nil, false, nil
+		}
+		n := len(stack) - 1
+		v := stack[n]
+		stack = stack[:n]
+		// Push children in reverse, so the first child is visited next.
+		if index, ok := v.(sift.Index); ok {
+			for i := index.Length() - 1; i >= 0; i-- {
+				if value, ok := index.Index(i); ok {
+					stack = append(stack, value)
+				}
+			}
+		}
+		if attr, ok := v.(sift.Attr); ok {
+			keys := attr.Keys()
+			for i := len(keys) - 1; i >= 0; i-- {
+				if value, ok := attr.Attr(keys[i]); ok {
+					stack = append(stack, value)
+				}
+			}
+		}
+		return v, true, nil
+	}
+}
+
+func init() {
+	for _, b := range []builtin{
+		{name: "range", arity: 1, gen: rangeBuiltin},
+		{name: "range", arity: 2, gen: rangeBuiltin},
+		{name: "first", arity: 1, gen: first},
+		{name: "limit", arity: 2, gen: limitBuiltin},
+		{name: "isempty", arity: 1, gen: isEmpty},
+		{name: "any", gen: anyAll(true, 0)},
+		{name: "any", arity: 1, gen: anyAll(true, 1)},
+		{name: "any", arity: 2, gen: anyAll(true, 2)},
+		{name: "all", gen: anyAll(false, 0)},
+		{name: "all", arity: 1, gen: anyAll(false, 1)},
+		{name: "all", arity: 2, gen: anyAll(false, 2)},
+	} {
+		builtins[b.key()] = b
+	}
+}
+
+// rangeBuiltin implements range(upto) and range(from; upto), which produce
+// the numbers from from (or 0) up to but not including upto, lazily.
+func rangeBuiltin(_ *CompileOptions, args []genFilter) genFilter {
+	return func(v sift.Value) generator {
+		if len(args) == 1 {
+			return flatMap(args[0](v), func(upto sift.Value) generator {
+				return rangeGen(sift.Must(sift.ToValue(0)), upto)
+			})
+		}
+		return flatMap(args[0](v), func(from sift.Value) generator {
+			return flatMap(args[1](v), func(upto sift.Value) generator {
+				return rangeGen(from, upto)
+			})
+		})
+	}
+}
+
+func rangeGen(from, upto sift.Value) generator {
+	n, ok := sift.AsFloat64(from)
+	if !ok {
+		return errorGen(fmt.Errorf("range: value %v is not a number", from))
+	}
+	end, ok := sift.AsFloat64(upto)
+	if !ok {
+		return errorGen(fmt.Errorf("range: value %v is not a number", upto))
+	}
+	return func() (sift.Value, bool, error) {
+		if !(n < end) {
+			return nil, false, nil
+		}
+		v := sift.Must(sift.ToValue(n))
+		n++
+		return v, true, nil
+	}
+}
+
+// first implements first(f), which produces the first output of f, if
+// any, without evaluating f further.
+func first(_ *CompileOptions, args []genFilter) genFilter {
+	return func(v sift.Value) generator {
+		out, ok, err := args[0](v)()
+		if err != nil {
+			return errorGen(err)
+		} else if !ok {
+			return noValues
+		}
+		return valuesGen(out)
+	}
+}
+
+// limitBuiltin implements limit(n; f), which produces at most n outputs of
+// f.
+func limitBuiltin(_ *CompileOptions, args []genFilter) genFilter {
+	return func(v sift.Value) generator {
+		return flatMap(args[0](v), func(nv sift.Value) generator {
+			n, ok := sift.AsFloat64(nv)
+			if !ok {
+				return errorGen(fmt.Errorf("limit: value %v is not a number", nv))
+			}
+			g := args[1](v)
+			return func() (sift.Value, bool, error) {
+				if !(n > 0) {
+					return nil, false, nil
+				}
+				n--
+				return g()
+			}
+		})
+	}
+}
+
+// isEmpty implements isempty(f), which produces true if f has no outputs.
+// Only f's first output is computed.
+func isEmpty(_ *CompileOptions, args []genFilter) genFilter {
+	return func(v sift.Value) generator {
+		_, ok, err := args[0](v)()
+		if err != nil {
+			return errorGen(err)
+		}
+		return valuesGen(sift.Must(sift.ToValue(!ok)))
+	}
+}
+
+// anyAll returns the implementation of any or all with the given arity.
+// any/0 and all/0 test the elements of an array, any(cond) and all(cond)
+// apply cond to the elements, and any(gen; cond) and all(gen; cond) apply
+// cond to the outputs of gen. Evaluation stops as soon as the result is
+// known: for any, when a condition is true, and for all, when one is false.
+func anyAll(isAny bool, arity int) func(*CompileOptions, []genFilter) genFilter {
+	return func(_ *CompileOptions, args []genFilter) genFilter {
+		var gen, cond genFilter = iterateGen, func(v sift.Value) generator { return valuesGen(v) }
+		switch arity {
+		case 1:
+			cond = args[0]
+		case 2:
+			gen, cond = args[0], args[1]
+		}
+		return func(v sift.Value) generator {
+			g := flatMap(gen(v), cond)
+			for {
+				c, ok, err := g()
+				if err != nil {
+					return errorGen(err)
+				} else if !ok {
+					break
+				}
+				if sift.Truthy(c) == isAny {
+					return valuesGen(sift.Must(sift.ToValue(isAny)))
+				}
+			}
+			return valuesGen(sift.Must(sift.ToValue(!isAny)))
+		}
+	}
+}
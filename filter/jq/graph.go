@@ -0,0 +1,186 @@
+package jq
+
+import (
+	"fmt"
+	"strings"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+)
+
+// A graph is built from an array of nodes, usually objects. Each node is
+// identified by its id attribute. Edges are found by applying a filter
+// to each node, which produces the ids of the nodes it points to.
+type graph struct {
+	nodes []sift.Value
+	keys  []string
+	byKey map[string]int
+	edges [][]int
+}
+
+func newGraph(v sift.Value, edges sift.Filter) (*graph, error) {
+	nodes, err := iterate(v)
+	if err != nil {
+		return nil, err
+	}
+	g := &graph{
+		nodes: nodes,
+		keys:  make([]string, len(nodes)),
+		byKey: make(map[string]int),
+		edges: make([][]int, len(nodes)),
+	}
+	for i, node := range nodes {
+		id, ok := sift.GetStringAttr(node, "id")
+		if !ok || sift.IsNull(id) {
+			return nil, fmt.Errorf("node %s does not have an id", describe(node))
+		}
+		key, err := valueKey(id)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := g.byKey[key]; ok {
+			return nil, fmt.Errorf("duplicate node id %s", key)
+		}
+		g.keys[i] = key
+		g.byKey[key] = i
+	}
+	for i, node := range nodes {
+		targets, err := edges(node)
+		if err != nil {
+			return nil, err
+		}
+		for _, target := range targets {
+			j, ok, err := g.lookup(target)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				g.edges[i] = append(g.edges[i], j)
+			}
+		}
+	}
+	return g, nil
+}
+
+// lookup returns the index of the node with the given id. Ids that don't
+// identify any node (including null, as in a root's parent) are ignored.
+func (g *graph) lookup(id sift.Value) (int, bool, error) {
+	if sift.IsNull(id) {
+		return 0, false, nil
+	}
+	key, err := valueKey(id)
+	if err != nil {
+		return 0, false, err
+	}
+	i, ok := g.byKey[key]
+	return i, ok, nil
+}
+
+// valueKey returns a string that identifies v, suitable for use as a map key.
+func valueKey(v sift.Value) (string, error) {
+	b := &strings.Builder{}
+	if err := json.NewEncoder(b).Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// reachable produces an array of the nodes in the input array reachable
+// from the nodes with ids produced by its first argument, following edges
+// produced by its second argument. Nodes are listed in breadth-first order,
+// starting with the initial nodes.
+func reachable(_ *CompileOptions, args []sift.Filter) sift.Filter {
+	from, edges := args[0], args[1]
+	return func(v sift.Value) ([]sift.Value, error) {
+		g, err := newGraph(v, edges)
+		if err != nil {
+			return nil, fmt.Errorf("reachable: %v", err)
+		}
+		starts, err := from(v)
+		if err != nil {
+			return nil, err
+		}
+		seen := make([]bool, len(g.nodes))
+		var queue []int
+		for _, start := range starts {
+			i, ok, err := g.lookup(start)
+			if err != nil {
+				return nil, err
+			}
+			if ok && !seen[i] {
+				seen[i] = true
+				queue = append(queue, i)
+			}
+		}
+		var outs []sift.Value
+		for len(queue) > 0 {
+			i := queue[0]
+			queue = queue[1:]
+			outs = append(outs, g.nodes[i])
+			for _, j := range g.edges[i] {
+				if !seen[j] {
+					seen[j] = true
+					queue = append(queue, j)
+				}
+			}
+		}
+		return []sift.Value{sift.Must(sift.ToValue(outs))}, nil
+	}
+}
+
+// topoSort produces an array of the nodes in the input array, sorted so that
+// each node appears after the nodes with ids produced by its argument (its
+// dependencies, or its parent). Nodes that may appear in any order keep their
+// relative order from the input. An error is reported if there is a cycle.
+func topoSort(_ *CompileOptions, args []sift.Filter) sift.Filter {
+	edges := args[0]
+	return func(v sift.Value) ([]sift.Value, error) {
+		g, err := newGraph(v, edges)
+		if err != nil {
+			return nil, fmt.Errorf("topo_sort: %v", err)
+		}
+
+		const (
+			unvisited = iota
+			visiting
+			visited
+		)
+		state := make([]int, len(g.nodes))
+		outs := make([]sift.Value, 0, len(g.nodes))
+		var stack []int
+		var visit func(i int) error
+		visit = func(i int) error {
+			switch state[i] {
+			case visited:
+				return nil
+			case visiting:
+				cycle := []string{g.keys[i]}
+				for k := len(stack) - 1; stack[k] != i; k-- {
+					cycle = append(cycle, g.keys[stack[k]])
+				}
+				cycle = append(cycle, g.keys[i])
+				for l, r := 0, len(cycle)-1; l < r; l, r = l+1, r-1 {
+					cycle[l], cycle[r] = cycle[r], cycle[l]
+				}
+				return fmt.Errorf("topo_sort: cycle: %s", strings.Join(cycle, " -> "))
+			}
+			state[i] = visiting
+			stack = append(stack, i)
+			for _, j := range g.edges[i] {
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+			stack = stack[:len(stack)-1]
+			state[i] = visited
+			outs = append(outs, g.nodes[i])
+			return nil
+		}
+		for i := range g.nodes {
+			if err := visit(i); err != nil {
+				return nil, err
+			}
+		}
+		return []sift.Value{sift.Must(sift.ToValue(outs))}, nil
+	}
+}
@@ -0,0 +1,144 @@
+package jq
+
+import (
+	"context"
+	"fmt"
+	gotoken "go/token"
+
+	"go.jayconrod.com/sift"
+)
+
+// Host provides functions and data to a program each time it is evaluated.
+// Unlike CompileOptions, a Host may differ between evaluations of the same
+// compiled program, so a server can compile a program once and evaluate it
+// with data and callbacks specific to each request.
+type Host struct {
+	// Context is passed to host functions. When Context is done, evaluation
+	// stops with an error wrapping the context's error, which try and ?
+	// can't catch. If Context is nil, context.Background is used.
+	Context context.Context
+
+	// Data is produced by the host_data builtin. If Data is nil,
+	// host_data produces null.
+	Data sift.Value
+
+	// Funcs maps the names of host functions declared in
+	// CompileOptions.HostFuncs to their implementations.
+	Funcs map[string]HostFunc
+}
+
+// A HostFunc is a function implemented by the host application that
+// a program may call. input is the value the function is called with,
+// and args holds one value for each argument. When arguments produce
+// multiple values, the function is called once for each combination.
+type HostFunc func(ctx context.Context, input sift.Value, args []sift.Value) ([]sift.Value, error)
+
+// A Plugin provides functions that programs may call, like
+// CompileOptions.Funcs, for applications that load builtins from
+// independently versioned packages.
+type Plugin struct {
+	// Requirements describes the interface version and capabilities the
+	// plugin needs. They're checked with sift.Negotiate before Funcs is
+	// called.
+	Requirements sift.PluginRequirements
+
+	// Funcs returns the plugin's functions, keyed by name and arity like
+	// CompileOptions.Funcs. version is the interface version returned by
+	// sift.Negotiate.
+	Funcs func(version int) map[string]HostFunc
+}
+
+// A Program is a compiled jq program that may be evaluated with
+// a different Host each time.
+type Program struct {
+	main *code
+	vars []sift.Value
+	opts *CompileOptions
+}
+
+// CompileProgram parses a jq program. Unlike CompileWithOptions, it returns
+// a Program, which may be evaluated with a Host.
+func CompileProgram(name, src string, opts CompileOptions) (*Program, error) {
+	fset := gotoken.NewFileSet()
+	f, err := Parse(fset, name, src)
+	if err != nil {
+		return nil, err
+	}
+	return CompileFile(fset, f, opts)
+}
+
+// Filter returns a filter that evaluates the program with the functions and
+// data provided by h. h may be nil if the program does not call host
+// functions.
+func (p *Program) Filter(h *Host) sift.Filter {
+	return sift.Collecting(p.Emit(h))
+}
+
+// Emit is like Filter, but the returned filter emits each value as soon as
+// the program produces it, so a program that generates many values, like
+// range(1e9), doesn't need to hold them all in memory.
+func (p *Program) Emit(h *Host) sift.EmitFilter {
+	return func(v sift.Value, emit func(sift.Value) error) error {
+		run := &runState{opts: p.opts, host: h}
+		if p.opts.MaxDepth > 0 || p.opts.Timeout > 0 || h != nil && h.Context != nil {
+			// Each evaluation has its own deadline.
+			run.limits = newLimits(p.opts, h)
+		}
+		fr := newFrame(p.main, nil, nil, 0)
+		copy(fr.locals, p.vars)
+		m := newMachine(run, p.main, fr, 0, v)
+		max := p.opts.MaxOutputs
+		for n := 0; ; n++ {
+			out, ok, err := m.next()
+			if err != nil {
+				return err
+			} else if !ok {
+				return nil
+			}
+			if max > 0 && n == max {
+				return fmt.Errorf("%w: program produced more than %d values for one input", ErrTooManyOutputs, max)
+			}
+			if err := emit(out); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// callHost returns a filter that calls the host function named by key with
+// the given arguments.
+func callHost(run *runState, key string, args []sift.Filter) sift.Filter {
+	var fn HostFunc
+	if run.host != nil {
+		fn = run.host.Funcs[key]
+	}
+	if fn == nil {
+		return func(sift.Value) ([]sift.Value, error) {
+			return nil, fmt.Errorf("host function %s was not provided", key)
+		}
+	}
+	return callFunc(run, fn, args)
+}
+
+// callFunc returns a filter that calls fn with the given arguments, once for
+// each combination of their values. fn receives the Host's context, if
+// there is one.
+func callFunc(run *runState, fn HostFunc, args []sift.Filter) sift.Filter {
+	ctx := context.Background()
+	if h := run.host; h != nil && h.Context != nil {
+		ctx = h.Context
+	}
+	operands := append([]sift.Filter{id}, args...)
+	return sift.Nary(operands, func(vs []sift.Value) ([]sift.Value, error) {
+		return fn(ctx, vs[0], vs[1:])
+	})
+}
+
+// hostData returns a filter that produces the data provided by the host.
+func hostData(run *runState, _ []sift.Filter) sift.Filter {
+	var data sift.Value = sift.NullValue
+	if h := run.host; h != nil && h.Data != nil {
+		data = h.Data
+	}
+	return sift.Literal(data)
+}
@@ -1,26 +1,267 @@
 package jq
 
 import (
+	"fmt"
 	gotoken "go/token"
+	"io"
+	"strings"
+	"time"
 
 	"go.jayconrod.com/sift"
 )
 
+// CompileOptions controls how a jq program is compiled.
+type CompileOptions struct {
+	// SearchPath is a list of directories searched for modules named in
+	// import and include directives. Directives in a module search the
+	// directory containing the module before directories in this list.
+	SearchPath []string
+
+	// Now returns the current time for the now builtin. If Now is nil,
+	// time.Now is used. Setting Now to a function that returns a fixed time
+	// makes programs that use now reproducible.
+	Now func() time.Time
+
+	// NullSafe makes indexing, slicing, and iteration produce null or
+	// nothing instead of reporting errors for values of the wrong type.
+	// For example, .[0] produces null when its input is an object, and
+	// .[] produces nothing when its input is a number. This gives paths
+	// the same lenient behavior as the dig builtin.
+	NullSafe bool
+
+	// HostFuncs lists functions the host application provides when the
+	// program is evaluated, by name and arity, like "lookup_user/1".
+	// Programs may call these functions like builtins. Implementations are
+	// provided with a Host passed to Program.Filter; see CompileProgram.
+	HostFuncs []string
+
+	// Funcs maps functions implemented by the application, by name and
+	// arity like "geoip/1", to their implementations. Programs may call
+	// these functions like builtins; they take precedence over builtins
+	// with the same name and arity. Unlike HostFuncs, implementations are
+	// bound at compile time, so they're the same each time the program is
+	// evaluated. Like host functions, they're assumed to interact with the
+	// outside world, so ProfileNoIO and ProfilePure reject programs that
+	// call them. The context passed to each function is the Host's context
+	// if there is one, or context.Background otherwise.
+	Funcs map[string]HostFunc
+
+	// Plugins provide functions like Funcs, after checking that this
+	// version of sift provides what they require. Programs fail to compile
+	// if a plugin is incompatible or if two plugins provide the same
+	// function. Functions in Funcs take precedence over functions provided
+	// by plugins.
+	Plugins []Plugin
+
+	// Vars binds variables that the program may reference. For example,
+	// a program may refer to Vars["limit"] as $limit. This lets callers
+	// parameterize programs without building source text. Vars are visible
+	// in the main program but not in imported modules.
+	Vars map[string]sift.Value
+
+	// Profile restricts the language features a program may use. Programs
+	// that use features the profile does not allow are rejected at compile
+	// time. The zero value, ProfileFull, allows everything.
+	Profile Profile
+
+	// Input is the source of values for the input and inputs builtins.
+	// Typically, this is the same Decoder that provides values to the
+	// program's filter, so input reads the value after the current one.
+	// If Input is nil, input and inputs report errors.
+	Input sift.Decoder
+
+	// DisableEnv rejects programs that read environment variables with
+	// the env builtin.
+	DisableEnv bool
+
+	// DisableInput rejects programs that read additional inputs with the
+	// input and inputs builtins.
+	DisableInput bool
+
+	// MaxDepth is the maximum number of nested function calls. Programs
+	// that recurse deeper fail with ErrRecursionDepth. Zero means there
+	// is no limit. Calls in tail position, where a function's outputs are
+	// the outputs of the call, replace the calling function and don't
+	// count toward the depth, so tail-recursive functions may loop
+	// indefinitely in constant space.
+	MaxDepth int
+
+	// MaxOutputs is the maximum number of values the program may produce
+	// for one input. Programs that produce more fail with
+	// ErrTooManyOutputs. Zero means there is no limit.
+	MaxOutputs int
+
+	// Timeout is the maximum amount of time the program may take to
+	// evaluate one input. Programs that take longer fail with ErrTimeout.
+	// The deadline is checked when functions are called, which is
+	// sufficient to stop unbounded recursion. Zero means there
+	// is no limit.
+	Timeout time.Duration
+
+	// Trace is called before each expression in the program is evaluated,
+	// including expressions in imported modules. It may inspect the
+	// expression, its input, and the variables in scope; debuggers use it
+	// to pause evaluation. If Trace returns an error, evaluation stops,
+	// and the program returns the error; try, ?, and ?// do not catch it.
+	// Programs compiled with Trace are slower and must not be evaluated
+	// concurrently.
+	Trace func(*Step) error
+
+	// Coverage, if set, records which expressions are evaluated. See
+	// NewCoverage.
+	Coverage *Coverage
+
+	// Debug receives the output of the debug builtin, which describes its
+	// input with sift.Format. If Debug is nil, os.Stderr is used.
+	Debug io.Writer
+}
+
+// A Profile is a subset of the language a program may be restricted to.
+// Profiles let services accept programs from untrusted users without
+// allowing them to interact with the outside world.
+type Profile int
+
+const (
+	// ProfileFull allows all builtins and host functions.
+	ProfileFull Profile = iota
+
+	// ProfileNoIO rejects builtins and host functions that interact with
+	// anything other than the program's input, like reading the
+	// environment or calling back into the host application.
+	ProfileNoIO
+
+	// ProfilePure rejects everything ProfileNoIO rejects and also builtins
+	// that may produce different results for the same input, like now.
+	// Programs restricted to this profile are deterministic.
+	ProfilePure
+)
+
+func (p Profile) String() string {
+	switch p {
+	case ProfileFull:
+		return "full"
+	case ProfileNoIO:
+		return "no-io"
+	case ProfilePure:
+		return "pure"
+	default:
+		return fmt.Sprintf("Profile(%d)", int(p))
+	}
+}
+
+// ParseProfile returns the profile with the given name, as returned by
+// Profile.String.
+func ParseProfile(name string) (Profile, error) {
+	for p := ProfileFull; p <= ProfilePure; p++ {
+		if p.String() == name {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown profile %q", name)
+}
+
+// effects is a set of things a function may do besides computing its
+// outputs from its input and arguments.
+type effects int
+
+const (
+	// effectIO indicates a function interacts with the world outside
+	// the program.
+	effectIO effects = 1 << iota
+
+	// effectNondeterministic indicates a function may produce different
+	// outputs for the same input and arguments.
+	effectNondeterministic
+)
+
+// allows returns whether a profile allows functions with effects e.
+func (p Profile) allows(e effects) bool {
+	switch p {
+	case ProfileNoIO:
+		return e&effectIO == 0
+	case ProfilePure:
+		return e == 0
+	default:
+		return true
+	}
+}
+
 // Compile parses a jq program and returns the sift filter it describes.
 func Compile(name, src string) (filter sift.Filter, err error) {
-	fset := gotoken.NewFileSet()
-	f := fset.AddFile(name, -1, len(src))
-	s := newScanner(f, []byte(src))
-	p := newParser(s)
-	defer func() {
-		r := recover()
-		if r == nil {
-			return
-		} else if e, ok := r.(error); ok {
-			filter, err = nil, e
-		} else {
-			panic(r)
+	return CompileWithOptions(name, src, CompileOptions{})
+}
+
+// CompileWithOptions is like Compile but accepts options that control
+// compilation.
+func CompileWithOptions(name, src string, opts CompileOptions) (filter sift.Filter, err error) {
+	prog, err := CompileProgram(name, src, opts)
+	if err != nil {
+		return nil, err
+	}
+	return prog.Filter(nil), nil
+}
+
+// RuntimeError is an error that occurred while a compiled program was
+// running, for example, when a program indexes an array with a string.
+// Position is the location in the program of the expression that failed.
+//
+// If the error occurred in a function defined with def, Stack lists the
+// function calls that were in progress, innermost first.
+type RuntimeError struct {
+	Position gotoken.Position
+	Err      error
+	Stack    []Frame
+}
+
+// A Frame describes a call to a function defined with def.
+type Frame struct {
+	// Function is the name and arity of the called function, like "f/1".
+	Function string
+
+	// Position is the location of the call.
+	Position gotoken.Position
+}
+
+// maxErrorFrames is the number of lines of stack RuntimeError.Error prints.
+// If there are more, the lines in the middle are elided.
+const maxErrorFrames = 20
+
+// Error returns the error's position and message, followed by its stack.
+// Consecutive identical frames, as in deep recursion, are printed once
+// with a count, and if the stack is still long, frames in the middle are
+// elided.
+func (e *RuntimeError) Error() string {
+	if len(e.Stack) == 0 {
+		return fmt.Sprintf("%s: %v", e.Position, e.Err)
+	}
+	var lines []string
+	for i := 0; i < len(e.Stack); {
+		f := e.Stack[i]
+		n := 1
+		for i+n < len(e.Stack) && e.Stack[i+n] == f {
+			n++
+		}
+		line := fmt.Sprintf("in %s called at %s", f.Function, f.Position)
+		if n > 1 {
+			line += fmt.Sprintf(" (%d times)", n)
 		}
-	}()
-	return p.parse(), nil
+		lines = append(lines, line)
+		i += n
+	}
+	if len(lines) > maxErrorFrames {
+		half := maxErrorFrames / 2
+		elided := fmt.Sprintf("... %d frames elided ...", len(lines)-2*half)
+		lines = append(append(lines[:half:half], elided), lines[len(lines)-half:]...)
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s: %v", e.Position, e.Err)
+	for _, line := range lines {
+		fmt.Fprintf(b, "\n\t%s", line)
+	}
+	return b.String()
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
 }
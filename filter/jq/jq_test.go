@@ -1,12 +1,19 @@
 package jq_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	gotoken "go/token"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"go.jayconrod.com/sift"
 	"go.jayconrod.com/sift/encoding/json"
 	"go.jayconrod.com/sift/filter/jq"
+	"go.jayconrod.com/sift/ir"
 )
 
 func TestFilter(t *testing.T) {
@@ -18,6 +25,16 @@ func TestFilter(t *testing.T) {
 			program: "",
 			input:   "1",
 			want:    "1",
+		}, {
+			desc:    "input_key_order",
+			program: ".",
+			input:   `{"z": 1, "b": {"y": 2, "a": [{"d": 3, "c": 4}]}}`,
+			want:    `{"z":1,"b":{"y":2,"a":[{"d":3,"c":4}]}}`,
+		}, {
+			desc:    "input_key_order_field",
+			program: ".b",
+			input:   `{"z": 1, "b": {"y": 2, "a": 3}}`,
+			want:    `{"y":2,"a":3}`,
 		}, {
 			desc:    "lit_null",
 			program: "null",
@@ -212,7 +229,7 @@ null
 			desc:    "array_index_string",
 			program: `.["a"]`,
 			input:   `[]`,
-			wantErr: "cannot index array with value",
+			wantErr: `cannot index array with string ("a")`,
 		}, {
 			desc:    "array_index_not_array",
 			program: `.[0]`,
@@ -331,6 +348,51 @@ null
 			program: `"foo" * "bar"`,
 			input:   `true`,
 			wantErr: `cannot use numeric operator`,
+		}, {
+			desc:    "mul_string_number",
+			program: `"ab" * 3, 2 * "x", "ab" * 0.5, "ab" * 0, "ab" * -1`,
+			input:   `true`,
+			want: `
+"ababab"
+"xx"
+"ab"
+null
+null
+`,
+		}, {
+			desc:    "mul_string_huge",
+			program: `"ab" * 1e300`,
+			input:   `true`,
+			wantErr: `cannot repeat string`,
+		}, {
+			desc:    "mul_string_infinite",
+			program: `"ab" * infinite`,
+			input:   `true`,
+			wantErr: `cannot repeat string`,
+		}, {
+			desc:    "div_strings",
+			program: `"a,b,,c" / ",", "abc" / "", "" / ","`,
+			input:   `true`,
+			want: `
+["a","b","","c"]
+["a","b","c"]
+[]
+`,
+		}, {
+			desc:    "div_string_number",
+			program: `"a" / 1`,
+			input:   `true`,
+			wantErr: `cannot split string`,
+		}, {
+			desc:    "mul_objects",
+			program: `{"a":{"b":1,"c":2},"d":3,"e":{"f":4}} * {"a":{"b":5,"g":6},"d":{"h":7},"e":8}`,
+			input:   `true`,
+			want:    `{"a":{"b":5,"c":2,"g":6},"d":{"h":7},"e":8}`,
+		}, {
+			desc:    "mul_object_number",
+			program: `{} * 2`,
+			input:   `true`,
+			wantErr: `cannot merge object`,
 		}, {
 			desc:    "add_num",
 			program: `1 + 2`,
@@ -376,11 +438,513 @@ null
 			program: `[1, 2, 3] - [2]`,
 			input:   `true`,
 			want:    `[1,3]`,
+		}, {
+			desc:    "sub_array_nested",
+			program: `. - [{"b": [2], "a": 1}, "1", null]`,
+			input:   `[1, {"a": 1, "b": [2]}, "1", {"a": 1}, null, true, 1]`,
+			want:    `[1,{"a":1},true,1]`,
 		}, {
 			desc:    "sub_string",
 			program: `"foo" - "o"`,
 			input:   `true`,
 			wantErr: `cannot use numeric operator`,
+		}, {
+			desc:    "call_undefined",
+			program: `foo`,
+			input:   `null`,
+			wantErr: `foo/0 is not defined`,
+		}, {
+			desc:    "call_wrong_arity",
+			program: `nan(1)`,
+			input:   `null`,
+			wantErr: `nan/1 is not defined`,
+		}, {
+			desc:    "infinite",
+			program: `infinite, -infinite`,
+			input:   `null`,
+			want: `
+1.7976931348623157e+308
+-1.7976931348623157e+308
+`,
+		}, {
+			desc:    "nan",
+			program: `nan`,
+			input:   `null`,
+			want:    `null`,
+		}, {
+			desc:    "isinfinite",
+			program: `(infinite, -infinite, nan, 1) | isinfinite`,
+			input:   `null`,
+			want: `
+true
+true
+false
+false
+`,
+		}, {
+			desc:    "isnan",
+			program: `(nan, infinite, 1) | isnan`,
+			input:   `null`,
+			want: `
+true
+false
+false
+`,
+		}, {
+			desc:    "isnormal",
+			program: `.[] | isnormal`,
+			input:   `[1, 0, 1e-310, -2.5]`,
+			want: `
+true
+false
+false
+true
+`,
+		}, {
+			desc:    "isnormal_not_number",
+			program: `isnormal`,
+			input:   `"a"`,
+			wantErr: `is not a number`,
+		}, {
+			desc:    "combinations",
+			program: `combinations`,
+			input:   `[[1,2],["a","b"]]`,
+			want: `
+[1,"a"]
+[1,"b"]
+[2,"a"]
+[2,"b"]
+`,
+		}, {
+			desc:    "combinations_empty",
+			program: `[combinations]`,
+			input:   `[[1,2],[]] []`,
+			want: `
+[]
+[[]]
+`,
+		}, {
+			desc:    "combinations_n",
+			program: `combinations(2)`,
+			input:   `[0,1]`,
+			want: `
+[0,0]
+[0,1]
+[1,0]
+[1,1]
+`,
+		}, {
+			desc:    "combinations_not_array",
+			program: `combinations`,
+			input:   `[1]`,
+			wantErr: `cannot iterate over number (1)`,
+		}, {
+			desc:    "transpose",
+			program: `transpose`,
+			input:   `[[1], [2,3]]`,
+			want:    `[[1,2],[null,3]]`,
+		}, {
+			desc:    "transpose_empty",
+			program: `transpose`,
+			input:   `[]`,
+			want:    `[]`,
+		}, {
+			desc:    "transpose_not_array",
+			program: `transpose`,
+			input:   `[1]`,
+			wantErr: `is not an array`,
+		}, {
+			desc:    "walk_builtin",
+			program: `walk(. + .)`,
+			input:   `[1,"a",{"b":2}]`,
+			want:    `[2,"aa",{"b":4},2,"aa",{"b":4}]`,
+		}, {
+			desc:    "walk_builtin_multiple",
+			program: `walk(., .)`,
+			input:   `[{"a":1}]`,
+			want: `
+[{"a":1},{"a":1}]
+[{"a":1},{"a":1}]
+`,
+		}, {
+			desc:    "walk_builtin_error",
+			program: `walk(. - 1)`,
+			input:   `{"a":"b"}`,
+			wantErr: `cannot use numeric operator`,
+		}, {
+			desc:    "depth",
+			program: `.[] | depth`,
+			input:   `[1, [], {"a":[[2]]}, [{}, 3]]`,
+			want: `
+0
+1
+3
+2
+`,
+		}, {
+			desc:    "node_count",
+			program: `node_count, (.[] | node_count)`,
+			input:   `[1, {"a":[2,3]}]`,
+			want: `
+6
+1
+4
+`,
+		}, {
+			desc:    "byte_size",
+			program: `.[] | byte_size`,
+			input:   `[1, "ab", {"a": [true, null]}]`,
+			want: `
+1
+4
+17
+`,
+		}, {
+			desc:    "reachable",
+			program: `reachable("b", "x"; .deps[]) | [.[].id]`,
+			input:   `[{"id":"a","deps":["b"]},{"id":"b","deps":["c","d"]},{"id":"c","deps":["b"]},{"id":"d","deps":[]}]`,
+			want:    `["b","c","d"]`,
+		}, {
+			desc:    "reachable_parent",
+			program: `reachable(3; .parent) | [.[].id]`,
+			input:   `[{"id":1,"parent":null},{"id":2,"parent":1},{"id":3,"parent":2},{"id":4,"parent":1}]`,
+			want:    `[3,2,1]`,
+		}, {
+			desc:    "reachable_no_id",
+			program: `reachable(1; .parent)`,
+			input:   `[{"parent":null}]`,
+			wantErr: `reachable: node object ({"parent":null}) does not have an id`,
+		}, {
+			desc:    "reachable_not_array",
+			program: `reachable(1; .parent)`,
+			input:   `{"nodes":[{"id":1,"parent":null},{"id":2,"parent":1}]}`,
+			wantErr: `reachable: cannot iterate over object ({"nodes":[{"id":1,"parent":nul...)`,
+		}, {
+			desc:    "topo_sort",
+			program: `topo_sort(.deps[]) | [.[].id]`,
+			input:   `[{"id":"app","deps":["lib","util"]},{"id":"lib","deps":["util"]},{"id":"util","deps":[]},{"id":"doc","deps":[]}]`,
+			want:    `["util","lib","app","doc"]`,
+		}, {
+			desc:    "topo_sort_cycle",
+			program: `topo_sort(.deps[])`,
+			input:   `[{"id":"a","deps":["b"]},{"id":"b","deps":["c"]},{"id":"c","deps":["a"]}]`,
+			wantErr: `cycle: "a" -> "b" -> "c" -> "a"`,
+		}, {
+			desc:    "to_tree",
+			program: `to_tree(.id; .parent)`,
+			input:   `[{"id":2,"parent":1},{"id":1,"parent":null},{"id":3,"parent":1},{"id":4,"parent":2}]`,
+			want:    `[{"children":[{"children":[{"children":[],"id":4,"parent":2}],"id":2,"parent":1},{"children":[],"id":3,"parent":1}],"id":1,"parent":null}]`,
+		}, {
+			desc:    "to_tree_cycle",
+			program: `to_tree(.id; .parent)`,
+			input:   `[{"id":1,"parent":2},{"id":2,"parent":1},{"id":3}]`,
+			wantErr: `2 objects are part of a cycle`,
+		}, {
+			desc:    "from_tree",
+			program: `from_tree("children")`,
+			input:   `{"id":1,"children":[{"id":2,"children":[{"id":4}]},{"id":3,"children":[]}]}`,
+			want:    `[{"id":1},{"id":2},{"id":4},{"id":3}]`,
+		}, {
+			desc:    "tree_round_trip",
+			program: `to_tree(.id; .parent) | from_tree("children")`,
+			input:   `[{"id":1,"parent":null},{"id":2,"parent":1},{"id":3,"parent":2}]`,
+			want:    `[{"id":1,"parent":null},{"id":2,"parent":1},{"id":3,"parent":2}]`,
+		}, {
+			desc:    "dig",
+			program: `dig("a"; "b"; 0)`,
+			input:   `{"a": {"b": [1, 2]}}`,
+			want:    `1`,
+		}, {
+			desc:    "dig_negative",
+			program: `dig("a"; -1)`,
+			input:   `{"a": [1, 2]}`,
+			want:    `2`,
+		}, {
+			desc:    "dig_missing",
+			program: `dig("a"; "b"; 0)`,
+			input:   `{"a": {"c": 1}}`,
+			want:    `null`,
+		}, {
+			desc:    "dig_wrong_type",
+			program: `dig("a"; "b"), dig(0), dig("a"; 0)`,
+			input:   `{"a": [1]}`,
+			want:    "null\nnull\n1",
+		}, {
+			desc:    "dig_multiple",
+			program: `dig("a", "b")`,
+			input:   `{"a": 1, "b": 2}`,
+			want:    "1\n2",
+		}, {
+			desc:    "getpointer",
+			program: `getpointer("/a~1b/1/c~0"), getpointer("")`,
+			input:   `{"a/b": [0, {"c~": 1}]}`,
+			want:    "1\n{\"a/b\":[0,{\"c~\":1}]}",
+		}, {
+			desc:    "getpointer_missing",
+			program: `getpointer("/a/2")`,
+			input:   `{"a": [0, 1]}`,
+			wantErr: `getpointer: json pointer "/a/2": no value at .a[2]`,
+		}, {
+			desc:    "error",
+			program: `error("bad")`,
+			input:   `null`,
+			wantErr: "bad",
+		}, {
+			desc:    "error_input",
+			program: `.a | error`,
+			input:   `{"a": {"code": 1}}`,
+			wantErr: `(not a string): {"code":1}`,
+		}, {
+			desc:    "error_suppressed",
+			program: `[.[] | error?]`,
+			input:   `[1]`,
+			want:    `[]`,
+		}, {
+			desc:    "try",
+			program: `[.[] | try error]`,
+			input:   `[1]`,
+			want:    `[]`,
+		}, {
+			desc:    "try_catch",
+			program: `try error({code: .}) catch .code + 1`,
+			input:   `1`,
+			want:    `2`,
+		}, {
+			desc:    "try_catch_message",
+			program: `try (. + "x") catch .`,
+			input:   `1`,
+			want:    `"cannot use numeric operator on value x"`,
+		}, {
+			desc:    "try_catch_outputs",
+			program: `[.[] | try (1, error, 3) catch "caught"]`,
+			input:   `[2]`,
+			want:    `[1,"caught"]`,
+		}, {
+			desc:    "try_catch_continuation",
+			program: `(try 1 catch "caught") | error`,
+			input:   `null`,
+			wantErr: `(not a string): 1`,
+		}, {
+			desc:    "try_field",
+			program: `{try: 1, catch: 2} | .try + .catch`,
+			input:   `null`,
+			want:    `3`,
+		}, {
+			desc:    "object_order",
+			program: `{b: 1, a: 2, "c": 3, b: 4}`,
+			input:   `null`,
+			want:    `{"b":4,"a":2,"c":3}`,
+		}, {
+			desc:    "type",
+			program: `[.[] | type]`,
+			input:   `[null, true, 1, "a", [], {}]`,
+			want:    `["null","boolean","number","string","array","object"]`,
+		}, {
+			desc:    "sort",
+			program: `sort`,
+			input:   `[3, "a", null, [1], {"a": 1}, true, 1, false]`,
+			want:    `[null,false,true,1,3,"a",[1],{"a":1}]`,
+		}, {
+			desc:    "sort_by",
+			program: `[sort_by(.a, .b)[].c]`,
+			input:   `[{"a": 2, "b": 1, "c": 1}, {"a": 1, "b": 2, "c": 2}, {"a": 1, "b": 1, "c": 3}, {"a": 1, "b": 1, "c": 4}]`,
+			want:    `[3,4,2,1]`,
+		}, {
+			desc:    "sort_not_array",
+			program: `sort`,
+			input:   `{"a": 1}`,
+			wantErr: "sort: value",
+		}, {
+			desc:    "and",
+			program: `[(true, false, null, 0) and true]`,
+			input:   `null`,
+			want:    `[true,false,false,true]`,
+		}, {
+			desc:    "or",
+			program: `[(true, false, null, 0) or false]`,
+			input:   `null`,
+			want:    `[true,false,false,true]`,
+		}, {
+			desc:    "and_multiple",
+			program: `[true and (true, false), false and (true, false)]`,
+			input:   `null`,
+			want:    `[true,false,false]`,
+		}, {
+			desc:    "and_short_circuit",
+			program: `.a and .a[0]`,
+			input:   `{"a": false}`,
+			want:    `false`,
+		}, {
+			desc:    "or_short_circuit",
+			program: `.a or .a[0]`,
+			input:   `{"a": {}}`,
+			want:    `true`,
+		}, {
+			desc:    "and_or_precedence",
+			program: `true or false and false, (true or false) and false`,
+			input:   `null`,
+			want:    "true\nfalse",
+		}, {
+			desc:    "and_error",
+			program: `.a and .a[0]`,
+			input:   `{"a": true}`,
+			wantErr: `cannot index`,
+		}, {
+			desc:    "not",
+			program: `[(true, false, null, 0) | not]`,
+			input:   `null`,
+			want:    `[false,true,true,false]`,
+		}, {
+			desc:    "and_key",
+			program: `{and: 1, or: 2} | .and + .or`,
+			input:   `null`,
+			want:    `3`,
+		}, {
+			desc:    "as",
+			program: `.a as $x | .b + $x`,
+			input:   `{"a": 1, "b": 2}`,
+			want:    `3`,
+		}, {
+			desc:    "as_multiple",
+			program: `(1, 2) as $x | $x * 10`,
+			input:   `null`,
+			want:    "10\n20",
+		}, {
+			desc:    "as_comma_body",
+			program: `1 as $x | $x, $x + 1`,
+			input:   `null`,
+			want:    "1\n2",
+		}, {
+			desc:    "as_in_object",
+			program: `{a: (1 as $x | $x), b: 2}`,
+			input:   `null`,
+			want:    `{"a":1,"b":2}`,
+		}, {
+			desc:    "as_undefined_after_body",
+			program: `(1 as $x | $x) | $x`,
+			input:   `null`,
+			wantErr: `$x is not defined`,
+		}, {
+			desc:    "as_array",
+			program: `. as [$a, [$b]] | {a: $a, b: $b}`,
+			input:   `[1, [2], 3]`,
+			want:    `{"a":1,"b":2}`,
+		}, {
+			desc:    "as_object",
+			program: `. as {a: $x, $b, "c": [$c], (.k): $d, $e: {f: $f}} | [$x, $b, $c, $d, $e, $f]`,
+			input:   `{"a": 1, "b": 2, "c": [3], "k": "d", "d": 4, "e": {"f": 5}}`,
+			want:    `[1,2,3,4,{"f":5},5]`,
+		}, {
+			desc:    "as_pattern_error",
+			program: `. as [$a] | $a`,
+			input:   `{"a": 1}`,
+			wantErr: `cannot destructure`,
+		}, {
+			desc:    "alt_destructure",
+			program: `.[] as [$a] ?// {val: $a} | $a`,
+			input:   `[[1], {"val": 2}, null]`,
+			want:    "1\n2\nnull",
+		}, {
+			desc:    "alt_destructure_unbound_null",
+			program: `.[] as [$a] ?// {b: $b} | [$a, $b]`,
+			input:   `[[1], {"b": 2}]`,
+			want:    "[1,null]\n[null,2]",
+		}, {
+			desc:    "alt_destructure_body_error",
+			program: `. as [$a] ?// $a | $a[0]`,
+			input:   `[1]`,
+			want:    `1`,
+		}, {
+			desc:    "alt_destructure_last_error",
+			program: `. as [$a] ?// {a: $a} | $a`,
+			input:   `1`,
+			wantErr: `with object pattern`,
+		}, {
+			desc:    "optional_index",
+			program: `[.[0]?, .["a"]?]`,
+			input:   `true`,
+			want:    `[]`,
+		}, {
+			desc:    "optional_index_ok",
+			program: `.[0]?`,
+			input:   `[1]`,
+			want:    `1`,
+		}, {
+			desc:    "optional_slice",
+			program: `[.[2:5]?], [.a[1:]?]`,
+			input:   `{"a": "xyz"}`,
+			want:    "[]\n[\"yz\"]",
+		}, {
+			desc:    "optional_group",
+			program: `[(.a + 1)?, (.b + 1)?]`,
+			input:   `{"a": "x", "b": 1}`,
+			want:    `[2]`,
+		}, {
+			desc:    "optional_chain",
+			program: `[.a[0]?.b]`,
+			input:   `{"a": 1}`,
+			want:    `[]`,
+		}, {
+			desc:    "def",
+			program: `def f: .+1; f, (2 | f)`,
+			input:   `1`,
+			want: `
+2
+3
+`,
+		}, {
+			desc:    "def_params",
+			program: `def f(g; h): [g, h]; f(.a; .b)`,
+			input:   `{"a":1,"b":2}`,
+			want:    `[1,2]`,
+		}, {
+			desc:    "def_var_params",
+			program: `def f($a; $b): [$a, $b, a]; f(1, 2; 3)`,
+			input:   `null`,
+			want: `
+[1,3,1,2]
+[2,3,1,2]
+`,
+		}, {
+			desc:    "def_closure",
+			program: `def f(g): def h: g; 10 | h; def k: 1; f(k + .)`,
+			input:   `2`,
+			want:    `11`,
+		}, {
+			desc:    "def_shadow",
+			program: `def f: 1; def g: f; def f: 2; [f, g]`,
+			input:   `null`,
+			want:    `[2,1]`,
+		}, {
+			desc:    "def_arity",
+			program: `def f: 1; def f(x): x + 1; [f, f(10)]`,
+			input:   `null`,
+			want:    `[1,11]`,
+		}, {
+			desc:    "def_scope",
+			program: `(def f: 1; f) | f`,
+			input:   `null`,
+			wantErr: `f/0 is not defined`,
+		}, {
+			desc:    "def_param_scope",
+			program: `def f(g): 1; g`,
+			input:   `null`,
+			wantErr: `g/0 is not defined`,
+		}, {
+			desc:    "def_object_value",
+			program: `{a: def f: 1; f | . + 1}`,
+			input:   `null`,
+			want:    `{"a":2}`,
+		}, {
+			desc:    "var_undefined",
+			program: `$x`,
+			input:   `null`,
+			wantErr: `$x is not defined`,
+		}, {
+			desc:    "keyword_key",
+			program: `{def: 1, as: 2} | .def + .as`,
+			input:   `null`,
+			want:    `3`,
 		}, {
 			desc:    "walk",
 			program: `..`,
@@ -394,6 +958,136 @@ null
 2
 3
 `,
+		}, {
+			desc:    "range",
+			program: `[range(3)], [range(1, 2; 3)]`,
+			input:   "null",
+			want:    "[0,1,2]\n[1,2,2]",
+		}, {
+			desc:    "range_error",
+			program: `range("a")`,
+			input:   "null",
+			wantErr: "range: value a is not a number",
+		}, {
+			desc:    "first",
+			program: `first(range(1e18)), [first(1 | .[]?)]`,
+			input:   "null",
+			want:    "0\n[]",
+		}, {
+			desc:    "first_stops",
+			program: `first(.[], (.[] | .[]))`,
+			input:   "[1,2]",
+			want:    "1",
+		}, {
+			desc:    "limit",
+			program: `[limit(3; range(1e18))], [limit(0; 1, 2)], [limit(1, 2; .[])]`,
+			input:   "[4,5,6]",
+			want:    "[0,1,2]\n[]\n[4,4,5]",
+		}, {
+			desc:    "isempty",
+			program: `isempty(1 | .[]?), isempty(range(1e18))`,
+			input:   "null",
+			want:    "true\nfalse",
+		}, {
+			desc:    "any_all",
+			program: `any, all, any(not), all(not), any(range(1e18); true), all(range(1e18); false)`,
+			input:   "[false,true]",
+			want:    "true\nfalse\ntrue\nfalse\ntrue\nfalse",
+		}, {
+			desc:    "lazy_iterate_error",
+			program: `[(.[] | .[])?]`,
+			input:   "[[1],2,[3]]",
+			want:    "[1]",
+		}, {
+			desc:    "pick",
+			program: `pick(.a.b, .c, .missing.x)`,
+			input:   `{"a": {"b": 1, "z": 2}, "c": [3], "d": 4}`,
+			want:    `{"a":{"b":1},"c":[3],"missing":{"x":null}}`,
+		}, {
+			desc:    "pick_index",
+			program: `pick(.[1], .[3])`,
+			input:   `[1,2,3]`,
+			want:    `[null,2,null,null]`,
+		}, {
+			desc:    "pick_iterate",
+			program: `pick(.a[].b)`,
+			input:   `{"a": [{"b": 1, "c": 2}, {"b": 3}]}`,
+			want:    `{"a":[{"b":1},{"b":3}]}`,
+		}, {
+			desc:    "pick_key_expr",
+			program: `pick(.[.k] | .x?)`,
+			input:   `{"k": "v", "v": {"x": 1, "y": 2}}`,
+			want:    `{"v":{"x":1}}`,
+		}, {
+			desc:    "pick_negative",
+			program: `pick(.[-1])`,
+			input:   `[1,2]`,
+			wantErr: `cannot set negative array index`,
+		}, {
+			desc:    "pick_not_path",
+			program: `pick(1)`,
+			input:   `null`,
+			wantErr: `not a path expression`,
+		}, {
+			desc:    "abs",
+			program: `[.[] | abs]`,
+			input:   `[-1.5, 0, 2]`,
+			want:    `[1.5,0,2]`,
+		}, {
+			desc:    "abs_error",
+			program: `abs`,
+			input:   `"a"`,
+			wantErr: `not a number`,
+		}, {
+			desc:    "toarray",
+			program: `[.[] | toarray]`,
+			input:   `[1, [2], null]`,
+			want:    `[[1],[2],[null]]`,
+		}, {
+			desc:    "trimstr",
+			program: `[.[] | ltrimstr("ab"), rtrimstr("ab")]`,
+			input:   `["abcab", 1, null]`,
+			want:    `["cab","abc",1,1,null,null]`,
+		}, {
+			desc:    "trimstr_non_string_arg",
+			program: `ltrimstr(1)`,
+			input:   `"1a"`,
+			want:    `"1a"`,
+		}, {
+			desc:    "splits",
+			program: `[splits(", *")], [splits("A"; "gi")], [splits("x*"; "n")], [splits(" a # comment\n"; "x")]`,
+			input:   `"a, b,c"`,
+			want:    "[\"a\",\"b\",\"c\"]\n[\"\",\", b,c\"]\n[\"a, b,c\"]\n[\"\",\", b,c\"]",
+		}, {
+			desc:    "splits_flags_error",
+			program: `[splits("a"; "q")]`,
+			input:   `"abc"`,
+			wantErr: `not a valid modifier string`,
+		}, {
+			desc:    "select",
+			program: `[.[] | select(.ok)], [.[] | select(.ok, true) | .n]`,
+			input:   `[{"n": 1, "ok": true}, {"n": 2, "ok": false}]`,
+			want:    "[{\"n\":1,\"ok\":true}]\n[1,1,2]",
+		}, {
+			desc:    "getpath",
+			program: `getpath(["a", "b", 1]), getpath([]), getpath(["a", "c"], ["x", "y", 0])`,
+			input:   `{"a": {"b": [1, 2]}}`,
+			want:    "2\n{\"a\":{\"b\":[1,2]}}\nnull\nnull",
+		}, {
+			desc:    "getpath_wrong_type",
+			program: `getpath(["a", "b"])`,
+			input:   `{"a": "x"}`,
+			wantErr: `getpath: cannot index value`,
+		}, {
+			desc:    "getpath_not_array",
+			program: `getpath("a")`,
+			input:   `{"a": 1}`,
+			wantErr: `getpath: path a is not an array`,
+		}, {
+			desc:    "have_literal_numbers",
+			program: `have_literal_numbers, have_decnum, .`,
+			input:   `100000000000000000001`,
+			want:    "true\nfalse\n100000000000000000001",
 		},
 	} {
 		t.Run(tc.desc, func(t *testing.T) {
@@ -430,3 +1124,1324 @@ null
 		})
 	}
 }
+
+func TestModules(t *testing.T) {
+	opts := jq.CompileOptions{SearchPath: []string{"testdata/modules"}}
+	for _, tc := range []struct {
+		desc, program, input, want, wantErr string
+	}{
+		{
+			desc:    "import",
+			program: `import "util" as util; [util::twice(.)]`,
+			input:   `1`,
+			want:    `[1,1]`,
+		}, {
+			desc:    "include",
+			program: `include "util"; addn(2)`,
+			input:   `1`,
+			want:    `3`,
+		}, {
+			desc:    "import_dir",
+			program: `import "lib" as lib; [lib::double | lib::inc]`,
+			input:   `1`,
+			want:    `[2,2]`,
+		}, {
+			desc:    "import_not_exported",
+			program: `import "lib" as lib; lib::u::addn(1)`,
+			input:   `1`,
+			wantErr: `lib::u::addn/1 is not defined`,
+		}, {
+			desc:    "import_unqualified",
+			program: `import "util" as util; twice(.)`,
+			input:   `1`,
+			wantErr: `twice/1 is not defined`,
+		}, {
+			desc:    "import_missing",
+			program: `import "missing" as m; .`,
+			input:   `1`,
+			wantErr: `module "missing" not found`,
+		}, {
+			desc:    "import_cycle",
+			program: `include "cycle"; .`,
+			input:   `1`,
+			wantErr: `import cycle`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			f, err := jq.CompileWithOptions(tc.desc, tc.program, opts)
+			if err != nil {
+				if tc.wantErr == "" {
+					t.Fatal(err)
+				} else if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("got error %q; want error with %q", err, tc.wantErr)
+				}
+				return
+			} else if tc.wantErr != "" {
+				t.Fatalf("got success; want error with %q", tc.wantErr)
+			}
+			dec := json.NewDecoder(strings.NewReader(tc.input))
+			w := &strings.Builder{}
+			enc := json.NewEncoder(w)
+			if err := sift.Sift(dec, f, enc); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := strings.TrimSpace(w.String()), strings.TrimSpace(tc.want); got != want {
+				t.Errorf("got:\n%s\n\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestNullSafe(t *testing.T) {
+	opts := jq.CompileOptions{NullSafe: true}
+	for _, tc := range []struct {
+		desc, program, input, want string
+	}{
+		{
+			desc:    "field",
+			program: `.a.b`,
+			input:   `{"a": 1}`,
+			want:    `null`,
+		}, {
+			desc:    "index_object",
+			program: `.[0]`,
+			input:   `{"a": 1}`,
+			want:    `null`,
+		}, {
+			desc:    "index_string",
+			program: `.["a"]`,
+			input:   `"abc"`,
+			want:    `null`,
+		}, {
+			desc:    "index_array",
+			program: `.a[1]`,
+			input:   `{"a": [1, 2]}`,
+			want:    `2`,
+		}, {
+			desc:    "slice",
+			program: `.[1:]`,
+			input:   `12`,
+			want:    `null`,
+		}, {
+			desc:    "iterate",
+			program: `[.[]]`,
+			input:   `12`,
+			want:    `[]`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			f, err := jq.CompileWithOptions(tc.desc, tc.program, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec := json.NewDecoder(strings.NewReader(tc.input))
+			w := &strings.Builder{}
+			enc := json.NewEncoder(w)
+			if err := sift.Sift(dec, f, enc); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := strings.TrimSpace(w.String()), strings.TrimSpace(tc.want); got != want {
+				t.Errorf("got:\n%s\n\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestRuntimeError(t *testing.T) {
+	for _, tc := range []struct {
+		desc, program, input string
+		line, column         int
+	}{
+		{
+			desc:    "index",
+			program: `.a | .[0]`,
+			input:   `{"a": true}`,
+			line:    1,
+			column:  7,
+		}, {
+			desc:    "iterate",
+			program: "[\n  .a[]\n]",
+			input:   `{"a": 1}`,
+			line:    2,
+			column:  5,
+		}, {
+			desc:    "binary",
+			program: `.a + .b`,
+			input:   `{"a": 1, "b": "x"}`,
+			line:    1,
+			column:  4,
+		}, {
+			desc:    "nested",
+			program: `(.a | .[0]) + 1`,
+			input:   `{"a": 1}`,
+			line:    1,
+			column:  8,
+		}, {
+			desc:    "builtin",
+			program: `1, isnan`,
+			input:   `"x"`,
+			line:    1,
+			column:  4,
+		}, {
+			desc:    "function",
+			program: "def f: -.;\n1, f",
+			input:   `"x"`,
+			line:    1,
+			column:  8,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			f, err := jq.Compile(tc.desc, tc.program)
+			if err != nil {
+				t.Fatal(err)
+			}
+			in, err := json.NewDecoder(strings.NewReader(tc.input)).Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = f(in)
+			var rerr *jq.RuntimeError
+			if !errors.As(err, &rerr) {
+				t.Fatalf("got error %v; want *jq.RuntimeError", err)
+			}
+			pos := rerr.Position
+			if pos.Filename != tc.desc || pos.Line != tc.line || pos.Column != tc.column {
+				t.Errorf("got position %v; want %s:%d:%d", pos, tc.desc, tc.line, tc.column)
+			}
+		})
+	}
+}
+
+func TestHost(t *testing.T) {
+	opts := jq.CompileOptions{HostFuncs: []string{"lookup/1"}}
+	prog, err := jq.CompileProgram("host", `{user: lookup(.id, .id + 1), tenant: host_data}`, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type ctxKey struct{}
+	newHost := func(tenant string) *jq.Host {
+		return &jq.Host{
+			Context: context.WithValue(context.Background(), ctxKey{}, tenant),
+			Data:    sift.Must(sift.ToValue(tenant)),
+			Funcs: map[string]jq.HostFunc{
+				"lookup/1": func(ctx context.Context, _ sift.Value, args []sift.Value) ([]sift.Value, error) {
+					id, _ := sift.AsFloat64(args[0])
+					name := fmt.Sprintf("%s-%g", ctx.Value(ctxKey{}), id)
+					return []sift.Value{sift.Must(sift.ToValue(name))}, nil
+				},
+			},
+		}
+	}
+	for _, tenant := range []string{"a", "b"} {
+		f := prog.Filter(newHost(tenant))
+		vs, err := f(sift.Must(sift.ToValue(map[string]interface{}{"id": 1})))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, v := range vs {
+			got = append(got, valueString(t, v))
+		}
+		want := []string{
+			fmt.Sprintf(`{"user":"%s-1","tenant":"%s"}`, tenant, tenant),
+			fmt.Sprintf(`{"user":"%s-2","tenant":"%s"}`, tenant, tenant),
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("got %v; want %v", got, want)
+		}
+	}
+
+	if _, err := prog.Filter(nil)(sift.NullValue); err == nil || !strings.Contains(err.Error(), "lookup/1 was not provided") {
+		t.Errorf("got error %v; want error about missing host function", err)
+	}
+	if _, err := jq.Compile("undeclared", `lookup(1)`); err == nil {
+		t.Error("undeclared host function: got success; want error")
+	}
+}
+
+func TestHostContext(t *testing.T) {
+	for _, tc := range []struct {
+		desc, src string
+	}{
+		{desc: "tail_loop", src: `def f: f; f`},
+		{desc: "generator", src: `[range(1e12)] | .[0]`},
+		{desc: "optional", src: `def f: f; (f)?`},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			prog, err := jq.CompileProgram(tc.desc, tc.src, jq.CompileOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			_, err = prog.Filter(&jq.Host{Context: ctx})(sift.NullValue)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("got error %v; want %v", err, context.DeadlineExceeded)
+			}
+		})
+	}
+}
+
+func TestEmit(t *testing.T) {
+	prog, err := jq.CompileProgram("emit", `range(1e9) | . * 2`, jq.CompileOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errStop := errors.New("stop")
+	var got []string
+	err = prog.Emit(nil)(sift.NullValue, func(v sift.Value) error {
+		got = append(got, valueString(t, v))
+		if len(got) == 3 {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop {
+		t.Fatalf("got error %v; want %v", err, errStop)
+	}
+	if want := "0 2 4"; strings.Join(got, " ") != want {
+		t.Errorf("got %v; want %s", got, want)
+	}
+}
+
+func TestFuncs(t *testing.T) {
+	var calls int
+	opts := jq.CompileOptions{Funcs: map[string]jq.HostFunc{
+		"geoip/1": func(_ context.Context, _ sift.Value, args []sift.Value) ([]sift.Value, error) {
+			calls++
+			ip, _ := sift.AsString(args[0])
+			if ip == "" {
+				return nil, errors.New("geoip: empty address")
+			}
+			return []sift.Value{sift.Must(sift.ToValue("region-" + ip))}, nil
+		},
+		"not/0": func(_ context.Context, input sift.Value, _ []sift.Value) ([]sift.Value, error) {
+			return []sift.Value{input}, nil
+		},
+	}}
+	f, err := jq.CompileWithOptions("funcs", `[geoip(.a, .b), not]`, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs, err := f(sift.Must(sift.ToValue(map[string]interface{}{"a": "x", "b": "y"})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := valueString(t, vs[0]), `["region-x","region-y",{"a":"x","b":"y"}]`; got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+	if calls != 2 {
+		t.Errorf("geoip called %d times; want 2", calls)
+	}
+	bf, err := jq.CompileWithOptions("builtins", `builtins`, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs, err = bf(sift.NullValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := valueString(t, vs[0]); !strings.Contains(got, `"geoip/1"`) {
+		t.Errorf("builtins: got %s; want list including geoip/1", got)
+	}
+
+	if _, err := f(sift.Must(sift.ToValue(map[string]interface{}{"a": ""}))); err == nil || !strings.Contains(err.Error(), "empty address") {
+		t.Errorf("got error %v; want error from geoip", err)
+	}
+	opts.Profile = jq.ProfilePure
+	if _, err := jq.CompileWithOptions("pure", `geoip(.a)`, opts); err == nil {
+		t.Error("calling Funcs with ProfilePure: got success; want error")
+	}
+	if _, err := jq.Compile("undeclared", `geoip(.a)`); err == nil {
+		t.Error("undeclared function: got success; want error")
+	}
+}
+
+func TestPlugins(t *testing.T) {
+	constant := func(name string) func(int) map[string]jq.HostFunc {
+		return func(int) map[string]jq.HostFunc {
+			return map[string]jq.HostFunc{
+				"plugin/0": func(context.Context, sift.Value, []sift.Value) ([]sift.Value, error) {
+					return []sift.Value{sift.Must(sift.ToValue(name))}, nil
+				},
+			}
+		}
+	}
+	req := func(name string, caps ...sift.Capability) sift.PluginRequirements {
+		return sift.PluginRequirements{Name: name, MinVersion: sift.InterfaceVersion, Requires: caps}
+	}
+	for _, tc := range []struct {
+		desc          string
+		opts          jq.CompileOptions
+		want, wantErr string
+	}{
+		{
+			desc: "plugin",
+			opts: jq.CompileOptions{Plugins: []jq.Plugin{{Requirements: req("a", sift.CapString), Funcs: constant("a")}}},
+			want: `"a"`,
+		}, {
+			desc: "funcs_override",
+			opts: jq.CompileOptions{
+				Funcs:   constant("funcs")(0),
+				Plugins: []jq.Plugin{{Requirements: req("a"), Funcs: constant("a")}},
+			},
+			want: `"funcs"`,
+		}, {
+			desc: "conflict",
+			opts: jq.CompileOptions{Plugins: []jq.Plugin{
+				{Requirements: req("a"), Funcs: constant("a")},
+				{Requirements: req("b"), Funcs: constant("b")},
+			}},
+			wantErr: "plugin/0 is provided by plugins a and b",
+		}, {
+			desc:    "incompatible",
+			opts:    jq.CompileOptions{Plugins: []jq.Plugin{{Requirements: req("a", "uuid"), Funcs: constant("a")}}},
+			wantErr: "plugin a: incompatible plugin",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			f, err := jq.CompileWithOptions(tc.desc, "plugin", tc.opts)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("got error %v; want error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			vs, err := f(sift.NullValue)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := valueString(t, vs[0]); got != tc.want {
+				t.Errorf("got %s; want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func valueString(t *testing.T, v sift.Value) string {
+	t.Helper()
+	w := &strings.Builder{}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(w.String())
+}
+
+func TestLower(t *testing.T) {
+	input := sift.Must(sift.ToValue(map[string]interface{}{
+		"a": map[string]interface{}{"b": []interface{}{1, 2}},
+		"n": 3,
+	}))
+	for _, tc := range []struct {
+		desc, src, want string
+	}{
+		{desc: "empty", src: ``, want: `.`},
+		{desc: "fields", src: `.a.b`, want: `.a | .b`},
+		{desc: "iterate", src: `.a.b[] * 2`, want: `(.a | .b | .[]) * 2`},
+		{desc: "comma", src: `(.n, 1) + 1`, want: `(.n, 1) + 1`},
+		{desc: "source", src: `.a | type`, want: `.a | jq("type")`},
+		{desc: "select", src: `(.a.b[], .x) | select(.)`, want: `(.a | .b | .[]), .x | select(.)`},
+		{desc: "optional", src: `.n.x?`, want: `jq(".n.x?")`},
+		{desc: "var", src: `.n as $x | $x + 1`, want: `jq(".n as $x | $x + 1")`},
+		{desc: "def", src: `def f: .n; f`, want: `jq("def f: .n; f")`},
+		{
+			desc: "syntax",
+			src:  `def f($x; g): [g, $x]; . as {a: {b: [$p, $q]}} | {k: f(.n; $p), (type): -$q, s: .a.b[1:], r: [.a.b[]?], t: (try error("e") catch .), u: .["n"], v: [.. | abs?], w: (.a.b | .[-1])?}`,
+			want: `jq("def f($x; g): [g, $x]; . as {a: {b: [$p, $q]}} | {k: f(.n; $p), (type): -$q, s: .a.b[1:], r: [.a.b[]?], t: (try error(\"e\") catch .), u: .[\"n\"], v: [.. | abs?], w: (.a.b | .[-1])?}")`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			fset := gotoken.NewFileSet()
+			f, err := jq.Parse(fset, tc.desc, tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n := jq.Lower(f)
+			if got := n.String(); got != tc.want {
+				t.Errorf("got %s; want %s", got, tc.want)
+			}
+
+			// Compile the tree after a round trip through its serialized
+			// form, so Source nodes are loaded by language name.
+			data, err := ir.Marshal(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n, err = ir.Unmarshal(data); err != nil {
+				t.Fatal(err)
+			}
+
+			// Loading Source nodes shouldn't parse their source, so replace
+			// it with text that doesn't parse.
+			ir.Inspect(n, func(n *ir.Node) bool {
+				if n.Op == ir.OpSource {
+					n.Src = strings.Repeat(")", len(n.Src))
+				}
+				return true
+			})
+			lowered, err := ir.Compile(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			direct, err := jq.Compile(tc.desc, tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got, want []string
+			for _, out := range []struct {
+				f  sift.Filter
+				vs *[]string
+			}{{lowered, &got}, {direct, &want}} {
+				vs, err := out.f(input)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, v := range vs {
+					*out.vs = append(*out.vs, valueString(t, v))
+				}
+			}
+			if strings.Join(got, " ") != strings.Join(want, " ") {
+				t.Errorf("lowered program produced %v; want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLowerError(t *testing.T) {
+	// Errors in loaded Source nodes have the same positions as errors in
+	// their source compiled directly.
+	fset := gotoken.NewFileSet()
+	f, err := jq.Parse(fset, "error.jq", `.a | (1, 2 | error("e" + (. | type)))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ir.Marshal(jq.Lower(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := ir.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lowered, err := ir.Compile(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = lowered(sift.NullValue)
+	var rerr *jq.RuntimeError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("got error %v; want *jq.RuntimeError", err)
+	}
+	if got, want := err.Error(), "ir:1:1: e"; !strings.HasPrefix(got, want) {
+		t.Errorf("got error %q; want %q", got, want)
+	}
+}
+
+func TestLowerOptimize(t *testing.T) {
+	// Calls to select are lowered to ir.Select nodes, so the optimizer may
+	// move a cheap select ahead of one whose predicate is kept as source.
+	fset := gotoken.NewFileSet()
+	f, err := jq.Parse(fset, "select.jq", `.[] | select(.tags | type) | select(.ok)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := ir.Optimize(jq.Lower(f))
+	if got, want := n.String(), `.[] | select(.ok) | select(.tags | jq("type"))`; got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+	opt, err := ir.Compile(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := sift.Must(sift.ToValue([]interface{}{
+		map[string]interface{}{"ok": true, "tags": []interface{}{}},
+		map[string]interface{}{"ok": false, "tags": 1},
+	}))
+	vs, err := opt(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != 1 || valueString(t, vs[0]) != `{"ok":true,"tags":[]}` {
+		t.Errorf("got %v; want [{\"ok\":true,\"tags\":[]}]", vs)
+	}
+}
+
+func TestVars(t *testing.T) {
+	opts := jq.CompileOptions{Vars: map[string]sift.Value{
+		"limit": sift.Must(sift.ToValue(2)),
+		"name":  sift.Must(sift.ToValue("x")),
+	}}
+	f, err := jq.CompileWithOptions("vars", `{($name): (.a + $limit)}`, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs, err := f(sift.Must(sift.ToValue(map[string]interface{}{"a": 1})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != 1 {
+		t.Fatalf("got %d values; want 1", len(vs))
+	}
+	if got, want := valueString(t, vs[0]), `{"x":3}`; got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+
+	if _, err := jq.CompileWithOptions("shadow", `1 as $limit | $limit`, opts); err != nil {
+		t.Errorf("shadowing an external variable: %v", err)
+	}
+	if _, err := jq.Compile("undefined", `$limit`); err == nil {
+		t.Error("undefined variable: got success; want error")
+	}
+	badOpts := jq.CompileOptions{Vars: map[string]sift.Value{"a-b": sift.NullValue}}
+	if _, err := jq.CompileWithOptions("bad", `.`, badOpts); err == nil || !strings.Contains(err.Error(), "invalid variable name") {
+		t.Errorf("got error %v; want invalid variable name", err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	add := sift.NewFunc("add", 1, func(input sift.Value, args []sift.Value) ([]sift.Value, error) {
+		x, _ := sift.AsFloat64(input)
+		y, _ := sift.AsFloat64(args[0])
+		return []sift.Value{sift.Must(sift.ToValue(x + y))}, nil
+	})
+	opts := jq.CompileOptions{Vars: map[string]sift.Value{"add": add}}
+	for _, tc := range []struct {
+		desc, program, want, wantErr string
+	}{
+		{desc: "call", program: `[call($add; 1, 2)]`, want: `[2,3]`},
+		{desc: "bound", program: `$add as $f | 10 | call($f; .)`, want: `20`},
+		{desc: "arity", program: `call($add)`, wantErr: "function add accepts 1 arguments; called with 0"},
+		{desc: "not_func", program: `call(1)`, wantErr: "call: value 1 is not a function"},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			f, err := jq.CompileWithOptions(tc.desc, tc.program, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			vs, err := f(sift.Must(sift.ToValue(1)))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("got error %v; want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(vs) != 1 {
+				t.Fatalf("got %d values; want 1", len(vs))
+			}
+			if got := valueString(t, vs[0]); got != tc.want {
+				t.Errorf("got %s; want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestErrorValue(t *testing.T) {
+	f, err := jq.Compile("err.jq", `{a: 1} | error({code: .a})`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f(sift.NullValue)
+	ev, ok := sift.AsErrorValue(err)
+	if !ok {
+		t.Fatalf("got error %v; want *sift.ErrorValue", err)
+	}
+	if got, want := valueString(t, ev.Value), `{"code":1}`; got != want {
+		t.Errorf("got payload %s; want %s", got, want)
+	}
+	if got, want := ev.Position, "err.jq:1:10"; got != want {
+		t.Errorf("got position %q; want %q", got, want)
+	}
+
+	// An ErrorValue in the input is raised when it's accessed, so it may
+	// be caught and inspected.
+	input := sift.LazyAttr(
+		sift.LazyField{Key: "ok", Value: func() sift.Value { return sift.Must(sift.ToValue(1)) }},
+		sift.LazyField{Key: "bad", Value: func() sift.Value {
+			return &sift.ErrorValue{Value: sift.Must(sift.ToValue(map[string]interface{}{"code": 2}))}
+		}},
+	)
+	f, err = jq.Compile("catch.jq", `[.ok, (try .bad catch .code), (.bad)?, try .["bad"] catch "index"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs, err := f(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != 1 {
+		t.Fatalf("got %d outputs; want 1", len(vs))
+	}
+	if got, want := valueString(t, vs[0]), `[1,2,"index"]`; got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+	f, err = jq.Compile("uncaught.jq", `.bad`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f(input); err == nil {
+		t.Error("accessing an error value succeeded; want error")
+	} else if ev, ok := sift.AsErrorValue(err); !ok || valueString(t, ev.Value) != `{"code":2}` {
+		t.Errorf("got error %v; want *sift.ErrorValue with payload {\"code\":2}", err)
+	}
+}
+
+func TestDebug(t *testing.T) {
+	w := &strings.Builder{}
+	f, err := jq.CompileWithOptions("debug", `.a | debug | . + 1`, jq.CompileOptions{Debug: w})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs, err := f(sift.Must(sift.ToValue(map[string]interface{}{"a": 1})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != 1 || valueString(t, vs[0]) != "2" {
+		t.Errorf("got %v; want [2]", vs)
+	}
+	if got, want := w.String(), "DEBUG: number 1\n"; got != want {
+		t.Errorf("got debug output %q; want %q", got, want)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	for _, tc := range []struct {
+		desc, program string
+		profile       jq.Profile
+		wantErr       string
+	}{
+		{
+			desc:    "full_now",
+			program: `now`,
+			profile: jq.ProfileFull,
+		}, {
+			desc:    "full_host",
+			program: `lookup`,
+			profile: jq.ProfileFull,
+		}, {
+			desc:    "noio_now",
+			program: `now`,
+			profile: jq.ProfileNoIO,
+		}, {
+			desc:    "noio_host",
+			program: `. as $x | lookup`,
+			profile: jq.ProfileNoIO,
+			wantErr: "noio_host:1:11: lookup/0 is not allowed in the no-io profile",
+		}, {
+			desc:    "pure_now",
+			program: `def f: now; 1`,
+			profile: jq.ProfilePure,
+			wantErr: "now/0 is not allowed in the pure profile",
+		}, {
+			desc:    "pure_ok",
+			program: `[.[] | walk(.)] | transpose`,
+			profile: jq.ProfilePure,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := jq.CompileOptions{HostFuncs: []string{"lookup/0"}, Profile: tc.profile}
+			_, err := jq.CompileWithOptions(tc.desc, tc.program, opts)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("got error %v; want error with %q", err, tc.wantErr)
+			}
+		})
+	}
+
+	for _, p := range []jq.Profile{jq.ProfileFull, jq.ProfileNoIO, jq.ProfilePure} {
+		if got, err := jq.ParseProfile(p.String()); err != nil || got != p {
+			t.Errorf("ParseProfile(%q) = %v, %v; want %v", p.String(), got, err, p)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	var deep strings.Builder
+	deep.WriteString("def f0: .; ")
+	for i := 1; i <= 40; i++ {
+		fmt.Fprintf(&deep, "def f%d: f%d, f%d; ", i, i-1, i-1)
+	}
+	deep.WriteString("f40")
+
+	for _, tc := range []struct {
+		desc, program string
+		opts          jq.CompileOptions
+		wantErr       error
+	}{
+		{
+			desc:    "depth",
+			program: `def f: [f]; f`,
+			opts:    jq.CompileOptions{MaxDepth: 100},
+			wantErr: jq.ErrRecursionDepth,
+		}, {
+			desc:    "depth_optional",
+			program: `(def f: [f]; f)?`,
+			opts:    jq.CompileOptions{MaxDepth: 100},
+			wantErr: jq.ErrRecursionDepth,
+		}, {
+			desc:    "depth_ok",
+			program: `def f: .; def g: f; g`,
+			opts:    jq.CompileOptions{MaxDepth: 2},
+		}, {
+			desc:    "depth_tail",
+			program: `def f: .[]? | f; f`,
+			opts:    jq.CompileOptions{MaxDepth: 1},
+		}, {
+			desc:    "outputs",
+			program: `.[]`,
+			opts:    jq.CompileOptions{MaxOutputs: 2},
+			wantErr: jq.ErrTooManyOutputs,
+		}, {
+			desc:    "outputs_ok",
+			program: `.[0], .[1]`,
+			opts:    jq.CompileOptions{MaxOutputs: 2},
+		}, {
+			desc:    "timeout",
+			program: deep.String(),
+			opts:    jq.CompileOptions{Timeout: 10 * time.Millisecond},
+			wantErr: jq.ErrTimeout,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			f, err := jq.CompileWithOptions(tc.desc, tc.program, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = f(sift.Must(sift.ToValue([]interface{}{1, 2, 3})))
+			if tc.wantErr == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("got error %v; want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	// Tail calls don't count toward MaxDepth, even with arguments and
+	// through several functions.
+	const n = 10000
+	var v interface{} = "leaf"
+	for i := 0; i < n; i++ {
+		v = []interface{}{v}
+	}
+	in := sift.Must(sift.ToValue(v))
+	opts := jq.CompileOptions{MaxDepth: 10}
+	for _, program := range []string{
+		`def f: ., (.[]? | f); [f]`,
+		`def f(g): g, (.[]? | f(g)); [f(.)]`,
+		`def f: def g: .[]? | f; ., g; [f]`,
+	} {
+		f, err := jq.CompileWithOptions("tail", program, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outs, err := f(in)
+		if err != nil {
+			t.Errorf("%s: %v", program, err)
+			continue
+		}
+		if got := outs[0].(sift.Index).Length(); got != n+1 {
+			t.Errorf("%s: got %d values; want %d", program, got, n+1)
+		}
+	}
+
+	// Calls that aren't in tail position do count.
+	f, err := jq.CompileWithOptions("non-tail", `def f: ., [.[]? | f]; [f]`, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f(in); !errors.Is(err, jq.ErrRecursionDepth) {
+		t.Errorf("got error %v; want %v", err, jq.ErrRecursionDepth)
+	}
+}
+
+func TestLazy(t *testing.T) {
+	// Generators stop as soon as a consumer has the values it needs, so
+	// count is only called for values that are used.
+	var calls int
+	opts := jq.CompileOptions{Funcs: map[string]jq.HostFunc{
+		"count/0": func(_ context.Context, input sift.Value, _ []sift.Value) ([]sift.Value, error) {
+			calls++
+			return []sift.Value{input}, nil
+		},
+	}}
+	for _, tc := range []struct {
+		program   string
+		want      string
+		wantCalls int
+	}{
+		{program: `first(range(1e9) | count)`, want: "0", wantCalls: 1},
+		{program: `[limit(3; .[] | count)]`, want: "[1,2,3]", wantCalls: 3},
+		{program: `any(.[] | count; .)`, want: "true", wantCalls: 1},
+		{program: `all(.[] | count; 0 | .[]?)`, want: "true", wantCalls: 5},
+		{program: `isempty(.. | count)`, want: "false", wantCalls: 1},
+	} {
+		t.Run(tc.program, func(t *testing.T) {
+			calls = 0
+			f, err := jq.CompileWithOptions("lazy", tc.program, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			vs, err := f(sift.Must(sift.ToValue([]interface{}{1, 2, 3, 4, 5})))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(vs) != 1 {
+				t.Fatalf("got %d values; want 1", len(vs))
+			}
+			if got := valueString(t, vs[0]); got != tc.want {
+				t.Errorf("got %s; want %s", got, tc.want)
+			}
+			if calls != tc.wantCalls {
+				t.Errorf("count called %d times; want %d", calls, tc.wantCalls)
+			}
+		})
+	}
+}
+
+// naturals is an infinite array of the natural numbers, produced one at
+// a time.
+type naturals struct{}
+
+func (naturals) Truth() bool { return true }
+
+func (naturals) Iterate() sift.Decoder {
+	var n float64
+	return decoderFunc(func() (sift.Value, error) {
+		n++
+		return sift.ToValue(n - 1)
+	})
+}
+
+type decoderFunc func() (sift.Value, error)
+
+func (f decoderFunc) Decode() (sift.Value, error) { return f() }
+
+func TestIterator(t *testing.T) {
+	for _, tc := range []struct {
+		program string
+		want    string
+	}{
+		{program: `first(.[])`, want: "0"},
+		{program: `[limit(3; .[])]`, want: "[0,1,2]"},
+		{program: `[limit(2; .[]?)]`, want: "[0,1]"},
+	} {
+		t.Run(tc.program, func(t *testing.T) {
+			f, err := jq.Compile("iterator", tc.program)
+			if err != nil {
+				t.Fatal(err)
+			}
+			vs, err := f(naturals{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(vs) != 1 {
+				t.Fatalf("got %d values; want 1", len(vs))
+			}
+			if got := valueString(t, vs[0]); got != tc.want {
+				t.Errorf("got %s; want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	opts := jq.CompileOptions{DisableEnv: true, DisableInput: true}
+	for _, program := range []string{`env`, `input`, `[inputs]`} {
+		if _, err := jq.CompileWithOptions("disabled", program, opts); err == nil || !strings.Contains(err.Error(), "is disabled") {
+			t.Errorf("%s: got error %v; want error with %q", program, err, "is disabled")
+		}
+	}
+}
+
+func TestInput(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader("1 2 3 4 5"))
+	f, err := jq.CompileWithOptions("input", `[., input]`, jq.CompileOptions{Input: dec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &strings.Builder{}
+	err = sift.Sift(dec, f, json.NewEncoder(w))
+	if err == nil || !strings.Contains(err.Error(), "no more inputs") {
+		t.Errorf("got error %v; want error with %q", err, "no more inputs")
+	}
+	if got, want := w.String(), "[1,2]\n[3,4]\n"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	dec = json.NewDecoder(strings.NewReader("1 2 3"))
+	f, err = jq.CompileWithOptions("inputs", `[., inputs]`, jq.CompileOptions{Input: dec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Reset()
+	if err := sift.Sift(dec, f, json.NewEncoder(w)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := w.String(), "[1,2,3]\n"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestStack(t *testing.T) {
+	program := `def get(k): .[k];
+def getUsers: get("users") | get(0);
+.a | getUsers`
+	f, err := jq.Compile("prog.jq", program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f(sift.Must(sift.ToValue(map[string]interface{}{"a": map[string]interface{}{"users": "x"}})))
+	var rerr *jq.RuntimeError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("got error %v; want *jq.RuntimeError", err)
+	}
+	want := `prog.jq:1:14: cannot index value x with value 0
+	in get/1 called at prog.jq:2:30
+	in getUsers/0 called at prog.jq:3:6`
+	if got := rerr.Error(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Deep recursion repeats the same frame, which is printed once.
+	f, err = jq.CompileWithOptions("rec.jq", `def f: 1 + f; f`, jq.CompileOptions{MaxDepth: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f(sift.NullValue)
+	want = `rec.jq:1:12: maximum recursion depth exceeded (100)
+	in f/0 called at rec.jq:1:12 (99 times)
+	in f/0 called at rec.jq:1:15`
+	if err == nil || err.Error() != want {
+		t.Errorf("got:\n%v\nwant:\n%s", err, want)
+	}
+
+	// Long stacks without repeated frames are elided in the middle.
+	rerr = &jq.RuntimeError{Err: errors.New("fail")}
+	for i := 0; i < 100; i++ {
+		name := []string{"a/0", "b/0"}[i%2]
+		rerr.Stack = append(rerr.Stack, jq.Frame{Function: name, Position: gotoken.Position{Filename: "x.jq", Line: i + 1, Column: 1}})
+	}
+	lines := strings.Split(rerr.Error(), "\n")
+	if len(lines) != 22 || !strings.Contains(lines[11], "80 frames elided") || !strings.Contains(lines[21], "x.jq:100:1") {
+		t.Errorf("got:\n%s\nwant 20 frames with 80 elided", rerr.Error())
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for _, test := range []struct {
+		name, src, want string
+	}{
+		{name: "identity", src: ` . `, want: `.`},
+		{name: "fields", src: `.a.b?."c d"`, want: `.a.b?."c d"`},
+		{name: "index", src: `.[0][1:][:2][]?`, want: `.[0][1:][:2][]?`},
+		{name: "literal_field", src: `"x".a`, want: `("x").a`},
+		{name: "try", src: `(.a)?`, want: `(.a)?`},
+		{name: "try_catch", src: `try .a catch (. + 1) | try error`, want: `(try .a catch (. + 1)) | (try error)`},
+		{name: "precedence", src: `(1+2)*3, 1+2*3 | . - (1 - 2)`, want: `(1 + 2) * 3, 1 + 2 * 3 | . - (1 - 2)`},
+		{name: "pipe_assoc", src: `(1 | 2) | 3`, want: `(1 | 2) | 3`},
+		{name: "and_or", src: `true and (false or true)`, want: `true and (false or true)`},
+		{name: "neg", src: `-(.a) + -1`, want: `-(.a) + -1`},
+		{name: "array", src: `[1,2] , []`, want: `[1, 2], []`},
+		{name: "object", src: `{a: 1, "b c": 2, (.k): (3, 4), and: 5}`, want: `{a: 1, "b c": 2, (.k): (3, 4), and: 5}`},
+		{name: "call", src: `f(1;2) | g`, want: `f(1; 2) | g`},
+		{name: "as", src: `. as [$a, {b: $b, $c}] ?// $a | $a`, want: `. as [$a, {b: $b, $c}] ?// $a | $a`},
+		{name: "as_operand", src: `1 + (2 as $x | $x)`, want: `1 + (2 as $x | $x)`},
+		{name: "def", src: `def f(g; $x): g + $x; f(.; 1)`, want: `def f(g; $x): g + $x; f(.; 1)`},
+		{name: "import", src: `import "a" as a; include "b"; a::f`, want: `import "a" as a; include "b"; a::f`},
+		{name: "string", src: `"a\"b\n"`, want: `"a\"b\n"`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fset := gotoken.NewFileSet()
+			f, err := jq.Parse(fset, test.name, test.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := jq.Format(f)
+			if got != test.want {
+				t.Errorf("got %s; want %s", got, test.want)
+			}
+
+			// Formatting should be stable.
+			f, err = jq.Parse(fset, test.name, got)
+			if err != nil {
+				t.Fatalf("parsing formatted program: %v", err)
+			}
+			if again := jq.Format(f); again != got {
+				t.Errorf("formatted again: got %s; want %s", again, got)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	fset := gotoken.NewFileSet()
+	f, err := jq.Parse(fset, "prog.jq", `def double: . * 2;
+.items[] | double`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rewrite calls to double so they triple instead.
+	var calls []string
+	jq.Inspect(f, func(n jq.Node) bool {
+		if call, ok := n.(*jq.Call); ok {
+			calls = append(calls, fmt.Sprintf("%s at %s", call.Name, fset.Position(call.Pos())))
+		}
+		if def, ok := n.(*jq.FuncDef); ok && def.Name == "double" {
+			def.Body.(*jq.Binary).Y = &jq.Literal{Value: sift.Must(sift.ToValue(3))}
+		}
+		return true
+	})
+	if want := []string{"double at prog.jq:2:12"}; fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Errorf("got calls %v; want %v", calls, want)
+	}
+	if got, want := jq.Format(f), `def double: . * 3; .items[] | double`; got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+
+	prog, err := jq.CompileFile(fset, f, jq.CompileOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs, err := prog.Filter(nil)(sift.Must(sift.ToValue(map[string]interface{}{"items": []interface{}{1, 2}})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, v := range vs {
+		got = append(got, valueString(t, v))
+	}
+	if want := []string{"3", "6"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+
+	if _, err := jq.Parse(fset, "bad.jq", `.a |`); err == nil {
+		t.Error("parsing invalid program: got success; want error")
+	}
+	if _, err := jq.Parse(fset, "undefined.jq", `undefined_function`); err != nil {
+		t.Errorf("parsing program with undefined function: %v", err)
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	for _, test := range []struct {
+		name, src string
+		want      []string
+	}{
+		{
+			name: "one",
+			src:  `.a | (1 2)`,
+			want: []string{"one:1:9: expected ); got number"},
+		}, {
+			name: "pipes",
+			src: `.a +
+| [1
+2]
+| {a 1}
+| .b`,
+			want: []string{
+				"pipes:2:1: expected expression; got |",
+				"pipes:3:1: expected , or ]; got number",
+				"pipes:4:6: expected :; got number",
+			},
+		}, {
+			name: "defs",
+			src: `def f: 1 1;
+def g(: 2;
+f`,
+			want: []string{
+				"defs:1:10: expected ;; got number",
+				"defs:2:7: expected parameter name; got :",
+			},
+		}, {
+			name: "scan",
+			src: `.a +
+"unterminated`,
+			want: []string{
+				"scan:2:1: string literal not terminated",
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := jq.Compile(test.name, test.src)
+			var errs jq.ErrorList
+			if !errors.As(err, &errs) {
+				t.Fatalf("got error %v; want jq.ErrorList", err)
+			}
+			var got []string
+			for _, e := range errs {
+				got = append(got, e.Error())
+			}
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("got errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	cov := jq.NewCoverage()
+	program := `def unused: 1;
+.n as [$x] ?// $x | ($x and "yes") or .missing`
+	f, err := jq.CompileWithOptions("cover.jq", program, jq.CompileOptions{Coverage: cov})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []interface{}{1, 2} {
+		if _, err := f(sift.Must(sift.ToValue(map[string]interface{}{"n": n}))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if covered, total := cov.Covered(); covered != 7 || total != 9 {
+		t.Errorf("got %d of %d expressions covered; want 7 of 9", covered, total)
+	}
+	b := &strings.Builder{}
+	if err := cov.WriteReport(b); err != nil {
+		t.Fatal(err)
+	}
+	want := `coverage: 7 of 9 expressions (77.8%)
+cover.jq:1:13: not evaluated: 1
+cover.jq:2:39: not evaluated: .missing
+`
+	if got := b.String(); got != want {
+		t.Errorf("got report:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	var steps []string
+	opts := jq.CompileOptions{
+		Trace: func(s *jq.Step) error {
+			step := fmt.Sprintf("%d:%d %d %s", s.Position.Line, s.Position.Column, s.Depth, jq.Format(s.Node))
+			if _, ok := s.Node.(*jq.Var); ok {
+				for _, b := range s.Vars() {
+					step += fmt.Sprintf(" $%s=%s", b.Name, valueString(t, b.Value))
+				}
+			}
+			steps = append(steps, step)
+			return nil
+		},
+	}
+	f, err := jq.CompileWithOptions("trace", `def f($x): $x + 1; . as $a | f($a)`, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f(sift.Must(sift.ToValue(1))); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`1:1 0 def f($x): $x + 1; . as $a | f($a)`,
+		`1:20 1 . as $a | f($a)`,
+		`1:20 2 .`,
+		`1:30 2 f($a)`,
+		`1:32 3 $a $a=1`,
+		`1:12 3 $x + 1`,
+		`1:12 4 $x $x=1`,
+		`1:17 4 1`,
+	}
+	if got := strings.Join(steps, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("got steps:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+
+	// Errors returned by Trace stop evaluation, even within ?.
+	errStop := errors.New("stop")
+	opts.Trace = func(*jq.Step) error { return errStop }
+	f, err = jq.CompileWithOptions("stop", `(.a)?`, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f(sift.NullValue); !errors.Is(err, errStop) {
+		t.Errorf("got error %v; want %v", err, errStop)
+	}
+}
+
+func TestNow(t *testing.T) {
+	opts := jq.CompileOptions{Now: func() time.Time { return time.Unix(1500000000, 500000000) }}
+	f, err := jq.CompileWithOptions("now", "now", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs, err := f(sift.NullValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != 1 {
+		t.Fatalf("got %d values; want 1", len(vs))
+	}
+	if got, ok := sift.AsFloat64(vs[0]); !ok || got != 1500000000.5 {
+		t.Errorf("got %v; want 1500000000.5", vs[0])
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	names := jq.Builtins()
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("names are not sorted: %v", names)
+	}
+	for _, want := range []string{"builtins/0", "combinations/0", "combinations/1", "dig/1+", "getpath/1", "have_literal_numbers/0", "walk/1"} {
+		i := sort.SearchStrings(names, want)
+		if i == len(names) || names[i] != want {
+			t.Errorf("%s not found in %v", want, names)
+		}
+	}
+
+	f, err := jq.Compile("builtins", "builtins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs, err := f(sift.NullValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != 1 {
+		t.Fatalf("got %d values; want 1", len(vs))
+	}
+	want := make([]sift.Value, len(names))
+	for i, name := range names {
+		want[i] = sift.Must(sift.ToValue(name))
+	}
+	if !sift.Equal(vs[0], sift.Must(sift.ToValue(want))) {
+		t.Errorf("got %v; want %v", vs[0], want)
+	}
+}
+
+func TestIconv(t *testing.T) {
+	f, err := jq.Compile("iconv", `iconv("latin1"), iconv("windows-1252")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs, err := f(sift.Must(sift.ToValue("caf\xe9 \x80")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"café \u0080", "café €"}
+	if len(vs) != len(want) {
+		t.Fatalf("got %d values; want %d", len(vs), len(want))
+	}
+	for i := range want {
+		if got, _ := sift.AsString(vs[i]); got != want[i] {
+			t.Errorf("got %q; want %q", got, want[i])
+		}
+	}
+
+	f, err = jq.Compile("iconv_unknown", `iconv("ebcdic")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f(sift.Must(sift.ToValue("a"))); err == nil || !strings.Contains(err.Error(), "unsupported encoding") {
+		t.Errorf("got error %v; want unsupported encoding", err)
+	}
+}
@@ -0,0 +1,114 @@
+package jq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Errors returned when a program exceeds a limit set in CompileOptions.
+// Limit errors are not caught by try, ?, or ?//, so a program cannot
+// ignore them. Use errors.Is to check for them.
+var (
+	ErrRecursionDepth = errors.New("maximum recursion depth exceeded")
+	ErrTooManyOutputs = errors.New("too many outputs")
+	ErrTimeout        = errors.New("evaluation timed out")
+)
+
+// isUncatchable returns whether err must not be caught by try, ?, or ?//.
+// This is true of limit errors, errors from a canceled Host.Context, and
+// errors returned by a Trace function.
+func isUncatchable(err error) bool {
+	var terr *traceError
+	return errors.Is(err, ErrRecursionDepth) ||
+		errors.Is(err, ErrTooManyOutputs) ||
+		errors.Is(err, ErrTimeout) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &terr)
+}
+
+// limits holds the resource limits for evaluating a program with one input.
+type limits struct {
+	maxDepth int
+	timeout  time.Duration
+	deadline time.Time
+
+	// ctx is the Host's context, or nil if there isn't one. Evaluation
+	// stops when it's done.
+	ctx context.Context
+
+	// steps counts instructions executed, so the deadline and context are
+	// polled periodically in loops that don't call functions.
+	steps int
+}
+
+// pollInterval is the number of instructions executed between polls of
+// the deadline and context.
+const pollInterval = 1024
+
+func newLimits(opts *CompileOptions, h *Host) *limits {
+	l := &limits{maxDepth: opts.MaxDepth, timeout: opts.Timeout}
+	if l.timeout > 0 {
+		l.deadline = time.Now().Add(l.timeout)
+	}
+	if h != nil && h.Context != nil && h.Context.Done() != nil {
+		l.ctx = h.Context
+	}
+	return l
+}
+
+// check returns an error if a function call at the given depth would exceed
+// the limits.
+func (l *limits) check(depth int) error {
+	if l.maxDepth > 0 && depth > l.maxDepth {
+		return fmt.Errorf("%w (%d)", ErrRecursionDepth, l.maxDepth)
+	}
+	return l.poll()
+}
+
+// step counts an instruction. Every pollInterval instructions, it returns
+// an error if the deadline has passed or the context is done.
+func (l *limits) step() error {
+	if l.steps++; l.steps%pollInterval != 0 {
+		return nil
+	}
+	return l.poll()
+}
+
+// poll returns an error if the deadline has passed or the context is done.
+func (l *limits) poll() error {
+	if l.timeout > 0 && time.Now().After(l.deadline) {
+		return fmt.Errorf("%w after %v", ErrTimeout, l.timeout)
+	}
+	if l.ctx != nil {
+		select {
+		case <-l.ctx.Done():
+			return fmt.Errorf("evaluation stopped: %w", l.ctx.Err())
+		default:
+		}
+	}
+	return nil
+}
+
+// capability is something a builtin needs access to that may be disabled
+// in CompileOptions.
+type capability int
+
+const (
+	noCapability capability = iota
+	capEnv
+	capInput
+)
+
+func (opts *CompileOptions) disabled(c capability) bool {
+	switch c {
+	case capEnv:
+		return opts.DisableEnv
+	case capInput:
+		return opts.DisableInput
+	default:
+		return false
+	}
+}
@@ -0,0 +1,179 @@
+package jq
+
+import (
+	"fmt"
+	gotoken "go/token"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/ir"
+)
+
+func init() {
+	for op, fn := range binaryOps {
+		ir.RegisterOp(op.String(), fn)
+	}
+	ir.RegisterLanguage("jq", func(src string) (sift.Filter, error) {
+		return Compile("ir", src)
+	})
+	ir.RegisterLoader("jq", loadSource)
+}
+
+// Lower translates a program's syntax tree into the intermediate
+// representation defined by package ir. Identity, literals, field access,
+// iteration, pipes, commas, binary operators, and calls to select become ir
+// nodes, so tools can examine and optimize the program's structure. Other expressions are
+// kept as jq source in ir.Source nodes with language "jq", which this
+// package registers. If part of the program can't be compiled on its own,
+// for example, because it refers to a variable bound elsewhere, or if the
+// program defines or imports functions, the whole program is kept as
+// source. Each Source node's Value holds the syntax tree of its source, so
+// compiling a loaded tree doesn't parse the source again.
+//
+// Source nodes are compiled with default options, so a program that
+// depends on CompileOptions, like one that reads $vars or input, should be
+// compiled with CompileWithOptions instead.
+func Lower(f *File) *ir.Node {
+	if f.Body == nil && len(f.Defs) == 0 && len(f.Imports) == 0 {
+		return ir.Identity()
+	}
+	if len(f.Defs) == 0 && len(f.Imports) == 0 {
+		if n, ok := lower(f.Body); ok {
+			return n
+		}
+	}
+	return source(f)
+}
+
+// lower translates x. It returns false if x contains a subexpression that
+// can't be compiled on its own.
+func lower(x Expr) (*ir.Node, bool) {
+	switch x := x.(type) {
+	case *Identity:
+		return ir.Identity(), true
+
+	case *Literal:
+		return ir.Literal(x.Value), true
+
+	case *Paren:
+		return lower(x.X)
+
+	case *Field:
+		if !x.Optional {
+			return lowerPostfix(x.X, ir.Field(x.Name))
+		}
+
+	case *Iterate:
+		if !x.Optional {
+			return lowerPostfix(x.X, ir.Iterate())
+		}
+
+	case *Call:
+		if x.Name == "select" && len(x.Args) == 1 {
+			pred, ok := lower(x.Args[0])
+			if !ok {
+				return nil, false
+			}
+			return ir.Select(pred), true
+		}
+
+	case *Binary:
+		l, ok := lower(x.X)
+		if !ok {
+			return nil, false
+		}
+		r, ok := lower(x.Y)
+		if !ok {
+			return nil, false
+		}
+		switch x.Op {
+		case OpPipe:
+			return irPipe(l, r), true
+		case OpComma:
+			return ir.Comma(l, r), true
+		default:
+			return ir.Binary(x.Op.String(), l, r), true
+		}
+	}
+
+	// Keep other expressions as source, as long as they don't refer to
+	// variables, which may be bound outside the expression.
+	ok := true
+	Inspect(x, func(n Node) bool {
+		if _, isVar := n.(*Var); isVar {
+			ok = false
+		}
+		return ok
+	})
+	if !ok {
+		return nil, false
+	}
+	return source(x), true
+}
+
+// source returns a Source node for x. The node's Value is the syntax tree
+// of its source text, which is parsed again so that positions in the tree
+// refer to that text.
+func source(x Node) *ir.Node {
+	src := Format(x)
+	n := ir.Source("jq", src)
+	fset := gotoken.NewFileSet()
+	f, err := Parse(fset, "ir", src)
+	if err != nil {
+		// The formatter should only produce valid programs, but if not,
+		// the error is reported when the node is compiled.
+		return n
+	}
+	fset.Iterate(func(tf *gotoken.File) bool {
+		n.Value, err = encodeSyntax(tf, f)
+		return false
+	})
+	if err != nil {
+		n.Value = nil
+	}
+	return n
+}
+
+// loadSource compiles a Source node's syntax tree, encoded in v by source,
+// without parsing src. It's equivalent to compiling src with Compile.
+func loadSource(src string, v sift.Value) (sift.Filter, error) {
+	fset := gotoken.NewFileSet()
+	tf := fset.AddFile("ir", -1, len(src))
+	tf.SetLinesForContent([]byte(src))
+	f, err := decodeSyntax(tf, v)
+	if err != nil {
+		return nil, fmt.Errorf("loading jq syntax tree: %w", err)
+	}
+	prog, err := CompileFile(fset, f, CompileOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return prog.Filter(nil), nil
+}
+
+// lowerPostfix translates a postfix expression like x.a, where op is the
+// node for the postfix operation. base is nil if the operation applies to
+// the input.
+func lowerPostfix(base Expr, op *ir.Node) (*ir.Node, bool) {
+	if base == nil {
+		return op, true
+	}
+	b, ok := lower(base)
+	if !ok {
+		return nil, false
+	}
+	return irPipe(b, op), true
+}
+
+// irPipe returns a pipe node that applies l, then r. Operands that are
+// themselves pipes are flattened.
+func irPipe(l, r *ir.Node) *ir.Node {
+	var args []*ir.Node
+	for _, n := range []*ir.Node{l, r} {
+		if n.Op == ir.OpPipe {
+			args = append(args, n.Args...)
+		} else {
+			args = append(args, n)
+		}
+	}
+	return ir.Pipe(args...)
+}
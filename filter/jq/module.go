@@ -0,0 +1,88 @@
+package jq
+
+import (
+	"fmt"
+	gotoken "go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// A module is a file containing function definitions that may be imported
+// by a jq program or by other modules.
+type module struct {
+	// defs is the list of functions defined in the module, in order.
+	defs []*function
+}
+
+// A loader locates, parses, and caches modules imported by a program.
+type loader struct {
+	fset    *gotoken.FileSet
+	opts    *CompileOptions
+	modules map[string]*module
+	loading map[string]bool
+}
+
+func newLoader(fset *gotoken.FileSet, opts *CompileOptions) *loader {
+	return &loader{
+		fset:    fset,
+		opts:    opts,
+		modules: make(map[string]*module),
+		loading: make(map[string]bool),
+	}
+}
+
+// load returns the module named by path. dir is the directory containing
+// the file with the import or include directive; it is searched before the
+// directories in the search path.
+func (l *loader) load(path, dir string) (*module, error) {
+	file, err := l.find(path, dir)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := l.modules[file]; ok {
+		return m, nil
+	}
+	if l.loading[file] {
+		return nil, fmt.Errorf("import cycle involving module %q", path)
+	}
+	l.loading[file] = true
+	defer delete(l.loading, file)
+
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	f, err := parseFile(l.fset, file, src, true)
+	if err != nil {
+		return nil, err
+	}
+	c := newCompiler(l.fset, l, l.opts, filepath.Dir(file))
+	m := c.compileModule(f)
+	l.modules[file] = m
+	return m, nil
+}
+
+// find returns the name of the file containing the module named by path.
+// For each directory in the search path, the files dir/path.jq and
+// dir/path/base.jq are tried, where base is the last component of path.
+func (l *loader) find(path, dir string) (string, error) {
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("module path %q must be relative", path)
+	}
+	dirs := l.opts.SearchPath
+	if dir != "" {
+		dirs = append([]string{dir}, dirs...)
+	}
+	for _, d := range dirs {
+		for _, file := range []string{
+			filepath.Join(d, path+".jq"),
+			filepath.Join(d, path, filepath.Base(path)+".jq"),
+		} {
+			if fi, err := os.Stat(file); err == nil && !fi.IsDir() {
+				return filepath.Abs(file)
+			}
+		}
+	}
+	return "", fmt.Errorf("module %q not found", path)
+}
@@ -5,10 +5,90 @@ import (
 	gotoken "go/token"
 	"math"
 	"strconv"
+	"strings"
 
 	"go.jayconrod.com/sift"
 )
 
+// Parse parses a jq program and returns its syntax tree. Positions of nodes
+// in the tree are recorded in fset. Modules named in import and include
+// directives are not loaded; use CompileFile to compile the tree.
+//
+// If the program has syntax errors, Parse returns a nil *File and an
+// ErrorList. The parser recovers from most errors, so the list may report
+// several.
+func Parse(fset *gotoken.FileSet, name, src string) (*File, error) {
+	return parseFile(fset, name, []byte(src), false)
+}
+
+// parseFile parses a program or, if module is true, a module.
+func parseFile(fset *gotoken.FileSet, name string, src []byte, module bool) (f *File, err error) {
+	tf := fset.AddFile(name, -1, len(src))
+	p := newParser(newScanner(tf, src))
+	defer func() {
+		if r := recover(); r != nil {
+			switch r := r.(type) {
+			case bailout:
+			case scanError:
+				p.errors = append(p.errors, &SyntaxError{Position: r.position, Msg: r.message})
+			default:
+				panic(r)
+			}
+		}
+		if len(p.errors) > 0 {
+			f, err = nil, p.errors
+		}
+	}()
+	if module {
+		return p.parseModule(), nil
+	}
+	return p.parse(), nil
+}
+
+// A SyntaxError is an error in the text of a program.
+type SyntaxError struct {
+	Position gotoken.Position
+	Msg      string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Position, e.Msg)
+}
+
+// ErrorList is a list of syntax errors, in the order they were found.
+// Parse and Compile return an ErrorList when a program has syntax errors.
+type ErrorList []*SyntaxError
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	case 2:
+		return fmt.Sprintf("%s (and 1 more error)", l[0])
+	default:
+		return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+	}
+}
+
+// maxErrors is the number of syntax errors after which the parser stops.
+const maxErrors = 10
+
+// bailout is panicked by the parser after recording a syntax error. It is
+// recovered by parsePipeElem.
+type bailout struct{}
+
+// badExpr stands in for an expression with a syntax error. It never
+// appears in a tree returned by Parse, since Parse returns no tree when
+// there are errors.
+type badExpr struct {
+	from gotoken.Pos
+}
+
+func (x *badExpr) Pos() gotoken.Pos { return x.from }
+func (*badExpr) exprNode()          {}
+
 type parser struct {
 	file    *gotoken.File
 	scanner *scanner
@@ -18,6 +98,12 @@ type parser struct {
 	lit string
 
 	initScanErr error
+
+	// nest is the number of brackets, braces, and parentheses scanned and
+	// not yet closed.
+	nest int
+
+	errors ErrorList
 }
 
 func newParser(s *scanner) *parser {
@@ -29,76 +115,245 @@ func newParser(s *scanner) *parser {
 	return p
 }
 
-func (p *parser) parse() sift.Filter {
+func (p *parser) parse() *File {
 	if p.initScanErr != nil {
 		panic(p.initScanErr)
 	}
-	if p.tok == eof {
-		return id
+	f := &File{Name: p.file.Name()}
+	f.Imports = p.parseDirectives()
+	if p.tok != eof {
+		f.Body = p.parseExpr()
 	}
-	f := p.parseExpr()
 	if p.tok != eof {
 		p.panicf(p.pos, "junk at end of file")
 	}
 	return f
 }
 
-func (p *parser) parseExpr() sift.Filter {
-	return p.parseBinary(binaryLevels)
+// parseModule parses a module file, which may contain import and include
+// directives followed by function definitions.
+func (p *parser) parseModule() *File {
+	if p.initScanErr != nil {
+		panic(p.initScanErr)
+	}
+	f := &File{Name: p.file.Name()}
+	f.Imports = p.parseDirectives()
+	for p.tok == def {
+		f.Defs = append(f.Defs, p.parseFuncDef())
+	}
+	if p.tok != eof {
+		p.panicf(p.pos, "expected %v; got %v", def, p.tok)
+	}
+	return f
+}
+
+// parseDirectives parses import and include directives at the beginning of
+// a file.
+func (p *parser) parseDirectives() []*Import {
+	var imports []*Import
+	for p.tok == import_ || p.tok == include {
+		pos, tok, _ := p.scan()
+		if p.tok != str {
+			p.panicf(p.pos, "expected module path; got %v", p.tok)
+		}
+		_, _, path := p.scan()
+		imp := &Import{ImportPos: pos, Path: path}
+		if tok == import_ {
+			if p.tok != as {
+				p.panicf(p.pos, "expected %v; got %v", as, p.tok)
+			}
+			p.scan()
+			if p.tok != identifier || strings.Contains(p.lit, "::") {
+				p.panicf(p.pos, "expected module name; got %v", p.tok)
+			}
+			_, _, imp.Name = p.scan()
+		}
+		if p.tok != semicolon {
+			p.panicf(p.pos, "expected %v; got %v", semicolon, p.tok)
+		}
+		p.scan()
+		imports = append(imports, imp)
+	}
+	return imports
+}
+
+func (p *parser) parseExpr() Expr {
+	return p.parsePipe(true)
+}
+
+// parsePipe parses a pipeline of expressions. Function definitions may
+// appear before any expression in the pipeline; their scope extends to
+// the end of the pipeline. If commaOk is false, the expressions in the
+// pipeline may not contain commas (unless they are nested within other
+// expressions).
+func (p *parser) parsePipe(commaOk bool) Expr {
+	if p.tok == def {
+		fn := p.parseFuncDef()
+		rest := p.parsePipe(commaOk)
+		return &FuncDefExpr{Def: fn, Rest: rest}
+	}
+
+	x := p.parsePipeElem(commaOk)
+	if p.tok != pipe {
+		return x
+	}
+	pos, _, _ := p.scan()
+	y := p.parsePipe(commaOk)
+	return &Binary{X: x, OpPos: pos, Op: OpPipe, Y: y}
+}
+
+// parsePipeElem parses an expression between pipes. If there is a syntax
+// error, the parser skips to the end of the expression, and a badExpr is
+// returned, so that errors later in the program may be reported, too.
+func (p *parser) parsePipeElem(commaOk bool) (x Expr) {
+	from, nest := p.pos, p.nest
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(bailout); !ok || len(p.errors) >= maxErrors {
+				panic(r)
+			}
+			p.skip(nest, true)
+			x = &badExpr{from: from}
+		}
+	}()
+
+	levels := binaryLevels
+	if !commaOk {
+		levels = binaryLevelsWithoutComma
+	}
+	return p.parseBinary(levels, commaOk)
+}
+
+// skip advances to the next semicolon or closing bracket at nesting level
+// nest, which likely ends the expression that had a syntax error. If
+// pipeEnds is true, skip also stops at the next pipe at that level.
+func (p *parser) skip(nest int, pipeEnds bool) {
+	for p.tok != eof {
+		if p.nest <= nest {
+			switch p.tok {
+			case rightParen, rightBracket, rightBrace, semicolon:
+				return
+			case pipe:
+				if pipeEnds {
+					return
+				}
+			}
+		}
+		p.scan()
+	}
+}
+
+// parseFuncDef parses a function definition like "def f(g; $x): body;".
+// If there is a syntax error, the parser skips past the semicolon that
+// likely ends the definition.
+func (p *parser) parseFuncDef() (fn *FuncDef) {
+	defPos, nest := p.pos, p.nest
+	inBody := false
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(bailout); !ok || len(p.errors) >= maxErrors {
+				panic(r)
+			}
+			if !inBody {
+				// The parameter list may be unclosed, so skip to the
+				// next semicolon at any level.
+				p.nest = nest
+			}
+			p.skip(nest, false)
+			if p.tok == semicolon {
+				p.scan()
+			}
+			fn = &FuncDef{DefPos: defPos, Body: &badExpr{from: defPos}}
+		}
+	}()
+
+	p.scan() // def
+	if p.tok != identifier && !p.tok.isKeyword() {
+		p.panicf(p.pos, "expected function name; got %v", p.tok)
+	}
+	namePos, _, name := p.scan()
+	if strings.Contains(name, "::") {
+		p.panicf(namePos, "function name may not contain ::")
+	}
+	fn = &FuncDef{DefPos: defPos, Name: name}
+	if p.tok == leftParen {
+		p.scan()
+		for {
+			switch p.tok {
+			case identifier, varIdentifier:
+				pos, tok, paramName := p.scan()
+				fn.Params = append(fn.Params, &Param{
+					NamePos:  pos,
+					Name:     paramName,
+					Variable: tok == varIdentifier,
+				})
+			default:
+				p.panicf(p.pos, "expected parameter name; got %v", p.tok)
+			}
+			if p.tok == semicolon {
+				p.scan()
+			} else if p.tok == rightParen {
+				p.scan()
+				break
+			} else {
+				p.panicf(p.pos, "expected %v or %v; got %v", semicolon, rightParen, p.tok)
+			}
+		}
+	}
+	if p.tok != colon {
+		p.panicf(p.pos, "expected %v; got %v", colon, p.tok)
+	}
+	p.scan()
+
+	inBody = true
+	fn.Body = p.parseExpr()
+
+	if p.tok != semicolon {
+		p.panicf(p.pos, "expected %v; got %v", semicolon, p.tok)
+	}
+	p.scan()
+	return fn
+}
+
+func funcKey(name string, arity int) string {
+	return fmt.Sprintf("%s/%d", name, arity)
 }
 
 type binaryLevel []struct {
-	tok     token
-	combine func(x, y sift.Filter) sift.Filter
+	tok token
+	op  Op
 }
 
 var binaryLevels = []binaryLevel{
-	{
-		{
-			tok:     pipe,
-			combine: sift.Compose,
-		},
-	}, {
-		{
-			tok:     comma,
-			combine: sift.Concat,
-		},
-	}, {
-		{
-			tok:     plus,
-			combine: binop(add),
-		}, {
-			tok:     minus,
-			combine: binop(sub),
-		},
-	}, {
-		{
-			tok:     star,
-			combine: numOp(func(x, y float64) float64 { return x * y }),
-		}, {
-			tok:     slash,
-			combine: numOp(func(x, y float64) float64 { return x / y }),
-		}, {
-			tok:     percent,
-			combine: numOp(math.Mod),
-		},
-	},
-}
-
-var binaryLevelsWithoutComma = append(binaryLevels[:1:1], binaryLevels[2:]...)
-
-func (p *parser) parseBinary(levels []binaryLevel) sift.Filter {
+	{{comma, OpComma}},
+	{{or, OpOr}},
+	{{and, OpAnd}},
+	{{plus, OpAdd}, {minus, OpSub}},
+	{{star, OpMul}, {slash, OpDiv}, {percent, OpMod}},
+}
+
+var binaryLevelsWithoutComma = binaryLevels[1:]
+
+// parseBinary parses an expression with binary operators in levels. The
+// operators in each level have higher precedence than those in the levels
+// before it. commaOk is passed to parsePipe when parsing the body of an
+// "as" binding, which extends to the end of the enclosing pipeline.
+func (p *parser) parseBinary(levels []binaryLevel, commaOk bool) Expr {
 	if len(levels) == 0 {
-		return p.parsePrimaryWithPostfix()
+		x := p.parsePrimaryWithPostfix()
+		if p.tok == as {
+			return p.parseBinding(x, commaOk)
+		}
+		return x
 	}
-	x := p.parseBinary(levels[1:])
+	x := p.parseBinary(levels[1:], commaOk)
 Terms:
 	for {
 		for _, op := range levels[0] {
 			if p.tok == op.tok {
-				p.scan()
-				y := p.parseBinary(levels[1:])
-				x = op.combine(x, y)
+				pos, _, _ := p.scan()
+				y := p.parseBinary(levels[1:], commaOk)
+				x = &Binary{X: x, OpPos: pos, Op: op.op, Y: y}
 				continue Terms
 			}
 		}
@@ -107,21 +362,104 @@ Terms:
 	return x
 }
 
-func (p *parser) parsePrimaryWithPostfix() sift.Filter {
-	f := p.parsePrimary()
-	return p.parsePostfixOrDot(f, false)
+// parseBinding parses a variable binding like "source as $x | body".
+// Several patterns may be given, separated by ?//.
+func (p *parser) parseBinding(source Expr, commaOk bool) Expr {
+	pos, _, _ := p.scan() // as
+	x := &As{X: source, AsPos: pos}
+	for {
+		x.Patterns = append(x.Patterns, p.parsePattern())
+		if p.tok != questionAlt {
+			break
+		}
+		p.scan()
+	}
+	if p.tok != pipe {
+		p.panicf(p.pos, "expected %v; got %v", pipe, p.tok)
+	}
+	p.scan()
+	x.Body = p.parsePipe(commaOk)
+	return x
 }
 
-func (p *parser) parsePrimary() sift.Filter {
+// parsePattern parses a destructuring pattern.
+func (p *parser) parsePattern() Pattern {
+	switch p.tok {
+	case varIdentifier:
+		pos, _, name := p.scan()
+		return &VarPattern{NamePos: pos, Name: name}
+
+	case leftBracket:
+		pos, _, _ := p.scan()
+		pat := &ArrayPattern{Lbrack: pos}
+		for {
+			pat.Elems = append(pat.Elems, p.parsePattern())
+			if p.tok == comma {
+				p.scan()
+			} else if p.tok == rightBracket {
+				p.scan()
+				return pat
+			} else {
+				p.panicf(p.pos, "expected %v or %v; got %v", comma, rightBracket, p.tok)
+			}
+		}
+
+	case leftBrace:
+		pos, _, _ := p.scan()
+		pat := &ObjectPattern{Lbrace: pos}
+		for {
+			entry := &ObjectPatternEntry{}
+			if p.tok == varIdentifier {
+				pos, _, name := p.scan()
+				entry.Var = &VarPattern{NamePos: pos, Name: name}
+			} else {
+				if p.tok == identifier || p.tok == str || p.tok.isKeyword() {
+					entry.Key = p.parseKeyLiteral()
+				} else if p.tok == leftParen {
+					entry.Key = p.parseGroup()
+				} else {
+					p.panicf(p.pos, "expected attribute name or variable; got %v", p.tok)
+				}
+				if p.tok != colon {
+					p.panicf(p.pos, "expected %v; got %v", colon, p.tok)
+				}
+			}
+			if p.tok == colon {
+				p.scan()
+				entry.Value = p.parsePattern()
+			}
+			pat.Entries = append(pat.Entries, entry)
+			if p.tok == comma {
+				p.scan()
+			} else if p.tok == rightBrace {
+				p.scan()
+				return pat
+			} else {
+				p.panicf(p.pos, "expected %v or %v; got %v", comma, rightBrace, p.tok)
+			}
+		}
+
+	default:
+		p.panicf(p.pos, "expected pattern; got %v", p.tok)
+		return nil
+	}
+}
+
+func (p *parser) parsePrimaryWithPostfix() Expr {
+	x := p.parsePrimary()
+	return p.parsePostfixOrDot(x, p.pos, false)
+}
+
+func (p *parser) parsePrimary() Expr {
 	if p.tok == null {
-		p.scan()
-		return sift.Literal(sift.Must(sift.ToValue(nil)))
+		pos, _, _ := p.scan()
+		return &Literal{ValuePos: pos, Value: sift.Must(sift.ToValue(nil))}
 	} else if p.tok == true_ {
-		p.scan()
-		return sift.Literal(sift.Must(sift.ToValue(true)))
+		pos, _, _ := p.scan()
+		return &Literal{ValuePos: pos, Value: sift.Must(sift.ToValue(true))}
 	} else if p.tok == false_ {
-		p.scan()
-		return sift.Literal(sift.Must(sift.ToValue(false)))
+		pos, _, _ := p.scan()
+		return &Literal{ValuePos: pos, Value: sift.Must(sift.ToValue(false))}
 	} else if p.tok == number {
 		n, err := strconv.ParseFloat(p.lit, 64)
 		if nerr, ok := err.(*strconv.NumError); ok && nerr.Err == strconv.ErrRange {
@@ -135,86 +473,138 @@ func (p *parser) parsePrimary() sift.Filter {
 		} else if err != nil {
 			p.panicf(p.pos, "invalid number: %v", err)
 		}
-		p.scan()
-		return sift.Literal(sift.Must(sift.ToValue(n)))
+		pos, _, _ := p.scan()
+		return &Literal{ValuePos: pos, Value: sift.Must(sift.ToValue(n))}
 	} else if p.tok == str {
-		s := p.lit
-		p.scan()
-		return sift.Literal(sift.Must(sift.ToValue(s)))
+		pos, _, s := p.scan()
+		return &Literal{ValuePos: pos, Value: sift.Must(sift.ToValue(s))}
 	} else if p.tok == dotDot {
-		p.scan()
-		return walk
+		pos, _, _ := p.scan()
+		return &Recurse{DotDot: pos}
 	} else if p.tok == minus {
-		p.scan()
-		f := p.parsePrimary()
-		return sift.Compose(f, sift.MapError(neg))
+		pos, _, _ := p.scan()
+		x := p.parsePrimary()
+		return &Neg{OpPos: pos, X: x}
 	} else if p.tok == leftBracket {
 		return p.parseArrayConstruct()
 	} else if p.tok == leftBrace {
 		return p.parseObjectConstruct()
 	} else if p.tok == dot {
 		dotOk := true
-		return p.parsePostfixOrDot(id, dotOk)
+		return p.parsePostfixOrDot(nil, p.pos, dotOk)
 	} else if p.tok == leftParen {
 		return p.parseGroup()
+	} else if p.tok == try_ {
+		return p.parseTry()
+	} else if p.tok == identifier {
+		return p.parseCall()
+	} else if p.tok == varIdentifier {
+		pos, _, name := p.scan()
+		return &Var{NamePos: pos, Name: name}
 	}
 	p.panicf(p.pos, "expected expression; got %v", p.tok)
 	return nil
 }
 
-func (p *parser) parseGroup() sift.Filter {
-	p.scan()
-	f := p.parseExpr()
+// parseTry parses an expression like try x catch h. As in jq, the body and
+// handler are postfix expressions, and the handler is optional.
+func (p *parser) parseTry() Expr {
+	pos, _, _ := p.scan() // try
+	x := &Try{TryPos: pos, X: p.parsePrimaryWithPostfix()}
+	if p.tok == catch_ {
+		p.scan()
+		x.Catch = p.parsePrimaryWithPostfix()
+	}
+	return x
+}
+
+func (p *parser) parseGroup() *Paren {
+	pos, _, _ := p.scan()
+	x := p.parseExpr()
 	if p.tok != rightParen {
 		p.panicf(p.pos, "expected %v; got %v", rightParen, p.tok)
 	}
 	p.scan()
-	return f
+	return &Paren{Lparen: pos, X: x}
+}
+
+func (p *parser) parseCall() Expr {
+	pos, _, name := p.scan()
+	call := &Call{NamePos: pos, Name: name}
+	if p.tok == leftParen {
+		p.scan()
+		for {
+			call.Args = append(call.Args, p.parseExpr())
+			if p.tok == semicolon {
+				p.scan()
+			} else if p.tok == rightParen {
+				p.scan()
+				break
+			} else {
+				p.panicf(p.pos, "expected %v or %v; got %v", semicolon, rightParen, p.tok)
+			}
+		}
+	}
+	return call
 }
 
-func (p *parser) parsePostfixOrDot(f sift.Filter, dotOk bool) sift.Filter {
+// parsePostfixOrDot parses field accesses, indices, and ? following x.
+// x is nil when parsing a path that starts with a dot; dotPos is the
+// position of that dot. If dotOk is true and the dot is not followed by
+// a field name, the dot is the identity.
+func (p *parser) parsePostfixOrDot(x Expr, dotPos gotoken.Pos, dotOk bool) Expr {
 	for {
 		switch p.tok {
 		case dot:
-			p.scan()
-			switch p.tok {
-			case identifier, str:
+			dotPos, _, _ := p.scan()
+			// Keywords are field names only when they immediately follow the
+			// dot, so ". as $x" is a binding, and ".as" is a field.
+			isField := p.tok == identifier || p.tok == str ||
+				p.tok.isKeyword() && p.pos == dotPos+1
+			if isField {
 				_, _, lit := p.scan()
+				field := &Field{X: x, Dot: dotPos, Name: lit}
 				if p.tok == questionMark {
 					p.scan()
-					f = sift.Compose(f, attrLit(lit, false))
-				} else {
-					f = sift.Compose(f, attrLit(lit, true))
-				}
-
-			default:
-				if !dotOk {
-					p.panicf(p.pos, "expected selector after %v; got %v", dot, p.tok)
+					field.Optional = true
 				}
+				x = field
+			} else if !dotOk {
+				p.panicf(p.pos, "expected selector after %v; got %v", dot, p.tok)
 			}
 
 		case leftBracket:
-			f = p.parseIndex(f)
+			x = p.parseIndex(x)
+
+		case questionMark:
+			pos, _, _ := p.scan()
+			if x == nil {
+				x = &Identity{Dot: dotPos}
+			}
+			x = &Try{X: x, Question: pos}
 
 		default:
-			return f
+			if x == nil {
+				x = &Identity{Dot: dotPos}
+			}
+			return x
 		}
 
 		dotOk = false
 	}
 }
 
-func (p *parser) parseIndex(base sift.Filter) sift.Filter {
-	p.scan() // leftBracket
-	var idx, begin, end sift.Filter
+func (p *parser) parseIndex(base Expr) Expr {
+	pos, _, _ := p.scan() // leftBracket
+	var idx, begin, end Expr
 	if p.tok == rightBracket {
 		p.scan()
-		f := iterate
+		x := &Iterate{X: base, Lbrack: pos}
 		if p.tok == questionMark {
 			p.scan()
-			f = iterateOpt
+			x.Optional = true
 		}
-		return sift.Compose(base, f)
+		return x
 	} else if p.tok == colon {
 		p.scan()
 		end = p.parseExpr()
@@ -233,27 +623,16 @@ func (p *parser) parseIndex(base sift.Filter) sift.Filter {
 	}
 	p.scan()
 	if idx != nil {
-		return sift.Binary(base, idx, index)
-	} else {
-		if begin == nil {
-			return sift.Binary(base, end, func(vbase, vend sift.Value) ([]sift.Value, error) {
-				return slice(vbase, nil, vend)
-			})
-		} else if end == nil {
-			return sift.Binary(base, begin, func(vbase, vbegin sift.Value) ([]sift.Value, error) {
-				return slice(vbase, vbegin, nil)
-			})
-		} else {
-			return sift.Ternary(base, begin, end, slice)
-		}
+		return &Index{X: base, Lbrack: pos, Index: idx}
 	}
+	return &Slice{X: base, Lbrack: pos, Begin: begin, End: end}
 }
 
-func (p *parser) parseArrayConstruct() sift.Filter {
-	p.scan() // leftBracket
-	var exprs []sift.Filter
+func (p *parser) parseArrayConstruct() Expr {
+	pos, _, _ := p.scan() // leftBracket
+	x := &Array{Lbrack: pos}
 	for p.tok != rightBracket {
-		exprs = append(exprs, p.parseExpr())
+		x.Elems = append(x.Elems, p.parseExpr())
 		if p.tok == comma {
 			p.scan()
 		} else if p.tok != rightBracket {
@@ -261,35 +640,18 @@ func (p *parser) parseArrayConstruct() sift.Filter {
 		}
 	}
 	p.scan() // rightBracket
-
-	return func(v sift.Value) ([]sift.Value, error) {
-		var results []sift.Value
-		for _, expr := range exprs {
-			rs, err := expr(v)
-			if err != nil {
-				return nil, err
-			}
-			results = append(results, rs...)
-		}
-		arr, err := sift.ToValue(results)
-		if err != nil {
-			return nil, err
-		}
-		return []sift.Value{arr}, nil
-	}
+	return x
 }
 
-func (p *parser) parseObjectConstruct() sift.Filter {
-	p.scan() // leftBrace
-
-	var attrs []sift.Filter
+func (p *parser) parseObjectConstruct() Expr {
+	pos, _, _ := p.scan() // leftBrace
+	x := &Object{Lbrace: pos}
 	for p.tok != rightBrace {
-		var key sift.Filter
-		if p.tok == identifier || p.tok == str {
-			_, _, id := p.scan()
-			key = sift.Literal(sift.Must(sift.ToValue(id)))
+		entry := &ObjectEntry{}
+		if p.tok == identifier || p.tok == str || p.tok.isKeyword() {
+			entry.Key = p.parseKeyLiteral()
 		} else if p.tok == leftParen {
-			key = p.parseGroup()
+			entry.Key = p.parseGroup()
 		} else {
 			p.panicf(p.pos, "expected attribute name or %v; got %v", rightBrace, p.tok)
 		}
@@ -299,8 +661,8 @@ func (p *parser) parseObjectConstruct() sift.Filter {
 		}
 		p.scan()
 
-		value := p.parseBinary(binaryLevelsWithoutComma)
-		attrs = append(attrs, key, value)
+		entry.Value = p.parsePipe(false)
+		x.Entries = append(x.Entries, entry)
 
 		if p.tok == comma {
 			p.scan() // trailing comma is okay
@@ -309,33 +671,35 @@ func (p *parser) parseObjectConstruct() sift.Filter {
 		}
 	}
 	p.scan() // rightBrace
+	return x
+}
 
-	if len(attrs) == 0 {
-		return func(sift.Value) ([]sift.Value, error) {
-			empty := sift.Must(sift.ToValue(map[string]sift.Value{}))
-			return []sift.Value{empty}, nil
-		}
-	}
-	return sift.Nary(attrs, constructObject)
+// parseKeyLiteral parses an object key written as an identifier, keyword,
+// or string.
+func (p *parser) parseKeyLiteral() *Literal {
+	pos, _, key := p.scan()
+	return &Literal{ValuePos: pos, Value: sift.Must(sift.ToValue(key))}
 }
 
 func (p *parser) scan() (gotoken.Pos, token, string) {
 	pos, tok, lit := p.pos, p.tok, p.lit
+	switch tok {
+	case leftParen, leftBracket, leftBrace:
+		p.nest++
+	case rightParen, rightBracket, rightBrace:
+		p.nest--
+	}
 	p.pos, p.tok, p.lit = p.scanner.scan()
 	return pos, tok, lit
 }
 
+// panicf records a syntax error at pos and bails out of the expression
+// being parsed. Errors on the same line as the previous error are likely
+// caused by it, so they're discarded.
 func (p *parser) panicf(pos gotoken.Pos, format string, args ...interface{}) {
-	message := fmt.Sprintf(format, args...)
-	err := parseError{p.file.Position(pos), message}
-	panic(err)
-}
-
-type parseError struct {
-	position gotoken.Position
-	message  string
-}
-
-func (e parseError) Error() string {
-	return fmt.Sprintf("%s: %s", e.position, e.message)
+	position := p.file.Position(pos)
+	if n := len(p.errors); n == 0 || p.errors[n-1].Position.Line != position.Line {
+		p.errors = append(p.errors, &SyntaxError{Position: position, Msg: fmt.Sprintf(format, args...)})
+	}
+	panic(bailout{})
 }
@@ -0,0 +1,103 @@
+package jq
+
+import (
+	"fmt"
+
+	"go.jayconrod.com/sift"
+)
+
+// compilePattern compiles a destructuring pattern. The emitted code pops
+// the value being destructured and stores parts of it in the pattern's
+// variables. Key expressions in object patterns may produce multiple keys,
+// so the code may continue more than once. Key expressions are evaluated
+// with the input of the "as" expression, saved in inSlot.
+//
+// Variables named in the pattern are added to vars and varList. A variable
+// that was already added by an earlier pattern is reused.
+func (c *compiler) compilePattern(pat Pattern, vars map[string]*variable, varList *[]*variable, inSlot int) {
+	patternVar := func(name string) *variable {
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		v := c.newVariable(name)
+		vars[name] = v
+		*varList = append(*varList, v)
+		return v
+	}
+
+	switch pat := pat.(type) {
+	case *VarPattern:
+		c.emit(inst{op: opStore, a: patternVar(pat.Name).slot})
+
+	case *ArrayPattern:
+		c.emit(inst{op: opCheckArray})
+		for i, elem := range pat.Elems {
+			c.emit(inst{op: opDup})
+			c.emit(inst{op: opElem, a: i})
+			c.compilePattern(elem, vars, varList, inSlot)
+		}
+		c.emit(inst{op: opPop})
+
+	case *ObjectPattern:
+		c.emit(inst{op: opCheckObject})
+		for _, entry := range pat.Entries {
+			c.emit(inst{op: opDup})
+			if entry.Var != nil {
+				v := patternVar(entry.Var.Name)
+				c.emit(inst{op: opDup})
+				c.emit(inst{op: opConst, v: sift.Must(sift.ToValue(v.name))})
+				c.emit(inst{op: opAttr})
+				if entry.Value != nil {
+					c.emit(inst{op: opDup})
+				}
+				c.emit(inst{op: opStore, a: v.slot})
+			} else {
+				c.emit(inst{op: opPush, a: inSlot})
+				c.compileExpr(entry.Key)
+				c.emit(inst{op: opAttr})
+			}
+			if entry.Value != nil {
+				c.compilePattern(entry.Value, vars, varList, inSlot)
+			} else if entry.Var == nil {
+				c.emit(inst{op: opPop})
+			}
+		}
+		c.emit(inst{op: opPop})
+
+	default:
+		panic(fmt.Sprintf("unexpected pattern of type %T", pat))
+	}
+}
+
+// patternsUseInput reports whether any of the patterns has an object entry
+// with a key expression, which must be evaluated with the input of the
+// "as" expression.
+func patternsUseInput(pats []Pattern) bool {
+	for _, pat := range pats {
+		switch pat := pat.(type) {
+		case *ArrayPattern:
+			if patternsUseInput(pat.Elems) {
+				return true
+			}
+		case *ObjectPattern:
+			for _, entry := range pat.Entries {
+				if entry.Key != nil {
+					return true
+				}
+				if entry.Value != nil && patternsUseInput([]Pattern{entry.Value}) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func attrOrNull(v, key sift.Value) sift.Value {
+	if attr, ok := v.(sift.Attr); ok {
+		if elem, ok := attr.Attr(key); ok {
+			return elem
+		}
+	}
+	return sift.NullValue
+}
@@ -0,0 +1,232 @@
+package jq
+
+import (
+	"fmt"
+
+	"go.jayconrod.com/sift"
+)
+
+// A pathExpr is a path expression like .a.b[0], the argument to pick.
+// Path expressions are compiled into a tree of steps rather than into
+// code, so pick can follow the paths they describe, not only the values at
+// the end of them.
+type pathExpr struct {
+	kind     pathKind
+	key      sift.Value // for pathKey with a constant key
+	arg      int        // for pathKey, the argument producing keys, or -1
+	optional bool       // for pathKey and pathIterate, suppress type errors
+	x, y     *pathExpr  // for pathPipe, pathComma, and pathTry
+}
+
+type pathKind int
+
+const (
+	pathIdentity pathKind = iota // .
+	pathKey                      // .[k] on the input
+	pathIterate                  // .[] on the input
+	pathPipe                     // x | y
+	pathComma                    // x, y
+	pathTry                      // x?
+)
+
+// callsPick reports whether x calls the pick builtin, rather than
+// a function of the same name defined by the program or the application.
+func (c *compiler) callsPick(x *Call) bool {
+	key := funcKey(x.Name, len(x.Args))
+	if key != "pick/1" {
+		return false
+	}
+	if _, ok := c.scope.lookup(key); ok {
+		return false
+	}
+	if _, ok := c.opts.Funcs[key]; ok {
+		return false
+	}
+	for _, hostKey := range c.opts.HostFuncs {
+		if key == hostKey {
+			return false
+		}
+	}
+	return true
+}
+
+// compilePick compiles a call to pick(pathexps), which produces an object
+// or array containing only the parts of its input at the paths produced by
+// pathexps. For example, pick(.a.b, .c) produces {"a":{"b":.a.b},"c":.c}.
+// Keys written as expressions, like .[$k], are compiled as closures and
+// evaluated with the input of the path expression containing them.
+func (c *compiler) compilePick(x *Call) {
+	var keys []*code
+	var toPath func(e Expr) *pathExpr
+	keyStep := func(e Expr, optional bool) *pathExpr {
+		if lit, ok := e.(*Literal); ok {
+			return &pathExpr{kind: pathKey, key: lit.Value, arg: -1, optional: optional}
+		}
+		keys = append(keys, c.compileClosure(e))
+		return &pathExpr{kind: pathKey, arg: len(keys) - 1, optional: optional}
+	}
+	pipe := func(x Expr, step *pathExpr) *pathExpr {
+		if x == nil {
+			return step
+		}
+		return &pathExpr{kind: pathPipe, x: toPath(x), y: step}
+	}
+	toPath = func(e Expr) *pathExpr {
+		switch e := e.(type) {
+		case *Identity:
+			return &pathExpr{kind: pathIdentity}
+		case *Paren:
+			return toPath(e.X)
+		case *Field:
+			key := sift.Must(sift.ToValue(e.Name))
+			return pipe(e.X, &pathExpr{kind: pathKey, key: key, arg: -1, optional: e.Optional})
+		case *Index:
+			return pipe(e.X, keyStep(e.Index, false))
+		case *Iterate:
+			return pipe(e.X, &pathExpr{kind: pathIterate, optional: e.Optional})
+		case *Try:
+			if e.Catch == nil {
+				return &pathExpr{kind: pathTry, x: toPath(e.X)}
+			}
+		case *Binary:
+			switch e.Op {
+			case OpPipe:
+				return &pathExpr{kind: pathPipe, x: toPath(e.X), y: toPath(e.Y)}
+			case OpComma:
+				return &pathExpr{kind: pathComma, x: toPath(e.X), y: toPath(e.Y)}
+			}
+		}
+		c.panicf(e.Pos(), "pick: argument is not a path expression")
+		return nil
+	}
+	p := toPath(x.Args[0])
+
+	c.positioned(x.NamePos, func() {
+		c.emitGo(keys, func(_ *runState, keys []sift.Filter) sift.Filter {
+			return func(v sift.Value) ([]sift.Value, error) {
+				out := sift.NullValue
+				err := p.paths(v, nil, keys, func(path []sift.Value, elem sift.Value) error {
+					var err error
+					out, err = sift.Path(path).Set(out, elem)
+					return err
+				})
+				if err != nil {
+					return nil, err
+				}
+				return []sift.Value{out}, nil
+			}
+		})
+	})
+}
+
+// paths calls yield with each path p produces, relative to v, which is the
+// value at prefix, and the value at each path. Missing values are null.
+func (p *pathExpr) paths(v sift.Value, prefix []sift.Value, keys []sift.Filter, yield func([]sift.Value, sift.Value) error) error {
+	switch p.kind {
+	case pathIdentity:
+		return yield(prefix, v)
+
+	case pathKey:
+		ks := []sift.Value{p.key}
+		if p.arg >= 0 {
+			var err error
+			if ks, err = keys[p.arg](v); err != nil {
+				return err
+			}
+		}
+		for _, k := range ks {
+			elem, err := pathStep(v, k)
+			if err != nil {
+				if p.optional {
+					continue
+				}
+				return err
+			}
+			if err := yield(appendPath(prefix, k), elem); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case pathIterate:
+		switch v := v.(type) {
+		case sift.Index:
+			for i := 0; i < v.Length(); i++ {
+				elem, ok := v.Index(i)
+				if !ok {
+					elem = sift.NullValue
+				}
+				if err := yield(appendPath(prefix, sift.Must(sift.ToValue(i))), elem); err != nil {
+					return err
+				}
+			}
+		case sift.Attr:
+			for _, k := range v.Keys() {
+				elem, _ := v.Attr(k)
+				if err := yield(appendPath(prefix, k), elem); err != nil {
+					return err
+				}
+			}
+		default:
+			if !sift.IsNull(v) && !p.optional {
+				return fmt.Errorf("cannot iterate over %s", describe(v))
+			}
+		}
+		return nil
+
+	case pathPipe:
+		return p.x.paths(v, prefix, keys, func(path []sift.Value, elem sift.Value) error {
+			return p.y.paths(elem, path, keys, yield)
+		})
+
+	case pathComma:
+		if err := p.x.paths(v, prefix, keys, yield); err != nil {
+			return err
+		}
+		return p.y.paths(v, prefix, keys, yield)
+
+	case pathTry:
+		// Errors from the path expression are suppressed, but errors
+		// building the result are not.
+		var yieldErr error
+		err := p.x.paths(v, prefix, keys, func(path []sift.Value, elem sift.Value) error {
+			yieldErr = yield(path, elem)
+			return yieldErr
+		})
+		if err != nil && err == yieldErr {
+			return err
+		}
+		return nil
+
+	default:
+		panic(fmt.Sprintf("unexpected path kind %d", p.kind))
+	}
+}
+
+func appendPath(prefix []sift.Value, k sift.Value) []sift.Value {
+	path := make([]sift.Value, len(prefix)+1)
+	copy(path, prefix)
+	path[len(prefix)] = k
+	return path
+}
+
+// pathStep returns the value at key k in v, which may be null.
+func pathStep(v, k sift.Value) (sift.Value, error) {
+	if sift.IsNull(v) {
+		return sift.NullValue, nil
+	}
+	if _, ok := sift.AsString(k); ok {
+		if _, ok := v.(sift.Attr); ok {
+			return attrOrNull(v, k), nil
+		}
+	} else if _, ok := sift.AsFloat64(k); ok {
+		if _, ok := v.(sift.Index); ok {
+			vs, err := index(v, k)
+			if err != nil || len(vs) == 0 {
+				return sift.NullValue, err
+			}
+			return vs[0], nil
+		}
+	}
+	return nil, fmt.Errorf("cannot index value %v with value %v", v, k)
+}
@@ -0,0 +1,135 @@
+// Package repl provides an engine for evaluating jq programs interactively.
+// A Session holds an input document and evaluates programs against it as
+// the user edits them. It has no user interface of its own, so command-line
+// tools and editor integrations can present results however they like.
+package repl
+
+import (
+	"errors"
+	"fmt"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/filter/jq"
+)
+
+// A Session evaluates jq programs against an input document. The input
+// persists across evaluations until it is replaced, so a user can refine
+// a program one edit at a time. A Session is not safe for concurrent use.
+type Session struct {
+	opts jq.CompileOptions
+
+	input    sift.Value
+	hasInput bool
+
+	// program and filter are the most recently compiled program and its
+	// filter. Evaluating the same text again reuses the filter.
+	program string
+	filter  sift.Filter
+
+	last *Result
+}
+
+// A Result is the outcome of evaluating a program.
+type Result struct {
+	// Program is the text that was evaluated.
+	Program string
+
+	// Values are the values the program produced, in order. If the
+	// program failed partway, Values is empty.
+	Values []sift.Value
+
+	// SyntaxErrors lists errors in the program's text. When there are
+	// syntax errors, the program is not evaluated.
+	SyntaxErrors jq.ErrorList
+
+	// Err is set if the program could not be compiled for another reason,
+	// like an undefined function, or if it failed while being evaluated.
+	Err error
+}
+
+// OK returns whether the program was compiled and evaluated successfully.
+func (r *Result) OK() bool {
+	return len(r.SyntaxErrors) == 0 && r.Err == nil
+}
+
+// NewSession returns a Session that compiles programs with opts. The
+// session has no input until SetInput or ReadInput is called.
+func NewSession(opts jq.CompileOptions) *Session {
+	return &Session{opts: opts}
+}
+
+// SetInput replaces the session's input document.
+func (s *Session) SetInput(v sift.Value) {
+	s.input, s.hasInput = v, true
+}
+
+// ReadInput reads the next value from dec and makes it the session's input.
+// It returns the error from dec, including io.EOF, without changing the
+// input if there is one.
+func (s *Session) ReadInput(dec sift.Decoder) error {
+	v, err := dec.Decode()
+	if err != nil {
+		return err
+	}
+	s.SetInput(v)
+	return nil
+}
+
+// Input returns the session's input document and whether there is one.
+func (s *Session) Input() (sift.Value, bool) {
+	return s.input, s.hasInput
+}
+
+// Eval compiles program, if it differs from the last program evaluated,
+// and evaluates it with the session's input. If there is no input, the
+// program is evaluated with null. The result is also returned by Last.
+func (s *Session) Eval(program string) *Result {
+	r := &Result{Program: program}
+	s.last = r
+	if s.filter == nil || program != s.program {
+		filter, err := jq.CompileWithOptions("repl", program, s.opts)
+		if err != nil {
+			var errs jq.ErrorList
+			if errors.As(err, &errs) {
+				r.SyntaxErrors = errs
+			} else {
+				r.Err = err
+			}
+			return r
+		}
+		s.program, s.filter = program, filter
+	}
+
+	in := s.input
+	if !s.hasInput {
+		in = sift.NullValue
+	}
+	r.Values, r.Err = s.filter(in)
+	if r.Err != nil {
+		r.Values = nil
+	}
+	return r
+}
+
+// Last returns the result of the most recent call to Eval, or nil if Eval
+// has not been called.
+func (s *Session) Last() *Result {
+	return s.last
+}
+
+// Keep replaces the session's input with the value produced by the most
+// recent evaluation, so the user can continue exploring from there. It
+// reports an error unless that evaluation produced exactly one value.
+func (s *Session) Keep() error {
+	if s.last == nil {
+		return errors.New("no program has been evaluated")
+	}
+	if !s.last.OK() {
+		return errors.New("last program failed")
+	}
+	if len(s.last.Values) != 1 {
+		return fmt.Errorf("last program produced %d values; can only keep one", len(s.last.Values))
+	}
+	s.SetInput(s.last.Values[0])
+	return nil
+}
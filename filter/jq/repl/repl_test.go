@@ -0,0 +1,90 @@
+package repl_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+	"go.jayconrod.com/sift/filter/jq"
+	"go.jayconrod.com/sift/filter/jq/repl"
+)
+
+func TestSession(t *testing.T) {
+	s := repl.NewSession(jq.CompileOptions{})
+	if _, ok := s.Input(); ok {
+		t.Fatal("new session has input")
+	}
+	if r := s.Eval(`.`); !r.OK() || len(r.Values) != 1 || !sift.IsNull(r.Values[0]) {
+		t.Errorf("evaluating without input: got %v, %v; want null", r.Values, r.Err)
+	}
+
+	dec := json.NewDecoder(strings.NewReader(`{"users": [{"name": "a"}, {"name": "b"}]}`))
+	if err := s.ReadInput(dec); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReadInput(dec); err != io.EOF {
+		t.Fatalf("reading past end: got %v; want io.EOF", err)
+	}
+	if _, ok := s.Input(); !ok {
+		t.Fatal("input lost after reading past end")
+	}
+
+	for _, test := range []struct {
+		program, want, wantSyntax, wantErr string
+	}{
+		{program: `.users[].name`, want: `"a" "b"`},
+		{program: `.users[].name`, want: `"a" "b"`},
+		{program: `.users[0] |`, wantSyntax: "expected expression"},
+		{program: `.users[0] | nope`, wantErr: "nope/0 is not defined"},
+		{program: `.users[0] | .name[0]`, wantErr: "cannot index"},
+		{program: `.users[0]`, want: `{"name":"a"}`},
+	} {
+		r := s.Eval(test.program)
+		if s.Last() != r {
+			t.Errorf("%s: Last did not return the result of Eval", test.program)
+		}
+		if test.wantSyntax != "" {
+			if len(r.SyntaxErrors) == 0 || !strings.Contains(r.SyntaxErrors.Error(), test.wantSyntax) {
+				t.Errorf("%s: got syntax errors %v; want %q", test.program, r.SyntaxErrors, test.wantSyntax)
+			}
+			continue
+		}
+		if test.wantErr != "" {
+			if r.Err == nil || !strings.Contains(r.Err.Error(), test.wantErr) {
+				t.Errorf("%s: got error %v; want %q", test.program, r.Err, test.wantErr)
+			}
+			continue
+		}
+		if !r.OK() {
+			t.Errorf("%s: unexpected errors %v, %v", test.program, r.SyntaxErrors, r.Err)
+			continue
+		}
+		if got := valuesString(t, r.Values); got != test.want {
+			t.Errorf("%s: got %s; want %s", test.program, got, test.want)
+		}
+	}
+
+	if err := s.Keep(); err != nil {
+		t.Fatal(err)
+	}
+	if r := s.Eval(`.name`); valuesString(t, r.Values) != `"a"` {
+		t.Errorf("after Keep: got %s; want \"a\"", valuesString(t, r.Values))
+	}
+	s.Eval(`.name, .name`)
+	if err := s.Keep(); err == nil {
+		t.Error("Keep after program with two values: got success; want error")
+	}
+}
+
+func valuesString(t *testing.T, vs []sift.Value) string {
+	b := &strings.Builder{}
+	enc := json.NewEncoder(b)
+	for _, v := range vs {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -21,7 +21,9 @@ const (
 	dotDot
 	comma
 	questionMark
+	questionAlt
 	colon
+	semicolon
 	pipe
 	star
 	slash
@@ -37,7 +39,16 @@ const (
 	null
 	true_
 	false_
+	def
+	import_
+	include
+	try_
+	catch_
+	as
+	and
+	or
 	identifier
+	varIdentifier
 	number
 	str
 )
@@ -56,8 +67,12 @@ func (t token) String() string {
 		return ","
 	case questionMark:
 		return "?"
+	case questionAlt:
+		return "?//"
 	case colon:
 		return ":"
+	case semicolon:
+		return ";"
 	case pipe:
 		return "|"
 	case star:
@@ -88,8 +103,26 @@ func (t token) String() string {
 		return "true"
 	case false_:
 		return "false"
+	case def:
+		return "def"
+	case import_:
+		return "import"
+	case include:
+		return "include"
+	case try_:
+		return "try"
+	case catch_:
+		return "catch"
+	case as:
+		return "as"
+	case and:
+		return "and"
+	case or:
+		return "or"
 	case identifier:
 		return "identifier"
+	case varIdentifier:
+		return "variable"
 	case number:
 		return "number"
 	case str:
@@ -99,6 +132,12 @@ func (t token) String() string {
 	}
 }
 
+// isKeyword returns whether t is a token for a reserved word. Keywords may
+// still be used as object keys and field names.
+func (t token) isKeyword() bool {
+	return null <= t && t <= or
+}
+
 type scanner struct {
 	file     *gotoken.File
 	src      []byte
@@ -142,9 +181,42 @@ Retry:
 			tok = true_
 		case "false":
 			tok = false_
+		case "def":
+			tok = def
+		case "import":
+			tok = import_
+		case "include":
+			tok = include
+		case "try":
+			tok = try_
+		case "catch":
+			tok = catch_
+		case "as":
+			tok = as
+		case "and":
+			tok = and
+		case "or":
+			tok = or
 		default:
 			tok = identifier
+			for s.ch == ':' && s.peek() == ':' {
+				// Qualified name of a function in an imported module, like mod::f.
+				s.next()
+				s.next()
+				if !isLetter(s.ch) && s.ch != '_' {
+					s.panicf(s.offset, "expected identifier after ::")
+				}
+				lit += "::" + s.scanIdentifier()
+			}
+		}
+
+	case ch == '$':
+		s.next()
+		if !isLetter(s.ch) && s.ch != '_' {
+			s.panicf(s.offset, "expected variable name after $")
 		}
+		lit = s.scanIdentifier()
+		tok = varIdentifier
 
 	case '0' <= ch && ch <= '9':
 		lit = s.scanNumber()
@@ -172,10 +244,18 @@ Retry:
 
 		case '?':
 			tok = questionMark
+			if s.ch == '/' && s.peek() == '/' {
+				s.next()
+				s.next()
+				tok = questionAlt
+			}
 
 		case ':':
 			tok = colon
 
+		case ';':
+			tok = semicolon
+
 		case '|':
 			tok = pipe
 
@@ -426,6 +506,17 @@ func (s *scanner) panicf(offset int, format string, args ...interface{}) {
 	panic(err)
 }
 
+// isIdentifier returns whether s is a valid identifier, the name of
+// a function or variable.
+func isIdentifier(s string) bool {
+	for i, ch := range s {
+		if !isLetter(ch) && ch != '_' && (i == 0 || !isDigit(ch)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
 func isLetter(ch rune) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || unicode.IsLetter(ch)
 }
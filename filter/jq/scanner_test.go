@@ -26,6 +26,9 @@ func TestIdentifier(t *testing.T) {
 		{"123𝔣𝔞𝔫𝔠𝔶", false},
 		{"☃", false},
 	} {
+		if got := isIdentifier(tc.text); got != tc.ok {
+			t.Errorf("isIdentifier(%q) = %v; want %v", tc.text, got, tc.ok)
+		}
 		fset := gotoken.NewFileSet()
 		file := fset.AddFile("test", -1, len(tc.text))
 		s := newScanner(file, []byte(tc.text))
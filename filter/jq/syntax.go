@@ -0,0 +1,184 @@
+package jq
+
+import (
+	"fmt"
+	gotoken "go/token"
+	"reflect"
+
+	"go.jayconrod.com/sift"
+)
+
+// Lower keeps expressions it can't translate into ir nodes as Source nodes.
+// Along with the source text, each Source node holds the source's syntax
+// tree, encoded as a Value by encodeSyntax, so loading the node doesn't
+// parse the text again.
+//
+// A node in an encoded tree is an object with a "kind" attribute naming its
+// type, like "Binary", and an attribute for each non-zero field, named like
+// the field. Positions are encoded as byte offsets into the source text.
+
+// syntaxKinds maps the name of each type that may appear in an encoded
+// syntax tree to the type.
+var syntaxKinds = make(map[string]reflect.Type)
+
+func init() {
+	for _, n := range []interface{}{
+		&File{}, &Import{}, &FuncDef{}, &Param{},
+		&Identity{}, &Recurse{}, &Literal{}, &Var{}, &Neg{}, &Binary{},
+		&Paren{}, &Field{}, &Index{}, &Slice{}, &Iterate{}, &Try{},
+		&Array{}, &Object{}, &ObjectEntry{}, &Call{}, &As{}, &FuncDefExpr{},
+		&VarPattern{}, &ArrayPattern{}, &ObjectPattern{}, &ObjectPatternEntry{},
+	} {
+		t := reflect.TypeOf(n).Elem()
+		syntaxKinds[t.Name()] = t
+	}
+}
+
+var (
+	posType   = reflect.TypeOf(gotoken.NoPos)
+	valueType = reflect.TypeOf((*sift.Value)(nil)).Elem()
+)
+
+// encodeSyntax returns the syntax tree rooted at n as a Value. tf is the
+// file n was parsed from.
+func encodeSyntax(tf *gotoken.File, n Node) (sift.Value, error) {
+	return encodeSyntaxValue(tf, reflect.ValueOf(n))
+}
+
+func encodeSyntaxValue(tf *gotoken.File, rv reflect.Value) (sift.Value, error) {
+	switch {
+	case rv.Type() == posType:
+		return sift.ToValue(tf.Offset(rv.Interface().(gotoken.Pos)))
+	case rv.Type() == valueType:
+		return rv.Interface().(sift.Value), nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return sift.NullValue, nil
+		}
+		if rv.Kind() == reflect.Interface {
+			return encodeSyntaxValue(tf, rv.Elem())
+		}
+		t := rv.Type().Elem()
+		if syntaxKinds[t.Name()] != t {
+			return nil, fmt.Errorf("cannot encode syntax node of type %v", rv.Type())
+		}
+		b := sift.NewObject().Set("kind", t.Name())
+		for i := 0; i < t.NumField(); i++ {
+			field := rv.Elem().Field(i)
+			if !t.Field(i).IsExported() || field.IsZero() {
+				continue
+			}
+			v, err := encodeSyntaxValue(tf, field)
+			if err != nil {
+				return nil, err
+			}
+			b.Set(t.Field(i).Name, v)
+		}
+		return b.Build()
+
+	case reflect.Slice:
+		elems := sift.NewArray(rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			v, err := encodeSyntaxValue(tf, rv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elems.Append(v)
+		}
+		return elems.Build(), nil
+
+	case reflect.String, reflect.Bool, reflect.Int:
+		return sift.ToValue(rv.Interface())
+
+	default:
+		return nil, fmt.Errorf("cannot encode syntax field of type %v", rv.Type())
+	}
+}
+
+// decodeSyntax returns the program whose syntax tree was encoded in v by
+// encodeSyntax. Positions in the tree are in tf, the file for the source
+// text the tree was parsed from.
+func decodeSyntax(tf *gotoken.File, v sift.Value) (*File, error) {
+	f := &File{}
+	if err := decodeSyntaxValue(tf, v, reflect.ValueOf(&f).Elem()); err != nil {
+		return nil, err
+	}
+	if f == nil {
+		return nil, fmt.Errorf("syntax tree is empty")
+	}
+	return f, nil
+}
+
+// decodeSyntaxValue stores the syntax encoded in v in dst.
+func decodeSyntaxValue(tf *gotoken.File, v sift.Value, dst reflect.Value) error {
+	t := dst.Type()
+	switch {
+	case t == posType:
+		offset, ok := sift.As[int](v)
+		if !ok || offset < 0 || offset > tf.Size() {
+			return fmt.Errorf("invalid position %v", v)
+		}
+		dst.Set(reflect.ValueOf(tf.Pos(offset)))
+		return nil
+	case t == valueType:
+		dst.Set(reflect.ValueOf(&v).Elem())
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if sift.IsNull(v) {
+			dst.Set(reflect.Zero(t))
+			return nil
+		}
+		kv, _ := sift.GetStringAttr(v, "kind")
+		kind, _ := sift.AsString(kv)
+		nt, ok := syntaxKinds[kind]
+		if !ok {
+			return fmt.Errorf("unknown syntax node kind %v", kv)
+		}
+		n := reflect.New(nt)
+		if !n.Type().AssignableTo(t) {
+			return fmt.Errorf("syntax node %s may not appear where %v is expected", kind, t)
+		}
+		for i := 0; i < nt.NumField(); i++ {
+			sf := nt.Field(i)
+			fv, ok := sift.GetStringAttr(v, sf.Name)
+			if !sf.IsExported() || !ok {
+				continue
+			}
+			if err := decodeSyntaxValue(tf, fv, n.Elem().Field(i)); err != nil {
+				return fmt.Errorf("%s.%s: %w", kind, sf.Name, err)
+			}
+		}
+		dst.Set(n)
+		return nil
+
+	case reflect.Slice:
+		ix, ok := v.(sift.Index)
+		if !ok {
+			return fmt.Errorf("value %v is not an array", v)
+		}
+		s := reflect.MakeSlice(t, ix.Length(), ix.Length())
+		for i := 0; i < ix.Length(); i++ {
+			e, _ := ix.Index(i)
+			if err := decodeSyntaxValue(tf, e, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(s)
+		return nil
+
+	case reflect.String, reflect.Bool, reflect.Int:
+		if err := sift.FromValue(v, dst.Addr().Interface()); err != nil {
+			return err
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("cannot decode syntax field of type %v", t)
+	}
+}
@@ -0,0 +1,65 @@
+package jq
+
+import (
+	gotoken "go/token"
+
+	"go.jayconrod.com/sift"
+)
+
+// A Step describes an expression a program is about to evaluate. Steps are
+// reported to the Trace function in CompileOptions.
+type Step struct {
+	// Node is the expression about to be evaluated.
+	Node Expr
+
+	// Position is the location of Node.
+	Position gotoken.Position
+
+	// Input is the value Node is evaluated with.
+	Input sift.Value
+
+	// Depth is the number of expressions whose evaluation is in progress,
+	// not counting Node. Expressions nested within Node are reported with
+	// a greater Depth, so a debugger can step over Node by waiting for
+	// a Step with the same or lower Depth.
+	Depth int
+
+	fr   *frame
+	vars []traceVar
+}
+
+// A Binding is a variable and its value.
+type Binding struct {
+	Name  string // without $
+	Value sift.Value
+}
+
+// Vars returns the variables visible to Node and their values, innermost
+// first. Variables hidden by other variables with the same name are not
+// included.
+func (s *Step) Vars() []Binding {
+	bindings := make([]Binding, len(s.vars))
+	for i, v := range s.vars {
+		value := s.fr.up(v.up).locals[v.slot]
+		if value == nil {
+			// The variable is bound by a pattern that hasn't been tried.
+			value = sift.NullValue
+		}
+		bindings[i] = Binding{Name: v.name, Value: value}
+	}
+	return bindings
+}
+
+// traceError wraps an error returned by a Trace function, so that try, ?,
+// and ?// don't catch it.
+type traceError struct {
+	err error
+}
+
+func (e *traceError) Error() string {
+	return e.err.Error()
+}
+
+func (e *traceError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,173 @@
+package jq
+
+import (
+	"fmt"
+
+	"go.jayconrod.com/sift"
+)
+
+// toTree converts its input, a flat array of objects that point to their
+// parents, into an array of trees. Each object's id is produced by the first
+// argument, and its parent's id is produced by the second. Each object in
+// the result has a "children" attribute listing the objects that point to it.
+// Objects with a null parent, or a parent not in the list, are roots.
+// Objects keep their relative order from the input.
+func toTree(_ *CompileOptions, args []sift.Filter) sift.Filter {
+	idf, parentf := args[0], args[1]
+	return func(v sift.Value) ([]sift.Value, error) {
+		nodes, err := iterate(v)
+		if err != nil {
+			return nil, err
+		}
+
+		byKey := make(map[string]int)
+		parentKeys := make([]string, len(nodes))
+		for i, node := range nodes {
+			id, err := single("to_tree: id", idf, node)
+			if err != nil {
+				return nil, err
+			}
+			key, err := valueKey(id)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := byKey[key]; ok {
+				return nil, fmt.Errorf("to_tree: duplicate id %s", key)
+			}
+			byKey[key] = i
+
+			parent, err := single("to_tree: parent", parentf, node)
+			if err != nil {
+				return nil, err
+			}
+			if !sift.IsNull(parent) {
+				if parentKeys[i], err = valueKey(parent); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		var roots []int
+		childIndices := make([][]int, len(nodes))
+		for i, key := range parentKeys {
+			if p, ok := byKey[key]; key != "" && ok {
+				childIndices[p] = append(childIndices[p], i)
+			} else {
+				roots = append(roots, i)
+			}
+		}
+
+		placed := 0
+		var build func(i int) (sift.Value, error)
+		build = func(i int) (sift.Value, error) {
+			placed++
+			children := make([]sift.Value, len(childIndices[i]))
+			for j, c := range childIndices[i] {
+				child, err := build(c)
+				if err != nil {
+					return nil, err
+				}
+				children[j] = child
+			}
+			return withAttr(nodes[i], "children", sift.Must(sift.ToValue(children)))
+		}
+		trees := make([]sift.Value, len(roots))
+		for j, r := range roots {
+			if trees[j], err = build(r); err != nil {
+				return nil, err
+			}
+		}
+		if placed < len(nodes) {
+			return nil, fmt.Errorf("to_tree: %d objects are part of a cycle", len(nodes)-placed)
+		}
+		return []sift.Value{sift.Must(sift.ToValue(trees))}, nil
+	}
+}
+
+// fromTree converts its input, an array of trees (or a single tree), into
+// a flat array of objects in depth-first pre-order. The argument produces
+// the name of the attribute that lists each object's children; that
+// attribute is removed from objects in the result.
+func fromTree(_ *CompileOptions, args []sift.Filter) sift.Filter {
+	return sift.Binary(id, args[0], func(v, keyv sift.Value) ([]sift.Value, error) {
+		key, ok := sift.AsString(keyv)
+		if !ok {
+			return nil, fmt.Errorf("from_tree: key %v is not a string", keyv)
+		}
+		trees := []sift.Value{v}
+		if _, ok := v.(sift.Index); ok {
+			trees, _ = iterate(v)
+		}
+		var outs []sift.Value
+		var flatten func(node sift.Value) error
+		flatten = func(node sift.Value) error {
+			attr, ok := node.(sift.Attr)
+			if !ok {
+				return fmt.Errorf("from_tree: node %v is not an object", node)
+			}
+			m := make(map[string]sift.Value)
+			var children []sift.Value
+			for _, k := range attr.Keys() {
+				name, ok := sift.AsString(k)
+				if !ok {
+					return fmt.Errorf("from_tree: object has non-string key %v", k)
+				}
+				value, ok := attr.Attr(k)
+				if !ok {
+					continue
+				}
+				if name == key {
+					if !sift.IsNull(value) {
+						var err error
+						if children, err = iterate(value); err != nil {
+							return fmt.Errorf("from_tree: %v", err)
+						}
+					}
+					continue
+				}
+				m[name] = value
+			}
+			outs = append(outs, sift.Must(sift.ToValue(m)))
+			for _, child := range children {
+				if err := flatten(child); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		for _, tree := range trees {
+			if err := flatten(tree); err != nil {
+				return nil, err
+			}
+		}
+		return []sift.Value{sift.Must(sift.ToValue(outs))}, nil
+	})
+}
+
+// single applies f to v and returns the only value f produces. An error is
+// returned if f produces any other number of values.
+func single(what string, f sift.Filter, v sift.Value) (sift.Value, error) {
+	vs, err := f(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(vs) != 1 {
+		return nil, fmt.Errorf("%s produced %d values; want 1", what, len(vs))
+	}
+	return vs[0], nil
+}
+
+// withAttr returns a copy of the object v with the attribute key set
+// to value.
+func withAttr(v sift.Value, key string, value sift.Value) (sift.Value, error) {
+	attr, ok := v.(sift.Attr)
+	if !ok {
+		return nil, fmt.Errorf("cannot set attribute %q of value %v", key, v)
+	}
+	m := make(map[string]sift.Value)
+	if err := copyAttrs(m, attr); err != nil {
+		return nil, err
+	}
+	m[key] = value
+	return sift.ToValue(m)
+}
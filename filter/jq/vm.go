@@ -0,0 +1,793 @@
+package jq
+
+import (
+	"errors"
+	"fmt"
+	gotoken "go/token"
+	"sync/atomic"
+
+	"go.jayconrod.com/sift"
+)
+
+// Programs are compiled to bytecode executed by a small backtracking
+// machine. Each expression is compiled to a sequence of instructions that
+// replaces the value on top of the stack (its input) with one of its
+// outputs. Expressions that produce several outputs push fork points;
+// after an output is consumed, the machine backtracks to the most recent
+// fork point to produce the next one. Errors unwind fork points until one
+// pushed by a try instruction catches them.
+
+// A code block is a compiled function body, closure, or main program.
+type code struct {
+	// name describes the code block for debugging.
+	name string
+
+	insts []inst
+
+	// level is the code block's lexical nesting depth. The main program
+	// and module top levels are at level 0.
+	level int
+
+	// nlocals and naux are the number of slots in a frame's locals and aux.
+	nlocals, naux int
+}
+
+type opcode uint8
+
+const (
+	opDup         opcode = iota // push a copy of the top value
+	opPop                       // discard the top value
+	opSwap                      // exchange the top two values
+	opConst                     // replace the top value with v
+	opLoad                      // replace the top value with local a of the frame b levels up
+	opPush                      // push local a of the frame b levels up
+	opStore                     // pop the top value into local a
+	opClear                     // set the locals listed in aux ([]int) to null
+	opApply                     // replace the top value with each output of filter
+	opNary                      // replace the top a values with each output of nary
+	opAnd                       // pop a value; if it's falsy, replace the top value with false and jump to a
+	opOr                        // pop a value; if it's truthy, replace the top value with true and jump to a
+	opTruth                     // replace the top value with its truthiness
+	opFork                      // push a fork point that resumes at a
+	opJump                      // jump to a
+	opBacktrack                 // resume at the most recent fork point
+	opTryBegin                  // push a try point that jumps to a on error, with the error's payload on top if aux is true; store its state in aux slot b
+	opTryEnd                    // suspend the try point in aux slot b while its output is consumed
+	opTryNone                   // clear aux slot b, so opTryEnd does nothing
+	opArrayStart                // start a new array builder in aux slot a
+	opAppend                    // pop the top value into the array builder in aux slot a
+	opArrayEnd                  // replace the top value with an array of the values in aux slot a
+	opCall                      // call the function described by aux (*callSite)
+	opCallParam                 // call the closure described by aux (*paramSite)
+	opCallGo                    // call the Go function described by aux (*goSite)
+	opGen                       // replace the top value with each output of gen, lazily
+	opCallGen                   // call the lazy Go function described by aux (*genSite)
+	opRet                       // return from a call, or produce an output at the top level
+	opTrace                     // report the step described by aux (*traceSite)
+	opCover                     // count an evaluation of aux (*coverNode)
+	opCheckArray                // fail unless the top value may be destructured by an array pattern
+	opCheckObject               // fail unless the top value may be destructured by an object pattern
+	opElem                      // replace the top value with its element a, or null
+	opAttr                      // pop a key; replace the top value with its attribute, or null
+)
+
+// An inst is a single instruction. Fields other than op are interpreted
+// according to op.
+type inst struct {
+	op     opcode
+	a, b   int
+	v      sift.Value
+	filter sift.Filter
+	gen    genFilter
+	nary   func([]sift.Value) ([]sift.Value, error)
+	aux    interface{}
+
+	// pos is the position attributed to errors reported by the instruction,
+	// or nil if errors should be attributed to the caller.
+	pos *gotoken.Position
+}
+
+// A callSite describes a call to a function defined with def.
+type callSite struct {
+	fn   *function
+	args []*code
+
+	// forward has an element for each argument. If it's not nil, the
+	// argument is just a call to one of the caller's parameters, and that
+	// parameter's closure is passed instead of a closure that calls it.
+	// This keeps recursive functions that pass their parameters along from
+	// building chains of closures.
+	forward []*paramSite
+
+	// pos is the position of the call, reported in stack frames and limit
+	// errors.
+	pos gotoken.Position
+
+	// depth is the trace depth of the call's arguments, relative to the
+	// caller's frame.
+	depth int
+}
+
+// A paramSite describes a call to a function parameter, up levels from the
+// calling code block.
+type paramSite struct {
+	up, index int
+	depth     int
+}
+
+// A goSite describes a call to a function implemented in Go: a builtin,
+// a host function, or a function in CompileOptions.Funcs. Arguments are
+// passed as filters.
+type goSite struct {
+	args  []*code
+	depth int
+	build func(run *runState, args []sift.Filter) sift.Filter
+}
+
+// A genSite is like a goSite for a function that evaluates its arguments
+// and produces its outputs lazily.
+type genSite struct {
+	args  []*code
+	depth int
+	build func(run *runState, args []genFilter) genFilter
+}
+
+// A traceSite describes an expression reported to CompileOptions.Trace.
+type traceSite struct {
+	node     Expr
+	position gotoken.Position
+	depth    int
+	vars     []traceVar
+}
+
+// A traceVar locates a variable visible at a traceSite.
+type traceVar struct {
+	name     string
+	up, slot int
+}
+
+// runState holds what's shared by all machines evaluating a program with
+// one input.
+type runState struct {
+	opts   *CompileOptions
+	host   *Host
+	limits *limits
+}
+
+// A callRecord describes a call in progress. Records form a linked list,
+// innermost first.
+type callRecord struct {
+	parent *callRecord
+
+	// code, pc, and fr are restored when the call returns.
+	code *code
+	pc   int
+	fr   *frame
+
+	// fn is the called function, or nil if a closure was called.
+	fn *function
+
+	// pos is the position of the call, and errPos is the position of the
+	// calling instruction, attributed to errors in the callee that have no
+	// position of their own.
+	pos    gotoken.Position
+	errPos *gotoken.Position
+
+	// depth is the number of function calls in progress, including this
+	// one if fn is set.
+	depth int
+
+	// tails lists the most recent calls this call replaced by being in
+	// tail position, innermost first, so they can be reported in
+	// RuntimeError.Stack.
+	tails []Frame
+}
+
+// maxTailFrames is the number of calls replaced by tail calls that are kept
+// for RuntimeError.Stack.
+const maxTailFrames = 8
+
+func (r *callRecord) frame() Frame {
+	return Frame{Function: funcKey(r.fn.name, len(r.fn.params)), Position: r.pos}
+}
+
+type forkKind uint8
+
+const (
+	forkResume     forkKind = iota // resume at pc
+	forkIter                       // resume at pc with the next of values pushed
+	forkTry                        // catch errors while try is active
+	forkReactivate                 // reactivate try when backtracking into its body
+	forkGen                        // resume at pc with the next value from gen
+)
+
+// A fork is a point the machine may backtrack to. It records the state
+// of the machine when the fork was pushed.
+type fork struct {
+	kind  forkKind
+	code  *code
+	pc    int
+	fr    *frame
+	calls *callRecord
+	stack []sift.Value
+
+	values []sift.Value
+	try    *tryState
+
+	// gen and in are set for forkGen. Errors from gen are attributed
+	// to in.
+	gen generator
+	in  *inst
+}
+
+// tryState tracks whether errors are being caught by a try point. A try
+// is suspended while the continuation consumes an output of its body, so
+// errors in the continuation aren't caught.
+type tryState struct {
+	active bool
+
+	// catch is set if the handler receives the error's payload instead of
+	// the try's input.
+	catch bool
+}
+
+// A machine evaluates a code block with one input value.
+type machine struct {
+	run   *runState
+	code  *code
+	pc    int
+	fr    *frame
+	calls *callRecord
+	stack []sift.Value
+	forks []fork
+
+	// depth is the number of function calls in progress when the machine
+	// was started. It's nonzero for machines evaluating closures passed to
+	// Go functions.
+	depth int
+
+	started, done bool
+
+	// err is an error from a generator that wasn't caught while
+	// backtracking.
+	err error
+}
+
+func newMachine(run *runState, c *code, fr *frame, depth int, input sift.Value) *machine {
+	return &machine{run: run, code: c, fr: fr, depth: depth, stack: []sift.Value{input}}
+}
+
+// next runs the machine until it produces its next output. ok is false
+// when there are no more outputs.
+func (m *machine) next() (v sift.Value, ok bool, err error) {
+	if m.done {
+		return nil, false, nil
+	}
+	if m.started && !m.backtrack() {
+		return m.stop()
+	}
+	m.started = true
+
+	for {
+		in := &m.code.insts[m.pc]
+		m.pc++
+		if l := m.run.limits; l != nil {
+			if err := l.step(); err != nil {
+				m.done = true
+				return nil, false, m.positionError(in.pos, err)
+			}
+		}
+		var err error
+		switch in.op {
+		case opDup:
+			m.push(m.top())
+
+		case opPop:
+			m.pop()
+
+		case opSwap:
+			n := len(m.stack)
+			m.stack[n-1], m.stack[n-2] = m.stack[n-2], m.stack[n-1]
+
+		case opConst:
+			m.setTop(in.v)
+
+		case opLoad:
+			m.setTop(m.fr.up(in.b).locals[in.a])
+
+		case opPush:
+			m.push(m.fr.up(in.b).locals[in.a])
+
+		case opStore:
+			m.fr.locals[in.a] = m.pop()
+
+		case opClear:
+			for _, slot := range in.aux.([]int) {
+				m.fr.locals[slot] = sift.NullValue
+			}
+
+		case opApply:
+			var vs []sift.Value
+			if vs, err = in.filter(m.pop()); err == nil && !m.yield(vs) {
+				return m.stop()
+			}
+
+		case opNary:
+			n := len(m.stack) - in.a
+			args := make([]sift.Value, in.a)
+			copy(args, m.stack[n:])
+			m.stack = m.stack[:n]
+			var vs []sift.Value
+			if vs, err = in.nary(args); err == nil && !m.yield(vs) {
+				return m.stop()
+			}
+
+		case opAnd:
+			if !sift.Truthy(m.pop()) {
+				m.setTop(sift.Must(sift.ToValue(false)))
+				m.pc = in.a
+			}
+
+		case opOr:
+			if sift.Truthy(m.pop()) {
+				m.setTop(sift.Must(sift.ToValue(true)))
+				m.pc = in.a
+			}
+
+		case opTruth:
+			m.setTop(sift.Must(sift.ToValue(sift.Truthy(m.top()))))
+
+		case opFork:
+			m.pushFork(forkResume, in.a)
+
+		case opJump:
+			m.pc = in.a
+
+		case opBacktrack:
+			if !m.backtrack() {
+				return m.stop()
+			}
+
+		case opTryBegin:
+			catch, _ := in.aux.(bool)
+			t := &tryState{active: true, catch: catch}
+			m.fr.aux[in.b] = t
+			m.pushFork(forkTry, in.a).try = t
+
+		case opTryEnd:
+			if t, ok := m.fr.aux[in.b].(*tryState); ok {
+				t.active = false
+				m.pushFork(forkReactivate, 0).try = t
+			}
+
+		case opTryNone:
+			m.fr.aux[in.b] = nil
+
+		case opArrayStart:
+			m.fr.aux[in.a] = sift.NewArray(0)
+
+		case opAppend:
+			m.fr.aux[in.a].(*sift.ArrayBuilder).Append(m.pop())
+
+		case opArrayEnd:
+			m.setTop(m.fr.aux[in.a].(*sift.ArrayBuilder).Build())
+
+		case opCall:
+			err = m.call(in, in.aux.(*callSite))
+
+		case opCallParam:
+			site := in.aux.(*paramSite)
+			cl := m.fr.up(site.up).args[site.index]
+			m.calls = &callRecord{
+				parent: m.calls,
+				code:   m.code,
+				pc:     m.pc,
+				fr:     m.fr,
+				errPos: in.pos,
+				depth:  m.callDepth(),
+			}
+			m.code, m.pc = cl.code, 0
+			m.fr = newFrame(cl.code, cl.fr, nil, m.fr.base+site.depth)
+
+		case opCallGo:
+			site := in.aux.(*goSite)
+			args := make([]sift.Filter, len(site.args))
+			for i, c := range site.args {
+				args[i] = m.closureFilter(closure{c, m.fr}, m.fr.base+site.depth)
+			}
+			var vs []sift.Value
+			if vs, err = site.build(m.run, args)(m.pop()); err == nil && !m.yield(vs) {
+				return m.stop()
+			}
+
+		case opGen:
+			if err = m.generate(in, in.gen(m.pop())); err == nil && m.done {
+				return m.stop()
+			}
+
+		case opCallGen:
+			site := in.aux.(*genSite)
+			args := make([]genFilter, len(site.args))
+			for i, c := range site.args {
+				args[i] = m.closureGen(closure{c, m.fr}, m.fr.base+site.depth)
+			}
+			if err = m.generate(in, site.build(m.run, args)(m.pop())); err == nil && m.done {
+				return m.stop()
+			}
+
+		case opRet:
+			r := m.calls
+			if r == nil {
+				return m.pop(), true, nil
+			}
+			m.code, m.pc, m.fr, m.calls = r.code, r.pc, r.fr, r.parent
+
+		case opTrace:
+			site := in.aux.(*traceSite)
+			step := &Step{
+				Node:     site.node,
+				Position: site.position,
+				Input:    m.top(),
+				Depth:    m.fr.base + site.depth,
+				fr:       m.fr,
+				vars:     site.vars,
+			}
+			if terr := m.run.opts.Trace(step); terr != nil {
+				err = &traceError{terr}
+			}
+
+		case opCover:
+			atomic.AddInt64(&in.aux.(*coverNode).count, 1)
+
+		case opCheckArray:
+			if v := m.top(); !isIndex(v) && !sift.IsNull(v) {
+				err = fmt.Errorf("cannot destructure value %v with array pattern", v)
+			}
+
+		case opCheckObject:
+			if v := m.top(); !isAttr(v) && !sift.IsNull(v) {
+				err = fmt.Errorf("cannot destructure value %v with object pattern", v)
+			}
+
+		case opElem:
+			elem, ok := sift.GetIntIndex(m.top(), in.a)
+			if !ok {
+				elem = sift.NullValue
+			}
+			m.setTop(elem)
+
+		case opAttr:
+			key := m.pop()
+			if _, ok := sift.AsString(key); !ok {
+				err = fmt.Errorf("cannot destructure object with key %v", key)
+			} else {
+				m.setTop(attrOrNull(m.top(), key))
+			}
+
+		default:
+			panic(fmt.Sprintf("unknown opcode %d", in.op))
+		}
+
+		if err != nil {
+			if err = m.raise(in, err); err != nil {
+				m.done = true
+				return nil, false, err
+			}
+		}
+	}
+}
+
+func (m *machine) push(v sift.Value) {
+	m.stack = append(m.stack, v)
+}
+
+func (m *machine) pop() sift.Value {
+	n := len(m.stack) - 1
+	v := m.stack[n]
+	m.stack = m.stack[:n]
+	return v
+}
+
+func (m *machine) top() sift.Value {
+	return m.stack[len(m.stack)-1]
+}
+
+func (m *machine) setTop(v sift.Value) {
+	m.stack[len(m.stack)-1] = v
+}
+
+// yield pushes the first of vs and a fork point for the rest. If vs is
+// empty, yield backtracks instead, returning false if there's nothing to
+// backtrack to.
+func (m *machine) yield(vs []sift.Value) bool {
+	switch len(vs) {
+	case 0:
+		return m.backtrack()
+	case 1:
+		m.push(vs[0])
+	default:
+		m.pushFork(forkIter, m.pc).values = vs[1:]
+		m.push(vs[0])
+	}
+	return true
+}
+
+// generate pushes the first value from g and a fork point for the rest.
+// If g has no values, generate backtracks instead, setting m.done if
+// there's nothing to backtrack to.
+func (m *machine) generate(in *inst, g generator) error {
+	v, ok, err := g()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		m.done = !m.backtrack()
+		return nil
+	}
+	f := m.pushFork(forkGen, m.pc)
+	f.gen, f.in = g, in
+	m.push(v)
+	return nil
+}
+
+// stop ends evaluation when there's nothing left to backtrack to.
+func (m *machine) stop() (sift.Value, bool, error) {
+	m.done = true
+	return nil, false, m.err
+}
+
+// pushFork pushes a fork point that saves the machine's current state and
+// resumes at pc.
+func (m *machine) pushFork(kind forkKind, pc int) *fork {
+	var stack []sift.Value
+	if kind != forkReactivate {
+		stack = make([]sift.Value, len(m.stack))
+		copy(stack, m.stack)
+	}
+	m.forks = append(m.forks, fork{
+		kind:  kind,
+		code:  m.code,
+		pc:    pc,
+		fr:    m.fr,
+		calls: m.calls,
+		stack: stack,
+	})
+	return &m.forks[len(m.forks)-1]
+}
+
+func (m *machine) restore(f *fork) {
+	m.code, m.pc, m.fr, m.calls = f.code, f.pc, f.fr, f.calls
+	m.stack = append(m.stack[:0], f.stack...)
+}
+
+// backtrack resumes at the most recent fork point. It returns false if
+// there are none left.
+func (m *machine) backtrack() bool {
+	for len(m.forks) > 0 {
+		n := len(m.forks) - 1
+		f := &m.forks[n]
+		switch f.kind {
+		case forkResume:
+			m.restore(f)
+			m.forks = m.forks[:n]
+			return true
+
+		case forkIter:
+			m.restore(f)
+			m.push(f.values[0])
+			if f.values = f.values[1:]; len(f.values) == 0 {
+				m.forks = m.forks[:n]
+			}
+			return true
+
+		case forkTry:
+			m.forks = m.forks[:n]
+
+		case forkReactivate:
+			f.try.active = true
+			m.forks = m.forks[:n]
+
+		case forkGen:
+			v, ok, err := f.gen()
+			if err != nil {
+				m.restore(f)
+				in := f.in
+				m.forks = m.forks[:n]
+				if m.err = m.raise(in, err); m.err != nil {
+					return false
+				}
+				return true
+			}
+			if !ok {
+				m.forks = m.forks[:n]
+				continue
+			}
+			m.restore(f)
+			m.push(v)
+			return true
+		}
+	}
+	return false
+}
+
+// raise reports an error returned by in. It attaches a position and call
+// stack to the error, then unwinds fork points until an active try point
+// catches it. raise returns nil if the error was caught; the machine
+// continues at the try point's handler. Otherwise, raise returns the error.
+func (m *machine) raise(in *inst, err error) error {
+	err = m.positionError(in.pos, err)
+	catchable := !isUncatchable(err)
+	for len(m.forks) > 0 {
+		n := len(m.forks) - 1
+		f := m.forks[n]
+		m.forks = m.forks[:n]
+		if f.kind == forkTry && f.try.active && catchable {
+			m.restore(&f)
+			if f.try.catch {
+				m.setTop(errorPayload(err))
+			}
+			return nil
+		}
+	}
+	return err
+}
+
+// errorPayload returns the value a catch handler receives for err: the
+// payload of an ErrorValue raised with error, or otherwise the error's
+// message without a position.
+func errorPayload(err error) sift.Value {
+	if ev, ok := sift.AsErrorValue(err); ok {
+		return ev.Value
+	}
+	var rerr *RuntimeError
+	if errors.As(err, &rerr) {
+		err = rerr.Err
+	}
+	return sift.Must(sift.ToValue(err.Error()))
+}
+
+// positionError returns err as a *RuntimeError. If err doesn't already
+// have a position, it's attributed to pos, or if pos is nil, to the
+// innermost call with a position. Calls to functions defined with def
+// that the error propagates through are recorded in its stack. If no
+// position is found, err is returned unchanged.
+func (m *machine) positionError(pos *gotoken.Position, err error) error {
+	r := m.calls
+	var rerr *RuntimeError
+	if !errors.As(err, &rerr) {
+		for ; pos == nil && r != nil; r = r.parent {
+			pos = r.errPos
+		}
+		if pos == nil {
+			return err
+		}
+		rerr = &RuntimeError{Position: *pos, Err: err}
+		err = rerr
+		if ev, ok := sift.AsErrorValue(rerr.Err); ok && ev.Position == "" {
+			ev.Position = pos.String()
+		}
+	}
+	for ; r != nil; r = r.parent {
+		if r.fn != nil {
+			rerr.Stack = append(rerr.Stack, r.frame())
+			rerr.Stack = append(rerr.Stack, r.tails...)
+		}
+	}
+	return err
+}
+
+// callDepth returns the number of function calls in progress.
+func (m *machine) callDepth() int {
+	if m.calls != nil {
+		return m.calls.depth
+	}
+	return m.depth
+}
+
+// call calls a function defined with def. Arguments are bound as closures
+// in the caller's frame.
+//
+// A call in tail position, where the caller would return the callee's
+// outputs unchanged, replaces the caller's call record instead of adding
+// one, so tail-recursive functions run in constant space and don't count
+// toward MaxDepth. The caller is still listed in RuntimeError.Stack, unless
+// it's one of many consecutive tail calls.
+func (m *machine) call(in *inst, site *callSite) error {
+	ret := &callRecord{
+		parent: m.calls,
+		code:   m.code,
+		pc:     m.pc,
+		fr:     m.fr,
+		fn:     site.fn,
+		pos:    site.pos,
+		errPos: in.pos,
+	}
+	depth := m.callDepth() + 1
+	if caller := m.calls; caller != nil && caller.fn != nil && m.atReturn() {
+		// Return directly to the caller's caller. Errors in the callee
+		// without a position are attributed as they would be in the
+		// caller.
+		ret.parent, ret.code, ret.pc, ret.fr = caller.parent, caller.code, caller.pc, caller.fr
+		if ret.errPos == nil {
+			ret.errPos = caller.errPos
+		}
+		depth = caller.depth
+		n := len(caller.tails) + 1
+		if n > maxTailFrames {
+			n = maxTailFrames
+		}
+		ret.tails = make([]Frame, 0, n)
+		ret.tails = append(ret.tails, caller.frame())
+		ret.tails = append(ret.tails, caller.tails[:n-1]...)
+	}
+	if l := m.run.limits; l != nil {
+		if err := l.check(depth); err != nil {
+			return &RuntimeError{Position: site.pos, Err: err}
+		}
+	}
+	ret.depth = depth
+	args := make([]closure, len(site.args))
+	for i, c := range site.args {
+		if p := site.forward[i]; p != nil {
+			args[i] = m.fr.up(p.up).args[p.index]
+		} else {
+			args[i] = closure{c, m.fr}
+		}
+	}
+	fn := site.fn
+	parent := m.fr.up(m.code.level - fn.level)
+	m.calls = ret
+	m.code, m.pc = fn.code, 0
+	m.fr = newFrame(fn.code, parent, args, m.fr.base+site.depth)
+	return nil
+}
+
+// atReturn reports whether the instruction at pc returns, possibly after
+// unconditional jumps.
+func (m *machine) atReturn() bool {
+	for pc := m.pc; ; {
+		switch in := &m.code.insts[pc]; in.op {
+		case opRet:
+			return true
+		case opJump:
+			pc = in.a
+		default:
+			return false
+		}
+	}
+}
+
+// closureFilter returns a filter that evaluates a closure with a new
+// machine, so that it may be passed to a Go function.
+func (m *machine) closureFilter(cl closure, base int) sift.Filter {
+	return collect(m.closureGen(cl, base))
+}
+
+// closureGen is like closureFilter, but the closure's outputs are produced
+// lazily. The new machine runs only as far as needed to produce each one.
+func (m *machine) closureGen(cl closure, base int) genFilter {
+	depth := m.callDepth()
+	return func(v sift.Value) generator {
+		fr := newFrame(cl.code, cl.fr, nil, base)
+		return newMachine(m.run, cl.code, fr, depth, v).next
+	}
+}
+
+func isIndex(v sift.Value) bool {
+	_, ok := v.(sift.Index)
+	return ok
+}
+
+// isArray returns whether v is an array, either random-access or produced
+// by an iterator.
+func isArray(v sift.Value) bool {
+	if _, ok := v.(sift.Iterator); ok {
+		return true
+	}
+	return isIndex(v)
+}
+
+func isAttr(v sift.Value) bool {
+	_, ok := v.(sift.Attr)
+	return ok
+}
@@ -0,0 +1,415 @@
+package sift_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+)
+
+func TestAndOr(t *testing.T) {
+	errEvaluated := errors.New("right operand evaluated")
+	fail := func(sift.Value) ([]sift.Value, error) { return nil, errEvaluated }
+	lit := func(i interface{}) sift.Filter { return sift.Literal(sift.Must(sift.ToValue(i))) }
+
+	for _, tc := range []struct {
+		desc    string
+		f       sift.Filter
+		want    []interface{}
+		wantErr error
+	}{
+		{
+			desc: "and_false",
+			f:    sift.And(lit(false), fail),
+			want: []interface{}{false},
+		}, {
+			desc: "and_null",
+			f:    sift.And(lit(nil), fail),
+			want: []interface{}{false},
+		}, {
+			desc:    "and_true",
+			f:       sift.And(lit(true), fail),
+			wantErr: errEvaluated,
+		}, {
+			desc: "and_values",
+			f:    sift.And(lit(1), sift.Concat(lit(0), lit(nil))),
+			want: []interface{}{true, false},
+		}, {
+			desc: "or_true",
+			f:    sift.Or(lit("x"), fail),
+			want: []interface{}{true},
+		}, {
+			desc:    "or_false",
+			f:       sift.Or(lit(false), fail),
+			wantErr: errEvaluated,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := tc.f(sift.NullValue)
+			if err != tc.wantErr {
+				t.Fatalf("got error %v; want %v", err, tc.wantErr)
+			}
+			want := sift.Must(sift.ToValue(tc.want))
+			if err == nil && !sift.Equal(sift.Must(sift.ToValue(got)), want) {
+				t.Errorf("got %v; want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	errBad := errors.New("not a number")
+	even := func(v sift.Value) (bool, error) {
+		n, ok := sift.AsFloat64(v)
+		if !ok {
+			return false, errBad
+		}
+		return int(n)%2 == 0, nil
+	}
+	input := []interface{}{1, 2, 3, 4}
+
+	for _, tc := range []struct {
+		desc string
+		f    sift.Filter
+		want []interface{}
+	}{
+		{desc: "select", f: sift.Select(even), want: []interface{}{2, 4}},
+		{desc: "reject", f: sift.Reject(even), want: []interface{}{1, 3}},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			var got []sift.Value
+			for _, i := range input {
+				vs, err := tc.f(sift.Must(sift.ToValue(i)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, vs...)
+			}
+			want := sift.Must(sift.ToValue(tc.want))
+			if !sift.Equal(sift.Must(sift.ToValue(got)), want) {
+				t.Errorf("got %v; want %v", got, want)
+			}
+			if _, err := tc.f(sift.NullValue); err != errBad {
+				t.Errorf("got error %v; want %v", err, errBad)
+			}
+		})
+	}
+}
+
+// errorDecoder decodes values from a list. For indices in errs, it returns
+// the error instead of the value.
+type errorDecoder struct {
+	values []interface{}
+	errs   map[int]error
+	i      int
+}
+
+func (d *errorDecoder) Decode() (sift.Value, error) {
+	if d.i >= len(d.values) {
+		return nil, io.EOF
+	}
+	i := d.i
+	d.i++
+	if err := d.errs[i]; err != nil {
+		return nil, err
+	}
+	return sift.ToValue(d.values[i])
+}
+
+// errorList is an error that can't be compared with ==.
+type errorList []error
+
+func (l errorList) Error() string {
+	return fmt.Sprint([]error(l))
+}
+
+func TestSiftWithOptions(t *testing.T) {
+	errDecode := errors.New("decode error")
+	errOdd := errors.New("odd value")
+	rejectOdd := func(v sift.Value) ([]sift.Value, error) {
+		if n, _ := sift.AsFloat64(v); int(n)%2 == 1 {
+			return nil, errOdd
+		}
+		return []sift.Value{v}, nil
+	}
+
+	for _, tc := range []struct {
+		desc          string
+		opts          sift.SiftOptions
+		want          []interface{}
+		wantPositions []int64
+		wantErr       error
+	}{
+		{
+			desc:    "stop",
+			want:    []interface{}{0},
+			wantErr: errOdd,
+		}, {
+			desc:          "continue",
+			opts:          sift.SiftOptions{ContinueOnError: true},
+			want:          []interface{}{0, 2, 6},
+			wantPositions: []int64{1, 3, 4, 5},
+		}, {
+			desc:          "max_errors",
+			opts:          sift.SiftOptions{ContinueOnError: true, MaxErrors: 1},
+			want:          []interface{}{0, 2},
+			wantPositions: []int64{1, 3},
+		}, {
+			desc: "on_error",
+			opts: sift.SiftOptions{OnError: func(err *sift.InputError) error {
+				if errors.Is(err, errDecode) {
+					return err.Err
+				}
+				return nil
+			}},
+			want:    []interface{}{0, 2},
+			wantErr: errDecode,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dec := &errorDecoder{
+				values: []interface{}{0, 1, 2, 3, 4, 5, 6},
+				errs:   map[int]error{4: errDecode},
+			}
+			enc := &recordEncoder{}
+			err := sift.SiftWithOptions(dec, rejectOdd, enc, tc.opts)
+			if tc.wantPositions != nil {
+				var errs sift.InputErrors
+				if !errors.As(err, &errs) {
+					t.Fatalf("got error %v; want InputErrors", err)
+				}
+				var got []int64
+				for _, e := range errs {
+					got = append(got, e.Position)
+				}
+				if fmt.Sprint(got) != fmt.Sprint(tc.wantPositions) {
+					t.Errorf("got errors at positions %v; want %v", got, tc.wantPositions)
+				}
+			} else if err != tc.wantErr {
+				t.Fatalf("got error %v; want %v", err, tc.wantErr)
+			}
+			want := sift.Must(sift.ToValue(tc.want))
+			if got := sift.Must(sift.ToValue(enc.values)); !sift.Equal(got, want) {
+				t.Errorf("got %v; want %v", got, want)
+			}
+		})
+	}
+
+	t.Run("repeated_decode_error", func(t *testing.T) {
+		dec := &errorDecoder{values: make([]interface{}, 3), errs: map[int]error{0: errDecode, 1: errDecode}}
+		err := sift.SiftWithOptions(dec, rejectOdd, &recordEncoder{}, sift.SiftOptions{ContinueOnError: true})
+		var errs sift.InputErrors
+		if !errors.As(err, &errs) || len(errs) != 1 {
+			t.Errorf("got error %v; want one skipped error", err)
+		}
+	})
+
+	t.Run("repeated_uncomparable_error", func(t *testing.T) {
+		// Comparing these errors with == would panic.
+		dec := &errorDecoder{values: make([]interface{}, 3), errs: map[int]error{
+			0: errorList{errDecode},
+			1: errorList{errDecode},
+		}}
+		err := sift.SiftWithOptions(dec, rejectOdd, &recordEncoder{}, sift.SiftOptions{ContinueOnError: true})
+		var errs sift.InputErrors
+		if !errors.As(err, &errs) || len(errs) != 1 {
+			t.Errorf("got error %v; want one skipped error", err)
+		}
+	})
+
+	t.Run("max_errors_stats", func(t *testing.T) {
+		dec := &errorDecoder{values: []interface{}{1, 2, 3, 4, 5}}
+		var stats sift.SiftStats
+		opts := sift.SiftOptions{ContinueOnError: true, MaxErrors: 1, Stats: &stats}
+		err := sift.SiftWithOptions(dec, rejectOdd, &recordEncoder{}, opts)
+		var errs sift.InputErrors
+		if !errors.As(err, &errs) {
+			t.Fatalf("got error %v; want InputErrors", err)
+		}
+		if stats.Skipped != len(errs) || stats.Skipped != 2 {
+			t.Errorf("got %d skipped with %d errors; want 2", stats.Skipped, len(errs))
+		}
+	})
+
+	t.Run("repeated_decode_error_flush", func(t *testing.T) {
+		// The JSON decoder returns the same error after a syntax error.
+		// Values written before it must still be flushed.
+		dec := json.NewDecoder(strings.NewReader(`2 {"a": } 4`))
+		rec := &recordEncoder{}
+		enc := sift.NewBatchEncoder(rec, sift.BatchOptions{Count: 10})
+		err := sift.SiftWithOptions(dec, rejectOdd, enc, sift.SiftOptions{OnError: func(*sift.InputError) error { return nil }})
+		var errs sift.InputErrors
+		if !errors.As(err, &errs) {
+			t.Fatalf("got error %v; want InputErrors", err)
+		}
+		want := sift.Must(sift.ToValue([]interface{}{[]interface{}{2}}))
+		if got := sift.Must(sift.ToValue(rec.values)); !sift.Equal(got, want) {
+			t.Errorf("got %v; want %v", got, want)
+		}
+	})
+}
+
+func TestSiftStats(t *testing.T) {
+	rejectOdd := func(v sift.Value) ([]sift.Value, error) {
+		if n, _ := sift.AsFloat64(v); int(n)%2 == 1 {
+			return nil, errors.New("odd value")
+		}
+		return []sift.Value{v}, nil
+	}
+	dec := sift.ChainDecoders(
+		json.NewDecoder(strings.NewReader("0 1")),
+		json.NewDecoder(strings.NewReader("2 3")),
+	)
+	out := &strings.Builder{}
+	enc := sift.WrapEncoder(json.NewEncoder(out), sift.Tee(&recordEncoder{}))
+	var stats sift.SiftStats
+	opts := sift.SiftOptions{ContinueOnError: true, Stats: &stats}
+	if err := sift.SiftWithOptions(dec, rejectOdd, enc, opts); err == nil {
+		t.Fatal("unexpected success")
+	}
+	if stats.Elapsed < 0 {
+		t.Errorf("got elapsed time %v; want non-negative", stats.Elapsed)
+	}
+	stats.Elapsed = 0
+	want := sift.SiftStats{Decoded: 4, Emitted: 2, BytesRead: 6, BytesWritten: 4, Skipped: 2}
+	if stats != want {
+		t.Errorf("got %+v; want %+v", stats, want)
+	}
+}
+
+func TestSiftRateLimit(t *testing.T) {
+	identity := sift.Map(func(v sift.Value) sift.Value { return v })
+	input := sift.Must(sift.ToValue([]interface{}{1, 2, 3}))
+
+	for _, tc := range []struct {
+		desc string
+		opts sift.SiftOptions
+	}{
+		// Three values are written two intervals apart.
+		{desc: "values", opts: sift.SiftOptions{ValuesPerSecond: 100}},
+		// Each value and newline is two bytes.
+		{desc: "bytes", opts: sift.SiftOptions{BytesPerSecond: 200}},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dec, _ := sift.Iterate(input)
+			out := &strings.Builder{}
+			start := time.Now()
+			if err := sift.SiftWithOptions(dec, identity, json.NewEncoder(out), tc.opts); err != nil {
+				t.Fatal(err)
+			}
+			if elapsed, want := time.Since(start), 20*time.Millisecond; elapsed < want {
+				t.Errorf("wrote 3 values in %v; want at least %v", elapsed, want)
+			}
+			if got, want := out.String(), "1\n2\n3\n"; got != want {
+				t.Errorf("got %q; want %q", got, want)
+			}
+		})
+	}
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		dec, _ := sift.Iterate(input)
+		rec := &recordEncoder{}
+		opts := sift.SiftOptions{ValuesPerSecond: 0.001, Context: ctx}
+		if err := sift.SiftWithOptions(dec, identity, rec, opts); err != context.DeadlineExceeded {
+			t.Errorf("got error %v; want %v", err, context.DeadlineExceeded)
+		}
+		if len(rec.values) != 1 {
+			t.Errorf("got %d values written; want 1", len(rec.values))
+		}
+	})
+
+	t.Run("max_buffered", func(t *testing.T) {
+		dec, _ := sift.Iterate(sift.Must(sift.ToValue([]interface{}{1, 2, 3, 4, 5})))
+		rec := &recordEncoder{}
+		enc := sift.NewBatchEncoder(rec, sift.BatchOptions{Count: 10})
+		if err := sift.SiftWithOptions(dec, identity, enc, sift.SiftOptions{MaxBuffered: 2}); err != nil {
+			t.Fatal(err)
+		}
+		want := sift.Must(sift.ToValue([]interface{}{[]interface{}{1, 2}, []interface{}{3, 4}, []interface{}{5}}))
+		if got := sift.Must(sift.ToValue(rec.values)); !sift.Equal(got, want) {
+			t.Errorf("got batches %v; want %v", got, want)
+		}
+	})
+
+	t.Run("max_buffered_undrainable", func(t *testing.T) {
+		// Flushing a reducing encoder ends the stream, so it can't be
+		// drained early.
+		dec, _ := sift.Iterate(sift.Must(sift.ToValue([]interface{}{1, 2, 3})))
+		rec := &recordEncoder{}
+		enc := sift.WrapEncoder(rec, sift.ReduceStream(sift.Must(sift.ToValue(0)), sumValues), sift.CountValues(new(int64)))
+		if err := sift.SiftWithOptions(dec, identity, enc, sift.SiftOptions{MaxBuffered: 2}); err == nil {
+			t.Fatal("unexpected success")
+		}
+		for _, v := range rec.values {
+			if n, _ := sift.AsFloat64(v); n != 6 {
+				t.Errorf("got partial sum %v", v)
+			}
+		}
+
+		dec, _ = sift.Iterate(sift.Must(sift.ToValue([]interface{}{1, 2, 3})))
+		enc = sift.WrapEncoder(rec, sift.ReduceStream(sift.Must(sift.ToValue(0)), sumValues))
+		if err := sift.SiftWithOptions(dec, identity, enc, sift.SiftOptions{MaxBuffered: 2}); err == nil {
+			t.Fatal("unexpected success")
+		}
+	})
+}
+
+func TestChainDecoders(t *testing.T) {
+	errDecode := errors.New("decode error")
+	iterate := func(i interface{}) sift.Decoder {
+		dec, _ := sift.Iterate(sift.Must(sift.ToValue(i)))
+		return dec
+	}
+	dec := sift.ChainDecoders(
+		iterate([]interface{}{1, 2}),
+		iterate([]interface{}{}),
+		&errorDecoder{values: []interface{}{nil, 3}, errs: map[int]error{0: errDecode}},
+	)
+	var got []sift.Value
+	var errs []error
+	for {
+		v, err := dec.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		got = append(got, v)
+	}
+	want := sift.Must(sift.ToValue([]interface{}{1, 2, 3}))
+	if !sift.Equal(sift.Must(sift.ToValue(got)), want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+	if len(errs) != 1 || errs[0] != errDecode {
+		t.Errorf("got errors %v; want [%v]", errs, errDecode)
+	}
+}
+
+func TestChainDecodersSkipFailedInput(t *testing.T) {
+	// The first decoder can't continue after its syntax error, so the rest
+	// of its input is skipped, and the second decoder is read.
+	dec := sift.ChainDecoders(
+		json.NewDecoder(strings.NewReader(`1 {bad`)),
+		json.NewDecoder(strings.NewReader(`2 3`)),
+	)
+	rec := &recordEncoder{}
+	identity := sift.Map(func(v sift.Value) sift.Value { return v })
+	err := sift.SiftWithOptions(dec, identity, rec, sift.SiftOptions{ContinueOnError: true})
+	var errs sift.InputErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Position != 1 {
+		t.Errorf("got error %v; want one skipped error at position 1", err)
+	}
+	want := sift.Must(sift.ToValue([]interface{}{1, 2, 3}))
+	if got := sift.Must(sift.ToValue(rec.values)); !sift.Equal(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
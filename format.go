@@ -0,0 +1,169 @@
+package sift
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format writes a human-readable description of v to w. Unlike an encoded
+// form like JSON, the description is meant for debugging: each value is
+// labeled with its type, nested values are indented, and values that
+// JSON can't represent, like byte strings, times, functions, and cycles,
+// are shown as they are. Annotated values are followed by their
+// annotations. The format may change between versions of this package.
+//
+// For example, the JSON value {"a": [1, "x"]} is described as:
+//
+//	object {
+//	  "a": array [
+//	    number 1
+//	    string "x"
+//	  ]
+//	}
+func Format(w io.Writer, v Value) error {
+	bw := bufio.NewWriter(w)
+	f := formatter{w: bw, visiting: make(map[identity]bool)}
+	f.format(v, 0)
+	bw.WriteByte('\n')
+	return bw.Flush()
+}
+
+// Sdump returns the description of v written by Format.
+func Sdump(v Value) string {
+	b := &strings.Builder{}
+	Format(b, v)
+	return b.String()
+}
+
+type formatter struct {
+	w *bufio.Writer
+
+	// visiting records objects and arrays being formatted, so cycles
+	// are not followed.
+	visiting map[identity]bool
+}
+
+func (f *formatter) format(v Value, depth int) {
+	if v == nil {
+		f.w.WriteString("<missing>")
+		return
+	}
+	var ann string
+	if a, ok := v.(Annotated); ok {
+		ann = " @ " + a.Annotations().String()
+		v = Strip(v)
+	}
+	defer f.w.WriteString(ann)
+
+	switch v := v.(type) {
+	case *ErrorValue:
+		f.w.WriteString("error ")
+		f.format(v.Value, depth)
+		return
+	case Func:
+		fmt.Fprint(f.w, v)
+		return
+	}
+
+	switch kindOrder(v) {
+	case kindNull:
+		f.w.WriteString("null")
+	case kindFalse, kindTrue:
+		b, _ := AsBool(v)
+		f.w.WriteString("boolean " + strconv.FormatBool(b))
+	case kindNumber:
+		if text, ok := AsBigNumber(v); ok {
+			f.w.WriteString("bignumber " + text)
+		} else {
+			f.w.WriteString("number " + numberKey(v))
+		}
+	case kindTime:
+		t, _ := AsTime(v)
+		f.w.WriteString("time " + t.Format(time.RFC3339Nano))
+	case kindString:
+		if b, ok := AsBytes(v); ok {
+			f.w.WriteString("bytes " + strconv.Quote(string(b)))
+		} else {
+			s, _ := AsString(v)
+			f.w.WriteString("string " + strconv.Quote(s))
+		}
+	case kindArray:
+		ix := v.(Index)
+		if !f.enter(v) {
+			f.w.WriteString("array <cycle>")
+			return
+		}
+		defer f.leave(v)
+		n := ix.Length()
+		if n == 0 {
+			f.w.WriteString("array []")
+			return
+		}
+		f.w.WriteString("array [\n")
+		for i := 0; i < n; i++ {
+			f.indent(depth + 1)
+			e, _ := ix.Index(i)
+			f.format(e, depth+1)
+			f.w.WriteByte('\n')
+		}
+		f.indent(depth)
+		f.w.WriteByte(']')
+	default:
+		a, ok := v.(Attr)
+		if !ok {
+			fmt.Fprint(f.w, v)
+			return
+		}
+		if !f.enter(v) {
+			f.w.WriteString("object <cycle>")
+			return
+		}
+		defer f.leave(v)
+		keys := a.Keys()
+		if len(keys) == 0 {
+			f.w.WriteString("object {}")
+			return
+		}
+		f.w.WriteString("object {\n")
+		for _, key := range keys {
+			f.indent(depth + 1)
+			f.w.WriteString(keyString(key))
+			f.w.WriteString(": ")
+			e, _ := a.Attr(key)
+			f.format(e, depth+1)
+			f.w.WriteByte('\n')
+		}
+		f.indent(depth)
+		f.w.WriteByte('}')
+	}
+}
+
+func (f *formatter) indent(depth int) {
+	for i := 0; i < depth; i++ {
+		f.w.WriteString("  ")
+	}
+}
+
+// enter records that v is being formatted. It returns false if v is
+// already being formatted, which means v contains itself.
+func (f *formatter) enter(v Value) bool {
+	id, ok := identify(v)
+	if !ok {
+		return true
+	}
+	if f.visiting[id] {
+		return false
+	}
+	f.visiting[id] = true
+	return true
+}
+
+func (f *formatter) leave(v Value) {
+	if id, ok := identify(v); ok {
+		delete(f.visiting, id)
+	}
+}
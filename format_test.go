@@ -0,0 +1,77 @@
+package sift_test
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestFormat(t *testing.T) {
+	cycle := map[string]interface{}{"a": 1}
+	cycleValue := sift.Must(sift.ToValue(cycle)).(sift.SetAttr)
+	cycleValue.SetAttr(sift.Must(sift.ToValue("self")), cycleValue)
+
+	for _, tc := range []struct {
+		desc string
+		v    sift.Value
+		want string
+	}{
+		{
+			desc: "scalars",
+			v: sift.Must(sift.ToValue([]interface{}{
+				nil, true, 1.5, "x\n", []byte("b"),
+				time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				new(big.Int).Lsh(big.NewInt(1), 64),
+			})),
+			want: `array [
+  null
+  boolean true
+  number 1.5
+  string "x\n"
+  bytes "b"
+  time 2024-01-02T03:04:05Z
+  bignumber 18446744073709551616
+]
+`,
+		}, {
+			desc: "nested",
+			v:    decodeJSON(t, `{"a": [1, {}], "b c": []}`),
+			want: `object {
+  "a": array [
+    number 1
+    object {}
+  ]
+  "b c": array []
+}
+`,
+		}, {
+			desc: "annotated",
+			v:    sift.Annotate(decodeJSON(t, `[1]`), sift.Annotations{Source: "in.json", Line: 2}),
+			want: `array [
+  number 1
+] @ in.json:2
+`,
+		}, {
+			desc: "error",
+			v:    &sift.ErrorValue{Value: decodeJSON(t, `"bad"`)},
+			want: `error string "bad"
+`,
+		}, {
+			desc: "cycle",
+			v:    cycleValue,
+			want: `object {
+  "a": number 1
+  "self": object <cycle>
+}
+`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := sift.Sdump(tc.v); got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
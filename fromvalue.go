@@ -0,0 +1,458 @@
+package sift
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FromValue stores v in the Go value dst points to. It's the inverse of
+// ToValue. dst must be a non-nil pointer.
+//
+// Values are stored according to the type of the destination:
+//
+//   - Null sets pointers, interfaces, maps, and slices to nil and leaves
+//     other values unchanged.
+//   - Pointers are allocated if they're nil, and v is stored in the value
+//     they point to.
+//   - Booleans, numbers, and strings are stored in destinations of the
+//     corresponding kind. Numbers stored in integers must be integers that
+//     fit in the destination's type.
+//   - A Time is stored in a time.Time. A string in RFC 3339 format may be
+//     stored in a time.Time, too.
+//   - A Bytes is stored in a byte slice. A base64 string may be stored in
+//     a byte slice, too, as with encoding/json.
+//   - A number is stored in a *big.Int or *big.Float exactly, if possible.
+//   - Objects are stored in structs, using the same field names as ToValue.
+//     Keys without a matching field are ignored. As with encoding/json,
+//     a key matches a field whose name differs only in case if there's no
+//     exact match.
+//   - Objects are stored in maps with string, integer, or
+//     encoding.TextUnmarshaler keys.
+//   - Arrays and iterators are stored in slices and arrays. Elements beyond
+//     the length of an array are ignored.
+//   - Any value may be stored in a Value, which is set to v.
+//   - Any value may be stored in an empty interface. Null, booleans,
+//     strings, objects, and arrays are stored as nil, bool, string,
+//     map[string]interface{}, and []interface{}. Numbers are stored as
+//     float64, or as json.Number if they're big numbers. Times and byte
+//     strings are stored as time.Time and []byte.
+//
+// If v can't be stored, FromValue returns an error describing where in v
+// the problem was found. dst may be partially modified.
+func FromValue(v Value, dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("FromValue: destination must be a non-nil pointer, not %T", dst)
+	}
+	if err := fromValue(v, rv.Elem()); err != nil {
+		if fe, ok := err.(*fromValueError); ok && fe.path != "" {
+			return fmt.Errorf("at %s: %s", fe.path, fe.msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// fromValueError is returned by fromValue. path is built up as the error
+// is returned through nested values.
+type fromValueError struct {
+	path, msg string
+}
+
+func (e *fromValueError) Error() string { return e.msg }
+
+// inKey adds an object key to the path of an error from a nested value.
+func inKey(err error, key string) error {
+	fe, ok := err.(*fromValueError)
+	if !ok {
+		return err
+	}
+	return &fromValueError{path: fieldSuffix(key) + fe.path, msg: fe.msg}
+}
+
+// inIndex adds an array index to the path of an error from a nested value.
+func inIndex(err error, i int) error {
+	fe, ok := err.(*fromValueError)
+	if !ok {
+		return err
+	}
+	return &fromValueError{path: "[" + strconv.Itoa(i) + "]" + fe.path, msg: fe.msg}
+}
+
+func cannotStore(v Value, t reflect.Type) error {
+	return &fromValueError{msg: fmt.Sprintf("cannot store %s in %v", typeName(v), t)}
+}
+
+var (
+	valueType = reflect.TypeOf((*Value)(nil)).Elem()
+	timeT     = reflect.TypeOf(time.Time{})
+	bigIntT   = reflect.TypeOf(big.Int{})
+	bigFloatT = reflect.TypeOf(big.Float{})
+)
+
+func fromValue(v Value, rv reflect.Value) error {
+	t := rv.Type()
+	if t == valueType {
+		rv.Set(reflect.ValueOf(&v).Elem())
+		return nil
+	}
+	if IsNull(v) {
+		switch rv.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+			rv.Set(reflect.Zero(t))
+		}
+		return nil
+	}
+
+	switch t {
+	case timeT:
+		if tm, ok := AsTime(v); ok {
+			rv.Set(reflect.ValueOf(tm))
+			return nil
+		} else if s, ok := AsString(v); ok {
+			tm, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return &fromValueError{msg: err.Error()}
+			}
+			rv.Set(reflect.ValueOf(tm))
+			return nil
+		}
+		return cannotStore(v, t)
+	case bigIntT:
+		i, ok := AsBigInt(v)
+		if !ok {
+			return cannotStore(v, t)
+		}
+		rv.Set(reflect.ValueOf(i).Elem())
+		return nil
+	case bigFloatT:
+		text, ok := AsBigNumber(v)
+		if !ok {
+			f, isNumber := AsFloat64(v)
+			if !isNumber {
+				return cannotStore(v, t)
+			}
+			text = strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		f, _, err := big.ParseFloat(text, 10, 0, big.ToNearestEven)
+		if err != nil {
+			return cannotStore(v, t)
+		}
+		rv.Set(reflect.ValueOf(f).Elem())
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			rv.Set(reflect.New(t.Elem()))
+		}
+		return fromValue(v, rv.Elem())
+
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			return cannotStore(v, t)
+		}
+		i, err := interfaceOf(v)
+		if err != nil {
+			return &fromValueError{msg: err.Error()}
+		}
+		rv.Set(reflect.ValueOf(&i).Elem())
+		return nil
+
+	case reflect.Bool:
+		b, ok := AsBool(v)
+		if !ok {
+			return cannotStore(v, t)
+		}
+		rv.SetBool(b)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := AsBigInt(v)
+		if !ok || !i.IsInt64() || rv.OverflowInt(i.Int64()) {
+			return cannotStore(v, t)
+		}
+		rv.SetInt(i.Int64())
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := AsBigInt(v)
+		if !ok || !i.IsUint64() || rv.OverflowUint(i.Uint64()) {
+			return cannotStore(v, t)
+		}
+		rv.SetUint(i.Uint64())
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		f, ok := AsFloat64(v)
+		if !ok || rv.OverflowFloat(f) {
+			return cannotStore(v, t)
+		}
+		rv.SetFloat(f)
+		return nil
+
+	case reflect.String:
+		s, ok := AsString(v)
+		if !ok {
+			return cannotStore(v, t)
+		}
+		rv.SetString(s)
+		return nil
+
+	case reflect.Struct:
+		return fromValueStruct(v, rv)
+
+	case reflect.Map:
+		return fromValueMap(v, rv)
+
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			if b, ok := AsBytes(v); ok {
+				rv.SetBytes(append([]byte(nil), b...))
+				return nil
+			} else if s, ok := AsString(v); ok {
+				b, err := base64.StdEncoding.DecodeString(s)
+				if err != nil {
+					return &fromValueError{msg: err.Error()}
+				}
+				rv.SetBytes(b)
+				return nil
+			}
+		}
+		fallthrough
+
+	case reflect.Array:
+		elems, ok := Iterate(v)
+		if !ok {
+			return cannotStore(v, t)
+		}
+		if rv.Kind() == reflect.Slice {
+			rv.Set(reflect.MakeSlice(t, 0, 0))
+		}
+		for i := 0; ; i++ {
+			e, err := elems.Decode()
+			if err == io.EOF {
+				if rv.Kind() == reflect.Array {
+					for ; i < rv.Len(); i++ {
+						rv.Index(i).Set(reflect.Zero(t.Elem()))
+					}
+				}
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if rv.Kind() == reflect.Array {
+				if i >= rv.Len() {
+					return nil
+				}
+				if err := fromValue(e, rv.Index(i)); err != nil {
+					return inIndex(err, i)
+				}
+				continue
+			}
+			ev := reflect.New(t.Elem()).Elem()
+			if err := fromValue(e, ev); err != nil {
+				return inIndex(err, i)
+			}
+			rv.Set(reflect.Append(rv, ev))
+		}
+
+	default:
+		return cannotStore(v, t)
+	}
+}
+
+func fromValueStruct(v Value, rv reflect.Value) error {
+	a, ok := v.(Attr)
+	if !ok {
+		return cannotStore(v, rv.Type())
+	}
+	fields := typeFields(rv.Type())
+	for _, key := range a.Keys() {
+		name, ok := AsString(key)
+		if !ok {
+			continue
+		}
+		f, ok := findField(fields, name)
+		if !ok {
+			continue
+		}
+		e, ok := a.Attr(key)
+		if !ok {
+			continue
+		}
+		fv, err := settableField(rv, f.index)
+		if err != nil {
+			return inKey(err, name)
+		}
+		if err := fromValue(e, fv); err != nil {
+			return inKey(err, name)
+		}
+	}
+	return nil
+}
+
+// findField returns the field with the given name, or if there is none,
+// the first field whose name matches ignoring case.
+func findField(fields []structField, name string) (structField, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			return f, true
+		}
+	}
+	return structField{}, false
+}
+
+// settableField returns the field of rv at index, allocating embedded
+// struct pointers on the way as needed.
+func settableField(rv reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				if !rv.CanSet() {
+					return reflect.Value{}, &fromValueError{msg: fmt.Sprintf("cannot set field in nil pointer to unexported struct %v", rv.Type().Elem())}
+				}
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+func fromValueMap(v Value, rv reflect.Value) error {
+	t := rv.Type()
+	a, ok := v.(Attr)
+	if !ok {
+		return cannotStore(v, t)
+	}
+	kt := t.Key()
+	switch {
+	case kt.Kind() == reflect.String,
+		reflect.PtrTo(kt).Implements(textUnmarshalerType):
+	default:
+		switch kt.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		default:
+			return cannotStore(v, t)
+		}
+	}
+	if rv.IsNil() {
+		rv.Set(reflect.MakeMap(t))
+	}
+	for _, key := range a.Keys() {
+		name, ok := AsString(key)
+		if !ok {
+			return &fromValueError{msg: fmt.Sprintf("cannot store key %v in %v", key, kt)}
+		}
+		kv := reflect.New(kt)
+		if tu, ok := kv.Interface().(encoding.TextUnmarshaler); ok {
+			if err := tu.UnmarshalText([]byte(name)); err != nil {
+				return inKey(&fromValueError{msg: err.Error()}, name)
+			}
+		} else {
+			switch kt.Kind() {
+			case reflect.String:
+				kv.Elem().SetString(name)
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				n, err := strconv.ParseInt(name, 10, 64)
+				if err != nil || kv.Elem().OverflowInt(n) {
+					return &fromValueError{msg: fmt.Sprintf("cannot store key %q in %v", name, kt)}
+				}
+				kv.Elem().SetInt(n)
+			default:
+				n, err := strconv.ParseUint(name, 10, 64)
+				if err != nil || kv.Elem().OverflowUint(n) {
+					return &fromValueError{msg: fmt.Sprintf("cannot store key %q in %v", name, kt)}
+				}
+				kv.Elem().SetUint(n)
+			}
+		}
+		e, ok := a.Attr(key)
+		if !ok {
+			continue
+		}
+		ev := reflect.New(t.Elem()).Elem()
+		if err := fromValue(e, ev); err != nil {
+			return inKey(err, name)
+		}
+		rv.SetMapIndex(kv.Elem(), ev)
+	}
+	return nil
+}
+
+// interfaceOf converts v to the representation used by encoding/json
+// for values of type interface{}: nil, bool, float64, string,
+// map[string]interface{}, or []interface{}. Big numbers, times, and byte
+// strings are converted to json.Number, time.Time, and []byte, which
+// encoding/json marshals as it would the corresponding JSON values.
+func interfaceOf(v Value) (interface{}, error) {
+	if IsNull(v) {
+		return nil, nil
+	} else if b, ok := AsBool(v); ok {
+		return b, nil
+	} else if text, ok := AsBigNumber(v); ok {
+		return json.Number(text), nil
+	} else if f, ok := AsFloat64(v); ok {
+		return f, nil
+	} else if s, ok := AsString(v); ok {
+		return s, nil
+	} else if b, ok := AsBytes(v); ok {
+		return append([]byte(nil), b...), nil
+	} else if t, ok := AsTime(v); ok {
+		return t, nil
+	} else if a, ok := v.(Attr); ok {
+		m := make(map[string]interface{})
+		for _, key := range a.Keys() {
+			s, ok := AsString(key)
+			if !ok {
+				return nil, fmt.Errorf("key %v is not a string", key)
+			}
+			elem, ok := a.Attr(key)
+			if !ok {
+				continue
+			}
+			i, err := interfaceOf(elem)
+			if err != nil {
+				return nil, err
+			}
+			m[s] = i
+		}
+		return m, nil
+	} else if ix, ok := v.(Index); ok {
+		n := ix.Length()
+		l := make([]interface{}, n)
+		for j := 0; j < n; j++ {
+			elem, ok := ix.Index(j)
+			if !ok {
+				elem = NullValue
+			}
+			i, err := interfaceOf(elem)
+			if err != nil {
+				return nil, err
+			}
+			l[j] = i
+		}
+		return l, nil
+	} else {
+		return nil, fmt.Errorf("cannot convert value %#v", v)
+	}
+}
@@ -0,0 +1,122 @@
+package sift_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.jayconrod.com/sift"
+)
+
+type fromRecord struct {
+	Base
+	Title string            `sift:"title"`
+	Tags  []string          `json:"tags"`
+	Attrs map[string]int    `json:"attrs"`
+	Next  *fromRecord       `json:"next"`
+	Any   interface{}       `json:"any"`
+	Raw   sift.Value        `json:"raw"`
+	When  time.Time         `json:"when"`
+	Data  []byte            `json:"data"`
+	Big   *big.Int          `json:"big"`
+	Pair  [2]uint8          `json:"pair"`
+	ByID  map[int64]bool    `json:"by_id"`
+	Named map[textKeyIn]int `json:"named"`
+}
+
+type textKeyIn string
+
+func (k *textKeyIn) UnmarshalText(text []byte) error {
+	*k = textKeyIn(strings.ToUpper(string(text)))
+	return nil
+}
+
+func TestFromValue(t *testing.T) {
+	when := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	v := sift.Must(sift.ToValue(map[string]interface{}{
+		"ID":      7,
+		"NAME":    "base",
+		"title":   "t",
+		"tags":    []interface{}{"a", "b"},
+		"attrs":   map[string]interface{}{"x": 1},
+		"next":    map[string]interface{}{"title": "next", "next": nil},
+		"any":     map[string]interface{}{"k": []interface{}{1.5, nil, true}},
+		"raw":     "raw",
+		"when":    when,
+		"data":    "Zm9v",
+		"big":     sift.Must(sift.NewBigNumber("123456789012345678901234567890")),
+		"pair":    []interface{}{1, 2, 3},
+		"by_id":   map[string]interface{}{"-1": true},
+		"named":   map[string]interface{}{"k": 1},
+		"ignored": 1,
+	}))
+	var got fromRecord
+	if err := sift.FromValue(v, &got); err != nil {
+		t.Fatal(err)
+	}
+	bigWant, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	want := fromRecord{
+		Base:  Base{ID: 7, Name: "base"},
+		Title: "t",
+		Tags:  []string{"a", "b"},
+		Attrs: map[string]int{"x": 1},
+		Next:  &fromRecord{Title: "next"},
+		Any:   map[string]interface{}{"k": []interface{}{1.5, nil, true}},
+		Raw:   sift.Must(sift.ToValue("raw")),
+		When:  when,
+		Data:  []byte("foo"),
+		Big:   bigWant,
+		Pair:  [2]uint8{1, 2},
+		ByID:  map[int64]bool{-1: true},
+		Named: map[textKeyIn]int{"K": 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %#v\nwant %#v", got, want)
+	}
+
+	var n interface{}
+	if err := sift.FromValue(sift.Must(sift.NewBigNumber("1e400")), &n); err != nil {
+		t.Fatal(err)
+	} else if n != json.Number("1e400") {
+		t.Errorf("big number in interface{}: got %#v; want json.Number", n)
+	}
+
+	// Round trip through ToValue. Nil slices and maps become empty arrays
+	// and objects, so only the outer record is compared.
+	want.Next = nil
+	var back fromRecord
+	if err := sift.FromValue(sift.Must(sift.ToValue(want)), &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, want) {
+		t.Errorf("round trip: got  %#v\nwant %#v", back, want)
+	}
+}
+
+func TestFromValueError(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		v       interface{}
+		dst     interface{}
+		wantErr string
+	}{
+		{desc: "not_pointer", v: 1, dst: 0, wantErr: "must be a non-nil pointer"},
+		{desc: "kind", v: "x", dst: new(int), wantErr: "cannot store string in int"},
+		{desc: "fraction", v: 1.5, dst: new(int), wantErr: "cannot store number in int"},
+		{desc: "overflow", v: 256, dst: new(uint8), wantErr: "cannot store number in uint8"},
+		{desc: "negative", v: -1, dst: new(uint), wantErr: "cannot store number in uint"},
+		{desc: "nested", v: map[string]interface{}{"tags": []interface{}{"a", 1}}, dst: new(fromRecord), wantErr: "at .tags[1]: cannot store number in string"},
+		{desc: "key", v: map[string]interface{}{"a b": 1}, dst: new(map[int]int), wantErr: `cannot store key "a b" in int`},
+		{desc: "bad_time", v: "yesterday", dst: new(time.Time), wantErr: "cannot parse"},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := sift.FromValue(sift.Must(sift.ToValue(tc.v)), tc.dst)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got error %v; want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
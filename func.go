@@ -0,0 +1,46 @@
+package sift
+
+import "fmt"
+
+// Func is implemented by values that may be called like functions. Funcs
+// let callbacks flow through pipelines as values: for example, a Go program
+// may bind a Func to a jq variable, and the program may call it with the
+// call builtin.
+//
+// Funcs are not data: they have no JSON representation, and a Func is
+// not Equal to any value, including itself.
+type Func interface {
+	Value
+
+	// Call calls the function with an input value and one value for each
+	// argument. A function may produce any number of values.
+	Call(input Value, args []Value) ([]Value, error)
+}
+
+// NewFunc returns a Func that calls fn. name is used to describe the
+// function in messages, like "<function lookup>". arity is the number of
+// arguments fn accepts; Call returns an error if it's called with
+// a different number. An arity less than zero means fn accepts any number
+// of arguments.
+func NewFunc(name string, arity int, fn func(input Value, args []Value) ([]Value, error)) Func {
+	return &funcType{name: name, arity: arity, fn: fn}
+}
+
+type funcType struct {
+	name  string
+	arity int
+	fn    func(Value, []Value) ([]Value, error)
+}
+
+func (f *funcType) Truth() bool { return true }
+
+func (f *funcType) String() string {
+	return fmt.Sprintf("<function %s>", f.name)
+}
+
+func (f *funcType) Call(input Value, args []Value) ([]Value, error) {
+	if f.arity >= 0 && len(args) != f.arity {
+		return nil, fmt.Errorf("function %s accepts %d arguments; called with %d", f.name, f.arity, len(args))
+	}
+	return f.fn(input, args)
+}
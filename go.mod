@@ -1,3 +1,3 @@
 module go.jayconrod.com/sift
 
-go 1.13
+go 1.18
@@ -0,0 +1,44 @@
+package sift
+
+// Hash returns a hash of v that's consistent with Equal: values that are
+// Equal, without options, have the same hash. Hash is computed from the
+// contents of v, not its implementation, so it's the same in every process,
+// but it may change between versions of this package. Hash may be used to group values in a
+// map, checking Equal on values with the same hash.
+//
+// Hash terminates on values that contain themselves: an object or array
+// reached again while it's being hashed contributes a fixed marker instead
+// of its contents, as do values nested more deeply than
+// DefaultMaxEqualDepth. Such values may have different hashes even if
+// Equal reports them equal.
+func Hash(v Value) uint64 {
+	h := newHasher()
+	writeKey(&h, v)
+	return uint64(h)
+}
+
+// hasher computes the 64-bit FNV-1a hash of the bytes written to it.
+type hasher uint64
+
+func newHasher() hasher {
+	return 14695981039346656037
+}
+
+func (h *hasher) WriteByte(b byte) error {
+	*h = (*h ^ hasher(b)) * 1099511628211
+	return nil
+}
+
+func (h *hasher) Write(p []byte) (int, error) {
+	for _, b := range p {
+		h.WriteByte(b)
+	}
+	return len(p), nil
+}
+
+func (h *hasher) WriteString(s string) (int, error) {
+	for i := 0; i < len(s); i++ {
+		h.WriteByte(s[i])
+	}
+	return len(s), nil
+}
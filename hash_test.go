@@ -0,0 +1,82 @@
+package sift_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+)
+
+func TestHash(t *testing.T) {
+	t0 := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	decode := func(s string) sift.Value {
+		v, err := json.NewDecoder(strings.NewReader(s)).Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	// Values in each group are Equal and must have the same hash. Values in
+	// different groups are not Equal and should have different hashes.
+	groups := [][]sift.Value{
+		{sift.NullValue, decode("null")},
+		{sift.Must(sift.ToValue(true)), decode("true")},
+		{sift.Must(sift.ToValue(false)), decode("false")},
+		{sift.Must(sift.ToValue(0)), sift.Must(sift.ToValue(math.Copysign(0, -1))), decode("0")},
+		{sift.Must(sift.ToValue(1.5)), sift.Must(sift.NewBigNumber("1.50")), decode("1.5")},
+		{sift.Must(sift.NewBigNumber("12345678901234567891")), sift.Must(sift.ToValue(uint64(12345678901234567891)))},
+		{sift.Must(sift.NewBigNumber("12345678901234567892"))},
+		{sift.Must(sift.ToValue("1.5")), decode(`"1.5"`)},
+		{sift.Must(sift.ToValue("")), decode(`""`)},
+		{sift.Must(sift.ToValue([]byte("1.5")))},
+		{sift.Must(sift.ToValue(t0)), sift.Must(sift.ToValue(t0.In(time.FixedZone("X", 3600))))},
+		{sift.Must(sift.ToValue([]interface{}{1, "a"})), decode(`[1, "a"]`)},
+		{sift.Must(sift.ToValue([]interface{}{"a", 1}))},
+		{sift.Must(sift.ToValue([]interface{}{}))},
+		{
+			sift.Must(sift.ToValue(map[string]interface{}{"a": 1, "b": []interface{}{}})),
+			sift.Must(sift.ToValue([]sift.KeyValue{{Key: "b", Value: []interface{}{}}, {Key: "a", Value: 1}})),
+			decode(`{"b": [], "a": 1}`),
+		},
+		{sift.Must(sift.ToValue(map[string]interface{}{}))},
+	}
+	seen := make(map[uint64]int)
+	for i, g := range groups {
+		h := sift.Hash(g[0])
+		for _, v := range g[1:] {
+			if !sift.Equal(g[0], v) {
+				t.Errorf("Equal(%v, %v) = false; want true", g[0], v)
+			}
+			if vh := sift.Hash(v); vh != h {
+				t.Errorf("Hash(%v) = %x; Hash(%v) = %x; want same hash", g[0], h, v, vh)
+			}
+		}
+		if j, ok := seen[h]; ok {
+			t.Errorf("Hash(%v) = Hash(%v); want different hashes", g[0], groups[j][0])
+		}
+		seen[h] = i
+	}
+
+	// Hashes must not depend on the process.
+	if got, want := sift.Hash(sift.Must(sift.ToValue("abc"))), uint64(0xc6cf8fb538aadbab); got != want {
+		t.Errorf("Hash(\"abc\") = %x; want %x", got, want)
+	}
+}
+
+func TestHashCycle(t *testing.T) {
+	// These would recurse forever without a guard.
+	if sift.Hash(&loop{"a"}) != sift.Hash(&loop{"a"}) {
+		t.Error("equal cyclic values have different hashes")
+	}
+	if sift.Hash(&loop{"a"}) == sift.Hash(&loop{"b"}) {
+		t.Error("different cyclic values have the same hash")
+	}
+	sift.Hash(nest(2 * sift.DefaultMaxEqualDepth))
+	if s := sift.NewSet(&loop{"a"}); !s.Has(&loop{"a"}) {
+		t.Error("set of a cyclic value doesn't contain an equal value")
+	}
+}
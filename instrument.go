@@ -0,0 +1,92 @@
+package sift
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Tracer receives events from filters wrapped with Instrument. Its
+// methods correspond to spans in tracing systems like OpenTelemetry:
+// StartFilter starts a span for one application of a filter, and the
+// function it returns ends the span, recording the number of values the
+// filter produced and the error it returned, if any. A Tracer may be called
+// concurrently if the filters it's used with are.
+type Tracer interface {
+	StartFilter(stage string, input Value) (end func(outputs int, err error))
+}
+
+// Instrument returns a Filter that applies f and reports each application
+// to t, labeled with stage. Wrapping each stage of a composed filter lets
+// a Tracer find where time is spent.
+func Instrument(stage string, f Filter, t Tracer) Filter {
+	return func(v Value) ([]Value, error) {
+		end := t.StartFilter(stage, v)
+		vs, err := f(v)
+		end(len(vs), err)
+		return vs, err
+	}
+}
+
+// FilterStats is a Tracer that records statistics for each stage.
+type FilterStats struct {
+	mu     sync.Mutex
+	stages map[string]*StageStats
+}
+
+// StageStats are statistics about the applications of one filter stage.
+type StageStats struct {
+	Stage string
+
+	// Calls is the number of times the filter was applied, and Errors is
+	// the number of times it returned an error.
+	Calls, Errors int64
+
+	// Outputs is the total number of values the filter produced. Outputs
+	// divided by Calls is the filter's average fan-out.
+	Outputs int64
+
+	// Duration is the total time spent applying the filter, including
+	// time spent in any stages nested inside it.
+	Duration time.Duration
+}
+
+var _ Tracer = (*FilterStats)(nil)
+
+// NewFilterStats returns a FilterStats with no recorded statistics.
+func NewFilterStats() *FilterStats {
+	return &FilterStats{stages: make(map[string]*StageStats)}
+}
+
+func (s *FilterStats) StartFilter(stage string, _ Value) func(int, error) {
+	start := time.Now()
+	return func(outputs int, err error) {
+		d := time.Since(start)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		st, ok := s.stages[stage]
+		if !ok {
+			st = &StageStats{Stage: stage}
+			s.stages[stage] = st
+		}
+		st.Calls++
+		if err != nil {
+			st.Errors++
+		}
+		st.Outputs += int64(outputs)
+		st.Duration += d
+	}
+}
+
+// Stages returns a copy of the statistics recorded for each stage, sorted
+// by stage name.
+func (s *FilterStats) Stages() []StageStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make([]StageStats, 0, len(s.stages))
+	for _, st := range s.stages {
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Stage < stats[j].Stage })
+	return stats
+}
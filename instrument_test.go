@@ -0,0 +1,41 @@
+package sift_test
+
+import (
+	"errors"
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestInstrument(t *testing.T) {
+	errOdd := errors.New("odd")
+	stats := sift.NewFilterStats()
+	explode := sift.Instrument("explode", func(v sift.Value) ([]sift.Value, error) {
+		return []sift.Value{v, v, v}, nil
+	}, stats)
+	check := sift.Instrument("check", func(v sift.Value) ([]sift.Value, error) {
+		if n, _ := sift.AsFloat64(v); int(n)%2 == 1 {
+			return nil, errOdd
+		}
+		return []sift.Value{v}, nil
+	}, stats)
+	f := sift.Compose(explode, check)
+	for _, i := range []interface{}{0, 2, 1} {
+		f(sift.Must(sift.ToValue(i)))
+	}
+
+	want := []sift.StageStats{
+		{Stage: "check", Calls: 7, Errors: 1, Outputs: 6},
+		{Stage: "explode", Calls: 3, Outputs: 9},
+	}
+	got := stats.Stages()
+	if len(got) != len(want) {
+		t.Fatalf("got %d stages; want %d", len(got), len(want))
+	}
+	for i := range got {
+		got[i].Duration = 0
+		if got[i] != want[i] {
+			t.Errorf("got %+v; want %+v", got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package sift
+
+import "math"
+
+// Converting a scalar to a Value usually allocates, since the scalar is
+// stored in an interface. Common scalars are interned: ToValue returns
+// canonical Values for them that were allocated once.
+const (
+	minInternedInt = -128
+	maxInternedInt = 1023
+)
+
+var (
+	trueValue   Value = boolType(true)
+	falseValue  Value = boolType(false)
+	emptyString Value = stringType("")
+	smallInts   [maxInternedInt - minInternedInt + 1]Value
+)
+
+func init() {
+	for i := range smallInts {
+		smallInts[i] = float64Type(i + minInternedInt)
+	}
+}
+
+// boolValue returns the canonical Value for b.
+func boolValue(b bool) Value {
+	if b {
+		return trueValue
+	}
+	return falseValue
+}
+
+// floatValue returns f as a Value, using a canonical Value for small
+// integers. Negative zero is not interned, so its sign is preserved.
+func floatValue(f float64) Value {
+	if f >= minInternedInt && f <= maxInternedInt && f == math.Trunc(f) && (f != 0 || !math.Signbit(f)) {
+		return smallInts[int(f)-minInternedInt]
+	}
+	return float64Type(f)
+}
+
+// stringValue returns s as a Value, using a canonical Value for the empty
+// string.
+func stringValue(s string) Value {
+	if s == "" {
+		return emptyString
+	}
+	return stringType(s)
+}
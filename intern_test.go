@@ -0,0 +1,41 @@
+package sift_test
+
+import (
+	"math"
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestInternAllocs(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		x    interface{}
+	}{
+		{desc: "null", x: nil},
+		{desc: "true", x: true},
+		{desc: "false", x: false},
+		{desc: "zero", x: 0},
+		{desc: "small_int", x: 42},
+		{desc: "negative_int", x: -1},
+		{desc: "integral_float", x: 3.0},
+		{desc: "empty_string", x: ""},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			allocs := testing.AllocsPerRun(100, func() {
+				sift.Must(sift.ToValue(tc.x))
+			})
+			if allocs != 0 {
+				t.Errorf("ToValue(%#v): got %v allocations; want 0", tc.x, allocs)
+			}
+		})
+	}
+}
+
+func TestInternNegativeZero(t *testing.T) {
+	v := sift.Must(sift.ToValue(math.Copysign(0, -1)))
+	f, ok := sift.AsFloat64(v)
+	if !ok || f != 0 || !math.Signbit(f) {
+		t.Errorf("got %v; want -0", v)
+	}
+}
@@ -0,0 +1,504 @@
+// Package ir defines an intermediate representation for filters.
+//
+// A filter built by composing sift.Filter functions is opaque: it's
+// a closure that can be applied but not examined. A Node is a tree that
+// describes what a filter does. Nodes may be inspected and printed,
+// then compiled into a sift.Filter with Compile.
+//
+// Nodes are built with the constructors in this package, like Pipe and
+// Field, or by front ends like the jq package's Lower function. Operations
+// a Node can't describe directly are represented by Func nodes, which refer
+// to Go functions registered with RegisterFunc, and Source nodes, which hold
+// a program in a language registered with RegisterLanguage.
+package ir
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+)
+
+// Op is the kind of a Node.
+type Op int
+
+const (
+	// OpIdentity produces its input.
+	OpIdentity Op = iota
+
+	// OpLiteral produces Value.
+	OpLiteral
+
+	// OpField produces the attribute of its input named Name. If the input
+	// is not an object or has no such attribute, it produces null.
+	OpField
+
+	// OpIterate produces the elements of its input, which must be an array.
+	OpIterate
+
+	// OpPipe applies each of its Args to the outputs of the one before.
+	OpPipe
+
+	// OpComma produces the outputs of each of its Args in order.
+	OpComma
+
+	// OpBinary applies the operator named by Name to the Cartesian product
+	// of the outputs of Args[0] and Args[1]. The operators "and" and "or"
+	// are built in; others are registered with RegisterOp.
+	OpBinary
+
+	// OpSelect produces its input once for each truthy output of Args[0].
+	OpSelect
+
+	// OpFunc applies the Go function registered with RegisterFunc as Name.
+	OpFunc
+
+	// OpSource applies Src, a program in the language registered with
+	// RegisterLanguage as Name. Value may hold a form of the program the
+	// language's front end prepared ahead of time, like a syntax tree,
+	// which the loader registered with RegisterLoader uses instead of
+	// compiling Src.
+	OpSource
+
+	// OpPath produces the value reached by looking up each attribute in
+	// Path in turn, like a pipe of OpField nodes.
+	OpPath
+)
+
+var opNames = [...]string{
+	OpIdentity: "identity",
+	OpLiteral:  "literal",
+	OpField:    "field",
+	OpIterate:  "iterate",
+	OpPipe:     "pipe",
+	OpComma:    "comma",
+	OpBinary:   "binary",
+	OpSelect:   "select",
+	OpFunc:     "func",
+	OpSource:   "source",
+	OpPath:     "path",
+}
+
+func (op Op) String() string {
+	if op < 0 || int(op) >= len(opNames) {
+		return fmt.Sprintf("Op(%d)", int(op))
+	}
+	return opNames[op]
+}
+
+// A Node is an operation in a filter's intermediate representation. Which
+// fields are used depends on Op, as described by the Op constants.
+type Node struct {
+	Op Op
+
+	// Value is the value produced by an OpLiteral node, or the prepared
+	// form of an OpSource node's program, if any.
+	Value sift.Value
+
+	// Name is the attribute name of an OpField node, the operator of an
+	// OpBinary node, the function of an OpFunc node, or the language of
+	// an OpSource node.
+	Name string
+
+	// Src is the program text of an OpSource node.
+	Src string
+
+	// Path is the list of attribute names of an OpPath node.
+	Path []string
+
+	// Args are the operands of OpPipe, OpComma, OpBinary, and OpSelect
+	// nodes.
+	Args []*Node
+}
+
+// Identity returns a node that produces its input.
+func Identity() *Node { return &Node{Op: OpIdentity} }
+
+// Literal returns a node that produces v.
+func Literal(v sift.Value) *Node { return &Node{Op: OpLiteral, Value: v} }
+
+// Field returns a node that produces the attribute of its input with the
+// given name.
+func Field(name string) *Node { return &Node{Op: OpField, Name: name} }
+
+// Path returns a node that produces the value reached by looking up each
+// of the given attribute names in turn.
+func Path(names ...string) *Node { return &Node{Op: OpPath, Path: names} }
+
+// Iterate returns a node that produces the elements of its input.
+func Iterate() *Node { return &Node{Op: OpIterate} }
+
+// Pipe returns a node that applies each node to the outputs of the one
+// before, like Compose.
+func Pipe(nodes ...*Node) *Node { return &Node{Op: OpPipe, Args: nodes} }
+
+// Comma returns a node that produces the outputs of each node in order,
+// like Concat.
+func Comma(nodes ...*Node) *Node { return &Node{Op: OpComma, Args: nodes} }
+
+// Binary returns a node that applies the named operator to the Cartesian
+// product of the outputs of x and y.
+func Binary(op string, x, y *Node) *Node {
+	return &Node{Op: OpBinary, Name: op, Args: []*Node{x, y}}
+}
+
+// Select returns a node that produces its input once for each truthy
+// output of pred.
+func Select(pred *Node) *Node { return &Node{Op: OpSelect, Args: []*Node{pred}} }
+
+// Func returns a node that applies the function registered as name.
+func Func(name string) *Node { return &Node{Op: OpFunc, Name: name} }
+
+// Source returns a node that applies src, a program in the language
+// registered as lang.
+func Source(lang, src string) *Node { return &Node{Op: OpSource, Name: lang, Src: src} }
+
+// Inspect traverses the tree rooted at n in depth-first order. It calls f
+// for each node; if f returns true, Inspect visits the node's operands.
+func Inspect(n *Node, f func(*Node) bool) {
+	if !f(n) {
+		return
+	}
+	for _, arg := range n.Args {
+		Inspect(arg, f)
+	}
+}
+
+// String returns a description of the tree rooted at n in a syntax like
+// jq's. Func nodes are written as their names, and Source nodes are written
+// as calls like jq("src").
+func (n *Node) String() string {
+	b := &strings.Builder{}
+	n.format(b, precLowest)
+	return b.String()
+}
+
+// Precedence levels used when formatting nodes. A node is parenthesized
+// when it binds less tightly than its context requires.
+const (
+	precLowest = iota
+	precPipe
+	precComma
+	precOr
+	precAnd
+	precAdd
+	precMul
+	precPrimary
+)
+
+func (n *Node) precedence() int {
+	switch n.Op {
+	case OpPipe:
+		return precPipe
+	case OpComma:
+		return precComma
+	case OpBinary:
+		switch n.Name {
+		case "or":
+			return precOr
+		case "and":
+			return precAnd
+		case "+", "-":
+			return precAdd
+		default:
+			return precMul
+		}
+	default:
+		return precPrimary
+	}
+}
+
+func (n *Node) format(b *strings.Builder, prec int) {
+	p := n.precedence()
+	if p < prec {
+		b.WriteString("(")
+		defer b.WriteString(")")
+	}
+	switch n.Op {
+	case OpIdentity:
+		b.WriteString(".")
+	case OpLiteral:
+		b.WriteString(literalText(n.Value))
+	case OpField:
+		writeField(b, n.Name)
+	case OpPath:
+		if len(n.Path) == 0 {
+			b.WriteString(".")
+		}
+		for _, name := range n.Path {
+			writeField(b, name)
+		}
+	case OpIterate:
+		b.WriteString(".[]")
+	case OpPipe, OpComma:
+		sep := " | "
+		if n.Op == OpComma {
+			sep = ", "
+		}
+		for i, arg := range n.Args {
+			if i > 0 {
+				b.WriteString(sep)
+			}
+			arg.format(b, p+1)
+		}
+	case OpBinary:
+		n.Args[0].format(b, p)
+		fmt.Fprintf(b, " %s ", n.Name)
+		n.Args[1].format(b, p+1)
+	case OpSelect:
+		b.WriteString("select(")
+		n.Args[0].format(b, precLowest)
+		b.WriteString(")")
+	case OpFunc:
+		b.WriteString(n.Name)
+	case OpSource:
+		fmt.Fprintf(b, "%s(%s)", n.Name, literalText(sift.Must(sift.ToValue(n.Src))))
+	default:
+		fmt.Fprintf(b, "<%v>", n.Op)
+	}
+}
+
+func writeField(b *strings.Builder, name string) {
+	if isIdentifier(name) {
+		fmt.Fprintf(b, ".%s", name)
+	} else {
+		fmt.Fprintf(b, ".%s", literalText(sift.Must(sift.ToValue(name))))
+	}
+}
+
+// literalText returns v formatted as a JSON literal.
+func literalText(v sift.Value) string {
+	if v == nil {
+		v = sift.NullValue
+	}
+	b := &strings.Builder{}
+	if err := json.NewEncoder(b).Encode(v); err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r != '_' && !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || i > 0 && '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+var registry struct {
+	mu      sync.RWMutex
+	funcs   map[string]sift.Filter
+	ops     map[string]func(x, y sift.Value) (sift.Value, error)
+	langs   map[string]func(src string) (sift.Filter, error)
+	loaders map[string]func(src string, v sift.Value) (sift.Filter, error)
+}
+
+// RegisterFunc makes f available to OpFunc nodes as name. RegisterFunc is
+// usually called from an init function. It panics if name is already
+// registered.
+func RegisterFunc(name string, f sift.Filter) {
+	register(&registry.funcs, name, f, "function")
+}
+
+// RegisterOp makes op available to OpBinary nodes as name. op must not
+// have side effects, since Optimize may apply it to constant operands ahead
+// of time. RegisterOp panics if name is already registered or is a built-in
+// operator.
+func RegisterOp(name string, op func(x, y sift.Value) (sift.Value, error)) {
+	if name == "and" || name == "or" {
+		panic(fmt.Sprintf("ir: operator %q is built in", name))
+	}
+	register(&registry.ops, name, op, "operator")
+}
+
+// RegisterLanguage makes a compiler for a language available to OpSource
+// nodes as lang. It panics if lang is already registered.
+func RegisterLanguage(lang string, compile func(src string) (sift.Filter, error)) {
+	register(&registry.langs, lang, compile, "language")
+}
+
+// RegisterLoader makes a loader for programs in a language available to
+// OpSource nodes with a Value. The loader receives the node's Src and Value
+// and returns the program's filter without compiling Src, so trees
+// prepared ahead of time load quickly. Source nodes without a Value, or in
+// languages with no loader, are compiled with the function registered with
+// RegisterLanguage. RegisterLoader panics if lang already has a loader.
+func RegisterLoader(lang string, load func(src string, v sift.Value) (sift.Filter, error)) {
+	register(&registry.loaders, lang, load, "loader")
+}
+
+func register[T any](m *map[string]T, name string, v T, kind string) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if *m == nil {
+		*m = make(map[string]T)
+	}
+	if _, ok := (*m)[name]; ok {
+		panic(fmt.Sprintf("ir: %s %q registered twice", kind, name))
+	}
+	(*m)[name] = v
+}
+
+func lookup[T any](m map[string]T, name, kind string) (T, error) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	v, ok := m[name]
+	if !ok {
+		return v, fmt.Errorf("unknown %s %q", kind, name)
+	}
+	return v, nil
+}
+
+// Compile returns a filter that does what the tree rooted at n describes.
+// An error is returned if the tree is malformed or refers to a function,
+// operator, or language that isn't registered.
+func Compile(n *Node) (sift.Filter, error) {
+	switch n.Op {
+	case OpIdentity:
+		return identity, nil
+
+	case OpLiteral:
+		v := n.Value
+		if v == nil {
+			v = sift.NullValue
+		}
+		return sift.Literal(v), nil
+
+	case OpField, OpPath:
+		path := n.Path
+		if n.Op == OpField {
+			path = []string{n.Name}
+		}
+		return func(v sift.Value) ([]sift.Value, error) {
+			for _, name := range path {
+				e, ok := sift.GetStringAttr(v, name)
+				if !ok {
+					e = sift.NullValue
+				}
+				v = e
+			}
+			return []sift.Value{v}, nil
+		}, nil
+
+	case OpIterate:
+		return iterate, nil
+
+	case OpPipe, OpComma:
+		if len(n.Args) == 0 {
+			return nil, fmt.Errorf("%v node has no operands", n.Op)
+		}
+		combine := sift.Compose
+		if n.Op == OpComma {
+			combine = sift.Concat
+		}
+		f, err := Compile(n.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, arg := range n.Args[1:] {
+			g, err := Compile(arg)
+			if err != nil {
+				return nil, err
+			}
+			f = combine(f, g)
+		}
+		return f, nil
+
+	case OpBinary:
+		if len(n.Args) != 2 {
+			return nil, fmt.Errorf("binary node has %d operands; want 2", len(n.Args))
+		}
+		x, err := Compile(n.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		y, err := Compile(n.Args[1])
+		if err != nil {
+			return nil, err
+		}
+		switch n.Name {
+		case "and":
+			return sift.And(x, y), nil
+		case "or":
+			return sift.Or(x, y), nil
+		}
+		op, err := lookup(registry.ops, n.Name, "operator")
+		if err != nil {
+			return nil, err
+		}
+		return sift.Binary(x, y, func(xv, yv sift.Value) ([]sift.Value, error) {
+			v, err := op(xv, yv)
+			if err != nil {
+				return nil, err
+			}
+			return []sift.Value{v}, nil
+		}), nil
+
+	case OpSelect:
+		if len(n.Args) != 1 {
+			return nil, fmt.Errorf("select node has %d operands; want 1", len(n.Args))
+		}
+		pred, err := Compile(n.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		return func(v sift.Value) ([]sift.Value, error) {
+			pvs, err := pred(v)
+			if err != nil {
+				return nil, err
+			}
+			var outs []sift.Value
+			for _, pv := range pvs {
+				if sift.Truthy(pv) {
+					outs = append(outs, v)
+				}
+			}
+			return outs, nil
+		}, nil
+
+	case OpFunc:
+		return lookup(registry.funcs, n.Name, "function")
+
+	case OpSource:
+		if n.Value != nil {
+			if load, err := lookup(registry.loaders, n.Name, "loader"); err == nil {
+				return load(n.Src, n.Value)
+			}
+		}
+		compile, err := lookup(registry.langs, n.Name, "language")
+		if err != nil {
+			return nil, err
+		}
+		return compile(n.Src)
+
+	default:
+		return nil, fmt.Errorf("unknown node kind %v", n.Op)
+	}
+}
+
+func identity(v sift.Value) ([]sift.Value, error) {
+	return []sift.Value{v}, nil
+}
+
+func iterate(v sift.Value) ([]sift.Value, error) {
+	ix, err := sift.Collect(v)
+	if err != nil {
+		return nil, err
+	}
+	elems := make([]sift.Value, ix.Length())
+	for i := range elems {
+		e, ok := ix.Index(i)
+		if !ok {
+			e = sift.NullValue
+		}
+		elems[i] = e
+	}
+	return elems, nil
+}
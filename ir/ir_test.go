@@ -0,0 +1,157 @@
+package ir_test
+
+import (
+	"strings"
+	"testing"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+	"go.jayconrod.com/sift/ir"
+)
+
+func init() {
+	ir.RegisterOp("max", func(x, y sift.Value) (sift.Value, error) {
+		if sift.Compare(x, y) < 0 {
+			return y, nil
+		}
+		return x, nil
+	})
+	ir.RegisterFunc("double", func(v sift.Value) ([]sift.Value, error) {
+		return []sift.Value{v, v}, nil
+	})
+
+	// The "const" language produces the JSON value in its source. Its
+	// loader produces a prepared value instead, so tests can tell which
+	// was used.
+	ir.RegisterLanguage("const", func(src string) (sift.Filter, error) {
+		v, err := json.NewDecoder(strings.NewReader(src)).Decode()
+		if err != nil {
+			return nil, err
+		}
+		return sift.Literal(v), nil
+	})
+	ir.RegisterLoader("const", func(_ string, v sift.Value) (sift.Filter, error) {
+		return sift.Literal(v), nil
+	})
+}
+
+func lit(i interface{}) *ir.Node {
+	return ir.Literal(sift.Must(sift.ToValue(i)))
+}
+
+func TestCompile(t *testing.T) {
+	for _, tc := range []struct {
+		desc, input string
+		n           *ir.Node
+		str, want   string
+		wantErr     string
+	}{
+		{
+			desc:  "identity",
+			n:     ir.Identity(),
+			input: `1`,
+			str:   `.`,
+			want:  `1`,
+		}, {
+			desc:  "field",
+			n:     ir.Pipe(ir.Field("a"), ir.Field("b c")),
+			input: `{"a": {"b c": 2}}`,
+			str:   `.a | ."b c"`,
+			want:  `2`,
+		}, {
+			desc:  "field_missing",
+			n:     ir.Field("a"),
+			input: `3`,
+			want:  `null`,
+		}, {
+			desc:  "iterate_select",
+			n:     ir.Pipe(ir.Iterate(), ir.Select(ir.Field("ok"))),
+			input: `[{"ok": true}, {"ok": false}, {"ok": 1}]`,
+			str:   `.[] | select(.ok)`,
+			want:  `{"ok":true} {"ok":1}`,
+		}, {
+			desc:  "comma_binary",
+			n:     ir.Binary("max", ir.Comma(lit(1), lit(5)), lit(3)),
+			input: `null`,
+			str:   `(1, 5) max 3`,
+			want:  `3 5`,
+		}, {
+			desc:  "and_or",
+			n:     ir.Binary("or", ir.Binary("and", lit(true), lit(nil)), lit("x")),
+			input: `null`,
+			str:   `true and null or "x"`,
+			want:  `true`,
+		}, {
+			desc:  "func",
+			n:     ir.Pipe(lit("a"), ir.Func("double")),
+			input: `null`,
+			str:   `"a" | double`,
+			want:  `"a" "a"`,
+		}, {
+			desc:    "unknown_func",
+			n:       ir.Func("nope"),
+			wantErr: `unknown function "nope"`,
+		}, {
+			desc:    "unknown_op",
+			n:       ir.Binary("^", lit(1), lit(2)),
+			wantErr: `unknown operator "^"`,
+		}, {
+			desc:  "source",
+			n:     ir.Source("const", `"src"`),
+			input: `null`,
+			str:   `const("\"src\"")`,
+			want:  `"src"`,
+		}, {
+			desc:  "source_loader",
+			n:     &ir.Node{Op: ir.OpSource, Name: "const", Src: `"src"`, Value: sift.Must(sift.ToValue("loaded"))},
+			input: `null`,
+			str:   `const("\"src\"")`,
+			want:  `"loaded"`,
+		}, {
+			desc:    "unknown_language",
+			n:       ir.Source("cobol", "ADD 1"),
+			str:     `cobol("ADD 1")`,
+			wantErr: `unknown language "cobol"`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if tc.str != "" {
+				if got := tc.n.String(); got != tc.str {
+					t.Errorf("String: got %s; want %s", got, tc.str)
+				}
+			}
+			f, err := ir.Compile(tc.n)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("got error %v; want error containing %q", err, tc.wantErr)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			input, err := json.NewDecoder(strings.NewReader(tc.input)).Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			vs, err := f(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := encodeAll(t, vs); got != tc.want {
+				t.Errorf("got %s; want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestInspect(t *testing.T) {
+	n := ir.Pipe(ir.Field("a"), ir.Comma(ir.Iterate(), ir.Select(ir.Identity())))
+	var ops []string
+	ir.Inspect(n, func(n *ir.Node) bool {
+		ops = append(ops, n.Op.String())
+		return n.Op != ir.OpSelect
+	})
+	if got, want := strings.Join(ops, " "), "pipe field comma iterate select"; got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+}
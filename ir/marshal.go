@@ -0,0 +1,195 @@
+package ir
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+)
+
+// Version is the version of the serialized form written by Marshal.
+// Unmarshal accepts this version and earlier ones.
+const Version = 1
+
+// Marshal returns a serialized form of the tree rooted at n. The form is
+// JSON text, and it's stable: the same tree always produces the same text,
+// and text written by one version of this package may be read by later
+// versions. A service may compile a program into a tree once, then send
+// the serialized tree to workers, which load it with Unmarshal.
+//
+// Func and Source nodes are serialized by name, so the functions and
+// languages they refer to must be registered by the program that loads
+// them. Source nodes are compiled again when the tree is compiled, unless
+// they have a Value and their language has a loader.
+func Marshal(n *Node) ([]byte, error) {
+	root, err := nodeValue(n)
+	if err != nil {
+		return nil, err
+	}
+	v, err := sift.NewObject().Set("version", Version).Set("root", root).Build()
+	if err != nil {
+		return nil, err
+	}
+	b := &bytes.Buffer{}
+	if err := json.NewEncoder(b).Encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Unmarshal returns the tree serialized in data by Marshal.
+func Unmarshal(data []byte) (*Node, error) {
+	v, err := json.NewDecoder(bytes.NewReader(data)).Decode()
+	if err == io.EOF {
+		return nil, fmt.Errorf("loading filter: no data")
+	} else if err != nil {
+		return nil, fmt.Errorf("loading filter: %w", err)
+	}
+	version, ok := sift.GetStringAttr(v, "version")
+	if vn, _ := sift.AsFloat64(version); !ok || vn < 1 || vn > Version {
+		return nil, fmt.Errorf("loading filter: unsupported version %v; want at most %d", version, Version)
+	}
+	root, ok := sift.GetStringAttr(v, "root")
+	if !ok {
+		return nil, fmt.Errorf("loading filter: missing root")
+	}
+	n, err := valueNode(root)
+	if err != nil {
+		return nil, fmt.Errorf("loading filter: %w", err)
+	}
+	return n, nil
+}
+
+// MarshalJSON returns the serialized form of n, without the version
+// wrapper Marshal adds.
+func (n *Node) MarshalJSON() ([]byte, error) {
+	v, err := nodeValue(n)
+	if err != nil {
+		return nil, err
+	}
+	b := &bytes.Buffer{}
+	if err := json.NewEncoder(b).Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+// UnmarshalJSON sets *n to the node serialized in data by MarshalJSON.
+func (n *Node) UnmarshalJSON(data []byte) error {
+	v, err := json.NewDecoder(bytes.NewReader(data)).Decode()
+	if err != nil {
+		return err
+	}
+	m, err := valueNode(v)
+	if err != nil {
+		return err
+	}
+	*n = *m
+	return nil
+}
+
+// nodeValue returns n as an object. Only the fields used by n's Op are
+// included.
+func nodeValue(n *Node) (sift.Value, error) {
+	if n.Op < 0 || int(n.Op) >= len(opNames) {
+		return nil, fmt.Errorf("cannot serialize node with unknown kind %v", n.Op)
+	}
+	b := sift.NewObject().Set("op", n.Op.String())
+	switch n.Op {
+	case OpLiteral:
+		v := n.Value
+		if v == nil {
+			v = sift.NullValue
+		}
+		b.Set("value", v)
+	case OpField, OpBinary, OpFunc:
+		b.Set("name", n.Name)
+	case OpSource:
+		b.Set("name", n.Name).Set("src", n.Src)
+		if n.Value != nil {
+			b.Set("value", n.Value)
+		}
+	case OpPath:
+		b.Set("path", append([]string{}, n.Path...))
+	}
+	if len(n.Args) > 0 {
+		args := sift.NewArray(len(n.Args))
+		for _, arg := range n.Args {
+			v, err := nodeValue(arg)
+			if err != nil {
+				return nil, err
+			}
+			args.Append(v)
+		}
+		b.Set("args", args.Build())
+	}
+	return b.Build()
+}
+
+// valueNode returns the node described by an object built by nodeValue.
+func valueNode(v sift.Value) (*Node, error) {
+	opv, _ := sift.GetStringAttr(v, "op")
+	opName, ok := sift.AsString(opv)
+	if !ok {
+		return nil, fmt.Errorf("node %v has no op", v)
+	}
+	n := &Node{Op: -1}
+	for op, name := range opNames {
+		if name == opName {
+			n.Op = Op(op)
+		}
+	}
+	if n.Op < 0 {
+		return nil, fmt.Errorf("unknown op %q", opName)
+	}
+
+	str := func(key string) (string, error) {
+		sv, _ := sift.GetStringAttr(v, key)
+		s, ok := sift.AsString(sv)
+		if !ok {
+			return "", fmt.Errorf("%s node: %s is not a string", opName, key)
+		}
+		return s, nil
+	}
+	var err error
+	switch n.Op {
+	case OpLiteral:
+		if n.Value, ok = sift.GetStringAttr(v, "value"); !ok {
+			return nil, fmt.Errorf("literal node has no value")
+		}
+	case OpField, OpBinary, OpFunc:
+		if n.Name, err = str("name"); err != nil {
+			return nil, err
+		}
+	case OpSource:
+		if n.Name, err = str("name"); err != nil {
+			return nil, err
+		}
+		if n.Src, err = str("src"); err != nil {
+			return nil, err
+		}
+		n.Value, _ = sift.GetStringAttr(v, "value")
+	case OpPath:
+		pv, _ := sift.GetStringAttr(v, "path")
+		if n.Path, ok = sift.As[[]string](pv); !ok {
+			return nil, fmt.Errorf("path node: path is not an array of strings")
+		}
+	}
+
+	if av, ok := sift.GetStringAttr(v, "args"); ok {
+		ix, ok := av.(sift.Index)
+		if !ok {
+			return nil, fmt.Errorf("%s node: args is not an array", opName)
+		}
+		n.Args = make([]*Node, ix.Length())
+		for i := range n.Args {
+			e, _ := ix.Index(i)
+			if n.Args[i], err = valueNode(e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return n, nil
+}
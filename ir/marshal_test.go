@@ -0,0 +1,75 @@
+package ir_test
+
+import (
+	"strings"
+	"testing"
+
+	"go.jayconrod.com/sift/ir"
+)
+
+func TestMarshal(t *testing.T) {
+	input := `{"a": {"b": [1, 2]}, "n": 3}`
+	for _, tc := range []struct {
+		desc string
+		n    *ir.Node
+	}{
+		{desc: "identity", n: ir.Identity()},
+		{desc: "literal", n: lit(map[string]interface{}{"x": []interface{}{1, "y", nil}})},
+		{desc: "field", n: ir.Field("n")},
+		{desc: "path", n: ir.Pipe(ir.Path("a", "b"), ir.Iterate())},
+		{desc: "comma", n: ir.Comma(ir.Field("n"), lit(1))},
+		{desc: "binary", n: ir.Binary("max", ir.Field("n"), lit(5))},
+		{desc: "select", n: ir.Pipe(ir.Path("a", "b"), ir.Iterate(), ir.Select(ir.Func("double")))},
+		{desc: "func", n: ir.Pipe(ir.Field("n"), ir.Func("double"))},
+		{desc: "source", n: ir.Source("const", `[1]`)},
+		{desc: "source_value", n: &ir.Node{Op: ir.OpSource, Name: "const", Src: `[1]`, Value: lit([]interface{}{2}).Value}},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			data, err := ir.Marshal(tc.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, err := ir.Unmarshal(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := n.String(), tc.n.String(); got != want {
+				t.Errorf("loaded %s; want %s", got, want)
+			}
+			if got, want := apply(t, n, input), apply(t, tc.n, input); got != want {
+				t.Errorf("loaded filter produced %s; want %s", got, want)
+			}
+			again, err := ir.Marshal(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(again) != string(data) {
+				t.Errorf("serialized form is not stable:\n%s\n%s", data, again)
+			}
+		})
+	}
+}
+
+func TestUnmarshalError(t *testing.T) {
+	for _, tc := range []struct {
+		desc, data, want string
+	}{
+		{desc: "empty", data: ``, want: "no data"},
+		{desc: "version", data: `{"version": 99, "root": {"op": "identity"}}`, want: "unsupported version"},
+		{desc: "no_version", data: `{"root": {"op": "identity"}}`, want: "unsupported version"},
+		{desc: "no_root", data: `{"version": 1}`, want: "missing root"},
+		{desc: "unknown_op", data: `{"version": 1, "root": {"op": "goto"}}`, want: `unknown op "goto"`},
+		{desc: "bad_name", data: `{"version": 1, "root": {"op": "field", "name": 1}}`, want: "name is not a string"},
+		{desc: "bad_args", data: `{"version": 1, "root": {"op": "pipe", "args": {}}}`, want: "args is not an array"},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := ir.Unmarshal([]byte(tc.data))
+			if err == nil {
+				t.Fatal("unexpected success")
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("got error %q; want error containing %q", err, tc.want)
+			}
+		})
+	}
+}
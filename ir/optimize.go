@@ -0,0 +1,166 @@
+package ir
+
+import (
+	"sort"
+
+	"go.jayconrod.com/sift"
+)
+
+// Optimize returns a tree equivalent to the one rooted at n that may be
+// compiled into a faster filter. n is not modified. Optimize:
+//
+//   - flattens nested pipes and commas and removes identity stages from
+//     pipes;
+//   - fuses chains of field lookups, like .a | .b | .c, into a single
+//     OpPath node;
+//   - folds expressions that don't depend on their input, like 1 + 2, into
+//     literals; expressions that contain Func or Source nodes are never
+//     folded, since they may have side effects;
+//   - moves cheap select stages ahead of adjacent expensive ones, so values
+//     are discarded before expensive predicates are evaluated. An expensive
+//     predicate is one that calls a Func or Source node. Reordering may
+//     prevent an expensive predicate from reporting an error for a value
+//     the cheap predicate discards.
+func Optimize(n *Node) *Node {
+	out := *n
+	if len(n.Args) > 0 {
+		out.Args = make([]*Node, len(n.Args))
+		for i, arg := range n.Args {
+			out.Args[i] = Optimize(arg)
+		}
+	}
+	switch out.Op {
+	case OpPipe:
+		return optimizePipe(&out)
+	case OpComma:
+		out.Args = flatten(OpComma, out.Args)
+		if len(out.Args) == 1 {
+			return out.Args[0]
+		}
+	}
+	return fold(&out)
+}
+
+func optimizePipe(n *Node) *Node {
+	var args []*Node
+	for _, arg := range flatten(OpPipe, n.Args) {
+		if arg.Op != OpIdentity {
+			args = append(args, arg)
+		}
+	}
+
+	// Fuse field lookups.
+	fused := args[:0]
+	for _, arg := range args {
+		if last := len(fused) - 1; last >= 0 && isLookup(fused[last]) && isLookup(arg) {
+			fused[last] = Path(append(lookupPath(fused[last]), lookupPath(arg)...)...)
+		} else {
+			fused = append(fused, arg)
+		}
+	}
+	args = fused
+
+	// Order runs of adjacent selects by cost.
+	for i := 0; i < len(args); {
+		j := i
+		for j < len(args) && args[j].Op == OpSelect {
+			j++
+		}
+		if j > i+1 {
+			run := args[i:j]
+			sort.SliceStable(run, func(a, b int) bool {
+				return !isExpensive(run[a]) && isExpensive(run[b])
+			})
+		}
+		i = j + 1
+	}
+
+	switch len(args) {
+	case 0:
+		return Identity()
+	case 1:
+		return args[0]
+	}
+	return fold(Pipe(args...))
+}
+
+// flatten returns args with operands of nested op nodes spliced in.
+func flatten(op Op, args []*Node) []*Node {
+	var flat []*Node
+	for _, arg := range args {
+		if arg.Op == op {
+			flat = append(flat, flatten(op, arg.Args)...)
+		} else {
+			flat = append(flat, arg)
+		}
+	}
+	return flat
+}
+
+func isLookup(n *Node) bool {
+	return n.Op == OpField || n.Op == OpPath
+}
+
+func lookupPath(n *Node) []string {
+	if n.Op == OpField {
+		return []string{n.Name}
+	}
+	return append([]string(nil), n.Path...)
+}
+
+// fold replaces n with literals if it doesn't depend on its input and is
+// safe to evaluate ahead of time. n is returned if it can't be folded.
+func fold(n *Node) *Node {
+	if n.Op == OpLiteral || !isConstant(n) || isExpensive(n) {
+		return n
+	}
+	f, err := Compile(n)
+	if err != nil {
+		return n
+	}
+	vs, err := f(sift.NullValue)
+	if err != nil || len(vs) == 0 {
+		// Keep the node so the error is reported when the filter is applied.
+		return n
+	}
+	lits := make([]*Node, len(vs))
+	for i, v := range vs {
+		lits[i] = Literal(v)
+	}
+	if len(lits) == 1 {
+		return lits[0]
+	}
+	return Comma(lits...)
+}
+
+// isConstant reports whether n produces the same values for every input.
+func isConstant(n *Node) bool {
+	switch n.Op {
+	case OpLiteral:
+		return true
+	case OpPipe:
+		return len(n.Args) > 0 && isConstant(n.Args[0])
+	case OpComma, OpBinary:
+		for _, arg := range n.Args {
+			if !isConstant(arg) {
+				return false
+			}
+		}
+		return len(n.Args) > 0
+	default:
+		return false
+	}
+}
+
+// isExpensive reports whether the tree rooted at n contains Func or Source
+// nodes, whose cost and side effects are unknown.
+func isExpensive(n *Node) bool {
+	expensive := false
+	Inspect(n, func(n *Node) bool {
+		if n.Op == OpFunc || n.Op == OpSource {
+			expensive = true
+		}
+		return !expensive
+	})
+	return expensive
+}
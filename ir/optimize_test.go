@@ -0,0 +1,107 @@
+package ir_test
+
+import (
+	"strings"
+	"testing"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+	"go.jayconrod.com/sift/ir"
+)
+
+func TestOptimize(t *testing.T) {
+	input := `{"a": {"b": {"c": [1, 2]}, "ok": true}, "n": 3}`
+	for _, tc := range []struct {
+		desc string
+		n    *ir.Node
+		want string
+	}{
+		{
+			desc: "identity",
+			n:    ir.Pipe(ir.Identity(), ir.Field("n"), ir.Identity()),
+			want: `.n`,
+		}, {
+			desc: "only_identity",
+			n:    ir.Pipe(ir.Identity(), ir.Identity()),
+			want: `.`,
+		}, {
+			desc: "fuse",
+			n:    ir.Pipe(ir.Field("a"), ir.Pipe(ir.Field("b"), ir.Field("c")), ir.Iterate()),
+			want: `.a.b.c | .[]`,
+		}, {
+			desc: "fold_binary",
+			n:    ir.Binary("max", ir.Binary("max", lit(1), lit(4)), lit(2)),
+			want: `4`,
+		}, {
+			desc: "fold_comma",
+			n:    ir.Pipe(ir.Comma(lit(1), ir.Comma(lit(2))), ir.Identity()),
+			want: `1, 2`,
+		}, {
+			desc: "fold_pipe",
+			n:    ir.Pipe(lit([]interface{}{1, 2}), ir.Iterate()),
+			want: `1, 2`,
+		}, {
+			desc: "no_fold_input",
+			n:    ir.Binary("max", ir.Field("n"), lit(1)),
+			want: `.n max 1`,
+		}, {
+			desc: "no_fold_func",
+			n:    ir.Pipe(lit(1), ir.Func("double")),
+			want: `1 | double`,
+		}, {
+			desc: "no_fold_error",
+			n:    ir.Pipe(lit(1), ir.Iterate()),
+			want: `1 | .[]`,
+		}, {
+			desc: "select_order",
+			n: ir.Pipe(
+				ir.Select(ir.Pipe(ir.Identity(), ir.Func("double"))),
+				ir.Select(ir.Pipe(ir.Field("a"), ir.Field("ok"))),
+			),
+			want: `select(.a.ok) | select(double)`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			opt := ir.Optimize(tc.n)
+			if got := opt.String(); got != tc.want {
+				t.Errorf("got %s; want %s", got, tc.want)
+			}
+			if tc.desc == "no_fold_error" {
+				return
+			}
+			if got, want := apply(t, opt, input), apply(t, tc.n, input); got != want {
+				t.Errorf("optimized filter produced %s; want %s", got, want)
+			}
+		})
+	}
+}
+
+// apply compiles n, applies it to input, and returns its outputs as JSON.
+func apply(t *testing.T, n *ir.Node, input string) string {
+	t.Helper()
+	f, err := ir.Compile(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := json.NewDecoder(strings.NewReader(input)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs, err := f(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encodeAll(t, vs)
+}
+
+func encodeAll(t *testing.T, vs []sift.Value) string {
+	t.Helper()
+	b := &strings.Builder{}
+	enc := json.NewEncoder(b)
+	for _, v := range vs {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,78 @@
+package sift
+
+import (
+	"fmt"
+	"io"
+)
+
+// Iterator is implemented by arrays whose elements are produced one at
+// a time, like an array backed by a decoder that reads millions of elements.
+// Unlike Index, Iterator doesn't require random access or a known length,
+// so elements may be processed without holding them all in memory.
+//
+// Values may implement both Index and Iterator. Code that only needs the
+// elements in order should use Iterate, which accepts either.
+type Iterator interface {
+	Value
+
+	// Iterate returns a Decoder that produces the array's elements in order,
+	// then returns io.EOF. Each call returns a new Decoder that starts from
+	// the first element. An implementation that can only be read once
+	// should return a Decoder that returns an error if it's called again.
+	Iterate() Decoder
+}
+
+// Iterate returns a Decoder that produces the elements of v in order and
+// true if v implements Iterator or Index. Missing elements of an Index are
+// produced as null. If v is neither, nil and false are returned.
+func Iterate(v Value) (Decoder, bool) {
+	if it, ok := v.(Iterator); ok {
+		return it.Iterate(), true
+	}
+	if ix, ok := v.(Index); ok {
+		return &indexDecoder{ix: ix, n: ix.Length()}, true
+	}
+	return nil, false
+}
+
+// Collect returns the elements of v as an Index. If v implements Index,
+// it's returned as is. If v implements Iterator, all its elements are read
+// into a new array. An error is returned for other values or if iteration
+// fails.
+func Collect(v Value) (Index, error) {
+	if ix, ok := v.(Index); ok {
+		return ix, nil
+	}
+	it, ok := v.(Iterator)
+	if !ok {
+		return nil, fmt.Errorf("cannot iterate over value %v", v)
+	}
+	var ix indexType
+	dec := it.Iterate()
+	for {
+		e, err := dec.Decode()
+		if err == io.EOF {
+			return ix, nil
+		} else if err != nil {
+			return nil, err
+		}
+		ix = append(ix, e)
+	}
+}
+
+type indexDecoder struct {
+	ix   Index
+	i, n int
+}
+
+func (d *indexDecoder) Decode() (Value, error) {
+	if d.i >= d.n {
+		return nil, io.EOF
+	}
+	e, ok := d.ix.Index(d.i)
+	if !ok {
+		e = NullValue
+	}
+	d.i++
+	return e, nil
+}
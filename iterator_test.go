@@ -0,0 +1,96 @@
+package sift_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+// countIterator is an array of the integers from 0 to n-1, produced one at
+// a time. If err is set, it's returned instead of io.EOF.
+type countIterator struct {
+	n   int
+	err error
+}
+
+func (countIterator) Truth() bool { return true }
+
+func (c countIterator) Iterate() sift.Decoder {
+	i := 0
+	return decoderFunc(func() (sift.Value, error) {
+		if i >= c.n {
+			if c.err != nil {
+				return nil, c.err
+			}
+			return nil, io.EOF
+		}
+		i++
+		return sift.ToValue(i - 1)
+	})
+}
+
+type decoderFunc func() (sift.Value, error)
+
+func (f decoderFunc) Decode() (sift.Value, error) { return f() }
+
+func TestIterate(t *testing.T) {
+	errTest := errors.New("test")
+	for _, tc := range []struct {
+		desc    string
+		v       sift.Value
+		want    interface{}
+		wantErr error
+	}{
+		{desc: "iterator", v: countIterator{n: 3}, want: []interface{}{0, 1, 2}},
+		{desc: "empty", v: countIterator{}, want: []interface{}{}},
+		{desc: "index", v: sift.Must(sift.ToValue([]interface{}{"a", "b"})), want: []interface{}{"a", "b"}},
+		{desc: "hole", v: sift.Must(sift.ToValue([]sift.Value{sift.NullValue, nil})), want: []interface{}{nil, nil}},
+		{desc: "error", v: countIterator{n: 1, err: errTest}, wantErr: errTest},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dec, ok := sift.Iterate(tc.v)
+			if !ok {
+				t.Fatal("Iterate: got false; want true")
+			}
+			var elems []sift.Value
+			for {
+				e, err := dec.Decode()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					if err != tc.wantErr {
+						t.Fatalf("got error %v; want %v", err, tc.wantErr)
+					}
+					break
+				}
+				elems = append(elems, e)
+			}
+			if tc.wantErr != nil {
+				if _, err := sift.Collect(tc.v); err != tc.wantErr {
+					t.Fatalf("Collect: got error %v; want %v", err, tc.wantErr)
+				}
+				return
+			}
+			want := sift.Must(sift.ToValue(tc.want))
+			if got := sift.Must(sift.ToValue(elems)); !sift.Equal(got, want) {
+				t.Errorf("Iterate: got %v; want %v", got, want)
+			}
+			ix, err := sift.Collect(tc.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ix.Length() != want.(sift.Index).Length() {
+				t.Errorf("Collect: got length %d; want %d", ix.Length(), want.(sift.Index).Length())
+			}
+		})
+	}
+
+	if _, ok := sift.Iterate(sift.Must(sift.ToValue("abc"))); ok {
+		t.Error("Iterate(string): got true; want false")
+	}
+	if _, err := sift.Collect(sift.Must(sift.ToValue("abc"))); err == nil {
+		t.Error("Collect(string): got nil error; want error")
+	}
+}
@@ -0,0 +1,61 @@
+package sift
+
+// Kind is a broad category of values, corresponding to the types of JSON
+// and jq. Use TypeOf to find the kind of a value instead of probing for
+// each interface.
+type Kind int
+
+const (
+	// KindOther is the kind of values that aren't data, like functions and
+	// errors.
+	KindOther Kind = iota
+	KindNull
+	KindBool
+	KindNumber
+	KindString
+	KindArray
+	KindObject
+)
+
+// TypeOf returns the kind of v. Byte strings and times are strings, since
+// they're written as strings in JSON. Values that implement Index are
+// arrays, and other values that implement Attr are objects.
+func TypeOf(v Value) Kind {
+	switch kindOrder(v) {
+	case kindNull:
+		return KindNull
+	case kindFalse, kindTrue:
+		return KindBool
+	case kindNumber:
+		return KindNumber
+	case kindTime, kindString:
+		return KindString
+	case kindArray:
+		return KindArray
+	}
+	if _, ok := v.(Attr); ok {
+		return KindObject
+	}
+	return KindOther
+}
+
+// String returns the name of the kind as jq's type builtin writes it, like
+// "boolean" or "object". KindOther is "other".
+func (k Kind) String() string {
+	switch k {
+	case KindNull:
+		return "null"
+	case KindBool:
+		return "boolean"
+	case KindNumber:
+		return "number"
+	case KindString:
+		return "string"
+	case KindArray:
+		return "array"
+	case KindObject:
+		return "object"
+	default:
+		return "other"
+	}
+}
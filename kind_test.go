@@ -0,0 +1,34 @@
+package sift_test
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestTypeOf(t *testing.T) {
+	for _, tc := range []struct {
+		v    interface{}
+		want sift.Kind
+	}{
+		{nil, sift.KindNull},
+		{false, sift.KindBool},
+		{1.5, sift.KindNumber},
+		{big.NewInt(1), sift.KindNumber},
+		{"a", sift.KindString},
+		{[]byte("a"), sift.KindString},
+		{time.Unix(0, 0), sift.KindString},
+		{[]int{1}, sift.KindArray},
+		{sift.NewSet(), sift.KindArray},
+		{map[string]int{}, sift.KindObject},
+		{sift.NewFunc("f", 0, nil), sift.KindOther},
+		{&sift.ErrorValue{Value: sift.NullValue}, sift.KindOther},
+	} {
+		v := sift.Must(sift.ToValue(tc.v))
+		if got := sift.TypeOf(v); got != tc.want {
+			t.Errorf("TypeOf(%v) = %v; want %v", v, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package sift
+
+import "sync"
+
+// A LazyField is an attribute of an object returned by LazyAttr. Value is
+// called to produce the attribute's value the first time it's accessed.
+// If Value returns nil, the attribute is null. Value may return an
+// *ErrorValue to report that the value couldn't be produced.
+type LazyField struct {
+	Key   string
+	Value func() Value
+}
+
+// LazyAttr returns an object with the given fields, in order. Each field's
+// value is produced when it's first accessed, and the result is kept for
+// later accesses, so decoders and integrations may expose large or
+// expensive records without producing every attribute up front. Listing
+// the object's keys doesn't produce any values. If a key appears more than
+// once, the last field is used, in the position of the first.
+//
+// The returned object may be used concurrently. Each field's Value is
+// called at most once.
+func LazyAttr(fields ...LazyField) Value {
+	a := &lazyAttrType{fields: make(map[string]*lazyField, len(fields))}
+	for _, f := range fields {
+		if _, ok := a.fields[f.Key]; !ok {
+			a.keys = append(a.keys, f.Key)
+		}
+		a.fields[f.Key] = &lazyField{get: f.Value}
+	}
+	return a
+}
+
+type lazyAttrType struct {
+	keys   []string
+	fields map[string]*lazyField
+}
+
+type lazyField struct {
+	once sync.Once
+	get  func() Value
+	v    Value
+}
+
+func (a *lazyAttrType) Truth() bool { return true }
+
+func (a *lazyAttrType) Keys() []Value {
+	keys := make([]Value, len(a.keys))
+	for i, key := range a.keys {
+		keys[i] = stringValue(key)
+	}
+	return keys
+}
+
+func (a *lazyAttrType) Attr(key Value) (Value, bool) {
+	name, ok := AsString(key)
+	if !ok {
+		return nil, false
+	}
+	f, ok := a.fields[name]
+	if !ok {
+		return nil, false
+	}
+	f.once.Do(func() {
+		f.v = f.get()
+		if f.v == nil {
+			f.v = NullValue
+		}
+		f.get = nil
+	})
+	return f.v, true
+}
@@ -0,0 +1,40 @@
+package sift_test
+
+import (
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestLazyAttr(t *testing.T) {
+	calls := make(map[string]int)
+	field := func(key string, v interface{}) sift.LazyField {
+		return sift.LazyField{Key: key, Value: func() sift.Value {
+			calls[key]++
+			if v == nil {
+				return nil
+			}
+			return sift.Must(sift.ToValue(v))
+		}}
+	}
+	v := sift.LazyAttr(field("b", 1), field("a", []int{2}), field("n", nil))
+
+	keys := v.(sift.Attr).Keys()
+	if len(keys) != 3 || len(calls) != 0 {
+		t.Fatalf("Keys: got %v with %d values produced; want 3 keys and none produced", keys, len(calls))
+	}
+	for i := 0; i < 2; i++ {
+		if e, ok := sift.GetStringAttr(v, "b"); !ok || !sift.Equal(e, sift.Must(sift.ToValue(1))) {
+			t.Errorf("b: got %v, %v; want 1, true", e, ok)
+		}
+	}
+	if calls["b"] != 1 || calls["a"] != 0 {
+		t.Errorf("got calls %v; want b once and a never", calls)
+	}
+	if _, ok := sift.GetStringAttr(v, "missing"); ok {
+		t.Error("missing: got true; want false")
+	}
+	if got, want := encodeJSON(t, v), `{"b":1,"a":[2],"n":null}`; got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+}
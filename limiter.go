@@ -0,0 +1,90 @@
+package sift
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// A Limiter limits the rate of some kind of work, like values written or
+// time spent evaluating programs. It's a token bucket: tokens are added
+// continuously at a fixed rate, up to a burst size, and work removes them.
+// A Limiter is safe for concurrent use.
+//
+// Take, Available, and Spend accept the current time, so callers may use
+// their own clock. Wait uses the system clock.
+type Limiter struct {
+	mu                  sync.Mutex
+	rate, burst, tokens float64
+	last                time.Time
+}
+
+// NewLimiter returns a Limiter that adds rate tokens per second and holds
+// at most burst tokens. The Limiter starts full at time now.
+func NewLimiter(rate, burst float64, now time.Time) *Limiter {
+	return &Limiter{rate: rate, burst: burst, tokens: burst, last: now}
+}
+
+func (l *Limiter) refillLocked(now time.Time) {
+	if d := now.Sub(l.last); d > 0 {
+		l.tokens = math.Min(l.tokens+d.Seconds()*l.rate, l.burst)
+		l.last = now
+	}
+}
+
+// Take removes n tokens if there are at least n and reports whether it did.
+func (l *Limiter) Take(now time.Time, n float64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refillLocked(now)
+	if l.tokens < n {
+		return false
+	}
+	l.tokens -= n
+	return true
+}
+
+// Available reports whether any tokens are left.
+func (l *Limiter) Available(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refillLocked(now)
+	return l.tokens > 0
+}
+
+// Spend removes n tokens, even if that leaves fewer than zero. This is
+// useful when the amount of work isn't known until it's done. Later calls
+// wait for the debt to be repaid.
+func (l *Limiter) Spend(now time.Time, n float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refillLocked(now)
+	l.tokens -= n
+}
+
+// Wait blocks until there are n tokens, then removes them. If n is more
+// than the burst size, Wait blocks until the Limiter is full instead, then
+// spends n. If ctx is done first, Wait returns ctx's error without removing
+// any tokens.
+func (l *Limiter) Wait(ctx context.Context, n float64) error {
+	for {
+		l.mu.Lock()
+		l.refillLocked(time.Now())
+		if l.tokens >= math.Min(n, l.burst) || math.IsInf(l.rate, 1) {
+			l.tokens -= n
+			l.mu.Unlock()
+			return nil
+		}
+		d := time.Duration((math.Min(n, l.burst) - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
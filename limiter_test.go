@@ -0,0 +1,63 @@
+package sift_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestLimiter(t *testing.T) {
+	start := time.Unix(0, 0)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	l := sift.NewLimiter(10, 2, start)
+
+	for _, step := range []struct {
+		desc string
+		ms   int
+		take float64
+		want bool
+	}{
+		{desc: "burst", ms: 0, take: 2, want: true},
+		{desc: "empty", ms: 0, take: 1, want: false},
+		{desc: "refilled", ms: 100, take: 1, want: true},
+		{desc: "capped", ms: 10000, take: 3, want: false},
+		{desc: "full", ms: 10000, take: 2, want: true},
+	} {
+		if got := l.Take(at(step.ms), step.take); got != step.want {
+			t.Errorf("%s: Take(%v) = %v; want %v", step.desc, step.take, got, step.want)
+		}
+	}
+
+	l.Spend(at(10000), 3)
+	if l.Available(at(10050)) {
+		t.Error("tokens available while in debt")
+	}
+	if !l.Available(at(10400)) {
+		t.Error("no tokens available after debt was repaid")
+	}
+}
+
+func TestLimiterWait(t *testing.T) {
+	l := sift.NewLimiter(100, 1, time.Now())
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed, want := time.Since(start), 20*time.Millisecond; elapsed < want {
+		t.Errorf("took 3 tokens in %v; want at least %v", elapsed, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	slow := sift.NewLimiter(0.001, 1, time.Now())
+	if err := slow.Wait(ctx, 1); err != nil {
+		t.Fatalf("waiting for available token: %v", err)
+	}
+	if err := slow.Wait(ctx, 1); err != context.Canceled {
+		t.Errorf("got error %v; want %v", err, context.Canceled)
+	}
+}
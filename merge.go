@@ -0,0 +1,124 @@
+package sift
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A MergeStrategy determines how Merge combines the values of attributes
+// that are present in both objects.
+type MergeStrategy int
+
+const (
+	// MergeReplace uses the right object's value. Nested objects are not
+	// merged.
+	MergeReplace MergeStrategy = iota
+
+	// MergeRecurse merges nested objects recursively and otherwise uses the
+	// right object's value. This is how jq's * operator merges objects.
+	MergeRecurse
+
+	// MergeAppend is like MergeRecurse, but nested arrays are concatenated.
+	MergeAppend
+
+	// MergeError merges nested objects recursively. Other values must be
+	// Equal; if they're not, Merge returns an error wrapping ErrMergeConflict.
+	MergeError
+)
+
+// ErrMergeConflict is returned by Merge with MergeError when both objects
+// have different values for the same attribute.
+var ErrMergeConflict = errors.New("merge conflict")
+
+// Merge returns an object with the attributes of l and r, which must be
+// objects with string keys. The values of attributes present in only one
+// object are used as they are. strategy determines how values present in
+// both are combined.
+//
+// The result's keys are in the order of l's keys, followed by the keys
+// only r has, in r's order. l and r are not modified.
+func Merge(l, r Value, strategy MergeStrategy) (Value, error) {
+	la, ok := l.(Attr)
+	if !ok {
+		return nil, fmt.Errorf("cannot merge %s with %s", typeName(l), typeName(r))
+	}
+	ra, ok := r.(Attr)
+	if !ok {
+		return nil, fmt.Errorf("cannot merge %s with %s", typeName(l), typeName(r))
+	}
+	return merge(la, ra, strategy, "")
+}
+
+func merge(l, r Attr, strategy MergeStrategy, path string) (Value, error) {
+	lkeys := l.Keys()
+	out := &orderedAttrType{values: make(map[string]Value, len(lkeys))}
+	for _, key := range lkeys {
+		name, ok := AsString(key)
+		if !ok {
+			return nil, fmt.Errorf("cannot merge object with non-string key %v", key)
+		}
+		if v, ok := l.Attr(key); ok {
+			out.set(name, v)
+		}
+	}
+
+	for _, key := range r.Keys() {
+		name, ok := AsString(key)
+		if !ok {
+			return nil, fmt.Errorf("cannot merge object with non-string key %v", key)
+		}
+		rv, ok := r.Attr(key)
+		if !ok {
+			continue
+		}
+		lv, ok := out.values[name]
+		if !ok || strategy == MergeReplace {
+			out.set(name, rv)
+			continue
+		}
+
+		la, lok := lv.(Attr)
+		ra, rok := rv.(Attr)
+		if lok && rok {
+			merged, err := merge(la, ra, strategy, path+fieldSuffix(name))
+			if err != nil {
+				return nil, err
+			}
+			out.set(name, merged)
+			continue
+		}
+		switch strategy {
+		case MergeAppend:
+			if li, ok := lv.(Index); ok {
+				if ri, ok := rv.(Index); ok {
+					out.set(name, appendIndex(li, ri))
+					continue
+				}
+			}
+		case MergeError:
+			if !Equal(lv, rv) {
+				return nil, fmt.Errorf("%w at %s: %s and %s", ErrMergeConflict, path+fieldSuffix(name), keyString(lv), keyString(rv))
+			}
+			continue
+		}
+		out.set(name, rv)
+	}
+	return out, nil
+}
+
+// appendIndex returns an array with the elements of l followed by the
+// elements of r. Missing elements are null.
+func appendIndex(l, r Index) Index {
+	ln, rn := l.Length(), r.Length()
+	ix := make(indexType, 0, ln+rn)
+	for _, src := range []Index{l, r} {
+		for i := 0; i < src.Length(); i++ {
+			e, ok := src.Index(i)
+			if !ok {
+				e = NullValue
+			}
+			ix = append(ix, e)
+		}
+	}
+	return ix
+}
@@ -0,0 +1,93 @@
+package sift_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+)
+
+func TestMerge(t *testing.T) {
+	// Results have l's keys in their original order, followed by r's new
+	// keys.
+	l := `{"a": 1, "obj": {"x": 1, "arr": [1]}, "arr": [1, 2], "same": "s"}`
+	r := `{"new": true, "obj": {"y": 2, "arr": [2]}, "arr": [3], "same": "s", "a": 2}`
+	for _, tc := range []struct {
+		desc     string
+		l, r     string
+		strategy sift.MergeStrategy
+		want     string
+		wantErr  string
+	}{
+		{
+			desc:     "replace",
+			l:        l,
+			r:        r,
+			strategy: sift.MergeReplace,
+			want:     `{"a":2,"obj":{"y":2,"arr":[2]},"arr":[3],"same":"s","new":true}`,
+		}, {
+			desc:     "recurse",
+			l:        l,
+			r:        r,
+			strategy: sift.MergeRecurse,
+			want:     `{"a":2,"obj":{"x":1,"arr":[2],"y":2},"arr":[3],"same":"s","new":true}`,
+		}, {
+			desc:     "append",
+			l:        l,
+			r:        r,
+			strategy: sift.MergeAppend,
+			want:     `{"a":2,"obj":{"x":1,"arr":[1,2],"y":2},"arr":[1,2,3],"same":"s","new":true}`,
+		}, {
+			desc:     "error_none",
+			l:        `{"a": {"b": 1}, "c": [1]}`,
+			r:        `{"a": {"d": 2}, "c": [1]}`,
+			strategy: sift.MergeError,
+			want:     `{"a":{"b":1,"d":2},"c":[1]}`,
+		}, {
+			desc:     "error_conflict",
+			l:        `{"a": {"b": 1}}`,
+			r:        `{"a": {"b": 2}}`,
+			strategy: sift.MergeError,
+			wantErr:  "merge conflict at .a.b: 1 and 2",
+		}, {
+			desc:     "not_object",
+			l:        `{}`,
+			r:        `[]`,
+			strategy: sift.MergeRecurse,
+			wantErr:  "cannot merge object with array",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			decode := func(s string) sift.Value {
+				v, err := json.NewDecoder(strings.NewReader(s)).Decode()
+				if err != nil {
+					t.Fatal(err)
+				}
+				return v
+			}
+			lv, rv := decode(tc.l), decode(tc.r)
+			got, err := sift.Merge(lv, rv, tc.strategy)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("got error %v; want %q", err, tc.wantErr)
+				}
+				if tc.strategy == sift.MergeError && !errors.Is(err, sift.ErrMergeConflict) {
+					t.Errorf("got error %v; want ErrMergeConflict", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := &strings.Builder{}
+			if err := json.NewEncoder(w).Encode(got); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(w.String()); got != tc.want {
+				t.Errorf("got %s; want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package sift
+
+import (
+	"context"
+	"hash"
+	"io"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// An EncoderMiddleware wraps an Encoder with additional behavior, like
+// counting or checking values before they are written. Middleware lets
+// output-side concerns be added to any Encoder without changing it.
+type EncoderMiddleware func(Encoder) Encoder
+
+// WrapEncoder applies middleware to enc. The first middleware is outermost:
+// it sees each value first, and it passes the value to the second, and so on,
+// until the value reaches enc.
+//
+// Encoders returned by the middleware in this package implement Flusher.
+// Flush flushes the wrapped encoder if it is a Flusher.
+func WrapEncoder(enc Encoder, mw ...EncoderMiddleware) Encoder {
+	for i := len(mw) - 1; i >= 0; i-- {
+		enc = mw[i](enc)
+	}
+	return enc
+}
+
+// middlewareEncoder is an Encoder that calls encode for each value.
+// encode is responsible for passing the value to next.
+type middlewareEncoder struct {
+	next   Encoder
+	encode func(next Encoder, v Value) error
+	flush  func() error
+	drain  func() error
+}
+
+var _ Flusher = middlewareEncoder{}
+
+func (e middlewareEncoder) Encode(v Value) error {
+	return e.encode(e.next, v)
+}
+
+// ByteCount returns the number of bytes written by the wrapped encoder, if
+// it's a ByteCounter.
+func (e middlewareEncoder) ByteCount() int64 {
+	return byteCount(e.next)
+}
+
+// Drain drains the wrapped encoder, then calls drain if it's set.
+func (e middlewareEncoder) Drain() error {
+	if err := drain(e.next); err != nil {
+		return err
+	}
+	if e.drain != nil {
+		return e.drain()
+	}
+	return nil
+}
+
+func (e middlewareEncoder) Flush() error {
+	if f, ok := e.next.(Flusher); ok {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+	if e.flush != nil {
+		return e.flush()
+	}
+	return nil
+}
+
+// CountValues returns middleware that adds 1 to *n for each value written
+// successfully. *n is updated atomically, so it may be read concurrently
+// with sync/atomic.LoadInt64, for example, to report progress.
+func CountValues(n *int64) EncoderMiddleware {
+	return func(enc Encoder) Encoder {
+		return middlewareEncoder{
+			next: enc,
+			encode: func(next Encoder, v Value) error {
+				if err := next.Encode(v); err != nil {
+					return err
+				}
+				atomic.AddInt64(n, 1)
+				return nil
+			},
+		}
+	}
+}
+
+// HashValues returns middleware that writes each value written successfully
+// to h, using an Encoder returned by newEncoder. For example, with
+// a JSON encoder and a SHA-256 hash, h may be used to compute a checksum of
+// a program's output. The sum is complete after the wrapping encoder
+// is flushed.
+func HashValues(h hash.Hash, newEncoder func(io.Writer) Encoder) EncoderMiddleware {
+	return func(enc Encoder) Encoder {
+		henc := newEncoder(h)
+		return middlewareEncoder{
+			next: enc,
+			encode: func(next Encoder, v Value) error {
+				if err := next.Encode(v); err != nil {
+					return err
+				}
+				return henc.Encode(v)
+			},
+			flush: func() error {
+				if f, ok := henc.(Flusher); ok {
+					return f.Flush()
+				}
+				return nil
+			},
+		}
+	}
+}
+
+// Tee returns middleware that writes each value to enc after it's written
+// successfully to the wrapped encoder. For example, Tee may be used to keep
+// a copy of a program's output in a file while it's written to standard
+// output. enc is flushed after the wrapped encoder.
+func Tee(enc Encoder) EncoderMiddleware {
+	return func(next Encoder) Encoder {
+		return middlewareEncoder{
+			next: next,
+			encode: func(next Encoder, v Value) error {
+				if err := next.Encode(v); err != nil {
+					return err
+				}
+				return enc.Encode(v)
+			},
+			flush: func() error {
+				if f, ok := enc.(Flusher); ok {
+					return f.Flush()
+				}
+				return nil
+			},
+			drain: func() error {
+				return drain(enc)
+			},
+		}
+	}
+}
+
+// Throttle returns middleware that limits the rate at which values are
+// written. Encode blocks as needed so that consecutive values are written
+// at least interval apart.
+func Throttle(interval time.Duration) EncoderMiddleware {
+	return ThrottleContext(context.Background(), interval)
+}
+
+// ThrottleContext is like Throttle, but when ctx is done, Encode stops
+// waiting and returns the context's error instead of writing the value.
+func ThrottleContext(ctx context.Context, interval time.Duration) EncoderMiddleware {
+	return func(enc Encoder) Encoder {
+		rate := math.Inf(1)
+		if interval > 0 {
+			rate = 1 / interval.Seconds()
+		}
+		l := NewLimiter(rate, 1, time.Now())
+		return middlewareEncoder{
+			next: enc,
+			encode: func(next Encoder, v Value) error {
+				if err := l.Wait(ctx, 1); err != nil {
+					return err
+				}
+				return next.Encode(v)
+			},
+		}
+	}
+}
+
+// Validate returns middleware that calls check on each value before it is
+// written. If check returns an error, the value is not written, and Encode
+// returns the error.
+func Validate(check func(Value) error) EncoderMiddleware {
+	return func(enc Encoder) Encoder {
+		return middlewareEncoder{
+			next: enc,
+			encode: func(next Encoder, v Value) error {
+				if err := check(v); err != nil {
+					return err
+				}
+				return next.Encode(v)
+			},
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package sift_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+)
+
+func TestWrapEncoder(t *testing.T) {
+	var order []string
+	trace := func(name string) sift.EncoderMiddleware {
+		return sift.Validate(func(sift.Value) error {
+			order = append(order, name)
+			return nil
+		})
+	}
+	rec := &recordEncoder{}
+	enc := sift.WrapEncoder(rec, trace("a"), trace("b"))
+	if err := enc.Encode(sift.NullValue); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(order), "[a b]"; got != want {
+		t.Errorf("got order %s; want %s", got, want)
+	}
+	if len(rec.values) != 1 {
+		t.Errorf("got %d values; want 1", len(rec.values))
+	}
+}
+
+func TestCountValues(t *testing.T) {
+	var n int64
+	enc := sift.WrapEncoder(&recordEncoder{}, sift.CountValues(&n))
+	for i := 0; i < 3; i++ {
+		if err := enc.Encode(sift.Must(sift.ToValue(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n != 3 {
+		t.Errorf("got %d; want 3", n)
+	}
+}
+
+func TestHashValues(t *testing.T) {
+	newJSON := func(w io.Writer) sift.Encoder { return json.NewEncoder(w) }
+	h := sha256.New()
+	enc := sift.WrapEncoder(&recordEncoder{}, sift.HashValues(h, newJSON))
+	for _, i := range []interface{}{1, "a", []interface{}{true}} {
+		if err := enc.Encode(sift.Must(sift.ToValue(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.(sift.Flusher).Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := sha256.Sum256([]byte("1\n\"a\"\n[true]\n"))
+	if got := h.Sum(nil); string(got) != string(want[:]) {
+		t.Errorf("got sum %x; want %x", got, want)
+	}
+}
+
+func TestTee(t *testing.T) {
+	main, tee := &recordEncoder{}, &recordEncoder{}
+	enc := sift.WrapEncoder(main, sift.Tee(tee))
+	for i := 0; i < 3; i++ {
+		if err := enc.Encode(sift.Must(sift.ToValue(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(main.values) != 3 || len(tee.values) != 3 {
+		t.Errorf("got %d and %d values; want 3 and 3", len(main.values), len(tee.values))
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	const interval = 10 * time.Millisecond
+	enc := sift.WrapEncoder(&recordEncoder{}, sift.Throttle(interval))
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := enc.Encode(sift.NullValue); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 2*interval {
+		t.Errorf("encoded 3 values in %v; want at least %v", elapsed, 2*interval)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), interval)
+	defer cancel()
+	rec := &recordEncoder{}
+	enc = sift.WrapEncoder(rec, sift.ThrottleContext(ctx, time.Hour))
+	if err := enc.Encode(sift.NullValue); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(sift.NullValue); err != context.DeadlineExceeded {
+		t.Errorf("got error %v; want %v", err, context.DeadlineExceeded)
+	}
+	if len(rec.values) != 1 {
+		t.Errorf("got %d values written; want 1", len(rec.values))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	errNegative := errors.New("negative")
+	rec := &recordEncoder{}
+	enc := sift.WrapEncoder(rec, sift.Validate(func(v sift.Value) error {
+		if n, _ := sift.AsFloat64(v); n < 0 {
+			return errNegative
+		}
+		return nil
+	}))
+	if err := enc.Encode(sift.Must(sift.ToValue(1))); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(sift.Must(sift.ToValue(-1))); err != errNegative {
+		t.Errorf("got error %v; want %v", err, errNegative)
+	}
+	if len(rec.values) != 1 {
+		t.Errorf("got %d values; want 1", len(rec.values))
+	}
+}
@@ -0,0 +1,101 @@
+package sift
+
+import "io"
+
+// MultiEncoder returns an Encoder that writes each value to all of the given
+// encoders, in order, like io.MultiWriter. If an encoder returns an error,
+// Encode stops and returns that error without writing the value to the
+// remaining encoders.
+//
+// The returned Encoder implements Flusher. Flush flushes each encoder that
+// is a Flusher and returns the first error, if any.
+func MultiEncoder(encs ...Encoder) Encoder {
+	all := make([]Encoder, 0, len(encs))
+	for _, enc := range encs {
+		if m, ok := enc.(multiEncoder); ok {
+			all = append(all, m...)
+		} else {
+			all = append(all, enc)
+		}
+	}
+	return multiEncoder(all)
+}
+
+type multiEncoder []Encoder
+
+var _ Flusher = multiEncoder(nil)
+
+func (m multiEncoder) Encode(v Value) error {
+	for _, enc := range m {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Drain drains each encoder and returns the first error, if any.
+func (m multiEncoder) Drain() error {
+	for _, enc := range m {
+		if err := drain(enc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multiEncoder) Flush() error {
+	var first error
+	for _, enc := range m {
+		if f, ok := enc.(Flusher); ok {
+			if err := f.Flush(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// ChainDecoders returns a Decoder that reads values from each of the given
+// decoders in sequence. When a decoder returns io.EOF, the next decoder is
+// read. The returned Decoder returns io.EOF after the last decoder does.
+// Errors other than io.EOF are returned as they are, and the same decoder
+// is read on the next call. If it returns the same error again, it can't
+// continue after the error, so the rest of its input is skipped, and the
+// next decoder is read. The returned Decoder is a ByteCounter; its count is
+// the sum of the counts of the decoders that are ByteCounters.
+func ChainDecoders(decs ...Decoder) Decoder {
+	return &chainDecoder{decs: decs}
+}
+
+type chainDecoder struct {
+	decs []Decoder
+
+	// done is the byte count of decoders that have been read to the end.
+	done int64
+
+	// lastErr is the error the current decoder returned on the last call,
+	// if any.
+	lastErr error
+}
+
+func (c *chainDecoder) Decode() (Value, error) {
+	for len(c.decs) > 0 {
+		v, err := c.decs[0].Decode()
+		if err == nil || err != io.EOF && !sameError(err, c.lastErr) {
+			c.lastErr = err
+			return v, err
+		}
+		c.done += byteCount(c.decs[0])
+		c.decs = c.decs[1:]
+		c.lastErr = nil
+	}
+	return nil, io.EOF
+}
+
+func (c *chainDecoder) ByteCount() int64 {
+	if len(c.decs) == 0 {
+		return c.done
+	}
+	return c.done + byteCount(c.decs[0])
+}
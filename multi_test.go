@@ -0,0 +1,32 @@
+package sift_test
+
+import (
+	"errors"
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestMultiEncoder(t *testing.T) {
+	errFull := errors.New("full")
+	a, b := &recordEncoder{}, &recordEncoder{}
+	full := sift.WrapEncoder(&recordEncoder{}, sift.Validate(func(sift.Value) error { return errFull }))
+	enc := sift.MultiEncoder(a, sift.MultiEncoder(b))
+	if err := enc.Encode(sift.NullValue); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.values) != 1 || len(b.values) != 1 {
+		t.Errorf("got %d and %d values; want 1 and 1", len(a.values), len(b.values))
+	}
+	if err := enc.(sift.Flusher).Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	enc = sift.MultiEncoder(a, full, b)
+	if err := enc.Encode(sift.NullValue); err != errFull {
+		t.Errorf("got error %v; want %v", err, errFull)
+	}
+	if len(a.values) != 2 || len(b.values) != 1 {
+		t.Errorf("got %d and %d values; want 2 and 1", len(a.values), len(b.values))
+	}
+}
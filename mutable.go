@@ -0,0 +1,153 @@
+package sift
+
+import "fmt"
+
+// SetAttr is implemented by objects whose attributes may be updated without
+// copying the whole object.
+type SetAttr interface {
+	Attr
+
+	// SetAttr returns an object with the attribute named by key set to v.
+	// The receiver may be modified and returned, so the caller must own it
+	// and must not use it afterward; only the returned object is valid.
+	// An error is returned if key can't be used as a key of the object.
+	SetAttr(key, v Value) (Attr, error)
+}
+
+// DeleteAttr is implemented by objects whose attributes may be removed
+// without copying the whole object.
+type DeleteAttr interface {
+	Attr
+
+	// DeleteAttr returns an object without the attribute named by key.
+	// As with SetAttr, the receiver may be modified and returned. It is not
+	// an error if the object has no such attribute.
+	DeleteAttr(key Value) (Attr, error)
+}
+
+// SetIndex is implemented by arrays whose elements may be updated without
+// copying the whole array.
+type SetIndex interface {
+	Index
+
+	// SetIndex returns an array with the element at index i set to v.
+	// If i is greater than or equal to the array's length, the array is
+	// extended, and elements between the old length and i are null. As with
+	// SetAttr, the receiver may be modified and returned. i must be
+	// non-negative.
+	SetIndex(i int, v Value) (Index, error)
+}
+
+// WithAttr returns v with the attribute named by key set to e. If v
+// implements SetAttr, v may be modified in place, so the caller must own
+// v. Otherwise, if v is null or an object, a new object is returned with
+// v's attributes copied (but not deeply copied). An error is returned for
+// other values.
+func WithAttr(v, key, e Value) (Value, error) {
+	if a, ok := v.(SetAttr); ok {
+		return a.SetAttr(key, e)
+	}
+	a, err := copyAttr(v)
+	if err != nil {
+		return nil, err
+	}
+	return a.SetAttr(key, e)
+}
+
+// WithoutAttr returns v without the attribute named by key. As with
+// WithAttr, v is modified in place if it implements DeleteAttr. Otherwise,
+// v's attributes are copied into a new object.
+func WithoutAttr(v, key Value) (Value, error) {
+	if a, ok := v.(DeleteAttr); ok {
+		return a.DeleteAttr(key)
+	}
+	a, err := copyAttr(v)
+	if err != nil {
+		return nil, err
+	}
+	return a.DeleteAttr(key)
+}
+
+// WithIndex returns v with the element at index i set to e. If v
+// implements SetIndex, v may be modified in place, so the caller must own
+// v. Otherwise, if v is null or an array, a new array is returned with v's
+// elements copied (but not deeply copied). An error is returned for other
+// values.
+func WithIndex(v Value, i int, e Value) (Value, error) {
+	if i < 0 {
+		return nil, fmt.Errorf("cannot set negative array index %d", i)
+	}
+	if ix, ok := v.(SetIndex); ok {
+		return ix.SetIndex(i, e)
+	}
+	var ix indexType
+	if src, ok := v.(Index); ok {
+		ix = make(indexType, src.Length())
+		for j := range ix {
+			ix[j], _ = src.Index(j)
+		}
+	} else if !IsNull(v) {
+		return nil, fmt.Errorf("cannot set index of value %v: not an array", v)
+	}
+	return ix.SetIndex(i, e)
+}
+
+// copyAttr returns a new object with the attributes of v, in the same
+// order. v must be null or an object with string keys.
+func copyAttr(v Value) (*orderedAttrType, error) {
+	if IsNull(v) {
+		return &orderedAttrType{values: make(map[string]Value)}, nil
+	}
+	src, ok := v.(Attr)
+	if !ok {
+		return nil, fmt.Errorf("cannot set attribute of value %v: not an object", v)
+	}
+	keys := src.Keys()
+	a := &orderedAttrType{values: make(map[string]Value, len(keys))}
+	for _, key := range keys {
+		name, ok := AsString(key)
+		if !ok {
+			return nil, fmt.Errorf("cannot copy object with non-string key %v", key)
+		}
+		if e, ok := src.Attr(key); ok {
+			a.set(name, e)
+		}
+	}
+	return a, nil
+}
+
+var (
+	_ SetAttr    = attrType(nil)
+	_ DeleteAttr = attrType(nil)
+	_ SetIndex   = indexType(nil)
+)
+
+func (a attrType) SetAttr(key, v Value) (Attr, error) {
+	name, ok := AsString(key)
+	if !ok {
+		return nil, fmt.Errorf("cannot set attribute with non-string key %v", key)
+	}
+	if a == nil {
+		a = make(attrType)
+	}
+	a[name] = v
+	return a, nil
+}
+
+func (a attrType) DeleteAttr(key Value) (Attr, error) {
+	if name, ok := AsString(key); ok {
+		delete(a, name)
+	}
+	return a, nil
+}
+
+func (ix indexType) SetIndex(i int, v Value) (Index, error) {
+	if i < 0 {
+		return nil, fmt.Errorf("cannot set negative array index %d", i)
+	}
+	for len(ix) <= i {
+		ix = append(ix, NullValue)
+	}
+	ix[i] = v
+	return ix, nil
+}
@@ -0,0 +1,138 @@
+package sift_test
+
+import (
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestWithAttr(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		v       interface{}
+		key     interface{}
+		e       interface{}
+		delete  bool
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			desc: "add",
+			v:    map[string]interface{}{"a": 1},
+			key:  "b",
+			e:    2,
+			want: map[string]interface{}{"a": 1, "b": 2},
+		}, {
+			desc: "replace",
+			v:    map[string]interface{}{"a": 1},
+			key:  "a",
+			e:    2,
+			want: map[string]interface{}{"a": 2},
+		}, {
+			desc: "null",
+			v:    nil,
+			key:  "a",
+			e:    1,
+			want: map[string]interface{}{"a": 1},
+		}, {
+			desc: "nil_map",
+			v:    map[string]sift.Value(nil),
+			key:  "a",
+			e:    1,
+			want: map[string]interface{}{"a": 1},
+		}, {
+			desc:   "delete",
+			v:      map[string]interface{}{"a": 1, "b": 2},
+			key:    "a",
+			delete: true,
+			want:   map[string]interface{}{"b": 2},
+		}, {
+			desc:   "delete_missing",
+			v:      map[string]interface{}{"a": 1},
+			key:    "b",
+			delete: true,
+			want:   map[string]interface{}{"a": 1},
+		}, {
+			desc:    "not_object",
+			v:       []interface{}{},
+			key:     "a",
+			e:       1,
+			wantErr: true,
+		}, {
+			desc:    "number_key",
+			v:       map[string]interface{}{},
+			key:     1,
+			e:       1,
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			v := sift.Must(sift.ToValue(tc.v))
+			key := sift.Must(sift.ToValue(tc.key))
+			var got sift.Value
+			var err error
+			if tc.delete {
+				got, err = sift.WithoutAttr(v, key)
+			} else {
+				got, err = sift.WithAttr(v, key, sift.Must(sift.ToValue(tc.e)))
+			}
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("got %v; want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := sift.Must(sift.ToValue(tc.want)); !sift.Equal(got, want) {
+				t.Errorf("got %v; want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWithIndex(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		v       interface{}
+		i       int
+		want    interface{}
+		wantErr bool
+	}{
+		{desc: "replace", v: []interface{}{1, 2}, i: 1, want: []interface{}{1, "x"}},
+		{desc: "append", v: []interface{}{1}, i: 1, want: []interface{}{1, "x"}},
+		{desc: "extend", v: []interface{}{1}, i: 3, want: []interface{}{1, nil, nil, "x"}},
+		{desc: "null", v: nil, i: 0, want: []interface{}{"x"}},
+		{desc: "negative", v: []interface{}{}, i: -1, wantErr: true},
+		{desc: "not_array", v: "abc", i: 0, wantErr: true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := sift.WithIndex(sift.Must(sift.ToValue(tc.v)), tc.i, sift.Must(sift.ToValue("x")))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("got %v; want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := sift.Must(sift.ToValue(tc.want)); !sift.Equal(got, want) {
+				t.Errorf("got %v; want %v", got, want)
+			}
+		})
+	}
+}
+
+// TestWithAttrInPlace checks that the default object type is updated in
+// place rather than copied.
+func TestWithAttrInPlace(t *testing.T) {
+	m := map[string]sift.Value{}
+	if _, err := sift.WithAttr(sift.Must(sift.ToValue(m)), sift.Must(sift.ToValue("a")), sift.NullValue); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m["a"]; !ok {
+		t.Error("object was copied; want it modified in place")
+	}
+}
@@ -0,0 +1,93 @@
+package sift
+
+import "fmt"
+
+// KeyValue is an attribute of an object. ToValue converts a []KeyValue to
+// an object whose Keys method returns keys in the order they appear in the
+// slice, rather than sorted, so the key order of input documents may be
+// preserved.
+type KeyValue struct {
+	Key   string
+	Value interface{}
+}
+
+// orderedAttrType is an object that remembers the order in which its keys
+// were added.
+type orderedAttrType struct {
+	keys   []string
+	values map[string]Value
+}
+
+var (
+	_ SetAttr    = (*orderedAttrType)(nil)
+	_ DeleteAttr = (*orderedAttrType)(nil)
+)
+
+// newOrderedAttr converts kvs to an ordered object. If a key appears more
+// than once, the last value is used, in the position of the first.
+func newOrderedAttr(kvs []KeyValue) (*orderedAttrType, error) {
+	a := &orderedAttrType{values: make(map[string]Value, len(kvs))}
+	for _, kv := range kvs {
+		v, err := ToValue(kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		a.set(kv.Key, v)
+	}
+	return a, nil
+}
+
+func (a *orderedAttrType) Truth() bool { return true }
+
+func (a *orderedAttrType) Keys() []Value {
+	keys := make([]Value, len(a.keys))
+	for i, key := range a.keys {
+		keys[i] = stringValue(key)
+	}
+	return keys
+}
+
+func (a *orderedAttrType) Attr(key Value) (Value, bool) {
+	name, ok := AsString(key)
+	if !ok {
+		return nil, false
+	}
+	v, ok := a.values[name]
+	return v, ok
+}
+
+// SetAttr sets the attribute named by key. A new key is added after all
+// other keys; an existing key keeps its position.
+func (a *orderedAttrType) SetAttr(key, v Value) (Attr, error) {
+	name, ok := AsString(key)
+	if !ok {
+		return nil, fmt.Errorf("cannot set attribute with non-string key %v", key)
+	}
+	a.set(name, v)
+	return a, nil
+}
+
+func (a *orderedAttrType) DeleteAttr(key Value) (Attr, error) {
+	name, ok := AsString(key)
+	if !ok {
+		return a, nil
+	}
+	if _, ok := a.values[name]; !ok {
+		return a, nil
+	}
+	delete(a.values, name)
+	for i, k := range a.keys {
+		if k == name {
+			a.keys = append(a.keys[:i], a.keys[i+1:]...)
+			break
+		}
+	}
+	return a, nil
+}
+
+func (a *orderedAttrType) set(name string, v Value) {
+	if _, ok := a.values[name]; !ok {
+		a.keys = append(a.keys, name)
+	}
+	a.values[name] = v
+}
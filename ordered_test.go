@@ -0,0 +1,50 @@
+package sift_test
+
+import (
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestOrderedAttr(t *testing.T) {
+	v := sift.Must(sift.ToValue([]sift.KeyValue{
+		{Key: "c", Value: 1},
+		{Key: "a", Value: 2},
+		{Key: "b", Value: 3},
+		{Key: "a", Value: 4},
+	}))
+	checkKeys := func(v sift.Value, want ...string) {
+		t.Helper()
+		var got []string
+		for _, key := range v.(sift.Attr).Keys() {
+			s, _ := sift.AsString(key)
+			got = append(got, s)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("got keys %q; want %q", got, want)
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("got keys %q; want %q", got, want)
+			}
+		}
+	}
+	checkKeys(v, "c", "a", "b")
+	if a, ok := sift.GetStringAttr(v, "a"); !ok || !sift.Equal(a, sift.Must(sift.ToValue(4))) {
+		t.Errorf("got a = %v, %v; want 4, true", a, ok)
+	}
+
+	sorted := sift.Must(sift.ToValue(map[string]interface{}{"a": 4, "b": 3, "c": 1}))
+	if !sift.Equal(v, sorted) || !sift.Equal(sorted, v) {
+		t.Errorf("ordered object not equal to sorted object with the same attributes")
+	}
+	if c := sift.Compare(v, sorted); c != 0 {
+		t.Errorf("Compare(ordered, sorted) = %d; want 0", c)
+	}
+
+	v = sift.Must(sift.WithAttr(v, sift.Must(sift.ToValue("d")), sift.NullValue))
+	v = sift.Must(sift.WithAttr(v, sift.Must(sift.ToValue("c")), sift.NullValue))
+	checkKeys(v, "c", "a", "b", "d")
+	v = sift.Must(sift.WithoutAttr(v, sift.Must(sift.ToValue("a"))))
+	checkKeys(v, "c", "b", "d")
+}
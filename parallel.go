@@ -0,0 +1,145 @@
+package sift
+
+import (
+	"io"
+	"runtime"
+	"sync"
+)
+
+// ParallelOptions controls how SiftParallel applies a filter.
+type ParallelOptions struct {
+	// Workers is the number of goroutines that apply the filter.
+	// If Workers is zero or negative, runtime.GOMAXPROCS(0) is used.
+	Workers int
+
+	// Unordered allows results to be encoded as soon as they're available,
+	// rather than in the order of the values they were produced from.
+	// Results produced from the same value are still encoded together,
+	// in order.
+	Unordered bool
+}
+
+// SiftParallel is like Sift, but it applies f to several values at once.
+// Values are decoded on one goroutine, f is applied on a pool of worker
+// goroutines, and results are encoded on the calling goroutine. Unless
+// opts.Unordered is set, results are encoded in the order of the decoded
+// values they were produced from, as with Sift.
+//
+// f must be safe to call concurrently. SiftParallel stops and returns
+// the first error it encounters; with ordered results, that's the error
+// for the earliest value. When SiftParallel returns early, a goroutine may
+// still be blocked in dec.Decode; it stops after Decode returns.
+func SiftParallel(dec Decoder, f Filter, enc Encoder, opts ParallelOptions) error {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	send := func(out chan<- parallelResult, r parallelResult) bool {
+		select {
+		case out <- r:
+			return true
+		case <-done:
+			return false
+		}
+	}
+
+	// With ordered results, each value gets its own result channel, and
+	// the channels are queued in order. Otherwise, all results are sent
+	// on a shared channel.
+	jobs := make(chan parallelJob, workers)
+	order := make(chan chan parallelResult, workers)
+	results := make(chan parallelResult, workers)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(order)
+		defer close(jobs)
+		for {
+			v, err := dec.Decode()
+			if err == io.EOF {
+				return
+			}
+			out := results
+			if !opts.Unordered {
+				out = make(chan parallelResult, 1)
+				select {
+				case order <- out:
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				send(out, parallelResult{err: err})
+				return
+			}
+			select {
+			case jobs <- parallelJob{v: v, out: out}:
+			case <-done:
+				return
+			}
+		}
+	}()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				vs, err := f(j.v)
+				if !send(j.out, parallelResult{vs: vs, err: err}) {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	encode := func(r parallelResult) error {
+		if r.err != nil {
+			return r.err
+		}
+		for _, v := range r.vs {
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if opts.Unordered {
+		for r := range results {
+			if err := encode(r); err != nil {
+				return err
+			}
+		}
+	} else {
+		for out := range order {
+			if err := encode(<-out); err != nil {
+				return err
+			}
+		}
+	}
+	if fl, ok := enc.(Flusher); ok {
+		return fl.Flush()
+	}
+	return nil
+}
+
+type parallelJob struct {
+	v   Value
+	out chan<- parallelResult
+}
+
+type parallelResult struct {
+	vs  []Value
+	err error
+}
@@ -0,0 +1,78 @@
+package sift_test
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestSiftParallel(t *testing.T) {
+	var input []interface{}
+	for i := 0; i < 50; i++ {
+		input = append(input, i)
+	}
+	// Earlier values take longer, so workers finish out of order.
+	slow := func(v sift.Value) ([]sift.Value, error) {
+		n, _ := sift.AsFloat64(v)
+		time.Sleep(time.Duration(50-n) * 100 * time.Microsecond)
+		return []sift.Value{v, v}, nil
+	}
+	var want []float64
+	for i := range input {
+		want = append(want, float64(i), float64(i))
+	}
+
+	for _, tc := range []struct {
+		desc string
+		opts sift.ParallelOptions
+	}{
+		{desc: "ordered", opts: sift.ParallelOptions{Workers: 4}},
+		{desc: "unordered", opts: sift.ParallelOptions{Workers: 4, Unordered: true}},
+		{desc: "default_workers"},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dec, _ := sift.Iterate(sift.Must(sift.ToValue(input)))
+			enc := &recordEncoder{}
+			if err := sift.SiftParallel(dec, slow, enc, tc.opts); err != nil {
+				t.Fatal(err)
+			}
+			var got []float64
+			for _, v := range enc.values {
+				n, _ := sift.AsFloat64(v)
+				got = append(got, n)
+			}
+			if tc.opts.Unordered {
+				sort.Float64s(got)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("got %d values; want %d", len(got), len(want))
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Fatalf("got %v; want %v", got, want)
+				}
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		errBad := errors.New("bad value")
+		f := func(v sift.Value) ([]sift.Value, error) {
+			if n, _ := sift.AsFloat64(v); n == 10 {
+				return nil, errBad
+			}
+			return []sift.Value{v}, nil
+		}
+		dec, _ := sift.Iterate(sift.Must(sift.ToValue(input)))
+		enc := &recordEncoder{}
+		if err := sift.SiftParallel(dec, f, enc, sift.ParallelOptions{Workers: 4}); err != errBad {
+			t.Fatalf("got error %v; want %v", err, errBad)
+		}
+		if len(enc.values) != 10 {
+			t.Errorf("got %d values before error; want 10", len(enc.values))
+		}
+	})
+}
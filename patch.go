@@ -0,0 +1,322 @@
+package sift
+
+import (
+	"fmt"
+	"io"
+)
+
+// Diff returns a JSON Patch, as defined in RFC 6902, that transforms l into
+// r when applied with ApplyPatch. The patch is an array of operations.
+// Objects are compared by key and arrays by index, so an element inserted
+// at the beginning of an array is seen as a change to every element.
+// Values that aren't Equal and can't be compared this way are replaced.
+func Diff(l, r Value) Value {
+	var ops indexType
+	diff(l, r, nil, &ops)
+	if ops == nil {
+		ops = indexType{}
+	}
+	return ops
+}
+
+func diff(l, r Value, p Path, ops *indexType) {
+	if Equal(l, r) {
+		return
+	}
+	if lnames, lok := attrNames(l); lok {
+		if rnames, rok := attrNames(r); rok {
+			rset := make(map[string]bool, len(rnames))
+			for _, name := range rnames {
+				rset[name] = true
+			}
+			for _, name := range lnames {
+				if !rset[name] {
+					*ops = append(*ops, patchOp("remove", appendPath(p, stringValue(name)), nil))
+				}
+			}
+			for _, name := range rnames {
+				rv, _ := GetStringAttr(r, name)
+				if lv, ok := GetStringAttr(l, name); ok {
+					diff(lv, rv, appendPath(p, stringValue(name)), ops)
+				} else {
+					*ops = append(*ops, patchOp("add", appendPath(p, stringValue(name)), rv))
+				}
+			}
+			return
+		}
+	}
+	if li, ok := l.(Index); ok {
+		if ri, ok := r.(Index); ok {
+			ln, rn := li.Length(), ri.Length()
+			for i := 0; i < ln && i < rn; i++ {
+				diff(indexOrNull(li, i), indexOrNull(ri, i), appendPath(p, floatValue(float64(i))), ops)
+			}
+			for i := ln; i < rn; i++ {
+				*ops = append(*ops, patchOp("add", appendPath(p, floatValue(float64(i))), indexOrNull(ri, i)))
+			}
+			for i := ln - 1; i >= rn; i-- {
+				*ops = append(*ops, patchOp("remove", appendPath(p, floatValue(float64(i))), nil))
+			}
+			return
+		}
+	}
+	*ops = append(*ops, patchOp("replace", p, r))
+}
+
+// attrNames returns the keys of v as strings and true if v is an object
+// with string keys.
+func attrNames(v Value) ([]string, bool) {
+	a, ok := v.(Attr)
+	if !ok {
+		return nil, false
+	}
+	keys := a.Keys()
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		if names[i], ok = AsString(key); !ok {
+			return nil, false
+		}
+	}
+	return names, true
+}
+
+func indexOrNull(ix Index, i int) Value {
+	if e, ok := ix.Index(i); ok && e != nil {
+		return e
+	}
+	return NullValue
+}
+
+// appendPath returns a new path with k after the elements of p. p is not
+// modified.
+func appendPath(p Path, k Value) Path {
+	return append(p[:len(p):len(p)], k)
+}
+
+func patchOp(op string, p Path, v Value) Value {
+	a := &orderedAttrType{values: make(map[string]Value, 3)}
+	a.set("op", stringValue(op))
+	a.set("path", stringValue(p.Pointer()))
+	if v != nil {
+		a.set("value", v)
+	}
+	return a
+}
+
+// ApplyPatch applies the JSON Patch patch, as defined in RFC 6902, to v and
+// returns the result. patch must be an array of operations, each an object
+// with an "op" attribute of "add", "remove", "replace", "move", "copy", or
+// "test". v is not modified. If any operation fails, an error is returned,
+// and no changes are made.
+func ApplyPatch(v, patch Value) (Value, error) {
+	dec, ok := Iterate(patch)
+	if !ok {
+		return nil, fmt.Errorf("json patch must be an array, got %s", typeName(patch))
+	}
+	for i := 0; ; i++ {
+		op, err := dec.Decode()
+		if err == io.EOF {
+			return v, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if v, err = applyPatchOp(v, op); err != nil {
+			return nil, fmt.Errorf("json patch operation %d: %w", i, err)
+		}
+	}
+}
+
+func applyPatchOp(v, op Value) (Value, error) {
+	if _, ok := op.(Attr); !ok {
+		return nil, fmt.Errorf("operation must be an object, got %s", typeName(op))
+	}
+	name, err := patchString(op, "op")
+	if err != nil {
+		return nil, err
+	}
+	ptr, err := patchString(op, "path")
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := ParsePointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	value, hasValue := GetStringAttr(op, "value")
+	switch name {
+	case "add", "replace", "test":
+		if !hasValue {
+			return nil, fmt.Errorf("%s operation has no value", name)
+		}
+	}
+
+	switch name {
+	case "add":
+		return patchAdd(v, tokens, value)
+
+	case "remove", "replace", "test":
+		p, e, err := patchLookup(v, ptr, tokens)
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "remove":
+			return p.Delete(v)
+		case "replace":
+			return p.Set(v, value)
+		default:
+			if !Equal(e, value) {
+				return nil, fmt.Errorf("test failed: value at %q is %s, not %s", ptr, keyString(e), keyString(value))
+			}
+			return v, nil
+		}
+
+	case "move", "copy":
+		from, err := patchString(op, "from")
+		if err != nil {
+			return nil, err
+		}
+		fromTokens, err := ParsePointer(from)
+		if err != nil {
+			return nil, err
+		}
+		fp, e, err := patchLookup(v, from, fromTokens)
+		if err != nil {
+			return nil, err
+		}
+		if name == "move" {
+			if len(fromTokens) < len(tokens) && isPointerPrefix(fromTokens, tokens) {
+				return nil, fmt.Errorf("cannot move %q into itself", from)
+			}
+			if v, err = fp.Delete(v); err != nil {
+				return nil, err
+			}
+		}
+		return patchAdd(v, tokens, e)
+
+	default:
+		return nil, fmt.Errorf("unknown operation %q", name)
+	}
+}
+
+// patchAdd adds e to v at the location identified by the pointer tokens.
+// The location's parent must exist. If the parent is an array, e is
+// inserted before the element at the index, which may be "-" or the
+// array's length to append. Otherwise, the attribute is added or replaced.
+func patchAdd(v Value, tokens []string, e Value) (Value, error) {
+	if len(tokens) == 0 {
+		return e, nil
+	}
+	pp, err := pointerPath(v, tokens[:len(tokens)-1])
+	if err != nil {
+		return nil, err
+	}
+	parent, ok := pp.Lookup(v)
+	if !ok {
+		return nil, fmt.Errorf("cannot add to %q: no value at %s", pp.Pointer(), pp)
+	}
+	last := tokens[len(tokens)-1]
+	switch parent := parent.(type) {
+	case Attr:
+		return appendPath(pp, stringValue(last)).Set(v, e)
+	case Index:
+		n := parent.Length()
+		i := n
+		if last != "-" {
+			if i, err = pointerIndex(last); err != nil {
+				return nil, fmt.Errorf("at %s: %w", pp, err)
+			}
+			if i > n {
+				return nil, fmt.Errorf("at %s: index %d is out of range", pp, i)
+			}
+		}
+		ix := make(indexType, 0, n+1)
+		for j := 0; j < i; j++ {
+			ix = append(ix, indexOrNull(parent, j))
+		}
+		ix = append(ix, e)
+		for j := i; j < n; j++ {
+			ix = append(ix, indexOrNull(parent, j))
+		}
+		return pp.Set(v, ix)
+	default:
+		return nil, fmt.Errorf("cannot add to %s at %s", typeName(parent), pp)
+	}
+}
+
+// patchLookup returns the path identified by a JSON Pointer in v and the
+// value at that path. An error is returned if there's no such value.
+func patchLookup(v Value, ptr string, tokens []string) (Path, Value, error) {
+	p, err := pointerPath(v, tokens)
+	if err != nil {
+		return nil, nil, err
+	}
+	e, ok := p.Lookup(v)
+	if !ok {
+		return nil, nil, fmt.Errorf("no value at %q", ptr)
+	}
+	return p, e, nil
+}
+
+// patchString returns the string attribute of a JSON Patch operation with
+// the given name.
+func patchString(op Value, name string) (string, error) {
+	v, ok := GetStringAttr(op, name)
+	if !ok {
+		return "", fmt.Errorf("operation has no %q attribute", name)
+	}
+	s, ok := AsString(v)
+	if !ok {
+		return "", fmt.Errorf("operation attribute %q must be a string, got %s", name, typeName(v))
+	}
+	return s, nil
+}
+
+func isPointerPrefix(prefix, tokens []string) bool {
+	for i := range prefix {
+		if prefix[i] != tokens[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ApplyMergePatch applies the JSON Merge Patch patch, as defined in RFC 7386,
+// to v and returns the result. If patch is an object, its attributes are
+// merged into v recursively: null attributes remove the corresponding
+// attributes of v, and others are added or replace them. If v isn't an
+// object, it's treated as an empty object. If patch isn't an object, it
+// replaces v entirely. v is not modified.
+func ApplyMergePatch(v, patch Value) (Value, error) {
+	pnames, ok := attrNames(patch)
+	if !ok {
+		if _, ok := patch.(Attr); ok {
+			return nil, fmt.Errorf("cannot apply merge patch with non-string keys")
+		}
+		return patch, nil
+	}
+	if _, ok := v.(Attr); !ok {
+		v = NullValue
+	}
+	out, err := copyAttr(v)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range pnames {
+		pv, _ := GetStringAttr(patch, name)
+		if IsNull(pv) {
+			out.DeleteAttr(stringValue(name))
+			continue
+		}
+		ov := out.values[name]
+		if ov == nil {
+			ov = NullValue
+		}
+		e, err := ApplyMergePatch(ov, pv)
+		if err != nil {
+			return nil, err
+		}
+		out.set(name, e)
+	}
+	return out, nil
+}
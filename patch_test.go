@@ -0,0 +1,160 @@
+package sift_test
+
+import (
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestDiff(t *testing.T) {
+	for _, tc := range []struct {
+		desc, l, r, want string
+	}{
+		{
+			desc: "equal",
+			l:    `{"a": [1, 2]}`,
+			r:    `{"a": [1, 2]}`,
+			want: `[]`,
+		}, {
+			desc: "replace_root",
+			l:    `1`,
+			r:    `"x"`,
+			want: `[{"op":"replace","path":"","value":"x"}]`,
+		}, {
+			desc: "object",
+			l:    `{"a": 1, "b": {"c": 2, "d": 3}, "e/f": 4}`,
+			r:    `{"a": 1, "b": {"c": 5, "g": 6}}`,
+			want: `[{"op":"remove","path":"/e~1f"},{"op":"remove","path":"/b/d"},{"op":"replace","path":"/b/c","value":5},{"op":"add","path":"/b/g","value":6}]`,
+		}, {
+			desc: "array_grow",
+			l:    `[1, 2]`,
+			r:    `[1, 3, 4]`,
+			want: `[{"op":"replace","path":"/1","value":3},{"op":"add","path":"/2","value":4}]`,
+		}, {
+			desc: "array_shrink",
+			l:    `[1, 2, 3, 4]`,
+			r:    `[0, 2]`,
+			want: `[{"op":"replace","path":"/0","value":0},{"op":"remove","path":"/3"},{"op":"remove","path":"/2"}]`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			l, r := decodeJSON(t, tc.l), decodeJSON(t, tc.r)
+			patch := sift.Diff(l, r)
+			if got := encodeJSON(t, patch); got != tc.want {
+				t.Errorf("Diff: got %s; want %s", got, tc.want)
+			}
+			got, err := sift.ApplyPatch(l, patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sift.Equal(got, r) {
+				t.Errorf("ApplyPatch: got %s; want %s", encodeJSON(t, got), tc.r)
+			}
+		})
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	for _, tc := range []struct {
+		desc, doc, patch, want, wantErr string
+	}{
+		{
+			desc:  "add",
+			doc:   `{"a": [1, 2]}`,
+			patch: `[{"op": "add", "path": "/b", "value": 3}, {"op": "add", "path": "/a/1", "value": 4}, {"op": "add", "path": "/a/-", "value": 5}]`,
+			want:  `{"a": [1, 4, 2, 5], "b": 3}`,
+		}, {
+			desc:  "remove_replace",
+			doc:   `{"a": [1, 2, 3], "b": 4}`,
+			patch: `[{"op": "remove", "path": "/a/0"}, {"op": "replace", "path": "/b", "value": [5]}]`,
+			want:  `{"a": [2, 3], "b": [5]}`,
+		}, {
+			desc:  "move_copy",
+			doc:   `{"a": {"b": 1}, "c": [2]}`,
+			patch: `[{"op": "move", "from": "/a/b", "path": "/c/0"}, {"op": "copy", "from": "/c", "path": "/d"}]`,
+			want:  `{"a": {}, "c": [1, 2], "d": [1, 2]}`,
+		}, {
+			desc:  "test",
+			doc:   `{"a": [1, {"b": null}]}`,
+			patch: `[{"op": "test", "path": "/a", "value": [1, {"b": null}]}]`,
+			want:  `{"a": [1, {"b": null}]}`,
+		}, {
+			desc:    "test_failed",
+			doc:     `{"a": 1}`,
+			patch:   `[{"op": "add", "path": "/b", "value": 2}, {"op": "test", "path": "/a", "value": 2}]`,
+			wantErr: `json patch operation 1: test failed: value at "/a" is 1, not 2`,
+		}, {
+			desc:    "add_missing_parent",
+			doc:     `{"a": 1}`,
+			patch:   `[{"op": "add", "path": "/b/c", "value": 2}]`,
+			wantErr: `json patch operation 0: cannot add to "/b": no value at .b`,
+		}, {
+			desc:    "add_out_of_range",
+			doc:     `[1]`,
+			patch:   `[{"op": "add", "path": "/2", "value": 2}]`,
+			wantErr: `json patch operation 0: at .: index 2 is out of range`,
+		}, {
+			desc:    "remove_missing",
+			doc:     `{"a": 1}`,
+			patch:   `[{"op": "remove", "path": "/b"}]`,
+			wantErr: `json patch operation 0: no value at "/b"`,
+		}, {
+			desc:    "move_into_self",
+			doc:     `{"a": {"b": 1}}`,
+			patch:   `[{"op": "move", "from": "/a", "path": "/a/c"}]`,
+			wantErr: `json patch operation 0: cannot move "/a" into itself`,
+		}, {
+			desc:    "unknown",
+			doc:     `{}`,
+			patch:   `[{"op": "frob", "path": ""}]`,
+			wantErr: `json patch operation 0: unknown operation "frob"`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			doc := decodeJSON(t, tc.doc)
+			got, err := sift.ApplyPatch(doc, decodeJSON(t, tc.patch))
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("got error %v; want %q", err, tc.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if want := decodeJSON(t, tc.want); !sift.Equal(got, want) {
+				t.Errorf("got %s; want %s", encodeJSON(t, got), tc.want)
+			}
+			if !sift.Equal(doc, decodeJSON(t, tc.doc)) {
+				t.Errorf("input was modified: %s", encodeJSON(t, doc))
+			}
+		})
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	// Examples from RFC 7386, appendix A.
+	for _, tc := range []struct {
+		doc, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	} {
+		got, err := sift.ApplyMergePatch(decodeJSON(t, tc.doc), decodeJSON(t, tc.patch))
+		if err != nil {
+			t.Errorf("%s + %s: %v", tc.doc, tc.patch, err)
+		} else if !sift.Equal(got, decodeJSON(t, tc.want)) {
+			t.Errorf("%s + %s: got %s; want %s", tc.doc, tc.patch, encodeJSON(t, got), tc.want)
+		}
+	}
+}
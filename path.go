@@ -0,0 +1,206 @@
+package sift
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Path identifies a value nested within another value. Each element is
+// either a string, naming an attribute of an object, or a non-negative
+// integer number, indexing an array. An empty path identifies the outer
+// value itself.
+type Path []Value
+
+// String returns the path in jq syntax, like `.a[0]["b c"]`. The empty path
+// is written as ".", and paths that start with an index or quoted key
+// start with ".", like `.[0]`.
+func (p Path) String() string {
+	if len(p) == 0 {
+		return "."
+	}
+	b := &strings.Builder{}
+	if name, ok := AsString(p[0]); !ok || fieldSuffix(name)[0] == '[' {
+		b.WriteByte('.')
+	}
+	for _, k := range p {
+		if name, ok := AsString(k); ok {
+			b.WriteString(fieldSuffix(name))
+		} else if f, ok := AsFloat64(k); ok {
+			b.WriteByte('[')
+			b.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+			b.WriteByte(']')
+		} else {
+			b.WriteByte('[')
+			b.WriteString(keyString(k))
+			b.WriteByte(']')
+		}
+	}
+	return b.String()
+}
+
+// Lookup returns the value at path p within v and true. If there's no such
+// value, nil and false are returned.
+func (p Path) Lookup(v Value) (Value, bool) {
+	for _, k := range p {
+		var ok bool
+		if name, isString := AsString(k); isString {
+			v, ok = GetStringAttr(v, name)
+		} else {
+			v, ok = GetIndex(v, k)
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// Set returns a copy of v with the value at path p set to e. v is not
+// modified: objects and arrays along the path are copied, and the rest of
+// v is shared with the result. Where the path doesn't exist, it's created:
+// null or missing values are replaced with objects for string keys and
+// arrays for indices, and arrays are extended with nulls as needed.
+func (p Path) Set(v, e Value) (Value, error) {
+	return p.set(v, e, 0)
+}
+
+func (p Path) set(v, e Value, i int) (Value, error) {
+	if i == len(p) {
+		return e, nil
+	}
+	k := p[i]
+	if v == nil {
+		v = NullValue
+	}
+	if name, ok := AsString(k); ok {
+		if !IsNull(v) {
+			if _, ok := v.(Attr); !ok {
+				return nil, p.errorf(i, "cannot index %s with string %q", typeName(v), name)
+			}
+		}
+		child, _ := GetStringAttr(v, name)
+		child, err := p.set(child, e, i+1)
+		if err != nil {
+			return nil, err
+		}
+		a, err := copyAttr(v)
+		if err != nil {
+			return nil, p.errorf(i, "%v", err)
+		}
+		a.set(name, child)
+		return a, nil
+	}
+
+	n, err := p.index(i)
+	if err != nil {
+		return nil, err
+	}
+	var ix indexType
+	if src, ok := v.(Index); ok {
+		ix = make(indexType, src.Length())
+		for j := range ix {
+			ix[j], _ = src.Index(j)
+		}
+	} else if !IsNull(v) {
+		return nil, p.errorf(i, "cannot index %s with number", typeName(v))
+	}
+	var child Value
+	if n < len(ix) {
+		child = ix[n]
+	}
+	child, err = p.set(child, e, i+1)
+	if err != nil {
+		return nil, err
+	}
+	return ix.SetIndex(n, child)
+}
+
+// Delete returns a copy of v without the value at path p. Deleted array
+// elements are removed, so later elements move down. As with Set, v is not
+// modified. If there's no value at p, v is returned. Deleting the empty
+// path returns null.
+func (p Path) Delete(v Value) (Value, error) {
+	if len(p) == 0 {
+		return NullValue, nil
+	}
+	return p.delete(v, 0)
+}
+
+func (p Path) delete(v Value, i int) (Value, error) {
+	if IsNull(v) {
+		return v, nil
+	}
+	k := p[i]
+	last := i == len(p)-1
+	if name, ok := AsString(k); ok {
+		if _, ok := v.(Attr); !ok {
+			return nil, p.errorf(i, "cannot delete string key %q from %s", name, typeName(v))
+		}
+		child, ok := GetStringAttr(v, name)
+		if !ok {
+			return v, nil
+		}
+		a, err := copyAttr(v)
+		if err != nil {
+			return nil, p.errorf(i, "%v", err)
+		}
+		if last {
+			return a.DeleteAttr(k)
+		}
+		child, err = p.delete(child, i+1)
+		if err != nil {
+			return nil, err
+		}
+		a.set(name, child)
+		return a, nil
+	}
+
+	n, err := p.index(i)
+	if err != nil {
+		return nil, err
+	}
+	src, ok := v.(Index)
+	if !ok {
+		return nil, p.errorf(i, "cannot delete index from %s", typeName(v))
+	}
+	if n >= src.Length() {
+		return v, nil
+	}
+	ix := make(indexType, 0, src.Length())
+	for j := 0; j < src.Length(); j++ {
+		e, _ := src.Index(j)
+		ix = append(ix, e)
+	}
+	if last {
+		return append(ix[:n], ix[n+1:]...), nil
+	}
+	if ix[n] == nil {
+		return v, nil
+	}
+	if ix[n], err = p.delete(ix[n], i+1); err != nil {
+		return nil, err
+	}
+	return ix, nil
+}
+
+// index returns the array index at p[i].
+func (p Path) index(i int) (int, error) {
+	f, ok := AsFloat64(p[i])
+	if !ok {
+		return 0, p.errorf(i, "path element %s is not a string or number", keyString(p[i]))
+	}
+	n := int(f)
+	if float64(n) != f {
+		return 0, p.errorf(i, "array index %v is not an integer", f)
+	}
+	if n < 0 {
+		return 0, p.errorf(i, "cannot set negative array index %d", n)
+	}
+	return n, nil
+}
+
+// errorf returns an error at the path's element i.
+func (p Path) errorf(i int, format string, args ...interface{}) error {
+	return fmt.Errorf("at %s: %s", p[:i], fmt.Sprintf(format, args...))
+}
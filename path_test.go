@@ -0,0 +1,142 @@
+package sift_test
+
+import (
+	"strings"
+	"testing"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+)
+
+func path(elems ...interface{}) sift.Path {
+	p := make(sift.Path, len(elems))
+	for i, e := range elems {
+		p[i] = sift.Must(sift.ToValue(e))
+	}
+	return p
+}
+
+func TestPath(t *testing.T) {
+	const input = `{"a": {"b": [1, {"c": 2}]}, "d": null}`
+	for _, tc := range []struct {
+		desc       string
+		path       sift.Path
+		str        string
+		lookup     string
+		set        string
+		setErr     string
+		delete     string
+		deleteErr  string
+		notPresent bool
+	}{
+		{
+			desc:   "root",
+			path:   path(),
+			str:    ".",
+			lookup: input,
+			set:    `"x"`,
+			delete: `null`,
+		}, {
+			desc:   "key",
+			path:   path("a", "b", 1, "c"),
+			str:    ".a.b[1].c",
+			lookup: `2`,
+			set:    `{"a":{"b":[1,{"c":"x"}]},"d":null}`,
+			delete: `{"a":{"b":[1,{}]},"d":null}`,
+		}, {
+			desc:   "index",
+			path:   path("a", "b", 0),
+			str:    ".a.b[0]",
+			lookup: `1`,
+			set:    `{"a":{"b":["x",{"c":2}]},"d":null}`,
+			delete: `{"a":{"b":[{"c":2}]},"d":null}`,
+		}, {
+			desc:       "create",
+			path:       path("d", "e f", 2),
+			str:        `.d["e f"][2]`,
+			notPresent: true,
+			set:        `{"a":{"b":[1,{"c":2}]},"d":{"e f":[null,null,"x"]}}`,
+			delete:     input,
+		}, {
+			desc:       "extend",
+			path:       path("a", "b", 3),
+			notPresent: true,
+			set:        `{"a":{"b":[1,{"c":2},null,"x"]},"d":null}`,
+			delete:     input,
+		}, {
+			desc:       "type_mismatch",
+			path:       path("a", "b", "c"),
+			notPresent: true,
+			setErr:     `at .a.b: cannot index array with string "c"`,
+			deleteErr:  `at .a.b: cannot delete string key "c" from array`,
+		}, {
+			desc:       "negative",
+			path:       path("a", "b", -1),
+			notPresent: true,
+			setErr:     "at .a.b: cannot set negative array index -1",
+			deleteErr:  "at .a.b: cannot set negative array index -1",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			v := decodeJSON(t, input)
+			if tc.str != "" {
+				if got := tc.path.String(); got != tc.str {
+					t.Errorf("String: got %s; want %s", got, tc.str)
+				}
+			}
+			got, ok := tc.path.Lookup(v)
+			if tc.notPresent {
+				if ok {
+					t.Errorf("Lookup: got %v; want false", got)
+				}
+			} else if !ok || !sift.Equal(got, decodeJSON(t, tc.lookup)) {
+				t.Errorf("Lookup: got %v, %v; want %s, true", got, ok, tc.lookup)
+			}
+
+			set, err := tc.path.Set(v, sift.Must(sift.ToValue("x")))
+			if tc.setErr != "" {
+				if err == nil || err.Error() != tc.setErr {
+					t.Errorf("Set: got error %v; want %q", err, tc.setErr)
+				}
+			} else if err != nil {
+				t.Errorf("Set: %v", err)
+			} else if got := encodeJSON(t, set); got != tc.set {
+				t.Errorf("Set: got %s; want %s", got, tc.set)
+			}
+
+			del, err := tc.path.Delete(v)
+			if tc.deleteErr != "" {
+				if err == nil || err.Error() != tc.deleteErr {
+					t.Errorf("Delete: got error %v; want %q", err, tc.deleteErr)
+				}
+			} else if err != nil {
+				t.Errorf("Delete: %v", err)
+			} else if got, want := encodeJSON(t, del), encodeJSON(t, decodeJSON(t, tc.delete)); got != want {
+				t.Errorf("Delete: got %s; want %s", got, want)
+			}
+
+			// The input must not be modified.
+			if !sift.Equal(v, decodeJSON(t, input)) {
+				t.Errorf("input was modified: %s", encodeJSON(t, v))
+			}
+		})
+	}
+}
+
+func decodeJSON(t *testing.T, s string) sift.Value {
+	t.Helper()
+	v, err := json.NewDecoder(strings.NewReader(s)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func encodeJSON(t *testing.T, v sift.Value) string {
+	t.Helper()
+	w := &strings.Builder{}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(w.String())
+}
@@ -0,0 +1,156 @@
+package sift
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// InterfaceVersion is the version of the interfaces through which sift and
+// plugins like out-of-tree decoders, encoders, and jq builtins interact:
+// Value and the interfaces describing values, Decoder, Encoder, and
+// Filter. It's incremented when those interfaces change in ways a plugin
+// written for an earlier version may not expect.
+//
+// MinInterfaceVersion is the earliest version sift still supports. Plugins
+// written for versions from MinInterfaceVersion to InterfaceVersion may be
+// used.
+const (
+	InterfaceVersion    = 1
+	MinInterfaceVersion = 1
+)
+
+// A Capability names an optional part of the value model that a plugin may
+// depend on, like an interface values may implement. Plugins list the
+// capabilities they need in PluginRequirements, so they can fail with
+// a clear error when used with a version of sift that doesn't provide them.
+type Capability string
+
+// Capabilities a plugin may require. Not every version of sift provides all
+// of them; see Capabilities.
+const (
+	CapNull    Capability = "null"
+	CapBool    Capability = "bool"
+	CapFloat64 Capability = "float64"
+	CapString  Capability = "string"
+	CapAttr    Capability = "attr"
+	CapIndex   Capability = "index"
+	CapBytes   Capability = "bytes"
+	CapTime    Capability = "time"
+	CapInt64   Capability = "int64"
+
+	CapBigNumber Capability = "bignumber"
+)
+
+// capabilities is the set of capabilities this version of sift provides.
+var capabilities = map[Capability]bool{
+	CapNull:    true,
+	CapBool:    true,
+	CapFloat64: true,
+	CapString:  true,
+	CapAttr:    true,
+	CapIndex:   true,
+
+	CapBytes:     true,
+	CapTime:      true,
+	CapBigNumber: true,
+}
+
+// Capabilities returns the capabilities this version of sift provides,
+// sorted.
+func Capabilities() []Capability {
+	caps := make([]Capability, 0, len(capabilities))
+	for c := range capabilities {
+		caps = append(caps, c)
+	}
+	sort.Slice(caps, func(i, j int) bool { return caps[i] < caps[j] })
+	return caps
+}
+
+// PluginRequirements describes what a plugin needs from sift.
+type PluginRequirements struct {
+	// Name identifies the plugin in errors.
+	Name string
+
+	// MinVersion and MaxVersion are the range of interface versions the
+	// plugin supports. If MaxVersion is 0, it's the same as MinVersion.
+	MinVersion, MaxVersion int
+
+	// Requires lists the capabilities the plugin depends on.
+	Requires []Capability
+}
+
+// ErrIncompatiblePlugin is wrapped by errors returned by Negotiate.
+var ErrIncompatiblePlugin = errors.New("incompatible plugin")
+
+// A PluginError describes why a plugin can't be used with this version of
+// sift. It wraps ErrIncompatiblePlugin.
+type PluginError struct {
+	// Plugin is the name of the plugin.
+	Plugin string
+
+	// MinVersion and MaxVersion are the range of interface versions the
+	// plugin supports. If the range doesn't overlap MinInterfaceVersion to
+	// InterfaceVersion, the plugin can't be used.
+	MinVersion, MaxVersion int
+
+	// Missing lists the capabilities the plugin requires that this version
+	// of sift doesn't provide.
+	Missing []Capability
+}
+
+func (e *PluginError) Error() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "plugin %s: %v", e.Plugin, ErrIncompatiblePlugin)
+	if e.MaxVersion < MinInterfaceVersion || e.MinVersion > InterfaceVersion || e.MinVersion > e.MaxVersion {
+		fmt.Fprintf(b, ": requires interface version %s; sift provides version %s", versionRange(e.MinVersion, e.MaxVersion), versionRange(MinInterfaceVersion, InterfaceVersion))
+	}
+	if len(e.Missing) > 0 {
+		missing := make([]string, len(e.Missing))
+		for i, c := range e.Missing {
+			missing[i] = string(c)
+		}
+		fmt.Fprintf(b, ": requires unsupported capabilities %s", strings.Join(missing, ", "))
+	}
+	return b.String()
+}
+
+func (e *PluginError) Unwrap() error {
+	return ErrIncompatiblePlugin
+}
+
+func versionRange(min, max int) string {
+	if min == max {
+		return fmt.Sprint(min)
+	}
+	return fmt.Sprintf("%d-%d", min, max)
+}
+
+// Negotiate checks that this version of sift provides the interface version
+// and capabilities a plugin requires. It returns the interface version the
+// plugin should use, the highest version both support. If the plugin can't
+// be used, Negotiate returns a *PluginError.
+//
+// Plugins should call Negotiate (or be registered with a function that
+// calls it, like jq.CompileOptions.Plugins) before producing or consuming
+// values, rather than failing later in ways that are hard to diagnose.
+func Negotiate(req PluginRequirements) (int, error) {
+	max := req.MaxVersion
+	if max == 0 {
+		max = req.MinVersion
+	}
+	var missing []Capability
+	for _, c := range req.Requires {
+		if !capabilities[c] {
+			missing = append(missing, c)
+		}
+	}
+	if max < MinInterfaceVersion || req.MinVersion > InterfaceVersion || req.MinVersion > max || len(missing) > 0 {
+		return 0, &PluginError{Plugin: req.Name, MinVersion: req.MinVersion, MaxVersion: max, Missing: missing}
+	}
+	if max > InterfaceVersion {
+		max = InterfaceVersion
+	}
+	return max, nil
+}
@@ -0,0 +1,61 @@
+package sift_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestNegotiate(t *testing.T) {
+	for _, tc := range []struct {
+		desc        string
+		req         sift.PluginRequirements
+		wantVersion int
+		wantErr     string
+	}{
+		{
+			desc:        "current",
+			req:         sift.PluginRequirements{Name: "p", MinVersion: sift.InterfaceVersion},
+			wantVersion: sift.InterfaceVersion,
+		}, {
+			desc:        "newer_plugin",
+			req:         sift.PluginRequirements{Name: "p", MinVersion: sift.MinInterfaceVersion, MaxVersion: sift.InterfaceVersion + 2},
+			wantVersion: sift.InterfaceVersion,
+		}, {
+			desc:        "capabilities",
+			req:         sift.PluginRequirements{Name: "p", MinVersion: 1, Requires: []sift.Capability{sift.CapString, sift.CapAttr}},
+			wantVersion: sift.InterfaceVersion,
+		}, {
+			desc:    "too_new",
+			req:     sift.PluginRequirements{Name: "p", MinVersion: sift.InterfaceVersion + 1},
+			wantErr: "plugin p: incompatible plugin: requires interface version 2",
+		}, {
+			desc:    "too_old",
+			req:     sift.PluginRequirements{Name: "p", MinVersion: sift.MinInterfaceVersion - 1},
+			wantErr: "requires interface version 0",
+		}, {
+			desc:    "missing",
+			req:     sift.PluginRequirements{Name: "p", MinVersion: 1, Requires: []sift.Capability{sift.CapString, "bignum", "uuid"}},
+			wantErr: "plugin p: incompatible plugin: requires unsupported capabilities bignum, uuid",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			version, err := sift.Negotiate(tc.req)
+			if tc.wantErr != "" {
+				var perr *sift.PluginError
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) || !errors.As(err, &perr) || !errors.Is(err, sift.ErrIncompatiblePlugin) {
+					t.Fatalf("got error %v; want *PluginError containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != tc.wantVersion {
+				t.Errorf("got version %d; want %d", version, tc.wantVersion)
+			}
+		})
+	}
+}
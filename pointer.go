@@ -0,0 +1,130 @@
+package sift
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParsePointer parses a JSON Pointer, as defined in RFC 6901, and returns
+// its reference tokens with "~1" and "~0" unescaped to "/" and "~". The
+// empty pointer, which identifies a whole document, has no tokens.
+// Otherwise, a pointer must start with "/".
+func ParsePointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return nil, nil
+	}
+	if ptr[0] != '/' {
+		return nil, fmt.Errorf("json pointer %q does not start with /", ptr)
+	}
+	tokens := strings.Split(ptr[1:], "/")
+	for i, tok := range tokens {
+		if !strings.Contains(tok, "~") {
+			continue
+		}
+		b := &strings.Builder{}
+		for j := 0; j < len(tok); j++ {
+			if tok[j] != '~' {
+				b.WriteByte(tok[j])
+				continue
+			}
+			if j+1 < len(tok) && tok[j+1] == '0' {
+				b.WriteByte('~')
+			} else if j+1 < len(tok) && tok[j+1] == '1' {
+				b.WriteByte('/')
+			} else {
+				return nil, fmt.Errorf("json pointer %q has invalid escape sequence", ptr)
+			}
+			j++
+		}
+		tokens[i] = b.String()
+	}
+	return tokens, nil
+}
+
+// ResolvePointer returns the value within v identified by the JSON Pointer
+// ptr. Tokens are used as attribute names in objects and as indices in
+// arrays. An error is returned if ptr is malformed or doesn't identify
+// a value in v.
+func ResolvePointer(v Value, ptr string) (Value, error) {
+	tokens, err := ParsePointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	p, err := pointerPath(v, tokens)
+	if err != nil {
+		return nil, fmt.Errorf("json pointer %q: %w", ptr, err)
+	}
+	e, ok := p.Lookup(v)
+	if !ok {
+		return nil, fmt.Errorf("json pointer %q: no value at %s", ptr, p)
+	}
+	return e, nil
+}
+
+// Pointer returns p as a JSON Pointer. Strings and numbers are both written
+// as reference tokens, so the pointer may not identify the same value in
+// a document with objects where p has array indices.
+func (p Path) Pointer() string {
+	b := &strings.Builder{}
+	for _, k := range p {
+		b.WriteByte('/')
+		if name, ok := AsString(k); ok {
+			b.WriteString(pointerEscaper.Replace(name))
+		} else if f, ok := AsFloat64(k); ok {
+			b.WriteString(strconv.FormatFloat(f, 'f', -1, 64))
+		} else {
+			b.WriteString(keyString(k))
+		}
+	}
+	return b.String()
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// pointerPath converts the reference tokens of a JSON Pointer to a Path
+// within v. Tokens are converted to array indices where the value they're
+// applied to is an array. Tokens applied to objects, and tokens past the
+// end of v, are kept as strings. The token "-", which refers to the
+// element after the end of an array, is converted to the array's length.
+func pointerPath(v Value, tokens []string) (Path, error) {
+	p := make(Path, len(tokens))
+	for i, tok := range tokens {
+		ix, ok := v.(Index)
+		if !ok {
+			p[i] = stringValue(tok)
+			v, _ = GetStringAttr(v, tok)
+			continue
+		}
+		var n int
+		if tok == "-" {
+			n = ix.Length()
+		} else {
+			var err error
+			n, err = pointerIndex(tok)
+			if err != nil {
+				return nil, fmt.Errorf("at %s: %w", p[:i], err)
+			}
+		}
+		p[i] = floatValue(float64(n))
+		v, _ = ix.Index(n)
+	}
+	return p, nil
+}
+
+// pointerIndex parses a reference token used as an array index. RFC 6901
+// only allows decimal digits without leading zeros.
+func pointerIndex(tok string) (int, error) {
+	valid := tok != "" && (tok == "0" || tok[0] != '0')
+	for i := 0; valid && i < len(tok); i++ {
+		valid = '0' <= tok[i] && tok[i] <= '9'
+	}
+	if !valid {
+		return 0, fmt.Errorf("invalid array index %q", tok)
+	}
+	n, err := strconv.Atoi(tok)
+	if err != nil {
+		return 0, fmt.Errorf("invalid array index %q", tok)
+	}
+	return n, nil
+}
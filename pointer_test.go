@@ -0,0 +1,56 @@
+package sift_test
+
+import (
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestPointer(t *testing.T) {
+	const input = `{"a": [1, {"b/c": 2, "d~e": 3, "": 4}], "01": 5}`
+	for _, tc := range []struct {
+		desc, ptr, want, wantErr string
+	}{
+		{desc: "root", ptr: "", want: input},
+		{desc: "attr", ptr: "/01", want: "5"},
+		{desc: "index", ptr: "/a/0", want: "1"},
+		{desc: "escape", ptr: "/a/1/b~1c", want: "2"},
+		{desc: "escape_tilde", ptr: "/a/1/d~0e", want: "3"},
+		{desc: "empty_token", ptr: "/a/1/", want: "4"},
+		{desc: "no_slash", ptr: "a", wantErr: `json pointer "a" does not start with /`},
+		{desc: "bad_escape", ptr: "/a~2", wantErr: `json pointer "/a~2" has invalid escape sequence`},
+		{desc: "leading_zero", ptr: "/a/01", wantErr: `json pointer "/a/01": at .a: invalid array index "01"`},
+		{desc: "end", ptr: "/a/-", wantErr: `json pointer "/a/-": no value at .a[2]`},
+		{desc: "missing", ptr: "/a/1/x/y", wantErr: `json pointer "/a/1/x/y": no value at .a[1].x.y`},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := sift.ResolvePointer(decodeJSON(t, input), tc.ptr)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("got error %v; want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := decodeJSON(t, tc.want); !sift.Equal(got, want) {
+				t.Errorf("got %s; want %s", encodeJSON(t, got), tc.want)
+			}
+		})
+	}
+}
+
+func TestPathPointer(t *testing.T) {
+	for _, tc := range []struct {
+		path sift.Path
+		want string
+	}{
+		{path(), ""},
+		{path("a", 0, "b/c~"), "/a/0/b~1c~0"},
+	} {
+		if got := tc.path.Pointer(); got != tc.want {
+			t.Errorf("%s: got %q; want %q", tc.path, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package sift
+
+// Reduce returns a Filter that applies f to a value and folds f's outputs
+// into a single result, like jq's reduce. The result starts as init, and
+// step is called with the result so far and each output of f in order to
+// produce the next result. The filter produces the final result, which is
+// init if f produces no values.
+//
+// init is shared by every application of the filter, so step must not
+// modify its accumulator argument in place.
+func Reduce(f Filter, init Value, step func(acc, v Value) (Value, error)) Filter {
+	return func(v Value) ([]Value, error) {
+		acc := init
+		err := Emitting(f)(v, func(fv Value) error {
+			var err error
+			acc, err = step(acc, fv)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []Value{acc}, nil
+	}
+}
+
+// ReduceStream returns middleware that folds all the values written to it
+// into a single result, as Reduce does for the outputs of a filter. Instead
+// of writing each value, the returned Encoder writes the result when it's
+// flushed, then starts again from init. Sift flushes its encoder after the
+// last value, so the result covers the whole stream.
+func ReduceStream(init Value, step func(acc, v Value) (Value, error)) EncoderMiddleware {
+	return func(enc Encoder) Encoder {
+		return &reduceEncoder{next: enc, init: init, acc: init, step: step}
+	}
+}
+
+type reduceEncoder struct {
+	next      Encoder
+	init, acc Value
+	step      func(acc, v Value) (Value, error)
+}
+
+var _ Flusher = (*reduceEncoder)(nil)
+
+func (e *reduceEncoder) ByteCount() int64 {
+	return byteCount(e.next)
+}
+
+func (e *reduceEncoder) Encode(v Value) error {
+	acc, err := e.step(e.acc, v)
+	if err != nil {
+		return err
+	}
+	e.acc = acc
+	return nil
+}
+
+func (e *reduceEncoder) Flush() error {
+	acc := e.acc
+	e.acc = e.init
+	if err := e.next.Encode(acc); err != nil {
+		return err
+	}
+	if f, ok := e.next.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
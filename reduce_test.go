@@ -0,0 +1,76 @@
+package sift_test
+
+import (
+	"errors"
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+// sumValues adds the number v to the number acc.
+func sumValues(acc, v sift.Value) (sift.Value, error) {
+	a, _ := sift.AsFloat64(acc)
+	n, ok := sift.AsFloat64(v)
+	if !ok {
+		return nil, errors.New("not a number")
+	}
+	return sift.ToValue(a + n)
+}
+
+func TestReduce(t *testing.T) {
+	elems := func(v sift.Value) ([]sift.Value, error) {
+		ix, err := sift.Collect(v)
+		if err != nil {
+			return nil, err
+		}
+		vs := make([]sift.Value, ix.Length())
+		for i := range vs {
+			vs[i], _ = ix.Index(i)
+		}
+		return vs, nil
+	}
+	f := sift.Reduce(elems, sift.Must(sift.ToValue(0)), sumValues)
+
+	for _, tc := range []struct {
+		desc    string
+		input   interface{}
+		want    float64
+		wantErr bool
+	}{
+		{desc: "sum", input: []interface{}{1, 2, 3}, want: 6},
+		{desc: "empty", input: []interface{}{}, want: 0},
+		{desc: "step_error", input: []interface{}{1, "a"}, wantErr: true},
+		{desc: "filter_error", input: "a", wantErr: true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			vs, err := f(sift.Must(sift.ToValue(tc.input)))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("got %v; want error", vs)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if len(vs) != 1 {
+				t.Fatalf("got %d values; want 1", len(vs))
+			}
+			if got, _ := sift.AsFloat64(vs[0]); got != tc.want {
+				t.Errorf("got %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReduceStream(t *testing.T) {
+	dec, _ := sift.Iterate(sift.Must(sift.ToValue([]interface{}{1, 2, 3, 4})))
+	rec := &recordEncoder{}
+	enc := sift.WrapEncoder(rec, sift.ReduceStream(sift.Must(sift.ToValue(0)), sumValues))
+	if err := sift.Sift(dec, sift.Map(func(v sift.Value) sift.Value { return v }), enc); err != nil {
+		t.Fatal(err)
+	}
+	want := sift.Must(sift.ToValue([]interface{}{10}))
+	if got := sift.Must(sift.ToValue(rec.values)); !sift.Equal(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,370 @@
+package sift
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// toValueReflect converts values that ToValue doesn't handle directly,
+// based on their kind.
+func toValueReflect(s *toValueState, rv reflect.Value) (Value, error) {
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return NullValue, nil
+		}
+		if rv.Kind() == reflect.Ptr {
+			if err := s.enter(rv); err != nil {
+				return nil, err
+			}
+			defer s.leave(rv)
+		}
+		return reflectElem(s, rv.Elem())
+	case reflect.Bool:
+		return boolValue(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return ToValue(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return ToValue(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return floatValue(rv.Float()), nil
+	case reflect.String:
+		return stringValue(rv.String()), nil
+	case reflect.Struct:
+		return structToValue(s, rv)
+	case reflect.Map:
+		if err := s.enter(rv); err != nil {
+			return nil, err
+		}
+		defer s.leave(rv)
+		return mapToValue(s, rv)
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return bytesType(rv.Bytes()), nil
+		}
+		if err := s.enter(rv); err != nil {
+			return nil, err
+		}
+		defer s.leave(rv)
+		fallthrough
+	case reflect.Array:
+		ix := make(indexType, rv.Len())
+		for i := range ix {
+			e, err := reflectElem(s, rv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			ix[i] = e
+		}
+		return ix, nil
+	case reflect.Invalid:
+		return NullValue, nil
+	default:
+		return nil, fmt.Errorf("cannot represent as value: %#v", rv)
+	}
+}
+
+// reflectElem converts a value reached through reflection, like a struct
+// field. Values that may be converted to interface{} go through ToValue,
+// so types with special handling, like time.Time, are recognized.
+func reflectElem(s *toValueState, rv reflect.Value) (Value, error) {
+	if rv.CanInterface() {
+		return toValue(s, rv.Interface())
+	}
+	return toValueReflect(s, rv)
+}
+
+// structToValue converts a struct to an object. The object's keys are in
+// the order the fields are declared.
+func structToValue(s *toValueState, rv reflect.Value) (Value, error) {
+	fields := typeFields(rv.Type())
+	a := &orderedAttrType{values: make(map[string]Value, len(fields))}
+	for _, f := range fields {
+		fv, ok := fieldByIndex(rv, f.index)
+		if !ok || f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		v, err := reflectElem(s, fv)
+		if _, ok := err.(*cycleError); ok {
+			return nil, err
+		} else if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.name, err)
+		}
+		a.set(f.name, v)
+	}
+	return a, nil
+}
+
+// mapToValue converts a map to an object. Keys are converted to strings
+// with mapKey. A map with struct{} values, which Go programs use as a set,
+// is converted to a Set of its keys instead.
+func mapToValue(s *toValueState, rv reflect.Value) (Value, error) {
+	if et := rv.Type().Elem(); et.Kind() == reflect.Struct && et.NumField() == 0 {
+		vs := make([]Value, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			v, err := reflectElem(s, iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			vs = append(vs, v)
+		}
+		return NewSet(vs...), nil
+	}
+	a := make(attrType, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		name, err := mapKey(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := a[name]; ok {
+			return nil, fmt.Errorf("cannot represent as value: map has more than one key %q", name)
+		}
+		v, err := reflectElem(s, iter.Value())
+		if _, ok := err.(*cycleError); ok {
+			return nil, err
+		} else if err != nil {
+			return nil, fmt.Errorf("key %q: %w", name, err)
+		}
+		a[name] = v
+	}
+	return a, nil
+}
+
+// startDetectingCyclesAfter is the depth of nested pointers, maps, and
+// slices after which ToValue starts checking for cycles. As in
+// encoding/json, shallow values aren't checked, since checking costs
+// a map lookup for each level.
+const startDetectingCyclesAfter = 1000
+
+// toValueState holds the pointers, maps, and slices that ToValue is in the
+// middle of converting, so it can report an error for a cyclic value
+// instead of recursing until the stack overflows.
+type toValueState struct {
+	depth int
+	seen  map[interface{}]bool
+}
+
+// enter records that ToValue is converting rv, a non-nil pointer, map, or
+// slice. It returns an error if rv is already being converted. If enter
+// returns nil, leave must be called after rv is converted.
+func (s *toValueState) enter(rv reflect.Value) error {
+	s.depth++
+	if s.depth <= startDetectingCyclesAfter {
+		return nil
+	}
+	if s.seen == nil {
+		s.seen = make(map[interface{}]bool)
+	}
+	key := cycleKey(rv)
+	if s.seen[key] {
+		s.depth--
+		return &cycleError{typ: rv.Type()}
+	}
+	s.seen[key] = true
+	return nil
+}
+
+func (s *toValueState) leave(rv reflect.Value) {
+	if s.depth > startDetectingCyclesAfter {
+		delete(s.seen, cycleKey(rv))
+	}
+	s.depth--
+}
+
+// cycleKey identifies the memory rv refers to. Slices are identified by
+// their length as well as their pointer, since different slices of the
+// same array may be nested without forming a cycle.
+func cycleKey(rv reflect.Value) interface{} {
+	if rv.Kind() == reflect.Slice {
+		return struct {
+			ptr uintptr
+			len int
+		}{rv.Pointer(), rv.Len()}
+	}
+	return rv.Pointer()
+}
+
+// A cycleError is returned by ToValue for a value that refers to itself.
+// It's not wrapped with the path to the cycle, which would be as long as
+// the cycle detection depth.
+type cycleError struct {
+	typ reflect.Type
+}
+
+func (e *cycleError) Error() string {
+	return fmt.Sprintf("cannot represent as value: encountered a cycle via %v", e.typ)
+}
+
+// mapKey converts a map key to a string. Strings are used as they are.
+// Types that implement encoding.TextMarshaler are marshaled. Booleans and
+// numbers are formatted as in JSON, so maps decoded from YAML, which may
+// have keys of any type, may be converted.
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.Interface {
+		if k.IsNil() {
+			return "", fmt.Errorf("cannot use nil as an object key")
+		}
+		k = k.Elem()
+	}
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if k.CanInterface() {
+		if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+			text, err := tm.MarshalText()
+			return string(text), err
+		}
+	}
+	switch k.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(k.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(k.Float(), 'g', -1, 64), nil
+	}
+	return "", fmt.Errorf("cannot use %v of type %v as an object key", k, k.Type())
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex, but it returns false
+// instead of panicking if the field is in a nil embedded struct pointer.
+func fieldByIndex(rv reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return reflect.Value{}, false
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, true
+}
+
+func isEmptyValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// A structField is a field of a struct type that's converted to an
+// attribute.
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	depth     int
+}
+
+var fieldCache sync.Map // map[reflect.Type][]structField
+
+// typeFields returns the fields of a struct type that are converted to
+// attributes, following the rules of encoding/json. Field names come from
+// the "sift" tag if present, then the "json" tag, then the field name.
+// A tag of "-" omits the field, and the "omitempty" option omits it when
+// it has an empty value. Exported fields of embedded structs are promoted,
+// unless they're hidden by a field with the same name at a shallower depth.
+// Fields with the same name at the same depth are omitted.
+func typeFields(t reflect.Type) []structField {
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.([]structField)
+	}
+
+	var fields []structField
+	var visit func(t reflect.Type, index []int, depth int, visited map[reflect.Type]bool)
+	visit = func(t reflect.Type, index []int, depth int, visited map[reflect.Type]bool) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		defer delete(visited, t)
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag, ok := sf.Tag.Lookup("sift")
+			if !ok {
+				tag = sf.Tag.Get("json")
+			}
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			fieldIndex := append(append([]int(nil), index...), i)
+			if sf.Anonymous && name == "" {
+				ft := sf.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					visit(ft, fieldIndex, depth+1, visited)
+					continue
+				}
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			fields = append(fields, structField{
+				name:      name,
+				index:     fieldIndex,
+				omitEmpty: hasOption(opts, "omitempty"),
+				depth:     depth,
+			})
+		}
+	}
+	visit(t, nil, 0, make(map[reflect.Type]bool))
+
+	// Keep the shallowest field with each name, unless there's more than one.
+	byName := make(map[string][]int)
+	for i, f := range fields {
+		byName[f.name] = append(byName[f.name], i)
+	}
+	dominant := fields[:0:0]
+	for i, f := range fields {
+		keep := true
+		for _, j := range byName[f.name] {
+			if j != i && fields[j].depth <= f.depth {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			dominant = append(dominant, f)
+		}
+	}
+
+	actual, _ := fieldCache.LoadOrStore(t, dominant)
+	return actual.([]structField)
+}
+
+func hasOption(opts, want string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package sift_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+)
+
+type Base struct {
+	ID   int
+	Name string `json:"name"`
+}
+
+type inner struct {
+	Shown  string
+	hidden string
+}
+
+type Level int
+
+type record struct {
+	Base
+	*inner
+	Name     string `sift:"name"`
+	Count    int    `json:"count,omitempty"`
+	Tags     string `sift:"tags,omitempty" json:"ignored"`
+	Skip     string `json:"-"`
+	Dash     string `json:"-,"`
+	Level    Level
+	When     time.Time
+	Next     *record
+	Any      interface{}
+	internal string
+}
+
+func TestToValueStruct(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		v    interface{}
+		want string
+	}{
+		{
+			desc: "empty",
+			v:    struct{}{},
+			want: `{}`,
+		}, {
+			desc: "fields",
+			v: record{
+				Base:     Base{ID: 1, Name: "hidden by outer"},
+				inner:    &inner{Shown: "shown", hidden: "hidden"},
+				Name:     "outer",
+				Skip:     "skip",
+				Dash:     "dash",
+				Level:    3,
+				When:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				Next:     &record{Count: 2},
+				Any:      []interface{}{true},
+				internal: "internal",
+			},
+			want: `{"ID":1,"Shown":"shown","name":"outer","-":"dash","Level":3,"When":"2024-01-02T03:04:05Z",` +
+				`"Next":{"ID":0,"name":"","count":2,"-":"","Level":0,"When":"0001-01-01T00:00:00Z","Next":null,"Any":null},"Any":[true]}`,
+		}, {
+			desc: "pointer",
+			v:    &Base{ID: 2},
+			want: `{"ID":2,"name":""}`,
+		}, {
+			desc: "nil_pointer",
+			v:    (*Base)(nil),
+			want: `null`,
+		}, {
+			desc: "named_scalar",
+			v:    Level(4),
+			want: `4`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			v, err := sift.ToValue(tc.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := &strings.Builder{}
+			if err := json.NewEncoder(w).Encode(v); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(w.String()); got != tc.want {
+				t.Errorf("got  %s\nwant %s", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := sift.ToValue(struct{ C chan int }{}); err == nil {
+		t.Error("struct with channel field: got nil error; want error")
+	}
+}
+
+type rawBytes []byte
+
+type textKey struct{ a, b int }
+
+func (k textKey) MarshalText() ([]byte, error) {
+	return []byte(strings.Repeat("a", k.a) + strings.Repeat("b", k.b)), nil
+}
+
+func TestToValueCollections(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		v       interface{}
+		want    string
+		wantErr bool
+	}{
+		{desc: "strings", v: []string{"a", "b"}, want: `["a","b"]`},
+		{desc: "ints", v: []int{1, 2}, want: `[1,2]`},
+		{desc: "array", v: [2]float32{1.5, 2}, want: `[1.5,2]`},
+		{desc: "nested", v: [][]bool{{true}, nil}, want: `[[true],[]]`},
+		{desc: "structs", v: []Base{{ID: 1}}, want: `[{"ID":1,"name":""}]`},
+		{desc: "named_bytes", v: rawBytes("foo"), want: `"Zm9v"`},
+		{desc: "string_map", v: map[string]string{"b": "2", "a": "1"}, want: `{"a":"1","b":"2"}`},
+		{desc: "int_map", v: map[int][]int{2: {2}, 1: nil}, want: `{"1":[],"2":[2]}`},
+		{desc: "yaml_map", v: map[interface{}]interface{}{"a": 1, true: map[interface{}]interface{}{1.5: "x"}}, want: `{"a":1,"true":{"1.5":"x"}}`},
+		{desc: "text_key", v: map[textKey]int{{1, 2}: 3}, want: `{"abb":3}`},
+		{desc: "duplicate_key", v: map[interface{}]int{1: 1, "1": 2}, wantErr: true},
+		{desc: "nil_key", v: map[interface{}]int{nil: 1}, wantErr: true},
+		{desc: "struct_key", v: map[Base]int{{}: 1}, wantErr: true},
+		{desc: "bad_elem", v: []chan int{nil}, wantErr: true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			v, err := sift.ToValue(tc.v)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("got %v; want error", v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := &strings.Builder{}
+			if err := json.NewEncoder(w).Encode(v); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(w.String()); got != tc.want {
+				t.Errorf("got %s; want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+type cycleNode struct {
+	Next *cycleNode
+}
+
+func TestToValueCycle(t *testing.T) {
+	n := &cycleNode{}
+	n.Next = n
+	m := map[string]interface{}{}
+	m["m"] = m
+	s := []interface{}{nil}
+	s[0] = s
+	deep := &cycleNode{}
+	for i := 0; i < 2000; i++ {
+		deep = &cycleNode{Next: deep}
+	}
+	for _, tc := range []struct {
+		desc    string
+		v       interface{}
+		wantErr bool
+	}{
+		{desc: "pointer", v: n, wantErr: true},
+		{desc: "map", v: m, wantErr: true},
+		{desc: "slice", v: s, wantErr: true},
+		{desc: "deep", v: deep},
+		{desc: "shared", v: []*cycleNode{deep, deep}},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := sift.ToValue(tc.v)
+			if !tc.wantErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "cycle") {
+				t.Fatalf("got error %v; want cycle error", err)
+			}
+			if len(err.Error()) > 200 {
+				t.Errorf("error is %d bytes long; want a short error", len(err.Error()))
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package sift
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Safe returns a Filter that applies f, but returns a *PanicError if f
+// panics instead of letting the panic crash the program. This lets
+// a long-running service apply filters it doesn't trust, like plugins or
+// functions written by users, without one bad filter stopping every stream.
+//
+// Safe can't undo f's side effects, so state f shares with other filters
+// may be inconsistent after a panic.
+func Safe(f Filter) Filter {
+	return func(v Value) (vs []Value, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				vs, err = nil, &PanicError{Value: v, Recovered: r, Stack: debug.Stack()}
+			}
+		}()
+		return f(v)
+	}
+}
+
+// A PanicError is returned by a filter wrapped with Safe when the wrapped
+// filter panics.
+type PanicError struct {
+	// Value is the input value the filter panicked on.
+	Value Value
+
+	// Recovered is the value passed to panic.
+	Recovered interface{}
+
+	// Stack is the stack trace of the goroutine that panicked, as formatted
+	// by runtime/debug.Stack.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("filter panicked: %v", e.Recovered)
+}
+
+// Unwrap returns the value passed to panic if it's an error, so errors.Is
+// and errors.As can match it.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Recovered.(error)
+	return err
+}
@@ -0,0 +1,84 @@
+package sift_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestSafe(t *testing.T) {
+	input := sift.Must(sift.ToValue(map[string]interface{}{"x": 1}))
+	for _, tc := range []struct {
+		desc    string
+		f       sift.Filter
+		wantErr string
+		wantIs  error
+	}{
+		{
+			desc: "ok",
+			f:    sift.Map(func(v sift.Value) sift.Value { return v }),
+		}, {
+			desc: "error",
+			f: func(sift.Value) ([]sift.Value, error) {
+				return nil, io.ErrUnexpectedEOF
+			},
+			wantErr: io.ErrUnexpectedEOF.Error(),
+			wantIs:  io.ErrUnexpectedEOF,
+		}, {
+			desc: "panic_string",
+			f: func(sift.Value) ([]sift.Value, error) {
+				panic("oops")
+			},
+			wantErr: "filter panicked: oops",
+		}, {
+			desc: "panic_error",
+			f: func(sift.Value) ([]sift.Value, error) {
+				panic(io.ErrUnexpectedEOF)
+			},
+			wantErr: "filter panicked: unexpected EOF",
+			wantIs:  io.ErrUnexpectedEOF,
+		}, {
+			desc: "panic_runtime",
+			f: func(v sift.Value) ([]sift.Value, error) {
+				var vs []sift.Value
+				return vs[:1], nil
+			},
+			wantErr: "filter panicked: runtime error",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			vs, err := sift.Safe(tc.f)(input)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(vs) != 1 || !sift.Equal(vs[0], input) {
+					t.Errorf("got %v; want [%v]", vs, input)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("got error %v; want error containing %q", err, tc.wantErr)
+			}
+			if tc.wantIs != nil && !errors.Is(err, tc.wantIs) {
+				t.Errorf("got error %v; want error matching %v", err, tc.wantIs)
+			}
+			var perr *sift.PanicError
+			if !errors.As(err, &perr) {
+				if strings.HasPrefix(tc.desc, "panic") {
+					t.Errorf("got error %#v; want *PanicError", err)
+				}
+				return
+			}
+			if !sift.Equal(perr.Value, input) {
+				t.Errorf("got value %v; want %v", perr.Value, input)
+			}
+			if !strings.Contains(string(perr.Stack), "safe_test.go") {
+				t.Errorf("stack does not contain the panicking function:\n%s", perr.Stack)
+			}
+		})
+	}
+}
@@ -0,0 +1,680 @@
+// Package schema validates values against JSON Schemas.
+//
+// Compile accepts a schema written for JSON Schema draft 2020-12 and returns
+// a Schema, which reports the ways a value doesn't conform as Violations.
+// Schema.Filter returns a filter that passes valid values through and
+// rejects invalid ones with a *ValidationError, so invalid records may be
+// handled in the middle of a pipeline.
+//
+// The validation vocabulary is supported: type, enum, const, the numeric,
+// string, array, and object keywords, the applicators (allOf, anyOf, oneOf,
+// not, if, then, else, properties, patternProperties, additionalProperties,
+// propertyNames, dependentRequired, prefixItems, items, contains), and $ref
+// to locations within the same schema, like "#/$defs/name". Annotations
+// like format, title, and description are ignored, as are unknown keywords.
+// unevaluatedProperties, unevaluatedItems, $dynamicRef, and references to
+// other documents are not supported; Compile reports an error for them.
+package schema
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"go.jayconrod.com/sift"
+)
+
+// A Schema is a compiled JSON Schema. A Schema may be used concurrently.
+type Schema struct {
+	// always is set for the boolean schemas true and false, which accept
+	// or reject every value.
+	always *bool
+
+	refSchema *Schema
+
+	types    []string
+	enum     []sift.Value
+	constVal sift.Value
+
+	minimum, maximum                   *float64
+	exclusiveMinimum, exclusiveMaximum *float64
+	multipleOf                         *float64
+
+	minLength, maxLength int
+	pattern              *regexp.Regexp
+
+	prefixItems              []*Schema
+	items                    *Schema
+	contains                 *Schema
+	minContains, maxContains int
+	minItems, maxItems       int
+	uniqueItems              bool
+
+	properties           map[string]*Schema
+	patternProperties    []patternSchema
+	additionalProperties *Schema
+	propertyNames        *Schema
+	required             []string
+	dependentRequired    map[string][]string
+	minProperties        int
+	maxProperties        int
+
+	allOf, anyOf, oneOf []*Schema
+	not                 *Schema
+	ifSchema            *Schema
+	thenSchema          *Schema
+	elseSchema          *Schema
+}
+
+type patternSchema struct {
+	re     *regexp.Regexp
+	schema *Schema
+}
+
+// A Violation describes one way a value doesn't conform to a schema.
+type Violation struct {
+	// Path is the location of the invalid value within the validated value.
+	Path sift.Path
+
+	// Keyword is the schema keyword the value violates, like "required".
+	Keyword string
+
+	// Message describes the violation.
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("at %s: %s", v.Path, v.Message)
+}
+
+// ValidationError is returned by the filter returned by Schema.Filter for
+// values that don't conform to the schema.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Violations) == 1 {
+		return e.Violations[0].String()
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%d schema violations:", len(e.Violations))
+	for _, v := range e.Violations {
+		b.WriteString("\n\t")
+		b.WriteString(v.String())
+	}
+	return b.String()
+}
+
+// Compile compiles a JSON Schema. The schema is usually decoded from JSON,
+// but it may be any value with the same structure.
+func Compile(schema sift.Value) (*Schema, error) {
+	c := &compiler{root: schema, refs: make(map[string]*Schema)}
+	return c.compile(schema, "")
+}
+
+type compiler struct {
+	root sift.Value
+
+	// refs maps the JSON Pointers of referenced schemas to the compiled
+	// schemas. A schema is added before it's compiled, so references
+	// that recurse are resolved.
+	refs map[string]*Schema
+}
+
+func newSchema() *Schema {
+	return &Schema{minContains: 1, maxContains: -1, maxLength: -1, maxItems: -1, maxProperties: -1}
+}
+
+func (c *compiler) compile(v sift.Value, ptr string) (*Schema, error) {
+	s := newSchema()
+	if err := c.compileInto(s, v, ptr); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *compiler) compileInto(s *Schema, v sift.Value, ptr string) error {
+	if b, ok := sift.AsBool(v); ok {
+		s.always = &b
+		return nil
+	}
+	a, ok := v.(sift.Attr)
+	if !ok {
+		return fmt.Errorf("schema at %q must be an object or boolean", ptr)
+	}
+	errorf := func(keyword, format string, args ...interface{}) error {
+		return fmt.Errorf("schema at %q: %s: %s", ptr+"/"+keyword, keyword, fmt.Sprintf(format, args...))
+	}
+
+	for _, key := range a.Keys() {
+		keyword, ok := sift.AsString(key)
+		if !ok {
+			return fmt.Errorf("schema at %q has non-string key %v", ptr, key)
+		}
+		kv, _ := a.Attr(key)
+		kptr := ptr + "/" + escape(keyword)
+		var err error
+		switch keyword {
+		case "$ref":
+			ref, ok := sift.AsString(kv)
+			if !ok {
+				return errorf(keyword, "must be a string")
+			}
+			if s.refSchema, err = c.resolve(ref); err != nil {
+				return errorf(keyword, "%v", err)
+			}
+
+		case "type":
+			if t, ok := sift.AsString(kv); ok {
+				s.types = []string{t}
+			} else if s.types, ok = stringList(kv); !ok {
+				return errorf(keyword, "must be a string or array of strings")
+			}
+			for _, t := range s.types {
+				switch t {
+				case "null", "boolean", "number", "integer", "string", "array", "object":
+				default:
+					return errorf(keyword, "unknown type %q", t)
+				}
+			}
+
+		case "enum":
+			ix, ok := kv.(sift.Index)
+			if !ok {
+				return errorf(keyword, "must be an array")
+			}
+			s.enum = make([]sift.Value, ix.Length())
+			for i := range s.enum {
+				s.enum[i], _ = sift.GetIntIndex(ix, i)
+			}
+
+		case "const":
+			s.constVal = kv
+
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf":
+			f, ok := sift.AsFloat64(kv)
+			if !ok {
+				return errorf(keyword, "must be a number")
+			}
+			switch keyword {
+			case "minimum":
+				s.minimum = &f
+			case "maximum":
+				s.maximum = &f
+			case "exclusiveMinimum":
+				s.exclusiveMinimum = &f
+			case "exclusiveMaximum":
+				s.exclusiveMaximum = &f
+			default:
+				if f <= 0 {
+					return errorf(keyword, "must be greater than 0")
+				}
+				s.multipleOf = &f
+			}
+
+		case "minLength", "maxLength", "minItems", "maxItems", "minProperties", "maxProperties", "minContains", "maxContains":
+			n, ok := count(kv)
+			if !ok {
+				return errorf(keyword, "must be a non-negative integer")
+			}
+			switch keyword {
+			case "minLength":
+				s.minLength = n
+			case "maxLength":
+				s.maxLength = n
+			case "minItems":
+				s.minItems = n
+			case "maxItems":
+				s.maxItems = n
+			case "minProperties":
+				s.minProperties = n
+			case "maxProperties":
+				s.maxProperties = n
+			case "minContains":
+				s.minContains = n
+			default:
+				s.maxContains = n
+			}
+
+		case "pattern":
+			p, ok := sift.AsString(kv)
+			if !ok {
+				return errorf(keyword, "must be a string")
+			}
+			if s.pattern, err = regexp.Compile(p); err != nil {
+				return errorf(keyword, "%v", err)
+			}
+
+		case "uniqueItems":
+			s.uniqueItems = sift.Truthy(kv)
+
+		case "required":
+			if s.required, ok = stringList(kv); !ok {
+				return errorf(keyword, "must be an array of strings")
+			}
+
+		case "dependentRequired":
+			da, ok := kv.(sift.Attr)
+			if !ok {
+				return errorf(keyword, "must be an object")
+			}
+			s.dependentRequired = make(map[string][]string)
+			for _, dk := range da.Keys() {
+				name, _ := sift.AsString(dk)
+				dv, _ := da.Attr(dk)
+				if s.dependentRequired[name], ok = stringList(dv); !ok {
+					return errorf(keyword, "%q must be an array of strings", name)
+				}
+			}
+
+		case "properties", "patternProperties", "$defs", "definitions":
+			pa, ok := kv.(sift.Attr)
+			if !ok {
+				return errorf(keyword, "must be an object")
+			}
+			if keyword == "$defs" || keyword == "definitions" {
+				// Definitions are compiled when they're referenced.
+				continue
+			}
+			for _, pk := range pa.Keys() {
+				name, _ := sift.AsString(pk)
+				pv, _ := pa.Attr(pk)
+				ps, err := c.compile(pv, kptr+"/"+escape(name))
+				if err != nil {
+					return err
+				}
+				if keyword == "properties" {
+					if s.properties == nil {
+						s.properties = make(map[string]*Schema)
+					}
+					s.properties[name] = ps
+					continue
+				}
+				re, err := regexp.Compile(name)
+				if err != nil {
+					return errorf(keyword, "%v", err)
+				}
+				s.patternProperties = append(s.patternProperties, patternSchema{re: re, schema: ps})
+			}
+
+		case "allOf", "anyOf", "oneOf", "prefixItems":
+			ix, ok := kv.(sift.Index)
+			if !ok || ix.Length() == 0 && keyword != "prefixItems" {
+				return errorf(keyword, "must be a non-empty array")
+			}
+			list := make([]*Schema, ix.Length())
+			for i := range list {
+				e, _ := sift.GetIntIndex(ix, i)
+				if list[i], err = c.compile(e, fmt.Sprintf("%s/%d", kptr, i)); err != nil {
+					return err
+				}
+			}
+			switch keyword {
+			case "allOf":
+				s.allOf = list
+			case "anyOf":
+				s.anyOf = list
+			case "oneOf":
+				s.oneOf = list
+			default:
+				s.prefixItems = list
+			}
+
+		case "items", "contains", "additionalProperties", "propertyNames", "not", "if", "then", "else":
+			sub, err := c.compile(kv, kptr)
+			if err != nil {
+				return err
+			}
+			switch keyword {
+			case "items":
+				s.items = sub
+			case "contains":
+				s.contains = sub
+			case "additionalProperties":
+				s.additionalProperties = sub
+			case "propertyNames":
+				s.propertyNames = sub
+			case "not":
+				s.not = sub
+			case "if":
+				s.ifSchema = sub
+			case "then":
+				s.thenSchema = sub
+			default:
+				s.elseSchema = sub
+			}
+
+		case "unevaluatedProperties", "unevaluatedItems", "$dynamicRef", "$recursiveRef":
+			return errorf(keyword, "not supported")
+		}
+	}
+	return nil
+}
+
+// resolve returns the schema referenced by ref, which must be a fragment
+// containing a JSON Pointer into the root schema, like "#/$defs/a".
+func (c *compiler) resolve(ref string) (*Schema, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("reference to another document %q is not supported", ref)
+	}
+	ptr, err := url.PathUnescape(ref[1:])
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := c.refs[ptr]; ok {
+		return s, nil
+	}
+	v, err := sift.ResolvePointer(c.root, ptr)
+	if err != nil {
+		return nil, err
+	}
+	s := newSchema()
+	c.refs[ptr] = s
+	if err := c.compileInto(s, v, ptr); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Validate returns the ways v doesn't conform to the schema. If v is
+// valid, Validate returns nil.
+func (s *Schema) Validate(v sift.Value) []Violation {
+	var vs []Violation
+	s.validate(v, nil, &vs)
+	return vs
+}
+
+// Valid returns whether v conforms to the schema.
+func (s *Schema) Valid(v sift.Value) bool {
+	return len(s.Validate(v)) == 0
+}
+
+// Filter returns a filter that produces its input if it conforms to the
+// schema. Otherwise, the filter returns a *ValidationError.
+func (s *Schema) Filter() sift.Filter {
+	return func(v sift.Value) ([]sift.Value, error) {
+		if vs := s.Validate(v); len(vs) > 0 {
+			return nil, &ValidationError{Violations: vs}
+		}
+		return []sift.Value{v}, nil
+	}
+}
+
+func (s *Schema) validate(v sift.Value, path sift.Path, out *[]Violation) {
+	report := func(keyword, format string, args ...interface{}) {
+		p := append(sift.Path(nil), path...)
+		*out = append(*out, Violation{Path: p, Keyword: keyword, Message: fmt.Sprintf(format, args...)})
+	}
+	if v == nil {
+		v = sift.NullValue
+	}
+	if s.always != nil {
+		if !*s.always {
+			report("false", "no value is allowed")
+		}
+		return
+	}
+	if s.refSchema != nil {
+		s.refSchema.validate(v, path, out)
+	}
+
+	if s.types != nil {
+		ok := false
+		for _, t := range s.types {
+			if hasType(v, t) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			report("type", "expected %s, got %s", strings.Join(s.types, " or "), sift.TypeOf(v))
+		}
+	}
+	if s.enum != nil {
+		ok := false
+		for _, e := range s.enum {
+			if sift.Equal(v, e) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			report("enum", "value is not one of the allowed values")
+		}
+	}
+	if s.constVal != nil && !sift.Equal(v, s.constVal) {
+		report("const", "value does not equal the constant")
+	}
+
+	if f, ok := sift.AsFloat64(v); ok {
+		if s.minimum != nil && f < *s.minimum {
+			report("minimum", "%v is less than the minimum %v", f, *s.minimum)
+		}
+		if s.maximum != nil && f > *s.maximum {
+			report("maximum", "%v is greater than the maximum %v", f, *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && f <= *s.exclusiveMinimum {
+			report("exclusiveMinimum", "%v is not greater than %v", f, *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && f >= *s.exclusiveMaximum {
+			report("exclusiveMaximum", "%v is not less than %v", f, *s.exclusiveMaximum)
+		}
+		if s.multipleOf != nil {
+			if q := f / *s.multipleOf; math.IsInf(q, 0) || q != math.Trunc(q) {
+				report("multipleOf", "%v is not a multiple of %v", f, *s.multipleOf)
+			}
+		}
+	}
+
+	if str, ok := sift.AsString(v); ok {
+		n := utf8.RuneCountInString(str)
+		if n < s.minLength {
+			report("minLength", "string is shorter than %d characters", s.minLength)
+		}
+		if s.maxLength >= 0 && n > s.maxLength {
+			report("maxLength", "string is longer than %d characters", s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(str) {
+			report("pattern", "string does not match pattern %q", s.pattern)
+		}
+	}
+
+	if ix, ok := v.(sift.Index); ok {
+		s.validateArray(ix, path, report, out)
+	}
+	if a, ok := v.(sift.Attr); ok {
+		s.validateObject(a, path, report, out)
+	}
+
+	for _, sub := range s.allOf {
+		sub.validate(v, path, out)
+	}
+	if s.anyOf != nil {
+		ok := false
+		for _, sub := range s.anyOf {
+			if sub.Valid(v) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			report("anyOf", "value does not match any schema")
+		}
+	}
+	if s.oneOf != nil {
+		n := 0
+		for _, sub := range s.oneOf {
+			if sub.Valid(v) {
+				n++
+			}
+		}
+		if n != 1 {
+			report("oneOf", "value matches %d schemas; want exactly one", n)
+		}
+	}
+	if s.not != nil && s.not.Valid(v) {
+		report("not", "value matches a schema it must not match")
+	}
+	if s.ifSchema != nil {
+		if s.ifSchema.Valid(v) {
+			if s.thenSchema != nil {
+				s.thenSchema.validate(v, path, out)
+			}
+		} else if s.elseSchema != nil {
+			s.elseSchema.validate(v, path, out)
+		}
+	}
+}
+
+func (s *Schema) validateArray(ix sift.Index, path sift.Path, report func(string, string, ...interface{}), out *[]Violation) {
+	n := ix.Length()
+	if n < s.minItems {
+		report("minItems", "array has fewer than %d items", s.minItems)
+	}
+	if s.maxItems >= 0 && n > s.maxItems {
+		report("maxItems", "array has more than %d items", s.maxItems)
+	}
+	elems := make([]sift.Value, n)
+	for i := range elems {
+		elems[i], _ = sift.GetIntIndex(ix, i)
+		if elems[i] == nil {
+			elems[i] = sift.NullValue
+		}
+	}
+	for i, e := range elems {
+		var sub *Schema
+		if i < len(s.prefixItems) {
+			sub = s.prefixItems[i]
+		} else {
+			sub = s.items
+		}
+		if sub != nil {
+			sub.validate(e, appendPath(path, float64(i)), out)
+		}
+	}
+	if s.uniqueItems && sift.NewSet(elems...).Length() != n {
+		report("uniqueItems", "array items are not unique")
+	}
+	if s.contains != nil {
+		matches := 0
+		for _, e := range elems {
+			if s.contains.Valid(e) {
+				matches++
+			}
+		}
+		if matches < s.minContains {
+			report("contains", "array has %d items matching the contains schema; want at least %d", matches, s.minContains)
+		}
+		if s.maxContains >= 0 && matches > s.maxContains {
+			report("maxContains", "array has %d items matching the contains schema; want at most %d", matches, s.maxContains)
+		}
+	}
+}
+
+func (s *Schema) validateObject(a sift.Attr, path sift.Path, report func(string, string, ...interface{}), out *[]Violation) {
+	keys := a.Keys()
+	if len(keys) < s.minProperties {
+		report("minProperties", "object has fewer than %d properties", s.minProperties)
+	}
+	if s.maxProperties >= 0 && len(keys) > s.maxProperties {
+		report("maxProperties", "object has more than %d properties", s.maxProperties)
+	}
+	has := func(name string) bool {
+		_, ok := sift.GetStringAttr(a, name)
+		return ok
+	}
+	for _, name := range s.required {
+		if !has(name) {
+			report("required", "missing required property %q", name)
+		}
+	}
+	names := make([]string, 0, len(s.dependentRequired))
+	for name := range s.dependentRequired {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !has(name) {
+			continue
+		}
+		for _, dep := range s.dependentRequired[name] {
+			if !has(dep) {
+				report("dependentRequired", "property %q requires property %q", name, dep)
+			}
+		}
+	}
+
+	for _, key := range keys {
+		name, ok := sift.AsString(key)
+		if !ok {
+			continue
+		}
+		e, _ := a.Attr(key)
+		p := appendPath(path, name)
+		if s.propertyNames != nil {
+			s.propertyNames.validate(key, p, out)
+		}
+		matched := false
+		if ps, ok := s.properties[name]; ok {
+			matched = true
+			ps.validate(e, p, out)
+		}
+		for _, pp := range s.patternProperties {
+			if pp.re.MatchString(name) {
+				matched = true
+				pp.schema.validate(e, p, out)
+			}
+		}
+		if !matched && s.additionalProperties != nil {
+			if s.additionalProperties.always != nil && !*s.additionalProperties.always {
+				report("additionalProperties", "property %q is not allowed", name)
+			} else {
+				s.additionalProperties.validate(e, p, out)
+			}
+		}
+	}
+}
+
+func appendPath(p sift.Path, k interface{}) sift.Path {
+	return append(p[:len(p):len(p)], sift.Must(sift.ToValue(k)))
+}
+
+func hasType(v sift.Value, t string) bool {
+	if t == "integer" {
+		f, ok := sift.AsFloat64(v)
+		return ok && f == math.Trunc(f) && !math.IsInf(f, 0)
+	}
+	return sift.TypeOf(v).String() == t
+}
+
+func stringList(v sift.Value) ([]string, bool) {
+	ix, ok := v.(sift.Index)
+	if !ok {
+		return nil, false
+	}
+	list := make([]string, ix.Length())
+	for i := range list {
+		e, _ := sift.GetIntIndex(ix, i)
+		if list[i], ok = sift.AsString(e); !ok {
+			return nil, false
+		}
+	}
+	return list, true
+}
+
+func count(v sift.Value) (int, bool) {
+	f, ok := sift.AsFloat64(v)
+	if !ok || f < 0 || f != math.Trunc(f) || f > math.MaxInt32 {
+		return 0, false
+	}
+	return int(f), true
+}
+
+func escape(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
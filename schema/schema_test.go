@@ -0,0 +1,185 @@
+package schema_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+	"go.jayconrod.com/sift/schema"
+)
+
+func decode(t *testing.T, s string) sift.Value {
+	t.Helper()
+	v, err := json.NewDecoder(strings.NewReader(s)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		desc, schema, value string
+		want                []string
+	}{
+		{
+			desc:   "true",
+			schema: `true`,
+			value:  `{"a": 1}`,
+		}, {
+			desc:   "false",
+			schema: `false`,
+			value:  `1`,
+			want:   []string{"at .: no value is allowed"},
+		}, {
+			desc:   "type",
+			schema: `{"type": ["string", "null"]}`,
+			value:  `1`,
+			want:   []string{"at .: expected string or null, got number"},
+		}, {
+			desc:   "integer",
+			schema: `{"type": "integer"}`,
+			value:  `1.5`,
+			want:   []string{"at .: expected integer, got number"},
+		}, {
+			desc:   "enum_const",
+			schema: `{"enum": [1, "a", {"b": null}], "const": {"b": null}}`,
+			value:  `{"b": null}`,
+		}, {
+			desc:   "enum_mismatch",
+			schema: `{"enum": [1, "a"]}`,
+			value:  `"b"`,
+			want:   []string{"at .: value is not one of the allowed values"},
+		}, {
+			desc:   "numbers",
+			schema: `{"minimum": 2, "exclusiveMaximum": 10, "multipleOf": 4}`,
+			value:  `[1, 4, 10]`,
+		}, {
+			desc:   "number_violations",
+			schema: `{"items": {"minimum": 2, "exclusiveMaximum": 10, "multipleOf": 4}}`,
+			value:  `[1, 4, 10]`,
+			want: []string{
+				"at .[0]: 1 is less than the minimum 2",
+				"at .[0]: 1 is not a multiple of 4",
+				"at .[2]: 10 is not less than 10",
+				"at .[2]: 10 is not a multiple of 4",
+			},
+		}, {
+			desc:   "strings",
+			schema: `{"minLength": 2, "maxLength": 3, "pattern": "^a"}`,
+			value:  `"bcde"`,
+			want: []string{
+				"at .: string is longer than 3 characters",
+				`at .: string does not match pattern "^a"`,
+			},
+		}, {
+			desc:   "arrays",
+			schema: `{"prefixItems": [{"type": "string"}], "items": {"type": "number"}, "minItems": 4, "uniqueItems": true, "contains": {"const": 2}, "maxContains": 1}`,
+			value:  `["a", 1, 1, "b"]`,
+			want: []string{
+				"at .[3]: expected number, got string",
+				"at .: array items are not unique",
+				"at .: array has 0 items matching the contains schema; want at least 1",
+			},
+		}, {
+			desc: "objects",
+			schema: `{
+				"properties": {"id": {"type": "integer"}, "tags": {"type": "array"}},
+				"patternProperties": {"^x-": {"type": "string"}},
+				"additionalProperties": false,
+				"required": ["id", "name"],
+				"dependentRequired": {"tags": ["owner"]},
+				"propertyNames": {"maxLength": 4}
+			}`,
+			value: `{"id": "1", "tags": [], "x-ab": 2, "other": true}`,
+			want: []string{
+				`at .: missing required property "name"`,
+				`at .: property "tags" requires property "owner"`,
+				`at .id: expected integer, got string`,
+				`at .["x-ab"]: expected string, got number`,
+				`at .other: string is longer than 4 characters`,
+				`at .: property "other" is not allowed`,
+			},
+		}, {
+			desc:   "applicators",
+			schema: `{"allOf": [{"type": "number"}], "anyOf": [{"minimum": 10}, {"maximum": 0}], "oneOf": [{"type": "integer"}, {"multipleOf": 1}], "not": {"const": 5}}`,
+			value:  `5`,
+			want: []string{
+				"at .: value does not match any schema",
+				"at .: value matches 2 schemas; want exactly one",
+				"at .: value matches a schema it must not match",
+			},
+		}, {
+			desc:   "if_then_else",
+			schema: `{"items": {"if": {"type": "string"}, "then": {"minLength": 1}, "else": {"type": "number"}}}`,
+			value:  `["", 1, null]`,
+			want: []string{
+				"at .[0]: string is shorter than 1 characters",
+				"at .[2]: expected number, got null",
+			},
+		}, {
+			desc:   "ref_recursive",
+			schema: `{"$ref": "#/$defs/node", "$defs": {"node": {"type": "object", "properties": {"next": {"$ref": "#/$defs/node"}}}}}`,
+			value:  `{"next": {"next": {"next": 1}}}`,
+			want:   []string{"at .next.next.next: expected object, got number"},
+		}, {
+			desc:   "ref_root",
+			schema: `{"type": "array", "items": {"$ref": "#"}}`,
+			value:  `[[], [[1]]]`,
+			want:   []string{"at .[1][0][0]: expected array, got number"},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			s, err := schema.Compile(decode(t, tc.schema))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, v := range s.Validate(decode(t, tc.value)) {
+				got = append(got, v.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("got violations:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	for _, tc := range []struct {
+		desc, schema, want string
+	}{
+		{desc: "not_object", schema: `1`, want: `schema at "" must be an object or boolean`},
+		{desc: "bad_type", schema: `{"type": "float"}`, want: `unknown type "float"`},
+		{desc: "bad_pattern", schema: `{"pattern": "("}`, want: "missing closing )"},
+		{desc: "bad_ref", schema: `{"$ref": "#/$defs/missing"}`, want: `no value at .["$defs"].missing`},
+		{desc: "remote_ref", schema: `{"$ref": "other.json"}`, want: "not supported"},
+		{desc: "unevaluated", schema: `{"unevaluatedProperties": false}`, want: "not supported"},
+		{desc: "nested", schema: `{"properties": {"a": {"minLength": -1}}}`, want: `schema at "/properties/a/minLength"`},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := schema.Compile(decode(t, tc.schema))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("got error %v; want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	s, err := schema.Compile(decode(t, `{"required": ["id"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := s.Filter()
+	if vs, err := f(decode(t, `{"id": 1}`)); err != nil || len(vs) != 1 {
+		t.Errorf("valid value: got %v, %v; want the value", vs, err)
+	}
+	_, err = f(decode(t, `{}`))
+	var verr *schema.ValidationError
+	if !errors.As(err, &verr) || len(verr.Violations) != 1 || verr.Violations[0].Keyword != "required" {
+		t.Errorf("invalid value: got error %v; want *ValidationError for required", err)
+	}
+}
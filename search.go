@@ -0,0 +1,118 @@
+package sift
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// SearchSorted finds the values in a sorted file whose key equals want,
+// without reading the whole file. The file must contain one value per
+// line, like newline-delimited JSON, sorted in non-decreasing order of the
+// key produced by key for each value. Keys are ordered by Compare.
+// newDecoder returns a Decoder for a single line.
+//
+// SearchSorted binary searches the file by seeking to the middle of
+// a range of bytes and reading the next full line, so it reads
+// O(log size) lines, plus the lines that match. Matching values are
+// returned in file order. If no value has the key, SearchSorted returns
+// nil without error.
+func SearchSorted(r io.ReaderAt, size int64, newDecoder func(io.Reader) Decoder, key Filter, want Value) ([]Value, error) {
+	s := &sortedFile{r: r, size: size, newDecoder: newDecoder, key: key}
+
+	// Find the first line whose key is not less than want. Lines before lo
+	// have smaller keys. hi is the start of a line whose key is not less
+	// than want, or size.
+	lo, hi := int64(0), size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, err := s.lineStart(mid)
+		if err != nil {
+			return nil, err
+		}
+		if start >= hi {
+			// No line starts between mid and hi. Check the line at lo.
+			start = lo
+		}
+		k, _, end, err := s.readLine(start)
+		if err != nil {
+			return nil, err
+		}
+		if Compare(k, want) < 0 {
+			lo = end
+		} else {
+			hi = start
+		}
+	}
+
+	var vs []Value
+	for off := lo; off < size; {
+		k, v, end, err := s.readLine(off)
+		if err != nil {
+			return nil, err
+		}
+		if Compare(k, want) != 0 {
+			break
+		}
+		vs = append(vs, v)
+		off = end
+	}
+	return vs, nil
+}
+
+// searchBufferSize is the size of reads while searching. Most lines are
+// expected to be short, so small reads avoid reading much more than the
+// lines being compared.
+const searchBufferSize = 512
+
+type sortedFile struct {
+	r          io.ReaderAt
+	size       int64
+	newDecoder func(io.Reader) Decoder
+	key        Filter
+}
+
+// lineStart returns the offset of the first line that starts at or after
+// off, or the file size if there is none.
+func (s *sortedFile) lineStart(off int64) (int64, error) {
+	if off == 0 {
+		return 0, nil
+	}
+	br := bufio.NewReaderSize(io.NewSectionReader(s.r, off-1, s.size-off+1), searchBufferSize)
+	skipped, err := br.ReadSlice('\n')
+	for err == bufio.ErrBufferFull {
+		n := len(skipped)
+		skipped, err = br.ReadSlice('\n')
+		off += int64(n)
+	}
+	if err == io.EOF {
+		return s.size, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return off - 1 + int64(len(skipped)), nil
+}
+
+// readLine decodes the value in the line starting at off and computes its
+// key. It returns the offset of the next line.
+func (s *sortedFile) readLine(off int64) (key, v Value, end int64, err error) {
+	br := bufio.NewReaderSize(io.NewSectionReader(s.r, off, s.size-off), searchBufferSize)
+	line, err := br.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, nil, 0, err
+	}
+	end = off + int64(len(line))
+	v, err = s.newDecoder(bytes.NewReader(line)).Decode()
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("line at offset %d: %w", off, err)
+	}
+	ks, err := s.key(v)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("line at offset %d: computing key: %w", off, err)
+	}
+	if len(ks) != 1 {
+		return nil, nil, 0, fmt.Errorf("line at offset %d: key produced %d values; want 1", off, len(ks))
+	}
+	return ks[0], v, end, nil
+}
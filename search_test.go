@@ -0,0 +1,78 @@
+package sift_test
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+)
+
+// countingReaderAt counts bytes read, so tests can check that a search
+// doesn't read the whole file.
+type countingReaderAt struct {
+	r io.ReaderAt
+	n int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.n += int64(n)
+	return n, err
+}
+
+func TestSearchSorted(t *testing.T) {
+	id := func(v sift.Value) ([]sift.Value, error) {
+		k, _ := sift.GetStringAttr(v, "id")
+		return []sift.Value{k}, nil
+	}
+	newDecoder := func(r io.Reader) sift.Decoder { return json.NewDecoder(r) }
+
+	b := &strings.Builder{}
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(b, "{\"id\":%d,\"pad\":%q}\n", i/2*2, strings.Repeat("x", i%7))
+	}
+	big := b.String()
+
+	for _, test := range []struct {
+		name, file string
+		want       interface{}
+		wantN      int
+	}{
+		{name: "empty", file: "", want: 1, wantN: 0},
+		{name: "one", file: "{\"id\":1}\n", want: 1, wantN: 1},
+		{name: "no_newline", file: "{\"id\":0}\n{\"id\":1}", want: 1, wantN: 1},
+		{name: "before", file: "{\"id\":1}\n{\"id\":2}\n", want: 0, wantN: 0},
+		{name: "after", file: "{\"id\":1}\n{\"id\":2}\n", want: 3, wantN: 0},
+		{name: "missing", file: "{\"id\":1}\n{\"id\":3}\n", want: 2, wantN: 0},
+		{name: "strings", file: "{\"id\":\"a\"}\n{\"id\":\"b\"}\n{\"id\":\"b\"}\n{\"id\":\"c\"}\n", want: "b", wantN: 2},
+		{name: "kinds", file: "{\"id\":null}\n{\"id\":true}\n{\"id\":5}\n{\"id\":\"5\"}\n", want: "5", wantN: 1},
+		{name: "big_first", file: big, want: 0, wantN: 2},
+		{name: "big_middle", file: big, want: 5000, wantN: 2},
+		{name: "big_last", file: big, want: 9998, wantN: 2},
+		{name: "big_odd", file: big, want: 5001, wantN: 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := &countingReaderAt{r: strings.NewReader(test.file)}
+			want := sift.Must(sift.ToValue(test.want))
+			vs, err := sift.SearchSorted(r, int64(len(test.file)), newDecoder, id, want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(vs) != test.wantN {
+				t.Fatalf("got %d values; want %d", len(vs), test.wantN)
+			}
+			for _, v := range vs {
+				k, _ := id(v)
+				if got, want := fmt.Sprint(k[0]), fmt.Sprint(want); got != want {
+					t.Errorf("got value with key %s; want %s", got, want)
+				}
+			}
+			if len(test.file) > 100000 && r.n > int64(len(test.file))/4 {
+				t.Errorf("read %d bytes of %d", r.n, len(test.file))
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+// Package service helps servers that compile and evaluate jq programs on
+// behalf of many tenants, like the users of a SaaS product. A Service keeps
+// each tenant's compiled filters, enforces per-tenant quotas on compilation,
+// evaluation time, and memory, and records metrics for each tenant.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/filter/jq"
+)
+
+// ErrQuotaExceeded is returned when a tenant has exceeded a quota. Errors
+// returned by Service and Filter methods wrap it with details about the
+// tenant and quota; use errors.Is to check for it.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// ErrClosed is returned when a closed Filter is evaluated.
+var ErrClosed = errors.New("filter is closed")
+
+// Quota limits the resources a tenant may use. Zero fields mean there is no
+// limit.
+type Quota struct {
+	// CompileRate is the number of programs a tenant may compile per second,
+	// on average. CompileBurst is the number of programs a tenant may
+	// compile at once before the rate applies. If CompileBurst is 0 and
+	// CompileRate is not, 1 is used.
+	CompileRate  float64
+	CompileBurst int
+
+	// EvalTime is the amount of time a tenant's filters may spend
+	// evaluating inputs per second of wall time, on average, across all the
+	// tenant's filters. For example, 500ms lets a tenant keep half a CPU
+	// busy. Evaluations that start after a tenant has used its time fail
+	// until the budget refills. The budget holds at most one second's worth
+	// of time.
+	EvalTime time.Duration
+
+	// EvalTimeout is the maximum amount of time a filter may take to
+	// evaluate one input. See jq.CompileOptions.Timeout.
+	EvalTimeout time.Duration
+
+	// MaxFilters is the number of compiled filters a tenant may have open
+	// at once.
+	MaxFilters int
+
+	// MaxProgramBytes is the total size of the source text of a tenant's
+	// open filters. Compiled programs take memory in proportion to their
+	// source, so this bounds the memory a tenant's filters retain.
+	MaxProgramBytes int
+
+	// MaxDepth and MaxOutputs bound the memory used to evaluate one input.
+	// See jq.CompileOptions.
+	MaxDepth   int
+	MaxOutputs int
+}
+
+// Options controls the behavior of a Service.
+type Options struct {
+	// Quota is the quota for tenants not listed in Quotas.
+	Quota Quota
+
+	// Quotas maps tenant names to quotas that override Quota.
+	Quotas map[string]Quota
+
+	// CompileOptions are used to compile every tenant's programs. Limits in
+	// CompileOptions are replaced with limits from the tenant's quota.
+	// Programs from tenants are usually untrusted, so Profile should
+	// usually be jq.ProfileNoIO or jq.ProfilePure.
+	CompileOptions jq.CompileOptions
+
+	// Now returns the current time, used to refill quotas. If Now is nil,
+	// time.Now is used.
+	Now func() time.Time
+}
+
+// Service compiles and evaluates jq programs for tenants. A Service is safe
+// for concurrent use.
+type Service struct {
+	opts Options
+	now  func() time.Time
+
+	mu      sync.Mutex
+	tenants map[string]*tenant
+}
+
+// New returns a Service with the given options.
+func New(opts Options) *Service {
+	now := opts.Now
+	if now == nil {
+		now = time.Now
+	}
+	return &Service{opts: opts, now: now, tenants: make(map[string]*tenant)}
+}
+
+// tenant holds the filters, quota usage, and metrics for one tenant. Fields
+// are guarded by mu.
+type tenant struct {
+	name  string
+	quota Quota
+
+	mu       sync.Mutex
+	filters  map[string]*Filter
+	bytes    int
+	compiles *sift.Limiter
+	evalTime *sift.Limiter
+	metrics  Metrics
+}
+
+func (s *Service) tenant(name string) *tenant {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.tenants[name]; ok {
+		return t
+	}
+	q, ok := s.opts.Quotas[name]
+	if !ok {
+		q = s.opts.Quota
+	}
+	burst := float64(q.CompileBurst)
+	if burst == 0 {
+		burst = 1
+	}
+	now := s.now()
+	t := &tenant{
+		name:     name,
+		quota:    q,
+		filters:  make(map[string]*Filter),
+		compiles: sift.NewLimiter(q.CompileRate, burst, now),
+		evalTime: sift.NewLimiter(q.EvalTime.Seconds(), q.EvalTime.Seconds(), now),
+	}
+	s.tenants[name] = t
+	return t
+}
+
+// Compile compiles a program for a tenant and stores it under name,
+// replacing and closing any filter the tenant already had with that name.
+// Compile fails with an error wrapping ErrQuotaExceeded if the tenant has
+// compiled too many programs recently, or if the new filter would exceed
+// the tenant's MaxFilters or MaxProgramBytes.
+func (s *Service) Compile(tenantName, name, src string) (*Filter, error) {
+	t := s.tenant(tenantName)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	old := t.filters[name]
+	n, bytes := len(t.filters)+1, t.bytes+len(src)
+	if old != nil {
+		n, bytes = n-1, bytes-len(old.src)
+	}
+	// The rate is checked last, so a compile rejected for another reason
+	// doesn't take a token.
+	var err error
+	switch {
+	case t.quota.MaxFilters > 0 && n > t.quota.MaxFilters:
+		err = t.quotaError("filters", fmt.Sprintf("%d", t.quota.MaxFilters))
+	case t.quota.MaxProgramBytes > 0 && bytes > t.quota.MaxProgramBytes:
+		err = t.quotaError("program bytes", fmt.Sprintf("%d", t.quota.MaxProgramBytes))
+	case t.quota.CompileRate > 0 && !t.compiles.Take(s.now(), 1):
+		err = t.quotaError("compile rate", fmt.Sprintf("%g per second", t.quota.CompileRate))
+	}
+	if err != nil {
+		t.metrics.Rejected++
+		return nil, err
+	}
+
+	opts := s.opts.CompileOptions
+	opts.Timeout = t.quota.EvalTimeout
+	opts.MaxDepth = t.quota.MaxDepth
+	opts.MaxOutputs = t.quota.MaxOutputs
+	start := s.now()
+	prog, err := jq.CompileProgram(name, src, opts)
+	t.metrics.Compiles++
+	t.metrics.CompileTime += s.now().Sub(start)
+	if err != nil {
+		t.metrics.CompileErrors++
+		return nil, err
+	}
+
+	if old != nil {
+		old.closeLocked()
+	}
+	f := &Filter{s: s, t: t, name: name, src: src, prog: prog}
+	t.filters[name] = f
+	t.bytes = bytes
+	t.metrics.Filters, t.metrics.ProgramBytes = len(t.filters), t.bytes
+	return f, nil
+}
+
+// Lookup returns the tenant's filter with the given name, if it's open.
+func (s *Service) Lookup(tenantName, name string) (*Filter, bool) {
+	t := s.tenant(tenantName)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f, ok := t.filters[name]
+	return f, ok
+}
+
+// Metrics returns a snapshot of a tenant's metrics.
+func (s *Service) Metrics(tenantName string) Metrics {
+	t := s.tenant(tenantName)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.metrics
+}
+
+// Tenants returns the names of tenants that have used the service, sorted.
+func (s *Service) Tenants() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.tenants))
+	for name := range s.tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (t *tenant) quotaError(resource, limit string) error {
+	return fmt.Errorf("tenant %q: %w: %s (limit %s)", t.name, ErrQuotaExceeded, resource, limit)
+}
+
+// Metrics describes a tenant's use of a Service.
+type Metrics struct {
+	// Compiles is the number of programs compiled, and CompileErrors is the
+	// number that failed to compile. CompileTime is the total time spent
+	// compiling.
+	Compiles, CompileErrors int64
+	CompileTime             time.Duration
+
+	// Evals is the number of inputs evaluated, and EvalErrors is the number
+	// of evaluations that failed. EvalTime is the total time spent
+	// evaluating.
+	Evals, EvalErrors int64
+	EvalTime          time.Duration
+
+	// Rejected is the number of compilations and evaluations that were
+	// rejected because they would exceed the tenant's quota.
+	Rejected int64
+
+	// Filters is the number of open filters, and ProgramBytes is the total
+	// size of their source.
+	Filters, ProgramBytes int
+}
+
+// A Filter is a program compiled by a Service for a tenant. A Filter stays
+// open, counting against the tenant's quota, until it's closed or replaced
+// by another program compiled with the same name.
+type Filter struct {
+	s    *Service
+	t    *tenant
+	name string
+	src  string
+	prog *jq.Program
+
+	closed bool // guarded by t.mu
+}
+
+// Name returns the name the filter was compiled with.
+func (f *Filter) Name() string {
+	return f.name
+}
+
+// Eval evaluates the filter with one input. ctx is passed to functions in
+// CompileOptions.Funcs. If one of those functions panics, Eval returns
+// a *sift.PanicError. Eval fails with an error wrapping ErrQuotaExceeded
+// if the tenant has used its evaluation time.
+func (f *Filter) Eval(ctx context.Context, v sift.Value) ([]sift.Value, error) {
+	t := f.t
+	t.mu.Lock()
+	if f.closed {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("%s: %w", f.name, ErrClosed)
+	}
+	if t.quota.EvalTime > 0 && !t.evalTime.Available(f.s.now()) {
+		t.metrics.Rejected++
+		t.mu.Unlock()
+		return nil, t.quotaError("evaluation time", fmt.Sprintf("%v per second", t.quota.EvalTime))
+	}
+	t.mu.Unlock()
+
+	start := f.s.now()
+	vs, err := sift.Safe(f.prog.Filter(&jq.Host{Context: ctx}))(v)
+	end := f.s.now()
+	elapsed := end.Sub(start)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.quota.EvalTime > 0 {
+		// Evaluations may overdraw the budget; later evaluations wait for it
+		// to be repaid.
+		t.evalTime.Spend(end, elapsed.Seconds())
+	}
+	t.metrics.Evals++
+	t.metrics.EvalTime += elapsed
+	if err != nil {
+		t.metrics.EvalErrors++
+	}
+	return vs, err
+}
+
+// SiftFilter returns a sift.Filter that evaluates f with
+// context.Background.
+func (f *Filter) SiftFilter() sift.Filter {
+	return func(v sift.Value) ([]sift.Value, error) {
+		return f.Eval(context.Background(), v)
+	}
+}
+
+// Close removes the filter from its tenant's filters, releasing its quota.
+// Evaluating a closed filter fails with ErrClosed. Closing a filter more
+// than once has no effect.
+func (f *Filter) Close() {
+	f.t.mu.Lock()
+	defer f.t.mu.Unlock()
+	if f.closed {
+		return
+	}
+	delete(f.t.filters, f.name)
+	f.t.bytes -= len(f.src)
+	f.closeLocked()
+}
+
+func (f *Filter) closeLocked() {
+	f.closed = true
+	f.t.metrics.Filters, f.t.metrics.ProgramBytes = len(f.t.filters), f.t.bytes
+}
@@ -0,0 +1,166 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/filter/jq"
+	"go.jayconrod.com/sift/service"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestCompileQuota(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	s := service.New(service.Options{
+		Quota: service.Quota{CompileRate: 1, CompileBurst: 2, MaxFilters: 2, MaxProgramBytes: 10},
+		Quotas: map[string]service.Quota{
+			"big": {},
+		},
+		Now: clock.now,
+	})
+
+	for _, tc := range []struct {
+		desc, tenant, name, src string
+		advance                 time.Duration
+		wantErr                 error
+	}{
+		{desc: "first", tenant: "a", name: "f", src: ".a"},
+		{desc: "burst", tenant: "a", name: "g", src: ".b"},
+		{desc: "rate", tenant: "a", name: "h", src: ".c", wantErr: service.ErrQuotaExceeded},
+		{desc: "other_tenant", tenant: "b", name: "f", src: ".a"},
+		// A compile rejected for its size doesn't count against the rate.
+		{desc: "rejected_size", tenant: "c", name: "f", src: ".[0,1,2,3,4]", wantErr: service.ErrQuotaExceeded},
+		{desc: "after_rejected_first", tenant: "c", name: "f", src: ".a"},
+		{desc: "after_rejected_burst", tenant: "c", name: "g", src: ".b"},
+		{desc: "max_filters", tenant: "a", name: "h", src: ".c", advance: time.Second, wantErr: service.ErrQuotaExceeded},
+		{desc: "replace", tenant: "a", name: "g", src: ".d", advance: time.Second},
+		{desc: "max_bytes", tenant: "a", name: "g", src: ".[0,1,2,3,4]", advance: time.Second, wantErr: service.ErrQuotaExceeded},
+		{desc: "unlimited", tenant: "big", name: "g", src: ".[0,1,2,3,4]"},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			clock.advance(tc.advance)
+			f, err := s.Compile(tc.tenant, tc.name, tc.src)
+			if !errors.Is(err, tc.wantErr) || (err != nil && tc.wantErr == nil) {
+				t.Fatalf("got error %v; want %v", err, tc.wantErr)
+			}
+			if err == nil && f.Name() != tc.name {
+				t.Errorf("got name %q; want %q", f.Name(), tc.name)
+			}
+		})
+	}
+
+	m := s.Metrics("a")
+	want := service.Metrics{Compiles: 3, Rejected: 3, Filters: 2, ProgramBytes: 4}
+	m.CompileTime = 0
+	if m != want {
+		t.Errorf("got metrics %+v; want %+v", m, want)
+	}
+	if got := s.Tenants(); len(got) != 4 || got[0] != "a" || got[1] != "b" || got[2] != "big" || got[3] != "c" {
+		t.Errorf("got tenants %v; want [a b big c]", got)
+	}
+
+	// Closing a filter releases its quota.
+	f, ok := s.Lookup("a", "f")
+	if !ok {
+		t.Fatal("filter f not found")
+	}
+	f.Close()
+	f.Close()
+	if _, err := f.Eval(context.Background(), sift.NullValue); !errors.Is(err, service.ErrClosed) {
+		t.Errorf("evaluating closed filter: got error %v; want %v", err, service.ErrClosed)
+	}
+	clock.advance(time.Second)
+	if _, err := s.Compile("a", "h", ".c"); err != nil {
+		t.Errorf("compiling after close: %v", err)
+	}
+	if _, ok := s.Lookup("a", "f"); ok {
+		t.Error("closed filter f was found")
+	}
+}
+
+func TestEvalQuota(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	opts := jq.CompileOptions{
+		Funcs: map[string]jq.HostFunc{
+			// work pretends to take as many milliseconds as its input.
+			"work/0": func(_ context.Context, input sift.Value, _ []sift.Value) ([]sift.Value, error) {
+				ms, _ := sift.AsFloat64(input)
+				clock.advance(time.Duration(ms) * time.Millisecond)
+				return []sift.Value{input}, nil
+			},
+		},
+	}
+	s := service.New(service.Options{
+		Quota:          service.Quota{EvalTime: 500 * time.Millisecond, MaxOutputs: 2},
+		CompileOptions: opts,
+		Now:            clock.now,
+	})
+	f, err := s.Compile("a", "work", "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	many, err := s.Compile("a", "many", ".[]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		desc    string
+		f       *service.Filter
+		in      interface{}
+		advance time.Duration
+		wantErr error
+	}{
+		{desc: "first", f: f, in: 400},
+		{desc: "overdraw", f: f, in: 400},
+		{desc: "exhausted", f: f, in: 1, wantErr: service.ErrQuotaExceeded},
+		{desc: "refilled", f: f, in: 1, advance: 400 * time.Millisecond},
+		{desc: "max_outputs", f: many, in: []interface{}{1, 2, 3}, advance: time.Second, wantErr: jq.ErrTooManyOutputs},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			clock.advance(tc.advance)
+			_, err := tc.f.Eval(context.Background(), sift.Must(sift.ToValue(tc.in)))
+			if !errors.Is(err, tc.wantErr) || (err != nil && tc.wantErr == nil) {
+				t.Fatalf("got error %v; want %v", err, tc.wantErr)
+			}
+		})
+	}
+
+	m := s.Metrics("a")
+	want := service.Metrics{Compiles: 2, Evals: 4, EvalErrors: 1, EvalTime: 801 * time.Millisecond, Rejected: 1, Filters: 2, ProgramBytes: 7}
+	if m != want {
+		t.Errorf("got metrics %+v; want %+v", m, want)
+	}
+}
+
+func TestEvalPanic(t *testing.T) {
+	opts := jq.CompileOptions{
+		Funcs: map[string]jq.HostFunc{
+			"crash/0": func(context.Context, sift.Value, []sift.Value) ([]sift.Value, error) {
+				panic("crash")
+			},
+		},
+	}
+	s := service.New(service.Options{CompileOptions: opts})
+	f, err := s.Compile("a", "crash", "crash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.Eval(context.Background(), sift.NullValue)
+	var perr *sift.PanicError
+	if !errors.As(err, &perr) {
+		t.Fatalf("got error %v; want *sift.PanicError", err)
+	}
+	if m := s.Metrics("a"); m.Evals != 1 || m.EvalErrors != 1 {
+		t.Errorf("got %d evals, %d errors; want 1, 1", m.Evals, m.EvalErrors)
+	}
+}
@@ -0,0 +1,113 @@
+package sift
+
+import "sort"
+
+// Set is an array of distinct values. Sets are hash-backed, so membership
+// tests and set operations take time proportional to the number of
+// elements involved, rather than comparing every pair of elements. Values
+// are distinct if they're not Equal.
+//
+// A Set is an Index whose elements are sorted by Compare, so it's encoded
+// as an array and is Equal to an array with the same elements in order.
+// Sets are immutable; operations return new sets.
+type Set struct {
+	elems   []Value
+	buckets map[uint64][]Value
+}
+
+var _ Index = (*Set)(nil)
+
+// NewSet returns a set of the given values. Duplicates are ignored.
+func NewSet(vs ...Value) *Set {
+	s := &Set{buckets: make(map[uint64][]Value, len(vs))}
+	for _, v := range vs {
+		s.add(v)
+	}
+	s.sort()
+	return s
+}
+
+// add adds v to the set if it's not already present. The caller must call
+// sort after adding elements.
+func (s *Set) add(v Value) {
+	if v == nil {
+		v = NullValue
+	}
+	h := Hash(v)
+	for _, e := range s.buckets[h] {
+		if Equal(e, v) {
+			return
+		}
+	}
+	s.buckets[h] = append(s.buckets[h], v)
+	s.elems = append(s.elems, v)
+}
+
+func (s *Set) sort() {
+	sort.SliceStable(s.elems, func(i, j int) bool {
+		return Compare(s.elems[i], s.elems[j]) < 0
+	})
+}
+
+func (s *Set) Truth() bool { return true }
+
+func (s *Set) Length() int { return len(s.elems) }
+
+func (s *Set) Index(i int) (Value, bool) {
+	if i < 0 || i >= len(s.elems) {
+		return nil, false
+	}
+	return s.elems[i], true
+}
+
+// Has returns whether the set has an element Equal to v.
+func (s *Set) Has(v Value) bool {
+	if v == nil {
+		v = NullValue
+	}
+	for _, e := range s.buckets[Hash(v)] {
+		if Equal(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Union returns a set of the elements in s, t, or both.
+func (s *Set) Union(t *Set) *Set {
+	u := &Set{buckets: make(map[uint64][]Value, len(s.elems)+len(t.elems))}
+	for _, src := range []*Set{s, t} {
+		for _, e := range src.elems {
+			u.add(e)
+		}
+	}
+	u.sort()
+	return u
+}
+
+// Intersect returns a set of the elements in both s and t.
+func (s *Set) Intersect(t *Set) *Set {
+	if len(t.elems) < len(s.elems) {
+		s, t = t, s
+	}
+	u := &Set{buckets: make(map[uint64][]Value)}
+	for _, e := range s.elems {
+		if t.Has(e) {
+			u.add(e)
+		}
+	}
+	u.sort()
+	return u
+}
+
+// Difference returns a set of the elements in s that are not in t.
+func (s *Set) Difference(t *Set) *Set {
+	u := &Set{buckets: make(map[uint64][]Value)}
+	for _, e := range s.elems {
+		if !t.Has(e) {
+			u.add(e)
+		}
+	}
+	u.sort()
+	return u
+}
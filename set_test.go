@@ -0,0 +1,53 @@
+package sift_test
+
+import (
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestSet(t *testing.T) {
+	values := func(s string) []sift.Value {
+		ix, err := sift.Collect(decodeJSON(t, s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		vs := make([]sift.Value, ix.Length())
+		for i := range vs {
+			vs[i], _ = ix.Index(i)
+		}
+		return vs
+	}
+	a := sift.NewSet(values(`[3, "x", 1, {"a": [1]}, 1.0, null, "x"]`)...)
+	b := sift.NewSet(values(`[{"a": [1]}, 2, 3]`)...)
+
+	for _, tc := range []struct {
+		desc string
+		got  sift.Value
+		want string
+	}{
+		{"new", a, `[null,1,3,"x",{"a":[1]}]`},
+		{"union", a.Union(b), `[null,1,2,3,"x",{"a":[1]}]`},
+		{"intersect", a.Intersect(b), `[3,{"a":[1]}]`},
+		{"difference", a.Difference(b), `[null,1,"x"]`},
+		{"map", sift.Must(sift.ToValue(map[int]struct{}{2: {}, 1: {}})), `[1,2]`},
+	} {
+		if got := encodeJSON(t, tc.got); got != tc.want {
+			t.Errorf("%s: got %s; want %s", tc.desc, got, tc.want)
+		}
+		if !sift.Equal(tc.got, decodeJSON(t, tc.want)) {
+			t.Errorf("%s: not Equal to %s", tc.desc, tc.want)
+		}
+	}
+
+	for _, v := range values(`[1, 1.0, "x", {"a": [1]}, null]`) {
+		if !a.Has(v) {
+			t.Errorf("Has(%v): got false; want true", v)
+		}
+	}
+	for _, v := range values(`[2, "1", {"a": []}, [null], false]`) {
+		if a.Has(v) {
+			t.Errorf("Has(%v): got true; want false", v)
+		}
+	}
+}
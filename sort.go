@@ -0,0 +1,53 @@
+package sift
+
+import "sort"
+
+// Sort returns a new array with the elements of ix sorted by Compare.
+// Missing elements are sorted as null. ix is not modified.
+func Sort(ix Index) Index {
+	out := indexElems(ix)
+	sort.SliceStable(out, func(i, j int) bool {
+		return Compare(out[i], out[j]) < 0
+	})
+	return out
+}
+
+// SortBy returns a new array with the elements of ix sorted by the keys
+// that key returns for them, compared with Compare. key is called once for
+// each element. The sort is stable, so elements with equal keys keep their
+// order. If key returns an error, SortBy returns it. ix is not modified.
+func SortBy(ix Index, key func(Value) (Value, error)) (Index, error) {
+	elems := indexElems(ix)
+	keys := make([]Value, len(elems))
+	for i, e := range elems {
+		k, err := key(e)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = k
+	}
+	sort.Stable(byKey{elems: elems, keys: keys})
+	return elems, nil
+}
+
+// indexElems copies the elements of ix into a new array. Missing elements
+// are null.
+func indexElems(ix Index) indexType {
+	elems := make(indexType, ix.Length())
+	for i := range elems {
+		elems[i] = indexOrNull(ix, i)
+	}
+	return elems
+}
+
+type byKey struct {
+	elems, keys []Value
+}
+
+func (s byKey) Len() int           { return len(s.elems) }
+func (s byKey) Less(i, j int) bool { return Compare(s.keys[i], s.keys[j]) < 0 }
+
+func (s byKey) Swap(i, j int) {
+	s.elems[i], s.elems[j] = s.elems[j], s.elems[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
@@ -0,0 +1,39 @@
+package sift_test
+
+import (
+	"errors"
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestSort(t *testing.T) {
+	in := decodeJSON(t, `[{"n": 2, "s": "b"}, {"n": 1, "s": "a"}, {"n": 2, "s": "a"}, 0]`).(sift.Index)
+	if got, want := encodeJSON(t, sift.Sort(in)), `[0,{"n":1,"s":"a"},{"n":2,"s":"a"},{"n":2,"s":"b"}]`; got != want {
+		t.Errorf("Sort: got %s; want %s", got, want)
+	}
+
+	byN := func(v sift.Value) (sift.Value, error) {
+		n, ok := sift.GetStringAttr(v, "n")
+		if !ok {
+			return sift.NullValue, nil
+		}
+		return n, nil
+	}
+	got, err := sift.SortBy(in, byN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sorting is stable: elements with equal keys keep their order.
+	if got, want := encodeJSON(t, got), `[0,{"n":1,"s":"a"},{"n":2,"s":"b"},{"n":2,"s":"a"}]`; got != want {
+		t.Errorf("SortBy: got %s; want %s", got, want)
+	}
+	if got, want := encodeJSON(t, in), `[{"n":2,"s":"b"},{"n":1,"s":"a"},{"n":2,"s":"a"},0]`; got != want {
+		t.Errorf("input was modified: %s", got)
+	}
+
+	errKey := errors.New("no key")
+	if _, err := sift.SortBy(in, func(sift.Value) (sift.Value, error) { return nil, errKey }); err != errKey {
+		t.Errorf("SortBy: got error %v; want %v", err, errKey)
+	}
+}
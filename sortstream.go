@@ -0,0 +1,191 @@
+package sift
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// SortStreamOptions controls how SortStream buffers values.
+type SortStreamOptions struct {
+	// MaxValues is the number of values SortStream holds in memory. When
+	// more values are written, the buffered values are sorted and spilled
+	// to a temporary file, and the files are merged when the stream is
+	// flushed. If MaxValues is zero or negative, all values are held in
+	// memory.
+	MaxValues int
+
+	// Dir is the directory temporary files are created in. If Dir is
+	// empty, os.TempDir is used.
+	Dir string
+
+	// NewEncoder and NewDecoder write and read values in temporary files.
+	// They must be set if MaxValues is positive. The values they read must
+	// have the same keys as the values they wrote.
+	NewEncoder func(io.Writer) Encoder
+	NewDecoder func(io.Reader) Decoder
+}
+
+// SortStream returns middleware that sorts all the values written to it by
+// the keys key produces for them, compared with Compare. key must produce
+// exactly one value for each value. Values are held until the returned
+// Encoder is flushed, then they're written in order. The sort is stable,
+// so values with equal keys are written in the order they were received.
+// Sift flushes its encoder after the last value, so the whole stream
+// is sorted.
+//
+// To sort streams larger than memory, set opts.MaxValues and provide
+// a format for temporary files.
+func SortStream(key Filter, opts SortStreamOptions) EncoderMiddleware {
+	return func(enc Encoder) Encoder {
+		return &sortEncoder{next: enc, key: key, opts: opts}
+	}
+}
+
+type sortEncoder struct {
+	next Encoder
+	key  Filter
+	opts SortStreamOptions
+	pos  int64
+	buf  byKey
+	runs []*os.File
+}
+
+var _ Flusher = (*sortEncoder)(nil)
+
+func (e *sortEncoder) ByteCount() int64 {
+	return byteCount(e.next)
+}
+
+func (e *sortEncoder) Encode(v Value) error {
+	k, err := assertionKey(e.key, v, e.pos)
+	if err != nil {
+		return err
+	}
+	e.pos++
+	e.buf.elems = append(e.buf.elems, v)
+	e.buf.keys = append(e.buf.keys, k)
+	if e.opts.MaxValues > 0 && len(e.buf.elems) >= e.opts.MaxValues {
+		return e.spill()
+	}
+	return nil
+}
+
+// spill sorts the buffered values and writes them to a new temporary file.
+func (e *sortEncoder) spill() (err error) {
+	if e.opts.NewEncoder == nil || e.opts.NewDecoder == nil {
+		return errors.New("sorting stream: NewEncoder and NewDecoder must be set to spill values to disk")
+	}
+	f, err := os.CreateTemp(e.opts.Dir, "sift-sort-*")
+	if err != nil {
+		return fmt.Errorf("sorting stream: %w", err)
+	}
+	e.runs = append(e.runs, f)
+	sort.Stable(e.buf)
+	enc := e.opts.NewEncoder(f)
+	for _, v := range e.buf.elems {
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("sorting stream: %w", err)
+		}
+	}
+	if fl, ok := enc.(Flusher); ok {
+		if err := fl.Flush(); err != nil {
+			return fmt.Errorf("sorting stream: %w", err)
+		}
+	}
+	e.buf = byKey{}
+	return nil
+}
+
+func (e *sortEncoder) Flush() error {
+	err := e.writeSorted()
+	for _, f := range e.runs {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	e.runs = nil
+	e.buf = byKey{}
+	e.pos = 0
+	if err != nil {
+		return err
+	}
+	if f, ok := e.next.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// writeSorted writes the buffered values, merged with the spilled runs.
+func (e *sortEncoder) writeSorted() error {
+	sort.Stable(e.buf)
+	if len(e.runs) == 0 {
+		for _, v := range e.buf.elems {
+			if err := e.next.Encode(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Merge the runs. Runs were spilled in order, and the buffer holds the
+	// latest values, so on ties, the earliest run wins to keep the sort
+	// stable.
+	var pos int64
+	heads := make([]sortRun, 0, len(e.runs)+1)
+	for _, f := range e.runs {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("sorting stream: %w", err)
+		}
+		r := sortRun{dec: e.opts.NewDecoder(f)}
+		heads = append(heads, r)
+	}
+	heads = append(heads, sortRun{dec: &indexDecoder{ix: indexType(e.buf.elems), n: len(e.buf.elems)}})
+	for i := range heads {
+		if err := heads[i].next(e.key, &pos); err != nil {
+			return err
+		}
+	}
+	for {
+		min := -1
+		for i, r := range heads {
+			if r.v != nil && (min < 0 || Compare(r.k, heads[min].k) < 0) {
+				min = i
+			}
+		}
+		if min < 0 {
+			return nil
+		}
+		if err := e.next.Encode(heads[min].v); err != nil {
+			return err
+		}
+		if err := heads[min].next(e.key, &pos); err != nil {
+			return err
+		}
+	}
+}
+
+// A sortRun is a sorted sequence of values being merged. v and k are the
+// next value and its key, or nil if the run is exhausted.
+type sortRun struct {
+	dec  Decoder
+	v, k Value
+}
+
+func (r *sortRun) next(key Filter, pos *int64) error {
+	v, err := r.dec.Decode()
+	if err == io.EOF {
+		r.v, r.k = nil, nil
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("sorting stream: %w", err)
+	}
+	k, err := assertionKey(key, v, *pos)
+	if err != nil {
+		return err
+	}
+	*pos++
+	r.v, r.k = v, k
+	return nil
+}
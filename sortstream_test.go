@@ -0,0 +1,61 @@
+package sift_test
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"go.jayconrod.com/sift"
+	"go.jayconrod.com/sift/encoding/json"
+)
+
+func TestSortStream(t *testing.T) {
+	key := sift.MapError(func(v sift.Value) (sift.Value, error) {
+		k, _ := v.(sift.Attr).Attr(sift.Must(sift.ToValue("k")))
+		return k, nil
+	})
+	var input, want []interface{}
+	for i := 0; i < 20; i++ {
+		// Keys repeat, so the sort must be stable to produce want.
+		input = append(input, map[string]interface{}{"k": (i * 7) % 5, "i": i})
+	}
+	for k := 0; k < 5; k++ {
+		for i := 0; i < 20; i++ {
+			if (i*7)%5 == k {
+				want = append(want, map[string]interface{}{"k": k, "i": i})
+			}
+		}
+	}
+
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		desc string
+		opts sift.SortStreamOptions
+	}{
+		{desc: "memory"},
+		{
+			desc: "spill",
+			opts: sift.SortStreamOptions{
+				MaxValues:  3,
+				Dir:        dir,
+				NewEncoder: func(w io.Writer) sift.Encoder { return json.NewEncoder(w) },
+				NewDecoder: func(r io.Reader) sift.Decoder { return json.NewDecoder(r) },
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dec, _ := sift.Iterate(sift.Must(sift.ToValue(input)))
+			rec := &recordEncoder{}
+			enc := sift.WrapEncoder(rec, sift.SortStream(key, tc.opts))
+			if err := sift.Sift(dec, sift.Map(func(v sift.Value) sift.Value { return v }), enc); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := sift.Must(sift.ToValue(rec.values)), sift.Must(sift.ToValue(want)); !sift.Equal(got, want) {
+				t.Errorf("got %v; want %v", got, want)
+			}
+			if files, _ := os.ReadDir(dir); len(files) != 0 {
+				t.Errorf("got %d temporary files left; want 0", len(files))
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package sift
+
+import "io"
+
+// A StreamFilter transforms a whole stream of values, rather than each value
+// independently like a Filter. A StreamFilter may keep state between values
+// and produce values after the last one, so it can compute aggregates like
+// counts and sums over an entire stream.
+//
+// Begin is called once before any values, Process is called for each value
+// in order, and End is called once after the last value. Begin resets any
+// state, so a StreamFilter may be used for several streams, one at a time.
+type StreamFilter interface {
+	Begin() error
+	Process(v Value) ([]Value, error)
+	End() ([]Value, error)
+}
+
+// SiftStream is like Sift, but it transforms values with a StreamFilter.
+// Values produced by f.End are encoded after the last value is processed.
+func SiftStream(dec Decoder, f StreamFilter, enc Encoder) error {
+	if err := f.Begin(); err != nil {
+		return err
+	}
+	encode := func(vs []Value) error {
+		for _, v := range vs {
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for {
+		vin, err := dec.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		vouts, err := f.Process(vin)
+		if err != nil {
+			return err
+		}
+		if err := encode(vouts); err != nil {
+			return err
+		}
+	}
+	vouts, err := f.End()
+	if err != nil {
+		return err
+	}
+	if err := encode(vouts); err != nil {
+		return err
+	}
+	if fl, ok := enc.(Flusher); ok {
+		return fl.Flush()
+	}
+	return nil
+}
+
+// Stateless returns a StreamFilter that applies f to each value
+// independently.
+func Stateless(f Filter) StreamFilter {
+	return &funcStreamFilter{process: f}
+}
+
+// ComposeStream returns a StreamFilter that passes values produced by f,
+// including those produced by f.End, to g.
+func ComposeStream(f, g StreamFilter) StreamFilter {
+	return composeStreamFilter{f: f, g: g}
+}
+
+type composeStreamFilter struct {
+	f, g StreamFilter
+}
+
+func (c composeStreamFilter) Begin() error {
+	if err := c.f.Begin(); err != nil {
+		return err
+	}
+	return c.g.Begin()
+}
+
+func (c composeStreamFilter) Process(v Value) ([]Value, error) {
+	fvs, err := c.f.Process(v)
+	if err != nil {
+		return nil, err
+	}
+	return c.processAll(fvs)
+}
+
+func (c composeStreamFilter) End() ([]Value, error) {
+	fvs, err := c.f.End()
+	if err != nil {
+		return nil, err
+	}
+	outs, err := c.processAll(fvs)
+	if err != nil {
+		return nil, err
+	}
+	gvs, err := c.g.End()
+	if err != nil {
+		return nil, err
+	}
+	return append(outs, gvs...), nil
+}
+
+func (c composeStreamFilter) processAll(fvs []Value) ([]Value, error) {
+	var outs []Value
+	for _, fv := range fvs {
+		gvs, err := c.g.Process(fv)
+		if err != nil {
+			return nil, err
+		}
+		outs = append(outs, gvs...)
+	}
+	return outs, nil
+}
+
+// Count returns a StreamFilter that produces nothing for each value and
+// produces the number of values at the end of the stream.
+func Count() StreamFilter {
+	var n float64
+	return &funcStreamFilter{
+		begin: func() { n = 0 },
+		process: func(Value) ([]Value, error) {
+			n++
+			return nil, nil
+		},
+		end: func() ([]Value, error) {
+			return []Value{floatValue(n)}, nil
+		},
+	}
+}
+
+// Fold returns a StreamFilter that folds all the values in a stream into
+// a single result, like ReduceStream, and produces the result at the end of
+// the stream. For example, Fold may compute the sum of a stream of numbers.
+func Fold(init Value, step func(acc, v Value) (Value, error)) StreamFilter {
+	var acc Value
+	return &funcStreamFilter{
+		begin: func() { acc = init },
+		process: func(v Value) ([]Value, error) {
+			var err error
+			acc, err = step(acc, v)
+			return nil, err
+		},
+		end: func() ([]Value, error) {
+			return []Value{acc}, nil
+		},
+	}
+}
+
+// Slurp returns a StreamFilter that collects all the values in a stream
+// into an array and produces the array at the end of the stream.
+func Slurp() StreamFilter {
+	var b *ArrayBuilder
+	return &funcStreamFilter{
+		begin: func() { b = NewArray(0) },
+		process: func(v Value) ([]Value, error) {
+			b.Append(v)
+			return nil, nil
+		},
+		end: func() ([]Value, error) {
+			return []Value{b.Build()}, nil
+		},
+	}
+}
+
+// funcStreamFilter is a StreamFilter implemented with functions. Nil
+// functions do nothing.
+type funcStreamFilter struct {
+	begin   func()
+	process Filter
+	end     func() ([]Value, error)
+}
+
+func (f *funcStreamFilter) Begin() error {
+	if f.begin != nil {
+		f.begin()
+	}
+	return nil
+}
+
+func (f *funcStreamFilter) Process(v Value) ([]Value, error) {
+	if f.process == nil {
+		return nil, nil
+	}
+	return f.process(v)
+}
+
+func (f *funcStreamFilter) End() ([]Value, error) {
+	if f.end == nil {
+		return nil, nil
+	}
+	return f.end()
+}
@@ -0,0 +1,74 @@
+package sift_test
+
+import (
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestSiftStream(t *testing.T) {
+	double := sift.Map(func(v sift.Value) sift.Value {
+		n, _ := sift.AsFloat64(v)
+		return sift.Must(sift.ToValue(2 * n))
+	})
+	dup := func(v sift.Value) ([]sift.Value, error) { return []sift.Value{v, v}, nil }
+
+	for _, tc := range []struct {
+		desc  string
+		f     sift.StreamFilter
+		input []interface{}
+		want  []interface{}
+	}{
+		{
+			desc:  "stateless",
+			f:     sift.Stateless(double),
+			input: []interface{}{1, 2},
+			want:  []interface{}{2, 4},
+		}, {
+			desc:  "count",
+			f:     sift.Count(),
+			input: []interface{}{"a", "b", "c"},
+			want:  []interface{}{3},
+		}, {
+			desc:  "count_empty",
+			f:     sift.Count(),
+			input: []interface{}{},
+			want:  []interface{}{0},
+		}, {
+			desc:  "fold",
+			f:     sift.Fold(sift.Must(sift.ToValue(0)), sumValues),
+			input: []interface{}{1, 2, 3},
+			want:  []interface{}{6},
+		}, {
+			desc:  "slurp",
+			f:     sift.Slurp(),
+			input: []interface{}{1, "a"},
+			want:  []interface{}{[]interface{}{1, "a"}},
+		}, {
+			desc:  "compose",
+			f:     sift.ComposeStream(sift.Slurp(), sift.Stateless(dup)),
+			input: []interface{}{1, 2},
+			want:  []interface{}{[]interface{}{1, 2}, []interface{}{1, 2}},
+		}, {
+			desc:  "compose_aggregate",
+			f:     sift.ComposeStream(sift.Stateless(double), sift.Fold(sift.Must(sift.ToValue(0)), sumValues)),
+			input: []interface{}{1, 2, 3},
+			want:  []interface{}{12},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			// Run twice to check that Begin resets state.
+			for i := 0; i < 2; i++ {
+				dec, _ := sift.Iterate(sift.Must(sift.ToValue(tc.input)))
+				enc := &recordEncoder{}
+				if err := sift.SiftStream(dec, tc.f, enc); err != nil {
+					t.Fatal(err)
+				}
+				want := sift.Must(sift.ToValue(tc.want))
+				if got := sift.Must(sift.ToValue(enc.values)); !sift.Equal(got, want) {
+					t.Errorf("got %v; want %v", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package sift
+
+import "io"
+
+// TakeFirst returns a Decoder that produces the first n values from dec,
+// then returns io.EOF without reading more, like the head command. When
+// used with Sift, decoding stops as soon as n values have been read.
+func TakeFirst(dec Decoder, n int) Decoder {
+	return &takeDecoder{dec: dec, n: n}
+}
+
+type takeDecoder struct {
+	dec Decoder
+	n   int
+}
+
+func (d *takeDecoder) Decode() (Value, error) {
+	if d.n <= 0 {
+		return nil, io.EOF
+	}
+	v, err := d.dec.Decode()
+	if err == nil {
+		d.n--
+	}
+	return v, err
+}
+
+// Skip returns a Decoder that discards the first n values from dec, then
+// produces the rest. Errors decoding the discarded values are returned.
+func Skip(dec Decoder, n int) Decoder {
+	return &skipDecoder{dec: dec, n: n}
+}
+
+type skipDecoder struct {
+	dec Decoder
+	n   int
+}
+
+func (d *skipDecoder) Decode() (Value, error) {
+	for ; d.n > 0; d.n-- {
+		if _, err := d.dec.Decode(); err != nil {
+			return nil, err
+		}
+	}
+	return d.dec.Decode()
+}
+
+// TakeWhile returns a Decoder that produces values from dec as long as pred
+// returns true for them. At the first value for which pred returns false,
+// the Decoder stops reading from dec and returns io.EOF from then on. If
+// pred returns an error, Decode returns it.
+func TakeWhile(dec Decoder, pred func(Value) (bool, error)) Decoder {
+	return &takeWhileDecoder{dec: dec, pred: pred}
+}
+
+type takeWhileDecoder struct {
+	dec  Decoder
+	pred func(Value) (bool, error)
+	done bool
+}
+
+func (d *takeWhileDecoder) Decode() (Value, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+	v, err := d.dec.Decode()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := d.pred(v)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		d.done = true
+		return nil, io.EOF
+	}
+	return v, nil
+}
@@ -0,0 +1,79 @@
+package sift_test
+
+import (
+	"io"
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+// countingDecoder produces the numbers 0, 1, 2, ... without end, recording
+// how many it has produced.
+type countingDecoder struct {
+	n int
+}
+
+func (d *countingDecoder) Decode() (sift.Value, error) {
+	v, err := sift.ToValue(d.n)
+	d.n++
+	return v, err
+}
+
+func TestTake(t *testing.T) {
+	lessThan := func(max float64) func(sift.Value) (bool, error) {
+		return func(v sift.Value) (bool, error) {
+			n, _ := sift.AsFloat64(v)
+			return n < max, nil
+		}
+	}
+
+	for _, tc := range []struct {
+		desc      string
+		dec       func(sift.Decoder) sift.Decoder
+		want      []interface{}
+		wantReads int
+	}{
+		{
+			desc:      "take_first",
+			dec:       func(d sift.Decoder) sift.Decoder { return sift.TakeFirst(d, 3) },
+			want:      []interface{}{0, 1, 2},
+			wantReads: 3,
+		}, {
+			desc:      "take_zero",
+			dec:       func(d sift.Decoder) sift.Decoder { return sift.TakeFirst(d, 0) },
+			wantReads: 0,
+		}, {
+			desc:      "skip",
+			dec:       func(d sift.Decoder) sift.Decoder { return sift.TakeFirst(sift.Skip(d, 2), 2) },
+			want:      []interface{}{2, 3},
+			wantReads: 4,
+		}, {
+			desc:      "take_while",
+			dec:       func(d sift.Decoder) sift.Decoder { return sift.TakeWhile(d, lessThan(2)) },
+			want:      []interface{}{0, 1},
+			wantReads: 3,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			src := &countingDecoder{}
+			dec := tc.dec(src)
+			var got []sift.Value
+			for {
+				v, err := dec.Decode()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, v)
+			}
+			want := sift.Must(sift.ToValue(tc.want))
+			if !sift.Equal(sift.Must(sift.ToValue(got)), want) {
+				t.Errorf("got %v; want %v", got, want)
+			}
+			if src.n != tc.wantReads {
+				t.Errorf("read %d values; want %d", src.n, tc.wantReads)
+			}
+		})
+	}
+}
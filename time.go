@@ -0,0 +1,38 @@
+package sift
+
+import "time"
+
+// Time is implemented by timestamps. Decoders for formats with a native time
+// type, like YAML, CBOR, and database rows, may decode timestamps as Time,
+// so filters and encoders can treat them as times rather than strings.
+// Encodings without a time type, like JSON, write Time values as RFC 3339
+// strings.
+//
+// Times are equal if they represent the same instant, regardless of their
+// locations. Times sort chronologically, after numbers and before strings.
+type Time interface {
+	Value
+
+	// IsTime returns whether the value is a timestamp.
+	IsTime() bool
+
+	// Time returns the time this value represents.
+	Time() time.Time
+}
+
+// AsTime returns a time and true if v implements Time. Otherwise, the zero
+// time and false are returned.
+func AsTime(v Value) (time.Time, bool) {
+	if t, ok := v.(Time); ok && t.IsTime() {
+		return t.Time(), true
+	}
+	return time.Time{}, false
+}
+
+type timeType time.Time
+
+var _ Time = timeType{}
+
+func (t timeType) Truth() bool     { return true }
+func (t timeType) IsTime() bool    { return true }
+func (t timeType) Time() time.Time { return time.Time(t) }
@@ -0,0 +1,38 @@
+package sift_test
+
+import (
+	"testing"
+	"time"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestTime(t *testing.T) {
+	utc := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	v := sift.Must(sift.ToValue(utc))
+	if got, ok := sift.AsTime(v); !ok || !got.Equal(utc) {
+		t.Errorf("AsTime: got %v, %v; want %v, true", got, ok, utc)
+	}
+	if _, ok := sift.AsTime(sift.Must(sift.ToValue(utc.Format(time.RFC3339)))); ok {
+		t.Error("AsTime(string): got true; want false")
+	}
+
+	for _, tc := range []struct {
+		desc  string
+		l, r  interface{}
+		equal bool
+	}{
+		{desc: "same", l: utc, r: utc, equal: true},
+		{desc: "zone", l: utc, r: utc.In(time.FixedZone("", -5*60*60)), equal: true},
+		{desc: "different", l: utc, r: utc.Add(time.Nanosecond)},
+		{desc: "string", l: utc, r: utc.Format(time.RFC3339)},
+		{desc: "string_reversed", l: utc.Format(time.RFC3339), r: utc},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			l, r := sift.Must(sift.ToValue(tc.l)), sift.Must(sift.ToValue(tc.r))
+			if got := sift.Equal(l, r); got != tc.equal {
+				t.Errorf("Equal(%v, %v) = %v; want %v", l, r, got, tc.equal)
+			}
+		})
+	}
+}
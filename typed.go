@@ -0,0 +1,99 @@
+package sift
+
+// Typed returns a function that applies f to Go values of type T and
+// converts the results back to T. This lets programs apply filters to
+// their own structs without handling Values directly.
+//
+// Inputs are converted with ToValue and outputs with FromValue, so "sift"
+// and "json" struct tags are respected as they are by MapT. An error is
+// returned if an input cannot be represented as a Value or if an output
+// cannot be stored in a T.
+func Typed[T any](f Filter) func(T) ([]T, error) {
+	return func(in T) ([]T, error) {
+		v, err := ToValue(in)
+		if err != nil {
+			return nil, err
+		}
+		vs, err := f(v)
+		if err != nil {
+			return nil, err
+		}
+		outs := make([]T, len(vs))
+		for i, v := range vs {
+			if err := FromValue(v, &outs[i]); err != nil {
+				return nil, err
+			}
+		}
+		return outs, nil
+	}
+}
+
+// ValueOf converts x to a Value with ToValue. It's equivalent to ToValue,
+// but the argument's type is checked at compile time, which helps when
+// passing typed values through generic code.
+func ValueOf[T any](x T) (Value, error) {
+	return ToValue(x)
+}
+
+// As returns v as a T and true if v may be stored in a T, following the
+// rules of FromValue. For example, As[string] returns a string's value,
+// and As[[]int] returns an array of integers. If v can't be stored in a T,
+// As returns the zero value and false.
+func As[T any](v Value) (T, bool) {
+	var x T
+	switch p := interface{}(&x).(type) {
+	case *string:
+		s, ok := AsString(v)
+		*p = s
+		return x, ok
+	case *float64:
+		f, ok := AsFloat64(v)
+		*p = f
+		return x, ok
+	case *bool:
+		b, ok := AsBool(v)
+		*p = b
+		return x, ok
+	}
+	if err := FromValue(v, &x); err != nil {
+		var zero T
+		return zero, false
+	}
+	return x, true
+}
+
+// MapT returns a Filter that converts each input to a T with FromValue,
+// calls f, and converts f's result back to a Value with ToValue. Unlike
+// Typed, the input and output types may differ. An error is returned if an
+// input can't be stored in a T or if f's result can't be represented as a
+// Value.
+func MapT[T, U any](f func(T) (U, error)) Filter {
+	return func(v Value) ([]Value, error) {
+		var x T
+		if err := FromValue(v, &x); err != nil {
+			return nil, err
+		}
+		y, err := f(x)
+		if err != nil {
+			return nil, err
+		}
+		out, err := ToValue(y)
+		if err != nil {
+			return nil, err
+		}
+		return []Value{out}, nil
+	}
+}
+
+// FilterT returns a Filter that converts each input to a T with FromValue
+// and emits the input unchanged if pred returns true. An error is returned
+// if an input can't be stored in a T.
+func FilterT[T any](pred func(T) (bool, error)) Filter {
+	return Select(func(v Value) (bool, error) {
+		var x T
+		if err := FromValue(v, &x); err != nil {
+			return false, err
+		}
+		return pred(x)
+	})
+}
@@ -0,0 +1,145 @@
+package sift_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.jayconrod.com/sift"
+)
+
+type typedRecord struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+func TestTyped(t *testing.T) {
+	double := sift.MapError(func(v sift.Value) (sift.Value, error) {
+		count, _ := sift.GetStringAttr(v, "count")
+		n, _ := sift.AsFloat64(count)
+		name, _ := sift.GetStringAttr(v, "name")
+		return sift.ToValue(map[string]interface{}{
+			"name":  name,
+			"count": n * 2,
+			"tags":  []interface{}{"doubled"},
+		})
+	})
+	f := sift.Typed[typedRecord](sift.Concat(sift.Map(func(v sift.Value) sift.Value { return v }), double))
+	got, err := f(typedRecord{Name: "a", Count: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []typedRecord{
+		{Name: "a", Count: 2},
+		{Name: "a", Count: 4, Tags: []string{"doubled"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v; want %#v", got, want)
+	}
+
+	bad := sift.Typed[typedRecord](sift.Literal(sift.Must(sift.ToValue("x"))))
+	if _, err := bad(typedRecord{}); err == nil {
+		t.Error("got success converting string to struct; want error")
+	}
+}
+
+func TestTypedConversions(t *testing.T) {
+	type event struct {
+		ID   int       `sift:"id" json:"ignored"`
+		When time.Time `sift:"when"`
+	}
+	when := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	f := sift.Typed[event](sift.Select(func(v sift.Value) (bool, error) {
+		if _, ok := sift.GetStringAttr(v, "ignored"); ok {
+			return false, fmt.Errorf("json tag used instead of sift tag")
+		}
+		w, _ := sift.GetStringAttr(v, "when")
+		_, ok := sift.AsTime(w)
+		return ok, nil
+	}))
+	got, err := f(event{ID: 1, When: when})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []event{{ID: 1, When: when}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v; want %#v", got, want)
+	}
+}
+
+func TestAs(t *testing.T) {
+	v, err := sift.ValueOf(map[string][]int{"a": {1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := sift.As[map[string][]int](v); !ok || len(got["a"]) != 2 || got["a"][1] != 2 {
+		t.Errorf("As[map[string][]int]: got %v, %v", got, ok)
+	}
+	if got, ok := sift.As[string](sift.Must(sift.ValueOf("x"))); !ok || got != "x" {
+		t.Errorf("As[string]: got %q, %v", got, ok)
+	}
+	if got, ok := sift.As[float64](sift.Must(sift.ValueOf(int64(3)))); !ok || got != 3 {
+		t.Errorf("As[float64]: got %v, %v", got, ok)
+	}
+	if got, ok := sift.As[bool](sift.NullValue); ok || got {
+		t.Errorf("As[bool](null): got %v, %v; want false, false", got, ok)
+	}
+	if got, ok := sift.As[[]string](v); ok || got != nil {
+		t.Errorf("As[[]string](object): got %v, %v; want nil, false", got, ok)
+	}
+	if got, ok := sift.As[sift.Value](v); !ok || !sift.Equal(got, v) {
+		t.Errorf("As[Value]: got %v, %v", got, ok)
+	}
+}
+
+func TestMapT(t *testing.T) {
+	label := sift.MapT(func(r typedRecord) (string, error) {
+		if r.Count < 0 {
+			return "", fmt.Errorf("negative count")
+		}
+		return fmt.Sprintf("%s=%d", r.Name, r.Count), nil
+	})
+	big := sift.FilterT(func(r typedRecord) (bool, error) {
+		return r.Count > 1, nil
+	})
+	f := sift.Compose(big, label)
+
+	for _, tc := range []struct {
+		desc    string
+		in      interface{}
+		want    []string
+		wantErr bool
+	}{
+		{desc: "kept", in: map[string]interface{}{"name": "a", "count": 2}, want: []string{"a=2"}},
+		{desc: "dropped", in: map[string]interface{}{"name": "b", "count": 1}},
+		{desc: "convert_error", in: "x", wantErr: true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			vs, err := f(sift.Must(sift.ToValue(tc.in)))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("unexpected success")
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, v := range vs {
+				s, ok := sift.AsString(v)
+				if !ok {
+					t.Fatalf("got non-string output %v", v)
+				}
+				got = append(got, s)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := label(sift.Must(sift.ToValue(map[string]interface{}{"name": "d", "count": -1}))); err == nil {
+		t.Error("got success from MapT when f failed; want error")
+	}
+}
@@ -1,8 +1,13 @@
 package sift
 
 import (
+	"encoding/json"
 	"fmt"
+	"math/big"
+	"reflect"
 	"sort"
+	"strconv"
+	"time"
 )
 
 // A Value is an element that may be processed and filtered by sift.
@@ -50,6 +55,18 @@ func AsBool(v Value) (bool, bool) {
 	return false, false
 }
 
+// Truthy returns whether v is considered true in a condition. Null and
+// false are not truthy; all other values are.
+func Truthy(v Value) bool {
+	if IsNull(v) {
+		return false
+	}
+	if b, ok := AsBool(v); ok {
+		return b
+	}
+	return true
+}
+
 // Float64 is implemented by double-precision floating point values.
 type Float64 interface {
 	Value
@@ -116,7 +133,7 @@ func GetAttr(v, key Value) (Value, bool) {
 
 // GetStringAttr is like GetAttr, but accepts string keys instead of Values.
 func GetStringAttr(v Value, name string) (Value, bool) {
-	key := stringType(name)
+	key := stringValue(name)
 	return GetAttr(v, key)
 }
 
@@ -136,13 +153,15 @@ type Index interface {
 	Index(i int) (Value, bool)
 }
 
-// Length returns the v's Length and true if v satisfies Index or is a string.
-// Otherwise, 0 and false are returned.
+// Length returns the v's Length and true if v satisfies Index or is a string
+// or byte string. Otherwise, 0 and false are returned.
 func Length(v Value) (int, bool) {
 	if i, ok := v.(Index); ok {
 		return i.Length(), true
 	} else if s, ok := AsString(v); ok {
 		return len(s), true
+	} else if b, ok := AsBytes(v); ok {
+		return len(b), true
 	} else {
 		return 0, false
 	}
@@ -177,141 +196,144 @@ func GetIntIndex(v Value, i int) (Value, bool) {
 	return ix.Index(i)
 }
 
-// Equal returns whether two values are equivalent.
-func Equal(l, r Value) bool {
-	if IsNull(l) {
-		return IsNull(r)
-	} else if _, ok := l.(Bool); ok {
-		_, ok := r.(Bool)
-		return ok && l.Truth() == r.Truth()
-	} else if lf, ok := l.(Float64); ok {
-		rf, ok := r.(Float64)
-		return ok && lf.Float64() == rf.Float64()
-	} else if ls, ok := l.(String); ok {
-		rs, ok := r.(String)
-		return ok && ls == rs
-	} else if la, ok := l.(Attr); ok {
-		ra, ok := r.(Attr)
-		if !ok {
-			return false
-		}
-		lkeys, rkeys := la.Keys(), ra.Keys()
-		if len(lkeys) != len(rkeys) {
-			return false
-		}
-		for i, lkey := range lkeys {
-			rkey := rkeys[i]
-			if !Equal(lkey, rkey) {
-				return false
-			}
-			lvalue, ok := la.Attr(lkey)
-			if !ok {
-				return false
-			}
-			rvalue, ok := ra.Attr(rkey)
-			if !ok {
-				return false
-			}
-			if !Equal(lvalue, rvalue) {
-				return false
-			}
-		}
-		return true
-	} else if li, ok := l.(Index); ok {
-		ri, ok := r.(Index)
-		if !ok {
-			return false
-		}
-		ln, rn := li.Length(), ri.Length()
-		if ln != rn {
-			return false
-		}
-		for i := 0; i < ln; i++ {
-			le, lok := li.Index(i)
-			re, rok := ri.Index(i)
-			if lok != rok || lok && !Equal(le, re) {
-				return false
-			}
-		}
-		return true
-	} else {
-		return false
-	}
-}
-
 // ToValue converts an arbitrary value to an implementation of Value.
 //
+// Values that implement Value are returned as they are. For values that
+// implement Valuer, SiftValue is called.
+//
 // Null is returned for nil values.
 //
 // A Bool is return for bool values.
 //
-// A Float64 is returned for float64 values.
+// A Float64 is returned for float64 values and integers that may be
+// represented exactly as float64. A BigNumber is returned for other
+// integers, *big.Int, and *big.Float values.
+//
+// A Bytes is returned for []byte values. The slice is not copied.
+//
+// A Time is returned for time.Time values.
 //
 // An Attr is returned for map[string]interface{} values. The keys are sorted.
 // The values are converted to Values recursively.
 //
+// An Attr is returned for []KeyValue values. The keys are kept in the order
+// they appear. The values are converted to Values recursively.
+//
 // An Index is returned for []inteface{} and []sift.Value values.
 //
-// An error is returned for all other values.
+// Values of other types are passed to converters added with
+// RegisterConverter. If none converts a value, it's converted based on its
+// kind, as described below.
+//
+// An Attr is returned for structs. Its keys are in the order fields are
+// declared. As with encoding/json, only exported fields are included, and
+// the fields of embedded structs are promoted. A field's key may be set
+// with a "sift" or "json" tag, like `sift:"name,omitempty"`. A tag of "-"
+// omits the field, and the "omitempty" option omits it when it's false, 0,
+// nil, or empty.
+//
+// An Attr is returned for other maps. Keys must be strings, booleans,
+// numbers, or implement encoding.TextMarshaler; they're converted to
+// strings. An Index is returned for other slices and arrays, except that
+// a Bytes is returned for other byte slices.
+//
+// Pointers and interfaces are dereferenced; nil is converted to Null.
+// Values of other types with an underlying bool, integer, floating point,
+// or string type are converted like values of those types.
+//
+// An error is returned for all other values, and for values that contain
+// themselves, like a struct with a pointer to itself.
 func ToValue(v interface{}) (Value, error) {
+	return toValue(&toValueState{}, v)
+}
+
+func toValue(s *toValueState, v interface{}) (Value, error) {
 	switch v := v.(type) {
 	case Value:
 		return v, nil
+	case Valuer:
+		return v.SiftValue()
 	case nil:
 		return NullValue, nil
 	case bool:
-		return boolType(v), nil
+		return boolValue(v), nil
 	case int8:
-		return float64Type(v), nil
+		return floatValue(float64(v)), nil
 	case int16:
-		return float64Type(v), nil
+		return floatValue(float64(v)), nil
 	case int32:
-		return float64Type(v), nil
+		return floatValue(float64(v)), nil
 	case uint8:
-		return float64Type(v), nil
+		return floatValue(float64(v)), nil
 	case uint16:
-		return float64Type(v), nil
+		return floatValue(float64(v)), nil
 	case uint32:
-		return float64Type(v), nil
+		return floatValue(float64(v)), nil
 	case float64:
-		return float64Type(v), nil
+		return floatValue(float64(v)), nil
 	case int:
-		f := float64Type(v)
+		f := float64(v)
 		if int(f) != v {
-			return nil, fmt.Errorf("cannot represent as value: %#v", v)
+			return bigInt(strconv.Itoa(v)), nil
 		}
-		return f, nil
+		return floatValue(f), nil
 	case int64:
-		f := float64Type(v)
+		f := float64(v)
 		if int64(f) != v {
-			return nil, fmt.Errorf("cannot represent as value: %#v", v)
+			return bigInt(strconv.FormatInt(v, 10)), nil
 		}
-		return f, nil
+		return floatValue(f), nil
 	case uint:
-		f := float64Type(v)
+		f := float64(v)
 		if uint(f) != v {
-			return nil, fmt.Errorf("cannot represent as value: %#v", v)
+			return bigInt(strconv.FormatUint(uint64(v), 10)), nil
 		}
-		return f, nil
+		return floatValue(f), nil
 	case uint64:
-		f := float64Type(v)
+		f := float64(v)
 		if uint64(f) != v {
-			return nil, fmt.Errorf("cannot represent as value: %#v", v)
+			return bigInt(strconv.FormatUint(v, 10)), nil
 		}
-		return f, nil
+		return floatValue(f), nil
 	case uintptr:
-		f := float64Type(v)
+		f := float64(v)
 		if uintptr(f) != v {
-			return nil, fmt.Errorf("cannot represent as value: %#v", v)
+			return bigInt(strconv.FormatUint(uint64(v), 10)), nil
+		}
+		return floatValue(f), nil
+	case *big.Int:
+		if v == nil {
+			return NullValue, nil
+		}
+		if f := float64(v.Int64()); v.IsInt64() && int64(f) == v.Int64() {
+			return floatValue(f), nil
 		}
-		return f, nil
+		return bigInt(v.String()), nil
+	case *big.Float:
+		if v == nil {
+			return NullValue, nil
+		}
+		if v.IsInf() {
+			return nil, fmt.Errorf("cannot represent as value: %v", v)
+		}
+		return NewBigNumber(v.Text('g', -1))
 	case string:
-		return stringType(v), nil
+		return stringValue(v), nil
+	case []byte:
+		return bytesType(v), nil
+	case time.Time:
+		return timeType(v), nil
+	case json.Number:
+		return numberValue(v)
 	case map[string]interface{}:
 		m := v
+		if err := s.enter(reflect.ValueOf(m)); err != nil {
+			return nil, err
+		}
+		defer s.leave(reflect.ValueOf(m))
 		vm := make(attrType)
 		for k, v := range m {
-			if value, err := ToValue(v); err != nil {
+			if value, err := toValue(s, v); err != nil {
 				return nil, err
 			} else {
 				vm[k] = value
@@ -320,11 +342,17 @@ func ToValue(v interface{}) (Value, error) {
 		return vm, nil
 	case map[string]Value:
 		return attrType(v), nil
+	case []KeyValue:
+		return newOrderedAttr(v)
 	case []interface{}:
 		l := v
+		if err := s.enter(reflect.ValueOf(l)); err != nil {
+			return nil, err
+		}
+		defer s.leave(reflect.ValueOf(l))
 		ix := make(indexType, len(l))
 		for j, e := range l {
-			v, err := ToValue(e)
+			v, err := toValue(s, e)
 			if err != nil {
 				return nil, err
 			}
@@ -334,7 +362,10 @@ func ToValue(v interface{}) (Value, error) {
 	case []Value:
 		return indexType(v), nil
 	default:
-		return nil, fmt.Errorf("cannot represent as value: %#v", v)
+		if cv, ok, err := convert(v); ok {
+			return cv, err
+		}
+		return toValueReflect(s, reflect.ValueOf(v))
 	}
 }
 
@@ -380,7 +411,7 @@ func (a attrType) Truth() bool { return true }
 func (a attrType) Keys() []Value {
 	keys := make([]Value, 0, len(a))
 	for key := range a {
-		keys = append(keys, stringType(key))
+		keys = append(keys, stringValue(key))
 	}
 	sort.Slice(keys, func(i, j int) bool {
 		return keys[i].(stringType) < keys[j].(stringType)
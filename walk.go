@@ -0,0 +1,56 @@
+package sift
+
+import "io"
+
+// Walk calls fn for v and each value nested within it, in pre-order, with
+// the path from v to the value. If fn returns false, values nested within
+// the value are skipped. If fn returns an error, Walk stops and returns it.
+//
+// Array elements are visited in order; missing elements are visited as
+// null. Arrays that implement Iterator are read one element at a time.
+// Object attributes are visited in the order of their keys.
+//
+// Walk reuses the path's storage between calls, so fn must copy the path
+// if it keeps it.
+func Walk(v Value, fn func(path Path, v Value) (descend bool, err error)) error {
+	var path Path
+	var walk func(v Value) error
+	walk = func(v Value) error {
+		descend, err := fn(path, v)
+		if err != nil || !descend {
+			return err
+		}
+		if elems, ok := Iterate(v); ok {
+			for i := 0; ; i++ {
+				e, err := elems.Decode()
+				if err == io.EOF {
+					return nil
+				} else if err != nil {
+					return err
+				}
+				path = append(path, floatValue(float64(i)))
+				err = walk(e)
+				path = path[:len(path)-1]
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if a, ok := v.(Attr); ok {
+			for _, key := range a.Keys() {
+				e, ok := a.Attr(key)
+				if !ok {
+					continue
+				}
+				path = append(path, key)
+				err := walk(e)
+				path = path[:len(path)-1]
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return walk(v)
+}
@@ -0,0 +1,47 @@
+package sift_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestWalk(t *testing.T) {
+	v := sift.Must(sift.ToValue([]sift.KeyValue{
+		{Key: "b", Value: []interface{}{1, map[string]interface{}{"c d": true}}},
+		{Key: "skip", Value: map[string]interface{}{"x": 1}},
+		{Key: "a", Value: nil},
+	}))
+	var got []string
+	err := sift.Walk(v, func(path sift.Path, v sift.Value) (bool, error) {
+		got = append(got, path.String())
+		_, isSkip := sift.GetStringAttr(v, "x")
+		return !isSkip, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".", ".b", ".b[0]", ".b[1]", `.b[1]["c d"]`, ".skip", ".a"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got paths %q; want %q", got, want)
+	}
+
+	errStop := errors.New("stop")
+	n := 0
+	err = sift.Walk(countIterator{n: 100}, func(path sift.Path, v sift.Value) (bool, error) {
+		if n++; n == 3 {
+			return false, errStop
+		}
+		return true, nil
+	})
+	if err != errStop || n != 3 {
+		t.Errorf("got error %v after %d calls; want %v after 3", err, n, errStop)
+	}
+
+	errIter := errors.New("iteration failed")
+	if err := sift.Walk(countIterator{n: 1, err: errIter}, func(sift.Path, sift.Value) (bool, error) { return true, nil }); err != errIter {
+		t.Errorf("got error %v; want %v", err, errIter)
+	}
+}
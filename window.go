@@ -0,0 +1,65 @@
+package sift
+
+import "io"
+
+// Batch returns a Decoder that groups consecutive values from dec into
+// arrays of n values, so a filter may process a batch at a time. The last
+// array has fewer than n values if the number of values isn't a multiple
+// of n. n must be positive.
+//
+// Batch groups values before they're filtered. To group values before
+// they're written, use BatchEncoder.
+func Batch(dec Decoder, n int) Decoder {
+	return Window(dec, n, n)
+}
+
+// Window returns a Decoder that produces arrays of size consecutive values
+// from dec, starting a new array every step values. If step is less than
+// size, the windows overlap, so each value may appear in several arrays;
+// for example, with size 3 and step 1, a filter may compute a moving
+// average. If step is greater than size, some values are skipped. size and
+// step must be positive.
+//
+// Only full windows are produced, except that when size and step are equal,
+// as with Batch, the last array may be partial.
+func Window(dec Decoder, size, step int) Decoder {
+	return &windowDecoder{dec: dec, size: size, step: step}
+}
+
+type windowDecoder struct {
+	dec        Decoder
+	size, step int
+	buf        []Value
+	skip       int // values to discard before filling buf
+	eof        bool
+}
+
+func (d *windowDecoder) Decode() (Value, error) {
+	for !d.eof && (d.skip > 0 || len(d.buf) < d.size) {
+		v, err := d.dec.Decode()
+		if err == io.EOF {
+			d.eof = true
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if d.skip > 0 {
+			d.skip--
+		} else {
+			d.buf = append(d.buf, v)
+		}
+	}
+	if len(d.buf) == 0 || len(d.buf) < d.size && (d.size != d.step || !d.eof) {
+		return nil, io.EOF
+	}
+
+	w := make(indexType, len(d.buf))
+	copy(w, d.buf)
+	if d.step < len(d.buf) {
+		d.buf = append(d.buf[:0], d.buf[d.step:]...)
+	} else {
+		d.skip = d.step - len(d.buf)
+		d.buf = d.buf[:0]
+	}
+	return w, nil
+}
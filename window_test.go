@@ -0,0 +1,64 @@
+package sift_test
+
+import (
+	"io"
+	"testing"
+
+	"go.jayconrod.com/sift"
+)
+
+func TestWindow(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		dec  func(sift.Decoder) sift.Decoder
+		n    int
+		want []interface{}
+	}{
+		{
+			desc: "batch",
+			dec:  func(d sift.Decoder) sift.Decoder { return sift.Batch(d, 2) },
+			n:    5,
+			want: []interface{}{[]interface{}{0, 1}, []interface{}{2, 3}, []interface{}{4}},
+		}, {
+			desc: "batch_exact",
+			dec:  func(d sift.Decoder) sift.Decoder { return sift.Batch(d, 2) },
+			n:    4,
+			want: []interface{}{[]interface{}{0, 1}, []interface{}{2, 3}},
+		}, {
+			desc: "batch_empty",
+			dec:  func(d sift.Decoder) sift.Decoder { return sift.Batch(d, 2) },
+		}, {
+			desc: "sliding",
+			dec:  func(d sift.Decoder) sift.Decoder { return sift.Window(d, 3, 1) },
+			n:    5,
+			want: []interface{}{[]interface{}{0, 1, 2}, []interface{}{1, 2, 3}, []interface{}{2, 3, 4}},
+		}, {
+			desc: "sliding_short",
+			dec:  func(d sift.Decoder) sift.Decoder { return sift.Window(d, 3, 1) },
+			n:    2,
+		}, {
+			desc: "gaps",
+			dec:  func(d sift.Decoder) sift.Decoder { return sift.Window(d, 2, 3) },
+			n:    8,
+			want: []interface{}{[]interface{}{0, 1}, []interface{}{3, 4}, []interface{}{6, 7}},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dec := tc.dec(sift.TakeFirst(&countingDecoder{}, tc.n))
+			var got []sift.Value
+			for {
+				v, err := dec.Decode()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, v)
+			}
+			want := sift.Must(sift.ToValue(tc.want))
+			if !sift.Equal(sift.Must(sift.ToValue(got)), want) {
+				t.Errorf("got %v; want %v", got, want)
+			}
+		})
+	}
+}